@@ -0,0 +1,26 @@
+package straw
+
+import "os"
+
+// AppendStore is implemented by stores which can append to a file without
+// the caller reading it first. The s3 and gcs backends implement it, by
+// composing a new object from the existing one and the data written, server
+// side.
+type AppendStore interface {
+	// AppendWriteCloser returns a writer which appends to the named file,
+	// creating it if it does not exist. What is written may not be visible
+	// until the writer is closed.
+	AppendWriteCloser(name string) (StrawWriter, error)
+}
+
+// AppendWriteCloser returns a writer which appends to the named file in ss,
+// creating it if it does not exist. If ss does not implement AppendStore,
+// the file is opened with OpenFile and os.O_APPEND, which for stores that
+// cannot modify files in place holds the whole file in memory until it is
+// closed.
+func AppendWriteCloser(ss StreamStore, name string) (StrawWriter, error) {
+	if as, ok := ss.(AppendStore); ok {
+		return as.AppendWriteCloser(name)
+	}
+	return ss.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+}
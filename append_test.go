@@ -0,0 +1,27 @@
+package straw_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+func TestAppendWriteCloser(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	mem, _ := straw.Open("mem://")
+	for _, s := range []string{"hello", " world"} {
+		w, err := straw.AppendWriteCloser(mem, "/a")
+		require.NoError(err)
+		_, err = w.Write([]byte(s))
+		require.NoError(err)
+		require.NoError(w.Close())
+	}
+
+	data, err := straw.ReadFile(mem, "/a")
+	require.NoError(err)
+	assert.Equal("hello world", string(data))
+}
@@ -0,0 +1,167 @@
+//go:build go1.16
+// +build go1.16
+
+package straw
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path"
+)
+
+// AsFS returns an fs.FS presenting the contents of ss, so that it can be used
+// with the standard library, such as with http.FS or fs.WalkDir. The returned
+// value also implements fs.ReadDirFS and fs.StatFS.
+// Names are resolved relative to the root of ss, and must satisfy
+// fs.ValidPath, so "a/b" refers to "/a/b" in ss and "." refers to its root.
+// Files opened from the returned fs.FS also implement io.Seeker and
+// io.ReaderAt.
+func AsFS(ss StreamStore) fs.FS {
+	return &storeFS{ss}
+}
+
+type storeFS struct {
+	ss StreamStore
+}
+
+// storePath maps a name in the fs.FS onto a path in the store.
+func (sfs *storeFS) storePath(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return path.Join("/", name), nil
+}
+
+// fsError translates err, returned by the store, into a *fs.PathError
+// wrapping fs.ErrNotExist where appropriate.
+func fsError(op, name string, err error) error {
+	if os.IsNotExist(err) {
+		err = fs.ErrNotExist
+	}
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+func (sfs *storeFS) Open(name string) (fs.File, error) {
+	p, err := sfs.storePath("open", name)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := sfs.ss.Stat(p)
+	if err != nil {
+		return nil, fsError("open", name, err)
+	}
+	if fi.IsDir() {
+		return &fsDir{sfs: sfs, name: name, fi: fi}, nil
+	}
+	r, err := sfs.ss.OpenReadCloser(p)
+	if err != nil {
+		return nil, fsError("open", name, err)
+	}
+	return &fsFile{r, fi}, nil
+}
+
+func (sfs *storeFS) Stat(name string) (fs.FileInfo, error) {
+	p, err := sfs.storePath("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := sfs.ss.Stat(p)
+	if err != nil {
+		return nil, fsError("stat", name, err)
+	}
+	return fi, nil
+}
+
+func (sfs *storeFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	p, err := sfs.storePath("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	fis, err := sfs.ss.Readdir(p)
+	if err != nil {
+		return nil, fsError("readdir", name, err)
+	}
+	entries := make([]fs.DirEntry, len(fis))
+	for i, fi := range fis {
+		entries[i] = dirEntry{fi}
+	}
+	return entries, nil
+}
+
+type fsFile struct {
+	StrawReader
+	fi fs.FileInfo
+}
+
+func (f *fsFile) Stat() (fs.FileInfo, error) {
+	return f.fi, nil
+}
+
+type fsDir struct {
+	sfs  *storeFS
+	name string
+	fi   fs.FileInfo
+
+	// entries holds the entries not yet returned by ReadDir, and is loaded
+	// on the first call.
+	entries []fs.DirEntry
+	loaded  bool
+}
+
+func (d *fsDir) Stat() (fs.FileInfo, error) {
+	return d.fi, nil
+}
+
+func (d *fsDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: ErrIsDirectory}
+}
+
+func (d *fsDir) Close() error {
+	return nil
+}
+
+func (d *fsDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.loaded {
+		entries, err := d.sfs.ReadDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries, d.loaded = entries, true
+	}
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(d.entries) {
+		n = len(d.entries)
+	}
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
+
+// dirEntry adapts an fs.FileInfo to an fs.DirEntry.
+type dirEntry struct {
+	fi fs.FileInfo
+}
+
+func (e dirEntry) Name() string {
+	return e.fi.Name()
+}
+
+func (e dirEntry) IsDir() bool {
+	return e.fi.IsDir()
+}
+
+func (e dirEntry) Type() fs.FileMode {
+	return e.fi.Mode().Type()
+}
+
+func (e dirEntry) Info() (fs.FileInfo, error) {
+	return e.fi, nil
+}
@@ -0,0 +1,38 @@
+//go:build go1.16
+// +build go1.16
+
+package straw_test
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uw-labs/straw"
+)
+
+func TestAsFS(t *testing.T) {
+	ss, _ := straw.Open("mem://")
+	ss.Mkdir("/a", 0755)
+	ss.Mkdir("/a/b", 0755)
+	writeContent(t, ss, "/a/1", []byte("one"))
+	writeContent(t, ss, "/a/b/2", []byte("two"))
+	writeContent(t, ss, "/3", []byte("three"))
+
+	fsys := straw.AsFS(ss)
+	if err := fstest.TestFS(fsys, "a/1", "a/b/2", "3"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := fs.ReadFile(fsys, "a/b/2")
+	assert.NoError(t, err)
+	assert.Equal(t, "two", string(data))
+
+	_, err = fsys.Open("missing")
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+
+	_, err = fsys.Open("/3")
+	assert.True(t, errors.Is(err, fs.ErrInvalid))
+}
@@ -0,0 +1,69 @@
+package straw
+
+import (
+	"bufio"
+	"io"
+)
+
+// NewBufferedWriter returns a StrawWriter which collects small writes to w
+// in a buffer of size bytes, passing them on to w only when the buffer is
+// full, and the remainder on Close, so that each call to w, which may be a
+// network round trip for sftp or an object store, carries as much data as
+// possible. Writes at least as large as the buffer are passed straight to w
+// once the buffer is empty.
+//
+// Since writes are deferred, an error writing to w may be returned by a later
+// Write, or by Close, rather than the Write which supplied the data, and
+// nothing written is durable until Close returns nil. After an error every
+// further Write fails with it. Close always closes w.
+func NewBufferedWriter(w StrawWriter, size int) StrawWriter {
+	return &bufferedWriter{w: w, buf: bufio.NewWriterSize(w, size)}
+}
+
+type bufferedWriter struct {
+	w      StrawWriter
+	buf    *bufio.Writer
+	closed bool
+}
+
+func (bw *bufferedWriter) Write(p []byte) (int, error) {
+	if bw.closed {
+		return 0, ErrClosed
+	}
+	return bw.buf.Write(p)
+}
+
+// ReadFrom lets w read r directly, if it implements io.ReaderFrom, once the
+// buffer has been flushed.
+func (bw *bufferedWriter) ReadFrom(r io.Reader) (int64, error) {
+	if bw.closed {
+		return 0, ErrClosed
+	}
+	return bw.buf.ReadFrom(r)
+}
+
+// Flush writes out the buffer, then flushes w if it implements Flusher.
+func (bw *bufferedWriter) Flush() error {
+	if bw.closed {
+		return ErrClosed
+	}
+	if err := bw.buf.Flush(); err != nil {
+		return err
+	}
+	if f, ok := bw.w.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+func (bw *bufferedWriter) Close() error {
+	if bw.closed {
+		return ErrClosed
+	}
+	bw.closed = true
+	err := bw.buf.Flush()
+	if cerr := bw.w.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,101 @@
+package straw_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+// recordingWriter records the size of each write it is given, failing them
+// with err if it is set.
+type recordingWriter struct {
+	data   []byte
+	writes []int
+	err    error
+	closed int
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	w.data = append(w.data, p...)
+	w.writes = append(w.writes, len(p))
+	return len(p), nil
+}
+
+func (w *recordingWriter) Close() error {
+	w.closed++
+	return nil
+}
+
+func TestBufferedWriter(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	rw := &recordingWriter{}
+	w := straw.NewBufferedWriter(rw, 8)
+	for _, s := range []string{"ab", "cd", "ef", "gh", "ij", "k"} {
+		_, err := w.Write([]byte(s))
+		require.NoError(err)
+	}
+	assert.Equal([]int{8}, rw.writes)
+	_, err := w.Write([]byte("0123456789"))
+	require.NoError(err)
+	assert.Equal(0, rw.closed)
+
+	require.NoError(w.Close())
+	assert.Equal("abcdefghijk0123456789", string(rw.data))
+	for _, n := range rw.writes {
+		assert.True(n >= 3, "write of %d bytes", n)
+	}
+	assert.Equal(1, rw.closed)
+
+	assert.Equal(straw.ErrClosed, w.Close())
+	_, err = w.Write([]byte("x"))
+	assert.Equal(straw.ErrClosed, err)
+	assert.Equal(1, rw.closed)
+}
+
+func TestBufferedWriterDeferredError(t *testing.T) {
+	assert := assert.New(t)
+
+	broken := errors.New("broken")
+	rw := &recordingWriter{err: broken}
+	w := straw.NewBufferedWriter(rw, 8)
+	_, err := w.Write([]byte("abc"))
+	assert.NoError(err)
+	assert.Equal(broken, w.Close())
+	assert.Equal(1, rw.closed)
+
+	rw = &recordingWriter{err: broken}
+	w = straw.NewBufferedWriter(rw, 4)
+	_, err = w.Write([]byte("abc"))
+	assert.NoError(err)
+	_, err = w.Write([]byte("def"))
+	assert.Equal(broken, err)
+	_, err = w.Write([]byte("g"))
+	assert.Equal(broken, err)
+	assert.Equal(broken, w.Close())
+}
+
+func TestBufferedWriterStore(t *testing.T) {
+	require := require.New(t)
+
+	mem, _ := straw.Open("mem://")
+	mw, err := mem.CreateWriteCloser("/f")
+	require.NoError(err)
+	w := straw.NewBufferedWriter(mw, 16)
+	for i := 0; i < 10; i++ {
+		_, err := w.Write([]byte("line\n"))
+		require.NoError(err)
+	}
+	require.NoError(w.Close())
+
+	data, err := straw.ReadFile(mem, "/f")
+	require.NoError(err)
+	require.Len(data, 50)
+}
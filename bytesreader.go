@@ -0,0 +1,11 @@
+package straw
+
+import "bytes"
+
+// NewBytesReader returns a StrawReader which reads b, as bytes.NewReader
+// does. Close does nothing, so the reader may still be used after it, which
+// makes it convenient for tests of code consuming a StrawReader, and for
+// wrappers serving content they hold in memory.
+func NewBytesReader(b []byte) StrawReader {
+	return &memFileReader{bytes.NewReader(b)}
+}
@@ -0,0 +1,44 @@
+package straw_test
+
+import (
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+func TestBytesReader(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	r := straw.NewBytesReader([]byte("0123456789"))
+
+	data, err := ioutil.ReadAll(r)
+	require.NoError(err)
+	assert.Equal("0123456789", string(data))
+
+	buf := make([]byte, 3)
+	n, err := r.ReadAt(buf, 8)
+	assert.Equal(io.EOF, err)
+	assert.Equal("89", string(buf[:n]))
+
+	pos, err := r.Seek(-4, io.SeekEnd)
+	require.NoError(err)
+	assert.Equal(int64(6), pos)
+	n, err = r.Read(buf)
+	require.NoError(err)
+	assert.Equal("678", string(buf[:n]))
+
+	_, err = r.Seek(-1, io.SeekStart)
+	assert.Error(err)
+
+	require.NoError(r.Close())
+	_, err = r.Seek(0, io.SeekStart)
+	require.NoError(err)
+	data, err = ioutil.ReadAll(r)
+	require.NoError(err)
+	assert.Equal("0123456789", string(data))
+}
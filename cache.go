@@ -0,0 +1,244 @@
+package straw
+
+import (
+	"container/list"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+	"time"
+)
+
+var _ StreamStore = &cacheStreamStore{}
+
+// NewCacheStore returns a StreamStore which keeps the content of files read
+// from backing in memory, evicting the least recently used files to hold at
+// most maxBytes of content. Files larger than maxBytes are never cached.
+// Each OpenReadCloser calls Stat on backing, and cached content is only used
+// if the size and modification time of the file are unchanged, so files
+// overwritten by other writers are not served stale. Otherwise the whole file
+// is read into memory when it is opened. Writing, removing or copying over a
+// file through the returned store drops it from the cache.
+func NewCacheStore(backing StreamStore, maxBytes int64) StreamStore {
+	return &cacheStreamStore{
+		ss:       backing,
+		maxBytes: maxBytes,
+		lru:      list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+type cacheStreamStore struct {
+	ss       StreamStore
+	maxBytes int64
+
+	lk    sync.Mutex
+	bytes int64
+	// lru holds *cacheEntry values, most recently used first.
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	name    string
+	size    int64
+	modTime time.Time
+	data    []byte
+}
+
+func (fs *cacheStreamStore) Close() error {
+	fs.lk.Lock()
+	fs.lru.Init()
+	fs.entries = make(map[string]*list.Element)
+	fs.bytes = 0
+	fs.lk.Unlock()
+	return fs.ss.Close()
+}
+
+func (fs *cacheStreamStore) Unwrap() StreamStore {
+	return fs.ss
+}
+
+// get returns the cached content of name if it matches fi.
+func (fs *cacheStreamStore) get(name string, fi os.FileInfo) ([]byte, bool) {
+	fs.lk.Lock()
+	defer fs.lk.Unlock()
+	el, ok := fs.entries[name]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*cacheEntry)
+	if e.size != fi.Size() || !e.modTime.Equal(fi.ModTime()) {
+		fs.remove(el)
+		return nil, false
+	}
+	fs.lru.MoveToFront(el)
+	return e.data, true
+}
+
+// put caches data as the content of name, evicting other entries as needed.
+func (fs *cacheStreamStore) put(name string, fi os.FileInfo, data []byte) {
+	fs.lk.Lock()
+	defer fs.lk.Unlock()
+	if el, ok := fs.entries[name]; ok {
+		fs.remove(el)
+	}
+	for fs.bytes+int64(len(data)) > fs.maxBytes {
+		fs.remove(fs.lru.Back())
+	}
+	fs.entries[name] = fs.lru.PushFront(&cacheEntry{name, fi.Size(), fi.ModTime(), data})
+	fs.bytes += int64(len(data))
+}
+
+// invalidate drops name from the cache.
+func (fs *cacheStreamStore) invalidate(name string) {
+	name = path.Clean(name)
+	fs.lk.Lock()
+	defer fs.lk.Unlock()
+	if el, ok := fs.entries[name]; ok {
+		fs.remove(el)
+	}
+}
+
+// remove drops el from the cache. fs.lk must be held.
+func (fs *cacheStreamStore) remove(el *list.Element) {
+	e := fs.lru.Remove(el).(*cacheEntry)
+	delete(fs.entries, e.name)
+	fs.bytes -= int64(len(e.data))
+}
+
+func (fs *cacheStreamStore) OpenReadCloser(name string) (StrawReader, error) {
+	fi, err := fs.ss.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() || fi.Size() > fs.maxBytes {
+		return fs.ss.OpenReadCloser(name)
+	}
+
+	key := path.Clean(name)
+	if data, ok := fs.get(key, fi); ok {
+		return NewBytesReader(data), nil
+	}
+
+	r, err := fs.ss.OpenReadCloser(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(r)
+	r.Close()
+	if err != nil {
+		return nil, err
+	}
+	// if the size no longer matches, the file changed after Stat, and the
+	// content may not belong to the version described by fi.
+	if int64(len(data)) == fi.Size() {
+		fs.put(key, fi, data)
+	}
+	return NewBytesReader(data), nil
+}
+
+func (fs *cacheStreamStore) CreateWriteCloser(name string) (StrawWriter, error) {
+	fs.invalidate(name)
+	w, err := fs.ss.CreateWriteCloser(name)
+	if err != nil {
+		return nil, err
+	}
+	cw := &cacheInvalidatingWriter{w, fs.invalidate, name}
+	if wa, ok := w.(io.WriterAt); ok {
+		return &cacheInvalidatingWriterAt{cw, wa}, nil
+	}
+	return cw, nil
+}
+
+func (fs *cacheStreamStore) OpenFile(name string, flag int, perm os.FileMode) (StrawReadWriteCloser, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return OpenFileBuffered(fs, name, flag, nil)
+	}
+	fs.invalidate(name)
+	f, err := fs.ss.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &cacheInvalidatingFile{f, fs.invalidate, name}, nil
+}
+
+func (fs *cacheStreamStore) Lstat(path string) (os.FileInfo, error) {
+	return fs.ss.Lstat(path)
+}
+
+func (fs *cacheStreamStore) Stat(path string) (os.FileInfo, error) {
+	return fs.ss.Stat(path)
+}
+
+func (fs *cacheStreamStore) Readdir(path string) ([]os.FileInfo, error) {
+	return fs.ss.Readdir(path)
+}
+
+func (fs *cacheStreamStore) Mkdir(path string, mode os.FileMode) error {
+	return fs.ss.Mkdir(path, mode)
+}
+
+func (fs *cacheStreamStore) Remove(path string) error {
+	fs.invalidate(path)
+	return fs.ss.Remove(path)
+}
+
+func (fs *cacheStreamStore) Chmod(name string, mode os.FileMode) error {
+	return fs.ss.Chmod(name, mode)
+}
+
+func (fs *cacheStreamStore) Truncate(name string, size int64) error {
+	fs.invalidate(name)
+	return fs.ss.Truncate(name, size)
+}
+
+func (fs *cacheStreamStore) Symlink(oldname, newname string) error {
+	fs.invalidate(newname)
+	return Symlink(fs.ss, oldname, newname)
+}
+
+func (fs *cacheStreamStore) Readlink(name string) (string, error) {
+	return Readlink(fs.ss, name)
+}
+
+func (fs *cacheStreamStore) Copy(src, dst string) error {
+	fs.invalidate(dst)
+	return fs.ss.Copy(src, dst)
+}
+
+// cacheInvalidatingWriter drops the file it writes from the cache again on
+// Close, in case it was read while being written.
+type cacheInvalidatingWriter struct {
+	StrawWriter
+	invalidate func(name string)
+	name       string
+}
+
+func (w *cacheInvalidatingWriter) Close() error {
+	err := w.StrawWriter.Close()
+	w.invalidate(w.name)
+	return err
+}
+
+// cacheInvalidatingWriterAt is a cacheInvalidatingWriter whose underlying
+// writer implements io.WriterAt.
+type cacheInvalidatingWriterAt struct {
+	*cacheInvalidatingWriter
+	io.WriterAt
+}
+
+// cacheInvalidatingFile drops the file it writes from the cache again on
+// Close.
+type cacheInvalidatingFile struct {
+	StrawReadWriteCloser
+	invalidate func(name string)
+	name       string
+}
+
+func (f *cacheInvalidatingFile) Close() error {
+	err := f.StrawReadWriteCloser.Close()
+	f.invalidate(f.name)
+	return err
+}
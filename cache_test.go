@@ -0,0 +1,83 @@
+package straw_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+func TestCacheStore(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	mem, _ := straw.Open("mem://")
+	rec := &TestRecordingStreamStore{wrapped: mem}
+	ss := straw.NewCacheStore(rec, 1024)
+
+	writeContent(t, mem, "/a", []byte("first"))
+
+	for i := 0; i < 3; i++ {
+		data, err := straw.ReadFile(ss, "/a")
+		require.NoError(err)
+		assert.Equal("first", string(data))
+	}
+	assert.Equal(1, len(rec.Calls("OpenReadCloser")))
+
+	// writes through the cache invalidate it.
+	writeContent(t, ss, "/a", []byte("second"))
+	data, err := straw.ReadFile(ss, "/a")
+	require.NoError(err)
+	assert.Equal("second", string(data))
+	assert.Equal(2, len(rec.Calls("OpenReadCloser")))
+
+	// so do writes made directly to the backing store.
+	time.Sleep(10 * time.Millisecond)
+	writeContent(t, mem, "/a", []byte("third!"))
+	data, err = straw.ReadFile(ss, "/a")
+	require.NoError(err)
+	assert.Equal("third!", string(data))
+	assert.Equal(3, len(rec.Calls("OpenReadCloser")))
+
+	require.NoError(ss.Remove("/a"))
+	_, err = ss.OpenReadCloser("/a")
+	assert.Error(err)
+}
+
+func TestCacheStoreEviction(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	mem, _ := straw.Open("mem://")
+	rec := &TestRecordingStreamStore{wrapped: mem}
+	ss := straw.NewCacheStore(rec, 10)
+
+	writeContent(t, mem, "/a", []byte("aaaa"))
+	writeContent(t, mem, "/b", []byte("bbbb"))
+	writeContent(t, mem, "/c", []byte("cccc"))
+	writeContent(t, mem, "/big", []byte("0123456789a"))
+
+	read := func(name string) {
+		_, err := straw.ReadFile(ss, name)
+		require.NoError(err)
+	}
+	read("/a")
+	read("/b")
+	read("/a")
+	read("/c") // evicts /b, the least recently used.
+	read("/a")
+	read("/b")
+	read("/big")
+	read("/big")
+
+	assert.Equal([]string{
+		"OpenReadCloser /a",
+		"OpenReadCloser /b",
+		"OpenReadCloser /c",
+		"OpenReadCloser /b",
+		"OpenReadCloser /big",
+		"OpenReadCloser /big",
+	}, rec.Calls("OpenReadCloser"))
+}
@@ -0,0 +1,64 @@
+package straw
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+)
+
+// Checksummer is implemented by stores that can provide the checksum of a
+// file without its content being read by the client, for example from object
+// metadata. Implementations return ErrNotSupported for algorithms they cannot
+// provide.
+type Checksummer interface {
+	Checksum(name string, algo string) ([]byte, error)
+}
+
+// Checksum returns the checksum of the named file using algo, which is one of
+// "md5", "sha1", "sha256" or "crc32c". If ss implements Checksummer and
+// supports algo, the checksum is obtained from it, otherwise the file is read
+// and the checksum computed.
+func Checksum(ss StreamStore, name string, algo string) ([]byte, error) {
+	h, err := newHash(algo)
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := ss.(Checksummer); ok {
+		sum, err := c.Checksum(name, algo)
+		if err != ErrNotSupported {
+			return sum, err
+		}
+	}
+
+	r, err := ss.OpenReadCloser(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(h, r); err != nil {
+		r.Close()
+		return nil, err
+	}
+	if err := r.Close(); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+func newHash(algo string) (hash.Hash, error) {
+	switch algo {
+	case "md5":
+		return md5.New(), nil
+	case "sha1":
+		return sha1.New(), nil
+	case "sha256":
+		return sha256.New(), nil
+	case "crc32c":
+		return crc32.New(crc32.MakeTable(crc32.Castagnoli)), nil
+	default:
+		return nil, fmt.Errorf("unsupported checksum algorithm %q", algo)
+	}
+}
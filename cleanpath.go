@@ -0,0 +1,33 @@
+package straw
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// CleanPath validates p, returning it in canonical form as path.Clean would.
+// Paths containing a null byte, or ".." elements which would escape above the
+// root (or, for relative paths, above the starting directory) are rejected
+// with an error wrapping ErrInvalidPath. Every backend validates incoming
+// paths with CleanPath, so paths built from untrusted input cannot be used to
+// reach unintended files or keys.
+func CleanPath(p string) (string, error) {
+	if strings.IndexByte(p, 0) != -1 {
+		return "", fmt.Errorf("%q: %w", p, ErrInvalidPath)
+	}
+	depth := 0
+	for _, elem := range strings.Split(p, "/") {
+		switch elem {
+		case "", ".":
+		case "..":
+			depth--
+			if depth < 0 {
+				return "", fmt.Errorf("%q: %w", p, ErrInvalidPath)
+			}
+		default:
+			depth++
+		}
+	}
+	return path.Clean(p), nil
+}
@@ -0,0 +1,55 @@
+package straw_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uw-labs/straw"
+)
+
+func TestCleanPath(t *testing.T) {
+	assert := assert.New(t)
+
+	for in, want := range map[string]string{
+		"":          ".",
+		"/":         "/",
+		"a/./b/":    "a/b",
+		"//a/b":     "/a/b",
+		"/a/../b":   "/b",
+		"a/b/../..": ".",
+	} {
+		got, err := straw.CleanPath(in)
+		assert.NoError(err, in)
+		assert.Equal(want, got, in)
+	}
+
+	for _, in := range []string{
+		"..",
+		"../etc",
+		"/a/../../b",
+		"a/\x00b",
+	} {
+		_, err := straw.CleanPath(in)
+		assert.True(errors.Is(err, straw.ErrInvalidPath), in)
+	}
+}
+
+func TestInvalidPathRejected(t *testing.T) {
+	assert := assert.New(t)
+
+	ss, _ := straw.Open("mem://")
+
+	_, err := ss.CreateWriteCloser("../escape")
+	assert.True(errors.Is(err, straw.ErrInvalidPath))
+
+	_, err = ss.Stat("/a/../../b")
+	assert.True(errors.Is(err, straw.ErrInvalidPath))
+
+	err = ss.Mkdir("a\x00", 0755)
+	assert.True(errors.Is(err, straw.ErrInvalidPath))
+
+	fi, err := ss.Stat(".")
+	assert.NoError(err)
+	assert.True(fi.IsDir())
+}
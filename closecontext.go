@@ -0,0 +1,35 @@
+package straw
+
+import "context"
+
+// ContextCloser is implemented by stores whose Close can be bounded by a
+// context, aborting any in-flight work if the context is done first.
+type ContextCloser interface {
+	CloseContext(ctx context.Context) error
+}
+
+// CloseContext closes ss, returning ctx.Err() if closing does not complete
+// before ctx is done. This allows a shutdown deadline to bound the teardown of
+// a store.
+// If ss implements ContextCloser, its CloseContext method is used. Otherwise
+// Close is called in a new goroutine, which is left to complete in the
+// background if ctx is done first.
+func CloseContext(ctx context.Context, ss StreamStore) error {
+	if cc, ok := ss.(ContextCloser); ok {
+		return cc.CloseContext(ctx)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- ss.Close()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
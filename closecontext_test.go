@@ -0,0 +1,65 @@
+package straw_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uw-labs/straw"
+)
+
+type slowCloseStreamStore struct {
+	straw.StreamStore
+	delay time.Duration
+}
+
+func (fs *slowCloseStreamStore) Close() error {
+	time.Sleep(fs.delay)
+	return fs.StreamStore.Close()
+}
+
+type contextCloseStreamStore struct {
+	straw.StreamStore
+	ctx context.Context
+}
+
+func (fs *contextCloseStreamStore) CloseContext(ctx context.Context) error {
+	fs.ctx = ctx
+	return nil
+}
+
+func TestCloseContextDeadline(t *testing.T) {
+	assert := assert.New(t)
+
+	ss, _ := straw.Open("mem://")
+	slow := &slowCloseStreamStore{ss, time.Second}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := straw.CloseContext(ctx, slow)
+	assert.Equal(context.DeadlineExceeded, err)
+	assert.True(time.Since(start) < 500*time.Millisecond)
+}
+
+func TestCloseContextCompletes(t *testing.T) {
+	assert := assert.New(t)
+
+	ss, _ := straw.Open("mem://")
+	slow := &slowCloseStreamStore{ss, time.Millisecond}
+
+	assert.NoError(straw.CloseContext(context.Background(), slow))
+}
+
+func TestCloseContextUsesContextCloser(t *testing.T) {
+	assert := assert.New(t)
+
+	ss, _ := straw.Open("mem://")
+	cc := &contextCloseStreamStore{StreamStore: ss}
+
+	ctx := context.Background()
+	assert.NoError(straw.CloseContext(ctx, cc))
+	assert.Equal(ctx, cc.ctx)
+}
@@ -0,0 +1,88 @@
+package straw
+
+import (
+	"io"
+	"sync"
+)
+
+// defaultCoalesceWindowSize is the window size used when NewCoalescingReader
+// is given one which is not positive.
+const defaultCoalesceWindowSize = 64 << 10
+
+// NewCoalescingReader returns a StrawReader which serves ReadAt calls from a
+// cached window of r. When a ReadAt falls outside the cached window, a window
+// of windowSize bytes, aligned to a multiple of windowSize where possible, is
+// fetched with a single ReadAt on r. This suits access patterns with many
+// small nearby reads, such as those of columnar file formats, where each ReadAt
+// on an object store would otherwise be a separate request.
+// ReadAt calls larger than windowSize go directly to r, as do Read and Seek.
+// If windowSize is not positive, a window of 64KiB is used.
+func NewCoalescingReader(r StrawReader, windowSize int64) StrawReader {
+	if windowSize <= 0 {
+		windowSize = defaultCoalesceWindowSize
+	}
+	return &coalescingReader{StrawReader: r, windowSize: windowSize}
+}
+
+type coalescingReader struct {
+	StrawReader
+	windowSize int64
+
+	lk        sync.Mutex
+	window    []byte
+	windowOff int64
+	// eof is set when the window extends to the end of the file.
+	eof bool
+}
+
+func (r *coalescingReader) ReadAt(buf []byte, off int64) (int, error) {
+	if len(buf) == 0 {
+		return 0, nil
+	}
+	if int64(len(buf)) > r.windowSize {
+		return r.StrawReader.ReadAt(buf, off)
+	}
+
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	if !r.covers(off, int64(len(buf))) {
+		if err := r.fetch(off, int64(len(buf))); err != nil {
+			return 0, err
+		}
+	}
+
+	rel := off - r.windowOff
+	if rel >= int64(len(r.window)) {
+		return 0, io.EOF
+	}
+	n := copy(buf, r.window[rel:])
+	if n < len(buf) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (r *coalescingReader) covers(off, n int64) bool {
+	if r.window == nil || off < r.windowOff {
+		return false
+	}
+	return r.eof || off+n <= r.windowOff+int64(len(r.window))
+}
+
+func (r *coalescingReader) fetch(off, n int64) error {
+	start := off - off%r.windowSize
+	if start+r.windowSize < off+n {
+		// the request straddles an aligned boundary.
+		start = off
+	}
+	window := make([]byte, r.windowSize)
+	i, err := r.StrawReader.ReadAt(window, start)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	r.window = window[:i]
+	r.windowOff = start
+	r.eof = err == io.EOF
+	return nil
+}
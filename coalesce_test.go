@@ -0,0 +1,108 @@
+package straw_test
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+type countingReader struct {
+	straw.StrawReader
+	readAts int
+}
+
+func (r *countingReader) ReadAt(buf []byte, off int64) (int, error) {
+	r.readAts++
+	return r.StrawReader.ReadAt(buf, off)
+}
+
+func TestCoalescingReader(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	data := make([]byte, 64)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	ss, _ := straw.Open("mem://")
+	writeContent(t, ss, "/a", data)
+
+	r, err := ss.OpenReadCloser("/a")
+	require.NoError(err)
+	cr := &countingReader{StrawReader: r}
+	coalescing := straw.NewCoalescingReader(cr, 16)
+
+	buf := make([]byte, 4)
+	for _, off := range []int64{20, 16, 28, 24} {
+		n, err := coalescing.ReadAt(buf, off)
+		assert.NoError(err)
+		assert.Equal(4, n)
+		assert.Equal(data[off:off+4], buf)
+	}
+	assert.Equal(1, cr.readAts)
+
+	// straddling the window boundary needs a new window.
+	n, err := coalescing.ReadAt(buf, 30)
+	assert.NoError(err)
+	assert.Equal(data[30:34], buf[:n])
+	assert.Equal(2, cr.readAts)
+
+	n, err = coalescing.ReadAt(buf, 50)
+	assert.NoError(err)
+	assert.Equal(data[50:54], buf[:n])
+	assert.Equal(3, cr.readAts)
+
+	// reading past the end of the file fetches a short window, after which
+	// further reads past the end are served from it.
+	n, err = coalescing.ReadAt(buf, 62)
+	assert.Equal(io.EOF, err)
+	assert.Equal(data[62:], buf[:n])
+	n, err = coalescing.ReadAt(buf, 70)
+	assert.Equal(io.EOF, err)
+	assert.Equal(0, n)
+	assert.Equal(4, cr.readAts)
+
+	// large reads bypass the window.
+	all := make([]byte, 64)
+	n, err = coalescing.ReadAt(all, 0)
+	assert.NoError(err)
+	assert.Equal(data, all[:n])
+	assert.Equal(5, cr.readAts)
+
+	assert.NoError(coalescing.Close())
+}
+
+func TestCoalescingReaderSizes(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	data := []byte("0123456789")
+	ss, _ := straw.Open("mem://")
+	writeContent(t, ss, "/a", data)
+
+	for _, size := range []int64{0, -1} {
+		r, err := ss.OpenReadCloser("/a")
+		require.NoError(err)
+		cr := &countingReader{StrawReader: r}
+		coalescing := straw.NewCoalescingReader(cr, size)
+
+		// empty reads need nothing from r.
+		n, err := coalescing.ReadAt(nil, 0)
+		assert.NoError(err)
+		assert.Equal(0, n)
+		assert.Equal(0, cr.readAts)
+
+		// the default window holds the whole file.
+		buf := make([]byte, 4)
+		for _, off := range []int64{0, 4} {
+			n, err = coalescing.ReadAt(buf, off)
+			assert.NoError(err)
+			assert.Equal(data[off:off+4], buf[:n])
+		}
+		assert.Equal(1, cr.readAts)
+		require.NoError(coalescing.Close())
+	}
+}
@@ -0,0 +1,191 @@
+package straw
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+// NewConcatReader returns a StrawReader presenting the concatenation of the
+// named files as a single stream. The size of each file is discovered with
+// Stat up front, and files are opened lazily as reading reaches them, so
+// seeking into a later file only opens that file. The returned reader also
+// has a Size method reporting the total size.
+// ReadAt calls spanning a file other than the one currently being read open
+// that file for the duration of the call.
+func NewConcatReader(ss StreamStore, names []string) (StrawReader, error) {
+	cr := &concatReader{
+		ss:      ss,
+		names:   names,
+		offsets: make([]int64, len(names)),
+		sizes:   make([]int64, len(names)),
+		cur:     -1,
+	}
+	for i, name := range names {
+		fi, err := ss.Stat(name)
+		if err != nil {
+			return nil, err
+		}
+		if fi.IsDir() {
+			return nil, &os.PathError{Op: "open", Path: name, Err: ErrIsDirectory}
+		}
+		cr.offsets[i] = cr.size
+		cr.sizes[i] = fi.Size()
+		cr.size += fi.Size()
+	}
+	return cr, nil
+}
+
+type concatReader struct {
+	ss      StreamStore
+	names   []string
+	offsets []int64
+	sizes   []int64
+	size    int64
+
+	lk  sync.Mutex
+	off int64
+	// cur is the index of the open part, or -1 if none is open.
+	cur  int
+	curR StrawReader
+	// curOff is the offset within the whole stream that curR is positioned at.
+	curOff int64
+}
+
+// Size returns the total size of the concatenated files.
+func (cr *concatReader) Size() int64 {
+	return cr.size
+}
+
+// partAt returns the index of the non-empty part containing off.
+func (cr *concatReader) partAt(off int64) int {
+	return sort.Search(len(cr.names), func(i int) bool {
+		return cr.offsets[i]+cr.sizes[i] > off
+	})
+}
+
+func (cr *concatReader) Read(buf []byte) (int, error) {
+	cr.lk.Lock()
+	defer cr.lk.Unlock()
+
+	if cr.off >= cr.size {
+		return 0, io.EOF
+	}
+	k := cr.partAt(cr.off)
+	if k != cr.cur {
+		if err := cr.closeCurrent(); err != nil {
+			return 0, err
+		}
+		r, err := cr.ss.OpenReadCloser(cr.names[k])
+		if err != nil {
+			return 0, err
+		}
+		cr.cur, cr.curR, cr.curOff = k, r, cr.offsets[k]
+	}
+	if cr.curOff != cr.off {
+		if _, err := cr.curR.Seek(cr.off-cr.offsets[k], io.SeekStart); err != nil {
+			return 0, err
+		}
+		cr.curOff = cr.off
+	}
+
+	if remaining := cr.offsets[k] + cr.sizes[k] - cr.off; int64(len(buf)) > remaining {
+		buf = buf[:remaining]
+	}
+	n, err := cr.curR.Read(buf)
+	cr.off += int64(n)
+	cr.curOff += int64(n)
+	if err == io.EOF {
+		if n == 0 {
+			return 0, io.ErrUnexpectedEOF
+		}
+		err = nil
+	}
+	return n, err
+}
+
+func (cr *concatReader) ReadAt(buf []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+
+	cr.lk.Lock()
+	defer cr.lk.Unlock()
+
+	var read int
+	for read < len(buf) {
+		if off >= cr.size {
+			return read, io.EOF
+		}
+		k := cr.partAt(off)
+		p := buf[read:]
+		if remaining := cr.offsets[k] + cr.sizes[k] - off; int64(len(p)) > remaining {
+			p = p[:remaining]
+		}
+		n, err := cr.readPartAt(k, p, off-cr.offsets[k])
+		read += n
+		off += int64(n)
+		if err == io.EOF && n == len(p) {
+			err = nil
+		}
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return read, err
+		}
+	}
+	return read, nil
+}
+
+func (cr *concatReader) readPartAt(k int, p []byte, off int64) (int, error) {
+	if k == cr.cur {
+		return cr.curR.ReadAt(p, off)
+	}
+	r, err := cr.ss.OpenReadCloser(cr.names[k])
+	if err != nil {
+		return 0, err
+	}
+	n, err := r.ReadAt(p, off)
+	if cerr := r.Close(); err == nil {
+		err = cerr
+	}
+	return n, err
+}
+
+func (cr *concatReader) Seek(offset int64, whence int) (int64, error) {
+	cr.lk.Lock()
+	defer cr.lk.Unlock()
+
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += cr.off
+	case io.SeekEnd:
+		offset += cr.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	cr.off = offset
+	return offset, nil
+}
+
+func (cr *concatReader) Close() error {
+	cr.lk.Lock()
+	defer cr.lk.Unlock()
+	return cr.closeCurrent()
+}
+
+func (cr *concatReader) closeCurrent() error {
+	if cr.curR == nil {
+		return nil
+	}
+	err := cr.curR.Close()
+	cr.cur, cr.curR = -1, nil
+	return err
+}
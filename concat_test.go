@@ -0,0 +1,55 @@
+package straw_test
+
+import (
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+func TestConcatReader(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, _ := straw.Open("mem://")
+	writeContent(t, ss, "/part-0000", []byte("abc"))
+	writeContent(t, ss, "/part-0001", []byte("defg"))
+	writeContent(t, ss, "/part-0002", []byte("hi"))
+
+	r, err := straw.NewConcatReader(ss, []string{"/part-0000", "/part-0001", "/part-0002"})
+	require.NoError(err)
+	defer r.Close()
+
+	assert.Equal(int64(9), r.(interface{ Size() int64 }).Size())
+
+	all, err := ioutil.ReadAll(r)
+	require.NoError(err)
+	assert.Equal("abcdefghi", string(all))
+
+	buf := make([]byte, 6)
+	n, err := r.ReadAt(buf, 2)
+	assert.NoError(err)
+	assert.Equal(6, n)
+	assert.Equal("cdefgh", string(buf))
+
+	n, err = r.ReadAt(buf, 6)
+	assert.Equal(io.EOF, err)
+	assert.Equal("ghi", string(buf[:n]))
+
+	pos, err := r.Seek(-4, io.SeekEnd)
+	require.NoError(err)
+	assert.Equal(int64(5), pos)
+	rest, err := ioutil.ReadAll(r)
+	require.NoError(err)
+	assert.Equal("fghi", string(rest))
+
+	_, err = r.Seek(1, io.SeekStart)
+	require.NoError(err)
+	buf = make([]byte, 4)
+	_, err = io.ReadFull(r, buf)
+	require.NoError(err)
+	assert.Equal("bcde", string(buf))
+}
@@ -0,0 +1,107 @@
+package straw_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+// TestConcurrentWriters writes the same file from several goroutines at once,
+// while others read it, which is most useful under the race detector. Every
+// read sees the whole of one writer's content, and the file is left with the
+// content of one of them.
+func TestConcurrentWriters(t *testing.T) {
+	dir, err := ioutil.TempDir("", "straw_concurrent_test_")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, tc := range []struct {
+		url  string
+		name string
+	}{
+		{"mem://", "/f"},
+		{"mem://?max_bytes=1MiB", "/f"},
+		{"file:///?atomic=true", filepath.Join(dir, "f")},
+	} {
+		t.Run(tc.url, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			ss, err := straw.Open(tc.url)
+			require.NoError(err)
+			contents := make(map[string]bool)
+			for i := 0; i < 8; i++ {
+				contents[string(bytes.Repeat([]byte(fmt.Sprint(i)), 1000))] = true
+			}
+			writeContent(t, ss, tc.name, []byte("0000"))
+			contents["0000"] = true
+
+			// each goroutine sends a single result, so none blocks sending.
+			const readers = 4
+			writers := len(contents) - 1
+			errs := make(chan error, writers+readers)
+			var wg sync.WaitGroup
+			for content := range contents {
+				if content == "0000" {
+					continue
+				}
+				wg.Add(1)
+				go func(content string) {
+					defer wg.Done()
+					errs <- func() error {
+						w, err := ss.CreateWriteCloser(tc.name)
+						if err != nil {
+							return err
+						}
+						for i := 0; i < len(content); i += 100 {
+							if _, err := w.Write([]byte(content[i : i+100])); err != nil {
+								w.Close()
+								return err
+							}
+						}
+						return w.Close()
+					}()
+				}(content)
+			}
+			for i := 0; i < readers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					errs <- func() error {
+						for j := 0; j < 20; j++ {
+							data, err := straw.ReadFile(ss, tc.name)
+							if err != nil {
+								return err
+							}
+							if !contents[string(data)] {
+								return fmt.Errorf("read half written content %.20q", data)
+							}
+							if _, err := ss.Stat(tc.name); err != nil {
+								return err
+							}
+						}
+						return nil
+					}()
+				}()
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				assert.NoError(err)
+			}
+
+			data, err := straw.ReadFile(ss, tc.name)
+			require.NoError(err)
+			assert.True(contents[string(data)])
+			assert.NotEqual("0000", string(data))
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package straw
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Config holds behaviour applied around every operation of a StreamStore
+// opened with OpenWithConfig, whatever its backend. The zero Config adds
+// nothing.
+type Config struct {
+	// OpTimeout, if non-zero, bounds how long each operation may take before
+	// failing with an error wrapping ErrTimeout. Operations which time out
+	// are abandoned rather than interrupted, and any reader or writer they
+	// go on to return is closed. Reads and writes on open files are not
+	// bounded; NewTimeoutStore bounds those too.
+	OpTimeout time.Duration
+	// Retry configures retries of idempotent operations which fail,
+	// including those which time out.
+	Retry RetryOptions
+	// MaxConcurrentOps, if non-zero, limits how many operations may be in
+	// progress at once.
+	MaxConcurrentOps int
+	// Observer, if non-nil, is called after each operation with the name of
+	// the method, the path it acted on, how long it took including any
+	// retries, and the error it returned.
+	Observer func(op, name string, d time.Duration, err error)
+}
+
+// OpenWithConfig opens the StreamStore for rawurl as Open does, and wraps it
+// to apply cfg. The optional interfaces which act on paths, such as
+// OptionsWriter, AllRemover and ContextStreamStore, are passed through the
+// wrapping to the backend, so that functions such as CreateWriteCloser and
+// RemoveAll behave as they would on the store Open returns.
+func OpenWithConfig(rawurl string, cfg Config) (StreamStore, error) {
+	ss, err := Open(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.wrap(ss), nil
+}
+
+func (cfg Config) wrap(ss StreamStore) StreamStore {
+	if cfg.MaxConcurrentOps > 0 {
+		ss = &opStreamStore{ss, limiting(cfg.MaxConcurrentOps)}
+	}
+	if cfg.OpTimeout > 0 {
+		ss = &opStreamStore{ss, timingOut(cfg.OpTimeout)}
+	}
+	if cfg.Retry.MaxAttempts > 1 {
+		ss = &opStreamStore{ss, retrying(cfg.Retry)}
+	}
+	if cfg.Observer != nil {
+		ss = &opStreamStore{ss, observing(cfg.Observer)}
+	}
+	return ss
+}
+
+// limiting returns an opFunc allowing at most n operations at once.
+// Operations waiting for others to finish give up when their context is
+// done.
+func limiting(n int) opFunc {
+	sem := make(chan struct{}, n)
+	return func(ctx context.Context, op, name string, f func() (interface{}, error)) (interface{}, error) {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		defer func() { <-sem }()
+		return f()
+	}
+}
+
+// timingOut returns an opFunc failing operations which take longer than d.
+func timingOut(d time.Duration) opFunc {
+	return func(ctx context.Context, op, name string, f func() (interface{}, error)) (interface{}, error) {
+		type result struct {
+			v   interface{}
+			err error
+		}
+		ch := make(chan result, 1)
+		go func() {
+			v, err := f()
+			ch <- result{v, err}
+		}()
+
+		t := time.NewTimer(d)
+		defer t.Stop()
+		select {
+		case res := <-ch:
+			return res.v, res.err
+		case <-t.C:
+			go func() {
+				// nobody will use whatever the abandoned operation returns.
+				if c, ok := (<-ch).v.(io.Closer); ok {
+					c.Close()
+				}
+			}()
+			return nil, fmt.Errorf("%s %s: %w", op, name, ErrTimeout)
+		}
+	}
+}
+
+// observing returns an opFunc reporting each operation to observer.
+func observing(observer func(op, name string, d time.Duration, err error)) opFunc {
+	return func(ctx context.Context, op, name string, f func() (interface{}, error)) (interface{}, error) {
+		start := time.Now()
+		v, err := f()
+		observer(op, name, time.Since(start), err)
+		return v, err
+	}
+}
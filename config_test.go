@@ -0,0 +1,217 @@
+package straw_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+// transientError is an error which reports itself temporary, as net errors
+// do, so is retried by default.
+type transientError struct{}
+
+func (transientError) Error() string   { return "transient" }
+func (transientError) Temporary() bool { return true }
+
+var errTransient error = transientError{}
+
+// flakyStreamStore fails the first failures calls to Stat with err, or
+// errTransient if it is nil, and takes delay over each Stat.
+type flakyStreamStore struct {
+	straw.StreamStore
+	delay time.Duration
+	err   error
+
+	lk       sync.Mutex
+	failures int
+	stats    int
+}
+
+func (ss *flakyStreamStore) Stat(name string) (os.FileInfo, error) {
+	time.Sleep(ss.delay)
+	ss.lk.Lock()
+	defer ss.lk.Unlock()
+	ss.stats++
+	if ss.stats <= ss.failures {
+		if ss.err != nil {
+			return nil, ss.err
+		}
+		return nil, errTransient
+	}
+	return ss.StreamStore.Stat(name)
+}
+
+var testFlaky *flakyStreamStore
+
+var testOptions *optionsStreamStore
+
+func init() {
+	straw.Register("configtest", func(u *url.URL) (straw.StreamStore, error) {
+		return testFlaky, nil
+	})
+	straw.Register("configoptionstest", func(u *url.URL) (straw.StreamStore, error) {
+		return testOptions, nil
+	})
+}
+
+func TestOpenWithConfigRetries(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	mem, _ := straw.Open("mem://")
+	writeFile(mem, "/a")
+	testFlaky = &flakyStreamStore{StreamStore: mem, failures: 2}
+
+	var observed []string
+	ss, err := straw.OpenWithConfig("configtest://", straw.Config{
+		Retry: straw.RetryOptions{MaxAttempts: 3, InitialBackoff: time.Millisecond},
+		Observer: func(op, name string, d time.Duration, err error) {
+			observed = append(observed, op+" "+name)
+		},
+	})
+	require.NoError(err)
+
+	_, err = ss.Stat("/a")
+	assert.NoError(err)
+	assert.Equal(3, testFlaky.stats)
+	assert.Equal([]string{"Stat /a"}, observed)
+
+	// missing files are not retried.
+	_, err = ss.Stat("/b")
+	assert.True(os.IsNotExist(err))
+	assert.Equal(4, testFlaky.stats)
+}
+
+func TestOpenWithConfigTimeout(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	mem, _ := straw.Open("mem://")
+	writeFile(mem, "/a")
+	testFlaky = &flakyStreamStore{StreamStore: mem, delay: 50 * time.Millisecond}
+
+	ss, err := straw.OpenWithConfig("configtest://", straw.Config{
+		OpTimeout: 10 * time.Millisecond,
+		Retry:     straw.RetryOptions{MaxAttempts: 2},
+	})
+	require.NoError(err)
+
+	_, err = ss.Stat("/a")
+	assert.True(errors.Is(err, straw.ErrTimeout))
+
+	// let the abandoned calls finish before checking they were retried.
+	time.Sleep(100 * time.Millisecond)
+	testFlaky.lk.Lock()
+	defer testFlaky.lk.Unlock()
+	assert.Equal(2, testFlaky.stats)
+}
+
+func TestOpenWithConfigOptionalInterfaces(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	mem, _ := straw.Open("mem://")
+	testOptions = &optionsStreamStore{StreamStore: mem}
+
+	var observed []string
+	ss, err := straw.OpenWithConfig("configoptionstest://", straw.Config{
+		OpTimeout:        time.Second,
+		Retry:            straw.RetryOptions{MaxAttempts: 3},
+		MaxConcurrentOps: 2,
+		Observer: func(op, name string, d time.Duration, err error) {
+			observed = append(observed, op+" "+name)
+		},
+	})
+	require.NoError(err)
+
+	w, err := straw.CreateWriteCloser(ss, "/a", straw.WithContentType("text/x"), straw.IfNotExists())
+	require.NoError(err)
+	require.NoError(w.Close())
+	assert.Equal("text/x", testOptions.opts.ContentType)
+	assert.True(testOptions.opts.IfNotExists)
+
+	require.NoError(straw.RemoveAll(ss, "/a"))
+	assert.Equal([]string{"/a"}, testOptions.removed)
+
+	exists, err := straw.Exists(ss, "/a")
+	require.NoError(err)
+	assert.False(exists)
+
+	assert.Equal([]string{"CreateWriteCloser /a", "RemoveAll /a", "Exists /a"}, observed)
+
+	// stores without the interface behave as they would unwrapped.
+	ss, err = straw.OpenWithConfig("mem://", straw.Config{Retry: straw.RetryOptions{MaxAttempts: 3}})
+	require.NoError(err)
+	_, err = straw.CreateWriteCloser(ss, "/a", straw.IfNotExists())
+	assert.True(errors.Is(err, straw.ErrNotSupported))
+	_, err = ss.(straw.Taggable).GetTags("/a")
+	assert.Equal(straw.ErrNotSupported, err)
+	require.NoError(ss.Mkdir("/d", 0755))
+	writeFile(ss, "/d/f")
+	require.NoError(straw.RemoveAll(ss, "/d"))
+	exists, err = straw.Exists(ss, "/d")
+	require.NoError(err)
+	assert.False(exists)
+}
+
+func TestWrappersForwardOptions(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	for name, wrap := range map[string]func(straw.StreamStore) straw.StreamStore{
+		"retry": func(ss straw.StreamStore) straw.StreamStore {
+			return straw.NewRetryStore(ss, straw.RetryOptions{MaxAttempts: 2})
+		},
+		"timeout": func(ss straw.StreamStore) straw.StreamStore {
+			return straw.NewTimeoutStore(ss, time.Second)
+		},
+		"logging": func(ss straw.StreamStore) straw.StreamStore {
+			return straw.NewLoggingStore(ss, testLogger(t))
+		},
+	} {
+		mem, _ := straw.Open("mem://")
+		opts := &optionsStreamStore{StreamStore: mem}
+		ss := wrap(opts)
+
+		w, err := straw.CreateWriteCloser(ss, "/a", straw.IfNotExists())
+		require.NoError(err, name)
+		_, err = w.Write([]byte("hello"))
+		require.NoError(err, name)
+		require.NoError(w.Close(), name)
+		assert.True(opts.opts.IfNotExists, name)
+		require.NoError(straw.RemoveAll(ss, "/a"), name)
+		assert.Equal([]string{"/a"}, opts.removed, name)
+	}
+}
+
+func TestRetryStopsWhenContextDone(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	mem, _ := straw.Open("mem://")
+	testFlaky = &flakyStreamStore{StreamStore: mem, failures: 10}
+	ss, err := straw.OpenWithConfig("configtest://", straw.Config{
+		Retry: straw.RetryOptions{MaxAttempts: 10, InitialBackoff: time.Hour},
+	})
+	require.NoError(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = straw.StatContext(ctx, ss, "/a")
+	assert.Equal(errTransient, err)
+	assert.Less(int64(time.Since(start)), int64(time.Second))
+	assert.Equal(1, testFlaky.stats)
+
+	_, err = straw.StatContext(ctx, ss, "/a")
+	assert.True(errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(1, testFlaky.stats)
+}
@@ -0,0 +1,132 @@
+package straw
+
+import (
+	"context"
+	"os"
+)
+
+// ContextStreamStore is implemented by stores whose operations can be
+// cancelled, or bounded by a deadline, with a context. The s3, gcs and sftp
+// backends implement it.
+// Readers and writers returned by OpenReadCloserContext and
+// CreateWriteCloserContext remain bound to ctx, so cancelling it also fails
+// any subsequent reads or writes on them.
+type ContextStreamStore interface {
+	StreamStore
+	OpenReadCloserContext(ctx context.Context, name string) (StrawReader, error)
+	CreateWriteCloserContext(ctx context.Context, name string) (StrawWriter, error)
+	LstatContext(ctx context.Context, path string) (os.FileInfo, error)
+	StatContext(ctx context.Context, path string) (os.FileInfo, error)
+	ReaddirContext(ctx context.Context, path string) ([]os.FileInfo, error)
+	MkdirContext(ctx context.Context, path string, mode os.FileMode) error
+	RemoveContext(ctx context.Context, path string) error
+	CopyContext(ctx context.Context, src, dst string) error
+	ChmodContext(ctx context.Context, name string, mode os.FileMode) error
+}
+
+// The following functions call the context aware method of ss if it
+// implements ContextStreamStore. Otherwise, they return ctx.Err() if ctx is
+// already done, and call the plain method if not, in which case the operation
+// cannot be interrupted.
+
+// OpenReadCloserContext opens the named file for reading, as
+// ss.OpenReadCloser does.
+func OpenReadCloserContext(ctx context.Context, ss StreamStore, name string) (StrawReader, error) {
+	if css, ok := ss.(ContextStreamStore); ok {
+		return css.OpenReadCloserContext(ctx, name)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return ss.OpenReadCloser(name)
+}
+
+// CreateWriteCloserContext creates the named file, as ss.CreateWriteCloser
+// does.
+func CreateWriteCloserContext(ctx context.Context, ss StreamStore, name string) (StrawWriter, error) {
+	if css, ok := ss.(ContextStreamStore); ok {
+		return css.CreateWriteCloserContext(ctx, name)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return ss.CreateWriteCloser(name)
+}
+
+// LstatContext returns information about the named file, as ss.Lstat does.
+func LstatContext(ctx context.Context, ss StreamStore, path string) (os.FileInfo, error) {
+	if css, ok := ss.(ContextStreamStore); ok {
+		return css.LstatContext(ctx, path)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return ss.Lstat(path)
+}
+
+// StatContext returns information about the named file, as ss.Stat does.
+func StatContext(ctx context.Context, ss StreamStore, path string) (os.FileInfo, error) {
+	if css, ok := ss.(ContextStreamStore); ok {
+		return css.StatContext(ctx, path)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return ss.Stat(path)
+}
+
+// ReaddirContext lists the named directory, as ss.Readdir does.
+func ReaddirContext(ctx context.Context, ss StreamStore, path string) ([]os.FileInfo, error) {
+	if css, ok := ss.(ContextStreamStore); ok {
+		return css.ReaddirContext(ctx, path)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return ss.Readdir(path)
+}
+
+// MkdirContext creates the named directory, as ss.Mkdir does.
+func MkdirContext(ctx context.Context, ss StreamStore, path string, mode os.FileMode) error {
+	if css, ok := ss.(ContextStreamStore); ok {
+		return css.MkdirContext(ctx, path, mode)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return ss.Mkdir(path, mode)
+}
+
+// RemoveContext removes the named file or empty directory, as ss.Remove
+// does.
+func RemoveContext(ctx context.Context, ss StreamStore, path string) error {
+	if css, ok := ss.(ContextStreamStore); ok {
+		return css.RemoveContext(ctx, path)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return ss.Remove(path)
+}
+
+// CopyContext copies the file src to dst, as ss.Copy does.
+func CopyContext(ctx context.Context, ss StreamStore, src, dst string) error {
+	if css, ok := ss.(ContextStreamStore); ok {
+		return css.CopyContext(ctx, src, dst)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return ss.Copy(src, dst)
+}
+
+// ChmodContext changes the mode of the named file, as ss.Chmod does.
+func ChmodContext(ctx context.Context, ss StreamStore, name string, mode os.FileMode) error {
+	if css, ok := ss.(ContextStreamStore); ok {
+		return css.ChmodContext(ctx, name, mode)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return ss.Chmod(name, mode)
+}
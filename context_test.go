@@ -0,0 +1,32 @@
+package straw_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uw-labs/straw"
+)
+
+func TestContextFallback(t *testing.T) {
+	assert := assert.New(t)
+
+	ss, _ := straw.Open("mem://")
+	writeFile(ss, "/a")
+
+	fi, err := straw.StatContext(context.Background(), ss, "/a")
+	assert.NoError(err)
+	assert.Equal("a", fi.Name())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = straw.StatContext(ctx, ss, "/a")
+	assert.Equal(context.Canceled, err)
+	_, err = straw.OpenReadCloserContext(ctx, ss, "/a")
+	assert.Equal(context.Canceled, err)
+	assert.Equal(context.Canceled, straw.RemoveContext(ctx, ss, "/a"))
+
+	_, err = ss.Stat("/a")
+	assert.NoError(err)
+}
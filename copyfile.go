@@ -0,0 +1,29 @@
+package straw
+
+import "io"
+
+// CopyFile copies the file srcPath in src to dstPath in dst, which may be a
+// different store, returning the number of bytes copied. The data is streamed
+// with io.Copy, so an io.WriterTo or io.ReaderFrom implemented by the reader
+// or writer is used. Both are closed before CopyFile returns, and an error
+// from closing the writer is returned, as it may mean the file was not
+// completely written.
+// To copy within a single store, the Copy method may be more efficient.
+func CopyFile(dst StreamStore, dstPath string, src StreamStore, srcPath string) (int64, error) {
+	r, err := src.OpenReadCloser(srcPath)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	w, err := dst.CreateWriteCloser(dstPath)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(w, r)
+	if err != nil {
+		w.Close()
+		return n, err
+	}
+	return n, w.Close()
+}
@@ -0,0 +1,63 @@
+package straw_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+// failingCloseStreamStore creates writers whose Close fails.
+type failingCloseStreamStore struct {
+	straw.StreamStore
+	err error
+}
+
+func (ss *failingCloseStreamStore) CreateWriteCloser(name string) (straw.StrawWriter, error) {
+	w, err := ss.StreamStore.CreateWriteCloser(name)
+	if err != nil {
+		return nil, err
+	}
+	return &failingCloseWriter{w, ss.err}, nil
+}
+
+type failingCloseWriter struct {
+	straw.StrawWriter
+	err error
+}
+
+func (w *failingCloseWriter) Close() error {
+	w.StrawWriter.Close()
+	return w.err
+}
+
+func TestCopyFile(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	mem, _ := straw.Open("mem://")
+	osfs, _ := straw.Open("file:///")
+	dir := tempDir()
+	defer os.RemoveAll(dir)
+
+	require.NoError(straw.WriteFile(mem, "/a", []byte("hello"), 0644))
+
+	n, err := straw.CopyFile(osfs, filepath.Join(dir, "a"), mem, "/a")
+	require.NoError(err)
+	assert.Equal(int64(5), n)
+
+	data, err := straw.ReadFile(osfs, filepath.Join(dir, "a"))
+	require.NoError(err)
+	assert.Equal("hello", string(data))
+
+	_, err = straw.CopyFile(osfs, filepath.Join(dir, "b"), mem, "/missing")
+	assert.True(os.IsNotExist(err))
+
+	closeErr := errors.New("upload failed")
+	_, err = straw.CopyFile(&failingCloseStreamStore{mem, closeErr}, "/b", mem, "/a")
+	assert.Equal(closeErr, err)
+}
@@ -0,0 +1,294 @@
+package straw
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+var _ StreamStore = &diskCacheStreamStore{}
+
+// errDiskCacheChanged is returned by fetch when the file changed after Stat,
+// so that the content read may not belong to the version being cached.
+var errDiskCacheChanged = errors.New("file changed while being cached")
+
+// NewDiskCacheStore returns a StreamStore which keeps copies of the files read
+// from backing in the local directory cacheDir, evicting the least recently
+// used files to hold at most maxBytes there. Files larger than maxBytes are
+// never cached. As with NewCacheStore, each OpenReadCloser calls Stat on
+// backing, and a cached copy is only used if the size and modification time
+// of the file are unchanged; otherwise the file is downloaded into the cache
+// before it is read. Writing, removing or copying over a file through the
+// returned store drops it from the cache.
+//
+// The cached copies outlive the store, so a store created later with the same
+// cacheDir starts warm, although the files found there are evicted in no
+// particular order. cacheDir is created if needed, and should not be shared
+// with stores in other processes, or used for anything else. Close does not
+// remove it.
+func NewDiskCacheStore(backing StreamStore, cacheDir string, maxBytes int64) StreamStore {
+	fs := &diskCacheStreamStore{
+		ss:       backing,
+		dir:      cacheDir,
+		maxBytes: maxBytes,
+		lru:      list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+	fs.load()
+	return fs
+}
+
+type diskCacheStreamStore struct {
+	ss       StreamStore
+	dir      string
+	maxBytes int64
+
+	lk    sync.Mutex
+	bytes int64
+	// lru holds *diskCacheEntry values, most recently used first.
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+type diskCacheEntry struct {
+	key  string
+	size int64
+}
+
+// diskCacheKey returns the name of the file in the cache directory holding
+// the content of name.
+func diskCacheKey(name string) string {
+	sum := sha256.Sum256([]byte(path.Clean(name)))
+	return hex.EncodeToString(sum[:])
+}
+
+// load indexes the files left in the cache directory by an earlier store,
+// removing any partly written ones.
+func (fs *diskCacheStreamStore) load() {
+	fis, err := ioutil.ReadDir(fs.dir)
+	if err != nil {
+		return
+	}
+	fs.lk.Lock()
+	defer fs.lk.Unlock()
+	for _, fi := range fis {
+		name := fi.Name()
+		switch {
+		case strings.HasSuffix(name, ".tmp"):
+			os.Remove(filepath.Join(fs.dir, name))
+		case fi.Mode().IsRegular() && len(name) == sha256.Size*2:
+			fs.add(name, fi.Size())
+		}
+	}
+}
+
+// cachePath returns the path of the cache file for key.
+func (fs *diskCacheStreamStore) cachePath(key string) string {
+	return filepath.Join(fs.dir, key)
+}
+
+// add indexes the cache file key, evicting other entries as needed. fs.lk
+// must be held.
+func (fs *diskCacheStreamStore) add(key string, size int64) {
+	if el, ok := fs.entries[key]; ok {
+		fs.lru.Remove(el)
+		fs.bytes -= el.Value.(*diskCacheEntry).size
+	}
+	for fs.bytes+size > fs.maxBytes && fs.lru.Len() != 0 {
+		fs.remove(fs.lru.Back())
+	}
+	fs.entries[key] = fs.lru.PushFront(&diskCacheEntry{key, size})
+	fs.bytes += size
+}
+
+// remove drops el from the cache, deleting its file. fs.lk must be held.
+func (fs *diskCacheStreamStore) remove(el *list.Element) {
+	e := fs.lru.Remove(el).(*diskCacheEntry)
+	delete(fs.entries, e.key)
+	fs.bytes -= e.size
+	os.Remove(fs.cachePath(e.key))
+}
+
+// get opens the cache file for key if it matches fi.
+func (fs *diskCacheStreamStore) get(key string, fi os.FileInfo) (*os.File, bool) {
+	fs.lk.Lock()
+	defer fs.lk.Unlock()
+	el, ok := fs.entries[key]
+	if !ok {
+		return nil, false
+	}
+	f, err := os.Open(fs.cachePath(key))
+	if err != nil {
+		fs.remove(el)
+		return nil, false
+	}
+	// the modification time of the cache file is set to that of the file it
+	// holds when it is written.
+	cfi, err := f.Stat()
+	if err != nil || cfi.Size() != fi.Size() || !cfi.ModTime().Equal(fi.ModTime()) {
+		f.Close()
+		fs.remove(el)
+		return nil, false
+	}
+	fs.lru.MoveToFront(el)
+	return f, true
+}
+
+// invalidate drops name from the cache.
+func (fs *diskCacheStreamStore) invalidate(name string) {
+	key := diskCacheKey(name)
+	fs.lk.Lock()
+	defer fs.lk.Unlock()
+	if el, ok := fs.entries[key]; ok {
+		fs.remove(el)
+	}
+}
+
+// fetch copies name, described by fi, from the backing store into the cache
+// file for key.
+func (fs *diskCacheStreamStore) fetch(name, key string, fi os.FileInfo) error {
+	if err := os.MkdirAll(fs.dir, 0755); err != nil {
+		return err
+	}
+	r, err := fs.ss.OpenReadCloser(name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	tmp, err := ioutil.TempFile(fs.dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(tmp, r)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && n != fi.Size() {
+		err = errDiskCacheChanged
+	}
+	if err == nil {
+		err = os.Chtimes(tmp.Name(), fi.ModTime(), fi.ModTime())
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), fs.cachePath(key))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	fs.lk.Lock()
+	fs.add(key, n)
+	fs.lk.Unlock()
+	return nil
+}
+
+func (fs *diskCacheStreamStore) Close() error {
+	return fs.ss.Close()
+}
+
+func (fs *diskCacheStreamStore) Unwrap() StreamStore {
+	return fs.ss
+}
+
+func (fs *diskCacheStreamStore) OpenReadCloser(name string) (StrawReader, error) {
+	fi, err := fs.ss.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() || fi.Size() > fs.maxBytes {
+		return fs.ss.OpenReadCloser(name)
+	}
+
+	key := diskCacheKey(name)
+	if f, ok := fs.get(key, fi); ok {
+		return file{f}, nil
+	}
+	if err := fs.fetch(name, key, fi); err != nil {
+		if err == errDiskCacheChanged {
+			return fs.ss.OpenReadCloser(name)
+		}
+		return nil, err
+	}
+	if f, ok := fs.get(key, fi); ok {
+		return file{f}, nil
+	}
+	// evicted again already, by a concurrent fetch.
+	return fs.ss.OpenReadCloser(name)
+}
+
+func (fs *diskCacheStreamStore) CreateWriteCloser(name string) (StrawWriter, error) {
+	fs.invalidate(name)
+	w, err := fs.ss.CreateWriteCloser(name)
+	if err != nil {
+		return nil, err
+	}
+	cw := &cacheInvalidatingWriter{w, fs.invalidate, name}
+	if wa, ok := w.(io.WriterAt); ok {
+		return &cacheInvalidatingWriterAt{cw, wa}, nil
+	}
+	return cw, nil
+}
+
+func (fs *diskCacheStreamStore) OpenFile(name string, flag int, perm os.FileMode) (StrawReadWriteCloser, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return OpenFileBuffered(fs, name, flag, nil)
+	}
+	fs.invalidate(name)
+	f, err := fs.ss.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &cacheInvalidatingFile{f, fs.invalidate, name}, nil
+}
+
+func (fs *diskCacheStreamStore) Lstat(path string) (os.FileInfo, error) {
+	return fs.ss.Lstat(path)
+}
+
+func (fs *diskCacheStreamStore) Stat(path string) (os.FileInfo, error) {
+	return fs.ss.Stat(path)
+}
+
+func (fs *diskCacheStreamStore) Readdir(path string) ([]os.FileInfo, error) {
+	return fs.ss.Readdir(path)
+}
+
+func (fs *diskCacheStreamStore) Mkdir(path string, mode os.FileMode) error {
+	return fs.ss.Mkdir(path, mode)
+}
+
+func (fs *diskCacheStreamStore) Remove(path string) error {
+	fs.invalidate(path)
+	return fs.ss.Remove(path)
+}
+
+func (fs *diskCacheStreamStore) Chmod(name string, mode os.FileMode) error {
+	return fs.ss.Chmod(name, mode)
+}
+
+func (fs *diskCacheStreamStore) Truncate(name string, size int64) error {
+	fs.invalidate(name)
+	return fs.ss.Truncate(name, size)
+}
+
+func (fs *diskCacheStreamStore) Symlink(oldname, newname string) error {
+	fs.invalidate(newname)
+	return Symlink(fs.ss, oldname, newname)
+}
+
+func (fs *diskCacheStreamStore) Readlink(name string) (string, error) {
+	return Readlink(fs.ss, name)
+}
+
+func (fs *diskCacheStreamStore) Copy(src, dst string) error {
+	fs.invalidate(dst)
+	return fs.ss.Copy(src, dst)
+}
@@ -0,0 +1,108 @@
+package straw_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+func TestDiskCacheStore(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "straw_diskcache_test_")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	mem, _ := straw.Open("mem://")
+	rec := &TestRecordingStreamStore{wrapped: mem}
+	ss := straw.NewDiskCacheStore(rec, dir, 1024)
+
+	writeContent(t, mem, "/a", []byte("first"))
+
+	for i := 0; i < 3; i++ {
+		data, err := straw.ReadFile(ss, "/a")
+		require.NoError(err)
+		assert.Equal("first", string(data))
+	}
+	assert.Equal(1, len(rec.Calls("OpenReadCloser")))
+	assert.Len(dirNames(t, dir), 1)
+
+	// a new store over the same directory starts warm.
+	ss = straw.NewDiskCacheStore(rec, dir, 1024)
+	data, err := straw.ReadFile(ss, "/a")
+	require.NoError(err)
+	assert.Equal("first", string(data))
+	assert.Equal(1, len(rec.Calls("OpenReadCloser")))
+
+	// writes through the cache invalidate it.
+	writeContent(t, ss, "/a", []byte("second"))
+	assert.Len(dirNames(t, dir), 0)
+	data, err = straw.ReadFile(ss, "/a")
+	require.NoError(err)
+	assert.Equal("second", string(data))
+	assert.Equal(2, len(rec.Calls("OpenReadCloser")))
+
+	// so do writes made directly to the backing store.
+	time.Sleep(10 * time.Millisecond)
+	writeContent(t, mem, "/a", []byte("third!"))
+	data, err = straw.ReadFile(ss, "/a")
+	require.NoError(err)
+	assert.Equal("third!", string(data))
+	assert.Equal(3, len(rec.Calls("OpenReadCloser")))
+
+	require.NoError(ss.Remove("/a"))
+	assert.Len(dirNames(t, dir), 0)
+	_, err = ss.OpenReadCloser("/a")
+	assert.Error(err)
+}
+
+func TestDiskCacheStoreEviction(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "straw_diskcache_test_")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	mem, _ := straw.Open("mem://")
+	rec := &TestRecordingStreamStore{wrapped: mem}
+	ss := straw.NewDiskCacheStore(rec, dir, 10)
+
+	writeContent(t, mem, "/a", []byte("aaaa"))
+	writeContent(t, mem, "/b", []byte("bbbb"))
+	writeContent(t, mem, "/c", []byte("cccc"))
+	writeContent(t, mem, "/big", []byte("0123456789a"))
+
+	read := func(name string) {
+		_, err := straw.ReadFile(ss, name)
+		require.NoError(err)
+	}
+	read("/a")
+	read("/b")
+	read("/a")
+	read("/c") // evicts /b, the least recently used.
+	read("/a")
+	read("/b")
+	read("/big")
+	read("/big")
+
+	assert.Equal([]string{
+		"OpenReadCloser /a",
+		"OpenReadCloser /b",
+		"OpenReadCloser /c",
+		"OpenReadCloser /b",
+		"OpenReadCloser /big",
+		"OpenReadCloser /big",
+	}, rec.Calls("OpenReadCloser"))
+	assert.Len(dirNames(t, dir), 2)
+
+	// a store with a smaller limit evicts what it finds to fit.
+	straw.NewDiskCacheStore(rec, dir, 5)
+	assert.Len(dirNames(t, dir), 1)
+}
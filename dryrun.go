@@ -0,0 +1,115 @@
+package straw
+
+import "os"
+
+var _ StreamStore = &dryRunStreamStore{}
+
+// NewDryRunStore returns a StreamStore which wraps ss, reading it as normal
+// but, rather than modifying it, passing each operation which would to log,
+// with the name of the operation, such as "create", "mkdir" or "remove", and
+// the path it would change, then reporting success. Writers and files opened
+// for writing discard what is written, so later reads still see ss as it
+// was, which lets a sync or cleanup job be planned against a real store to
+// see what it would do.
+func NewDryRunStore(ss StreamStore, log func(op, path string)) StreamStore {
+	return &dryRunStreamStore{ss, log}
+}
+
+type dryRunStreamStore struct {
+	ss  StreamStore
+	log func(op, path string)
+}
+
+func (fs *dryRunStreamStore) Unwrap() StreamStore {
+	return fs.ss
+}
+
+func (fs *dryRunStreamStore) Close() error {
+	return fs.ss.Close()
+}
+
+func (fs *dryRunStreamStore) OpenReadCloser(name string) (StrawReader, error) {
+	return fs.ss.OpenReadCloser(name)
+}
+
+func (fs *dryRunStreamStore) CreateWriteCloser(name string) (StrawWriter, error) {
+	fs.log("create", name)
+	return &dryRunWriter{}, nil
+}
+
+// OpenFile opens files for writing in memory, loaded with the content of the
+// file in ss, and discards them on Close.
+func (fs *dryRunStreamStore) OpenFile(name string, flag int, perm os.FileMode) (StrawReadWriteCloser, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return fs.ss.OpenFile(name, flag, perm)
+	}
+	fs.log("open", name)
+	quiet := &dryRunStreamStore{fs.ss, func(op, path string) {}}
+	return OpenFileBuffered(quiet, name, flag, nil)
+}
+
+func (fs *dryRunStreamStore) Lstat(path string) (os.FileInfo, error) {
+	return fs.ss.Lstat(path)
+}
+
+func (fs *dryRunStreamStore) Stat(path string) (os.FileInfo, error) {
+	return fs.ss.Stat(path)
+}
+
+func (fs *dryRunStreamStore) Readdir(path string) ([]os.FileInfo, error) {
+	return fs.ss.Readdir(path)
+}
+
+func (fs *dryRunStreamStore) Mkdir(path string, mode os.FileMode) error {
+	fs.log("mkdir", path)
+	return nil
+}
+
+func (fs *dryRunStreamStore) Remove(path string) error {
+	fs.log("remove", path)
+	return nil
+}
+
+func (fs *dryRunStreamStore) Chmod(name string, mode os.FileMode) error {
+	fs.log("chmod", name)
+	return nil
+}
+
+func (fs *dryRunStreamStore) Truncate(name string, size int64) error {
+	fs.log("truncate", name)
+	return nil
+}
+
+func (fs *dryRunStreamStore) Symlink(oldname, newname string) error {
+	fs.log("symlink", newname)
+	return nil
+}
+
+func (fs *dryRunStreamStore) Readlink(name string) (string, error) {
+	return Readlink(fs.ss, name)
+}
+
+func (fs *dryRunStreamStore) Copy(src, dst string) error {
+	fs.log("copy", dst)
+	return nil
+}
+
+// dryRunWriter discards what is written to it.
+type dryRunWriter struct {
+	closed bool
+}
+
+func (w *dryRunWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, ErrClosed
+	}
+	return len(p), nil
+}
+
+func (w *dryRunWriter) Close() error {
+	if w.closed {
+		return ErrClosed
+	}
+	w.closed = true
+	return nil
+}
@@ -0,0 +1,98 @@
+package straw_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+func TestDryRunStore(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	mem, _ := straw.Open("mem://")
+	require.NoError(mem.Mkdir("/a", 0755))
+	writeContent(t, mem, "/a/1", []byte("hello"))
+
+	var ops []string
+	ss := straw.NewDryRunStore(mem, func(op, path string) {
+		ops = append(ops, op+" "+path)
+	})
+
+	// reads see the real store.
+	data, err := straw.ReadFile(ss, "/a/1")
+	require.NoError(err)
+	assert.Equal("hello", string(data))
+	fis, err := ss.Readdir("/a")
+	require.NoError(err)
+	assert.Len(fis, 1)
+	f, err := ss.OpenFile("/a/1", os.O_RDONLY, 0)
+	require.NoError(err)
+	require.NoError(f.Close())
+
+	require.NoError(straw.WriteFile(ss, "/a/1", []byte("changed"), 0644))
+	f, err = ss.OpenFile("/a/1", os.O_RDWR, 0)
+	require.NoError(err)
+	data = make([]byte, 5)
+	_, err = f.Read(data)
+	require.NoError(err)
+	assert.Equal("hello", string(data))
+	_, err = f.WriteAt([]byte("J"), 0)
+	require.NoError(err)
+	require.NoError(f.Close())
+	require.NoError(ss.Mkdir("/b", 0755))
+	require.NoError(ss.Remove("/a/1"))
+	require.NoError(ss.Chmod("/a/1", 0600))
+	require.NoError(ss.Truncate("/a/1", 0))
+	require.NoError(ss.Copy("/a/1", "/a/2"))
+	require.NoError(straw.Symlink(ss, "/a/1", "/a/3"))
+
+	assert.Equal([]string{
+		"create /a/1",
+		"open /a/1",
+		"mkdir /b",
+		"remove /a/1",
+		"chmod /a/1",
+		"truncate /a/1",
+		"copy /a/2",
+		"symlink /a/3",
+	}, ops)
+
+	// nothing was changed.
+	data, err = straw.ReadFile(mem, "/a/1")
+	require.NoError(err)
+	assert.Equal("hello", string(data))
+	fi, err := mem.Stat("/a/1")
+	require.NoError(err)
+	assert.Equal(os.FileMode(0644), fi.Mode())
+	fis, err = mem.Readdir("/")
+	require.NoError(err)
+	assert.Len(fis, 1)
+	fis, err = mem.Readdir("/a")
+	require.NoError(err)
+	assert.Len(fis, 1)
+}
+
+func TestDryRunWriter(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	mem, _ := straw.Open("mem://")
+	ss := straw.NewDryRunStore(mem, func(op, path string) {})
+
+	w, err := ss.CreateWriteCloser("/missing/dir/f")
+	require.NoError(err)
+	n, err := w.Write([]byte("hello"))
+	require.NoError(err)
+	assert.Equal(5, n)
+	require.NoError(w.Close())
+	assert.Equal(straw.ErrClosed, w.Close())
+	_, err = w.Write([]byte("hello"))
+	assert.Equal(straw.ErrClosed, err)
+
+	_, err = mem.Stat("/missing")
+	assert.True(os.IsNotExist(err))
+}
@@ -0,0 +1,429 @@
+package straw
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+var _ StreamStore = &encryptedStreamStore{}
+
+// Encrypted files begin with a header of encryptedMagic followed by a random
+// nonce. The plaintext follows in blocks of encryptedBlockSize bytes, the
+// last of which may be shorter, each sealed separately with AES-GCM so that
+// any block can be decrypted on its own. The nonce of each block is the
+// header nonce with the block index XORed into its last 8 bytes, and the
+// index and whether the block is the last are authenticated as additional
+// data, so blocks cannot be reordered and files cannot be truncated at a
+// block boundary without detection. An empty file has a single empty block.
+const (
+	encryptedMagic     = "STRAWEC1"
+	encryptedNonceSize = 12
+	encryptedHeaderLen = len(encryptedMagic) + encryptedNonceSize
+	encryptedBlockSize = 64 << 10
+	encryptedTagSize   = 16
+	encryptedSealedLen = encryptedBlockSize + encryptedTagSize
+)
+
+var errNotEncrypted = errors.New("not an encrypted file")
+
+// NewEncryptedStore returns a StreamStore which encrypts the content of files
+// written to ss, and decrypts files read from it, with AES-GCM using key,
+// which must be 16, 24 or 32 bytes long to select AES-128, AES-192 or AES-256.
+// NewEncryptedStore panics if the key is any other length.
+// Content is encrypted in fixed size blocks, so readers support ReadAt and
+// Seek without decrypting the whole file, and FileInfos report the size of
+// the plaintext. Names and directory structure are not encrypted.
+func NewEncryptedStore(ss StreamStore, key []byte) StreamStore {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		panic("straw: NewEncryptedStore: " + err.Error())
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic("straw: NewEncryptedStore: " + err.Error())
+	}
+	return &encryptedStreamStore{ss, aead}
+}
+
+type encryptedStreamStore struct {
+	ss   StreamStore
+	aead cipher.AEAD
+}
+
+func (fs *encryptedStreamStore) Close() error {
+	return fs.ss.Close()
+}
+
+func (fs *encryptedStreamStore) Unwrap() StreamStore {
+	return fs.ss
+}
+
+func (fs *encryptedStreamStore) OpenReadCloser(name string) (StrawReader, error) {
+	r, err := fs.ss.OpenReadCloser(name)
+	if err != nil {
+		return nil, err
+	}
+	size, err := r.Seek(0, io.SeekEnd)
+	if err == nil {
+		_, err = r.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	header := make([]byte, encryptedHeaderLen)
+	if _, err := io.ReadFull(r, header); err != nil || string(header[:len(encryptedMagic)]) != encryptedMagic {
+		r.Close()
+		return nil, fmt.Errorf("%s : %w", name, errNotEncrypted)
+	}
+	blocks, plainSize, ok := encryptedLayout(size)
+	if !ok {
+		r.Close()
+		return nil, fmt.Errorf("%s : %w", name, errNotEncrypted)
+	}
+	return &encryptedReader{
+		aead:      fs.aead,
+		name:      name,
+		src:       r,
+		srcPos:    int64(encryptedHeaderLen),
+		nonce:     header[len(encryptedMagic):],
+		blocks:    blocks,
+		size:      plainSize,
+		cachedIdx: -1,
+	}, nil
+}
+
+func (fs *encryptedStreamStore) CreateWriteCloser(name string) (StrawWriter, error) {
+	nonce := make([]byte, encryptedNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	w, err := fs.ss.CreateWriteCloser(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(append([]byte(encryptedMagic), nonce...)); err != nil {
+		w.Close()
+		return nil, err
+	}
+	return &encryptedWriter{
+		aead:  fs.aead,
+		dst:   w,
+		nonce: nonce,
+		buf:   make([]byte, 0, encryptedBlockSize),
+	}, nil
+}
+
+func (fs *encryptedStreamStore) OpenFile(name string, flag int, perm os.FileMode) (StrawReadWriteCloser, error) {
+	return OpenFileBuffered(fs, name, flag, func(name string) error {
+		f, err := fs.ss.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+		if err != nil {
+			return err
+		}
+		return f.Close()
+	})
+}
+
+func (fs *encryptedStreamStore) Lstat(path string) (os.FileInfo, error) {
+	fi, err := fs.ss.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+	return encryptedFileInfo(fi), nil
+}
+
+func (fs *encryptedStreamStore) Stat(path string) (os.FileInfo, error) {
+	fi, err := fs.ss.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return encryptedFileInfo(fi), nil
+}
+
+func (fs *encryptedStreamStore) Readdir(path string) ([]os.FileInfo, error) {
+	fis, err := fs.ss.Readdir(path)
+	if err != nil {
+		return nil, err
+	}
+	for i, fi := range fis {
+		fis[i] = encryptedFileInfo(fi)
+	}
+	return fis, nil
+}
+
+func (fs *encryptedStreamStore) Mkdir(path string, mode os.FileMode) error {
+	return fs.ss.Mkdir(path, mode)
+}
+
+func (fs *encryptedStreamStore) Remove(path string) error {
+	return fs.ss.Remove(path)
+}
+
+func (fs *encryptedStreamStore) Chmod(name string, mode os.FileMode) error {
+	return fs.ss.Chmod(name, mode)
+}
+
+// Truncate decrypts the whole file and encrypts it again at the new size.
+func (fs *encryptedStreamStore) Truncate(name string, size int64) error {
+	return TruncateBuffered(fs, name, size)
+}
+
+func (fs *encryptedStreamStore) Symlink(oldname, newname string) error {
+	return Symlink(fs.ss, oldname, newname)
+}
+
+func (fs *encryptedStreamStore) Readlink(name string) (string, error) {
+	return Readlink(fs.ss, name)
+}
+
+// Copy copies the encrypted content of src, which remains readable with the
+// same key.
+func (fs *encryptedStreamStore) Copy(src, dst string) error {
+	return fs.ss.Copy(src, dst)
+}
+
+// encryptedLayout returns the number of blocks in, and the plaintext size of,
+// an encrypted file of size bytes. ok is false if no encrypted file has that
+// size.
+func encryptedLayout(size int64) (blocks, plainSize int64, ok bool) {
+	data := size - int64(encryptedHeaderLen)
+	if data < encryptedTagSize {
+		return 0, 0, false
+	}
+	blocks = (data + encryptedSealedLen - 1) / encryptedSealedLen
+	last := data - (blocks-1)*encryptedSealedLen
+	if last < encryptedTagSize {
+		return 0, 0, false
+	}
+	return blocks, data - blocks*encryptedTagSize, true
+}
+
+// encryptedFileInfo returns fi with the size of the plaintext of the file it
+// describes, or fi itself for directories. Files which are not valid
+// encrypted files are reported as empty.
+func encryptedFileInfo(fi os.FileInfo) os.FileInfo {
+	if fi.IsDir() {
+		return fi
+	}
+	_, size, _ := encryptedLayout(fi.Size())
+	return &resizedFileInfo{fi, size}
+}
+
+type resizedFileInfo struct {
+	os.FileInfo
+	size int64
+}
+
+func (fi *resizedFileInfo) Size() int64 {
+	return fi.size
+}
+
+// blockNonce returns the nonce for block idx of a file with the header nonce
+// nonce.
+func blockNonce(nonce []byte, idx int64) []byte {
+	n := append([]byte(nil), nonce...)
+	var ib [8]byte
+	binary.BigEndian.PutUint64(ib[:], uint64(idx))
+	for i := range ib {
+		n[len(n)-8+i] ^= ib[i]
+	}
+	return n
+}
+
+// blockAdditionalData returns the data authenticated with block idx.
+func blockAdditionalData(idx int64, last bool) []byte {
+	ad := make([]byte, 9)
+	binary.BigEndian.PutUint64(ad, uint64(idx))
+	if last {
+		ad[8] = 1
+	}
+	return ad
+}
+
+type encryptedWriter struct {
+	aead  cipher.AEAD
+	dst   StrawWriter
+	nonce []byte
+	idx   int64
+	// buf holds plaintext not yet written. A full block is only sealed once
+	// more data arrives, since the last block must be marked as such.
+	buf    []byte
+	closed bool
+}
+
+func (w *encryptedWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, ErrClosed
+	}
+	n := 0
+	for len(p) > 0 {
+		if len(w.buf) == encryptedBlockSize {
+			if err := w.seal(false); err != nil {
+				return n, err
+			}
+		}
+		c := copy(w.buf[len(w.buf):cap(w.buf)], p)
+		w.buf = w.buf[:len(w.buf)+c]
+		p = p[c:]
+		n += c
+	}
+	return n, nil
+}
+
+// seal encrypts and writes the buffered plaintext as the next block.
+func (w *encryptedWriter) seal(last bool) error {
+	sealed := w.aead.Seal(nil, blockNonce(w.nonce, w.idx), w.buf, blockAdditionalData(w.idx, last))
+	if _, err := w.dst.Write(sealed); err != nil {
+		return err
+	}
+	w.idx++
+	w.buf = w.buf[:0]
+	return nil
+}
+
+func (w *encryptedWriter) Close() error {
+	if w.closed {
+		return ErrClosed
+	}
+	w.closed = true
+	if err := w.seal(true); err != nil {
+		w.dst.Close()
+		return err
+	}
+	return w.dst.Close()
+}
+
+type encryptedReader struct {
+	aead   cipher.AEAD
+	name   string
+	src    StrawReader
+	srcPos int64
+	nonce  []byte
+	blocks int64
+	size   int64
+	pos    int64
+
+	// the most recently decrypted block, used by Read.
+	cachedIdx int64
+	cached    []byte
+}
+
+// blockLen returns the length of the sealed block idx.
+func (r *encryptedReader) blockLen(idx int64) int64 {
+	if idx < r.blocks-1 {
+		return encryptedSealedLen
+	}
+	return r.size - idx*encryptedBlockSize + encryptedTagSize
+}
+
+func (r *encryptedReader) blockOffset(idx int64) int64 {
+	return int64(encryptedHeaderLen) + idx*encryptedSealedLen
+}
+
+func (r *encryptedReader) open(idx int64, sealed []byte) ([]byte, error) {
+	plain, err := r.aead.Open(sealed[:0], blockNonce(r.nonce, idx), sealed, blockAdditionalData(idx, idx == r.blocks-1))
+	if err != nil {
+		return nil, fmt.Errorf("%s : block %d failed authentication", r.name, idx)
+	}
+	return plain, nil
+}
+
+func (r *encryptedReader) Read(p []byte) (int, error) {
+	if r.src == nil {
+		return 0, ErrClosed
+	}
+	if r.pos >= r.size {
+		return 0, io.EOF
+	}
+	idx := r.pos / encryptedBlockSize
+	if idx != r.cachedIdx {
+		off := r.blockOffset(idx)
+		if off != r.srcPos {
+			if _, err := r.src.Seek(off, io.SeekStart); err != nil {
+				return 0, err
+			}
+			r.srcPos = off
+		}
+		sealed := make([]byte, r.blockLen(idx))
+		n, err := io.ReadFull(r.src, sealed)
+		r.srcPos += int64(n)
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		plain, err := r.open(idx, sealed)
+		if err != nil {
+			return 0, err
+		}
+		r.cachedIdx, r.cached = idx, plain
+	}
+	n := copy(p, r.cached[r.pos-idx*encryptedBlockSize:])
+	r.pos += int64(n)
+	return n, nil
+}
+
+// ReadAt decrypts the blocks spanning the requested range, reading them with
+// the underlying ReadAt, so that it may be called concurrently.
+func (r *encryptedReader) ReadAt(p []byte, off int64) (int, error) {
+	if r.src == nil {
+		return 0, ErrClosed
+	}
+	if off < 0 {
+		return 0, fmt.Errorf("%s : negative offset", r.name)
+	}
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		if pos >= r.size {
+			return n, io.EOF
+		}
+		idx := pos / encryptedBlockSize
+		sealed := make([]byte, r.blockLen(idx))
+		if _, err := r.src.ReadAt(sealed, r.blockOffset(idx)); err != nil && !(err == io.EOF && idx == r.blocks-1) {
+			return n, err
+		}
+		plain, err := r.open(idx, sealed)
+		if err != nil {
+			return n, err
+		}
+		n += copy(p[n:], plain[pos-idx*encryptedBlockSize:])
+	}
+	return n, nil
+}
+
+func (r *encryptedReader) Seek(offset int64, whence int) (int64, error) {
+	if r.src == nil {
+		return 0, ErrClosed
+	}
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.pos
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, fmt.Errorf("%s : invalid whence %d", r.name, whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("%s : negative position", r.name)
+	}
+	r.pos = offset
+	return offset, nil
+}
+
+func (r *encryptedReader) Close() error {
+	if r.src == nil {
+		return ErrClosed
+	}
+	src := r.src
+	r.src = nil
+	return src.Close()
+}
@@ -0,0 +1,114 @@
+package straw_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+var testKey = bytes.Repeat([]byte{7}, 32)
+
+func TestEncryptedStoreRoundTrip(t *testing.T) {
+	for _, size := range []int{0, 1, 64 << 10, 64<<10 + 1, 200 << 10} {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		mem, _ := straw.Open("mem://")
+		ss := straw.NewEncryptedStore(mem, testKey)
+
+		data := make([]byte, size)
+		rand.New(rand.NewSource(int64(size))).Read(data)
+		writeContent(t, ss, "/f", data)
+
+		raw, err := straw.ReadFile(mem, "/f")
+		require.NoError(err)
+		if size >= 16 {
+			assert.False(bytes.Contains(raw, data[:16]), "plaintext stored for size %d", size)
+		}
+
+		fi, err := ss.Stat("/f")
+		require.NoError(err)
+		assert.Equal(int64(size), fi.Size())
+		fis, err := ss.Readdir("/")
+		require.NoError(err)
+		assert.Equal(int64(size), fis[0].Size())
+
+		got, err := straw.ReadFile(ss, "/f")
+		require.NoError(err)
+		assert.True(bytes.Equal(data, got), "content mismatch for size %d", size)
+	}
+}
+
+func TestEncryptedStoreRandomAccess(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	mem, _ := straw.Open("mem://")
+	ss := straw.NewEncryptedStore(mem, testKey)
+
+	data := make([]byte, 300<<10)
+	rand.New(rand.NewSource(1)).Read(data)
+	writeContent(t, ss, "/f", data)
+
+	r, err := ss.OpenReadCloser("/f")
+	require.NoError(err)
+	defer r.Close()
+
+	buf := make([]byte, 100<<10)
+	n, err := r.ReadAt(buf, 50<<10)
+	require.NoError(err)
+	assert.True(bytes.Equal(data[50<<10:150<<10], buf[:n]))
+
+	n, err = r.ReadAt(buf, 250<<10)
+	assert.Equal(io.EOF, err)
+	assert.True(bytes.Equal(data[250<<10:], buf[:n]))
+
+	pos, err := r.Seek(-1000, io.SeekEnd)
+	require.NoError(err)
+	assert.Equal(int64(len(data)-1000), pos)
+	rest, err := ioutil.ReadAll(r)
+	require.NoError(err)
+	assert.True(bytes.Equal(data[len(data)-1000:], rest))
+
+	_, err = r.Seek(70<<10, io.SeekStart)
+	require.NoError(err)
+	_, err = io.ReadFull(r, buf[:10])
+	require.NoError(err)
+	assert.Equal(data[70<<10:70<<10+10], buf[:10])
+}
+
+func TestEncryptedStoreDetectsTampering(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	mem, _ := straw.Open("mem://")
+	ss := straw.NewEncryptedStore(mem, testKey)
+
+	data := make([]byte, 100<<10)
+	writeContent(t, ss, "/f", data)
+	raw, err := straw.ReadFile(mem, "/f")
+	require.NoError(err)
+
+	// truncation at a block boundary.
+	writeContent(t, mem, "/truncated", raw[:20+64<<10+16])
+	_, err = straw.ReadFile(ss, "/truncated")
+	assert.Error(err)
+
+	raw[len(raw)-1] ^= 1
+	writeContent(t, mem, "/flipped", raw)
+	_, err = straw.ReadFile(ss, "/flipped")
+	assert.Error(err)
+
+	_, err = straw.ReadFile(straw.NewEncryptedStore(mem, bytes.Repeat([]byte{8}, 32)), "/f")
+	assert.Error(err)
+
+	writeContent(t, mem, "/plain", []byte("hello"))
+	_, err = ss.OpenReadCloser("/plain")
+	assert.Error(err)
+}
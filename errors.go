@@ -0,0 +1,54 @@
+package straw
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+var (
+	// ErrNotSupported is returned when an operation is not supported by a
+	// StreamStore.
+	ErrNotSupported = errors.New("operation not supported")
+	// ErrInvalidPath is returned, wrapped, for paths rejected by CleanPath.
+	ErrInvalidPath = errors.New("invalid path")
+	// ErrTimeout is returned, wrapped, when WaitForExists gives up waiting,
+	// for operations exceeding Config.OpTimeout, and for calls exceeding
+	// the timeout of a store created with NewTimeoutStore.
+	ErrTimeout = errors.New("timed out")
+	// ErrNoSpace is returned, wrapped, by writes which would take a store
+	// beyond its capacity, such as a mem store created with MemMaxBytes.
+	ErrNoSpace = errors.New("no space left in store")
+	// ErrReadOnly is returned, wrapped, by the methods of a store created
+	// with NewReadOnlyStore which would modify it.
+	ErrReadOnly = errors.New("store is read-only")
+	// ErrChecksumMismatch is returned, wrapped in an *os.PathError, by
+	// Close of a writer created with CreateSumWriter when the content written
+	// does not have the expected checksum.
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	// ErrPreconditionFailed is returned, wrapped in an *os.PathError, when
+	// a write made with IfNotExists or IfMatch is refused because the file
+	// exists or has changed. The file is left as it was, so callers may
+	// read it again and retry.
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// ErrClosed is returned, possibly wrapped, by Write, WriteAt and Close
+	// of a writer or file which has already been closed, by every backend.
+	// It is os.ErrClosed, as returned for local files.
+	ErrClosed = os.ErrClosed
+	// ErrIsDirectory is returned, wrapped in an *os.PathError, when a file
+	// is opened or copied but the path is a directory. It is the errno
+	// returned for local files, so errors.Is matches those too.
+	ErrIsDirectory error = syscall.EISDIR
+	// ErrNotDirectory is returned, wrapped in an *os.PathError, when a path
+	// used as a directory, such as the parent of a file being created, is a
+	// file. It is the errno returned for local files.
+	ErrNotDirectory error = syscall.ENOTDIR
+	// ErrDirectoryNotEmpty is returned, wrapped in an *os.PathError, when
+	// removing a directory which still has entries. It is the errno returned
+	// for local files.
+	ErrDirectoryNotEmpty error = syscall.ENOTEMPTY
+	// ErrSymlinkLoop is reported, wrapped in an *os.PathError, by WalkFollow
+	// for a symbolic link to a directory which contains it. It is the errno
+	// returned for local files with too many levels of links to resolve.
+	ErrSymlinkLoop error = syscall.ELOOP
+)
@@ -0,0 +1,28 @@
+package straw
+
+import "os"
+
+// ExistsChecker is implemented by stores that can determine whether a path
+// exists more cheaply than a full Stat, such as object stores that can check
+// for an object with a HEAD request.
+type ExistsChecker interface {
+	Exists(name string) (bool, error)
+}
+
+// Exists reports whether a file or directory exists at name. It returns
+// false and a nil error if nothing does, and false with the error for any
+// other failure.
+// If ss implements ExistsChecker, its Exists method is used, otherwise the
+// result is derived from Stat.
+func Exists(ss StreamStore, name string) (bool, error) {
+	if ec, ok := ss.(ExistsChecker); ok {
+		return ec.Exists(name)
+	}
+	if _, err := ss.Stat(name); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,36 @@
+package straw_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+func TestExists(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	mem, _ := straw.Open("mem://")
+	require.NoError(mem.Mkdir("/dir", 0755))
+	writeContent(t, mem, "/dir/a", []byte("hello"))
+
+	for name, want := range map[string]bool{
+		"/":        true,
+		"/dir":     true,
+		"/dir/a":   true,
+		"/dir/b":   false,
+		"/missing": false,
+	} {
+		exists, err := straw.Exists(mem, name)
+		require.NoError(err, name)
+		assert.Equal(want, exists, name)
+	}
+
+	// errors other than the file not existing are returned.
+	exists, err := straw.Exists(&flakyStreamStore{StreamStore: mem, failures: 1}, "/dir/a")
+	assert.True(errors.Is(err, errTransient))
+	assert.False(exists)
+}
@@ -0,0 +1,18 @@
+package straw
+
+// Flusher is implemented by writers which can be asked to pass on the data
+// written so far, without ending the file. Callers may type assert a
+// StrawWriter to Flusher, and skip flushing where it is not implemented.
+//
+// Files of the local filesystem are synced to disk, though an atomic store's
+// writer still only renames its file into place on Close. Writers from
+// NewBufferedWriter write out their buffer, and flush the writer beneath if
+// it can be. On the s3 backend, only writers from AppendWriteCloser which
+// append to an existing object implement it, uploading what is buffered as a
+// part if there is enough for one. Other s3 writers, and those of gcs, do not
+// implement it, as their uploaders send each part only once it is full. What
+// has been flushed to an object store is not visible until the writer is
+// closed.
+type Flusher interface {
+	Flush() error
+}
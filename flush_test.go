@@ -0,0 +1,73 @@
+package straw_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+// flushingWriter is a recordingWriter which counts calls to Flush.
+type flushingWriter struct {
+	recordingWriter
+	flushes int
+}
+
+func (w *flushingWriter) Flush() error {
+	w.flushes++
+	return nil
+}
+
+func TestBufferedWriterFlush(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	fw := &flushingWriter{}
+	w := straw.NewBufferedWriter(fw, 8)
+	_, err := w.Write([]byte("abc"))
+	require.NoError(err)
+	assert.Empty(fw.data)
+
+	require.NoError(w.(straw.Flusher).Flush())
+	assert.Equal("abc", string(fw.data))
+	assert.Equal(1, fw.flushes)
+
+	// writers which can't be flushed just get the buffer.
+	rw := &recordingWriter{}
+	w = straw.NewBufferedWriter(rw, 8)
+	_, err = w.Write([]byte("abc"))
+	require.NoError(err)
+	require.NoError(w.(straw.Flusher).Flush())
+	assert.Equal("abc", string(rw.data))
+
+	require.NoError(w.Close())
+	assert.Equal(straw.ErrClosed, w.(straw.Flusher).Flush())
+}
+
+func TestOSFlush(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := tempDir()
+	for _, rawurl := range []string{"file://", "file://?atomic=true", "file://?sync=true"} {
+		ss, err := straw.Open(rawurl)
+		require.NoError(err)
+		name := filepath.Join(dir, "a")
+
+		w, err := ss.CreateWriteCloser(name)
+		require.NoError(err, rawurl)
+		_, err = w.Write([]byte("hello"))
+		require.NoError(err)
+		f, ok := w.(straw.Flusher)
+		require.True(ok, rawurl)
+		require.NoError(f.Flush(), rawurl)
+		require.NoError(w.Close())
+
+		data, err := ioutil.ReadFile(name)
+		require.NoError(err)
+		assert.Equal("hello", string(data), rawurl)
+	}
+}
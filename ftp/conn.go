@@ -0,0 +1,262 @@
+package ftp
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// conn is a control connection to an FTP server, logged in and set to binary
+// transfers. It can carry one transfer at a time, so the store keeps a pool
+// of them.
+type conn struct {
+	nc   net.Conn
+	text *textproto.Conn
+	host string
+	// tlsConfig is that used for the control connection and every data
+	// connection, or nil for plain FTP.
+	tlsConfig *tls.Config
+	// noEPSV is set once the server rejects EPSV, so PASV is used instead.
+	noEPSV bool
+}
+
+// dial connects and logs in to the server at addr. With a tlsConfig, the
+// connection is upgraded with AUTH TLS before logging in, as explicit FTPS,
+// and data connections are protected too.
+func dial(addr, user, password string, tlsConfig *tls.Config) (*conn, error) {
+	nc, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	host, _, _ := net.SplitHostPort(addr)
+	c := &conn{nc: nc, text: textproto.NewConn(nc), host: host}
+	if err := c.login(user, password, tlsConfig); err != nil {
+		c.nc.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *conn) login(user, password string, tlsConfig *tls.Config) error {
+	if _, _, err := c.text.ReadResponse(2); err != nil {
+		return err
+	}
+	if tlsConfig != nil {
+		if _, err := c.cmd(2, "AUTH TLS"); err != nil {
+			return err
+		}
+		c.tlsConfig = tlsConfig
+		c.nc = tls.Client(c.nc, tlsConfig)
+		c.text = textproto.NewConn(c.nc)
+		if _, err := c.cmd(2, "PBSZ 0"); err != nil {
+			return err
+		}
+		if _, err := c.cmd(2, "PROT P"); err != nil {
+			return err
+		}
+	}
+	code, err := c.cmd(0, "USER %s", user)
+	switch {
+	case err != nil:
+		return err
+	case code == 331:
+		if _, err := c.cmd(2, "PASS %s", password); err != nil {
+			return err
+		}
+	case code/100 != 2:
+		return &textproto.Error{Code: code, Msg: "login failed"}
+	}
+	_, err = c.cmd(2, "TYPE I")
+	return err
+}
+
+// cmd sends a command and reads the response, which must have the code
+// expectCode, or begin with its digits if it has fewer, unless it is 0. A
+// response with another code is returned as a *textproto.Error.
+func (c *conn) cmd(expectCode int, format string, args ...interface{}) (int, error) {
+	code, _, err := c.cmdMsg(expectCode, format, args...)
+	return code, err
+}
+
+func (c *conn) cmdMsg(expectCode int, format string, args ...interface{}) (int, string, error) {
+	id, err := c.text.Cmd(format, args...)
+	if err != nil {
+		return 0, "", err
+	}
+	c.text.StartResponse(id)
+	defer c.text.EndResponse(id)
+	return c.text.ReadResponse(expectCode)
+}
+
+// size returns the size of the named file, with SIZE.
+func (c *conn) size(name string) (int64, error) {
+	_, msg, err := c.cmdMsg(213, "SIZE %s", name)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(msg), 10, 64)
+}
+
+// modTime returns the modification time of the named file, with MDTM.
+func (c *conn) modTime(name string) (time.Time, error) {
+	_, msg, err := c.cmdMsg(213, "MDTM %s", name)
+	if err != nil {
+		return time.Time{}, err
+	}
+	// the time may have a fraction of a second, which is dropped.
+	msg = strings.TrimSpace(msg)
+	if i := strings.IndexByte(msg, '.'); i != -1 {
+		msg = msg[:i]
+	}
+	return time.Parse("20060102150405", msg)
+}
+
+// list returns the lines of a LIST of the named directory. The directory is
+// made the working directory and listed without an argument, as servers
+// differ in whether they treat an argument as a path or as options to ls,
+// and list a file given as an argument rather than failing.
+func (c *conn) list(name string) ([]string, error) {
+	if _, err := c.cmd(2, "CWD %s", name); err != nil {
+		return nil, err
+	}
+	dc, err := c.transfer(0, "LIST")
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	r := textproto.NewReader(bufio.NewReader(dc))
+	for {
+		line, err := r.ReadLine()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			dc.Close()
+			c.finish()
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+	dc.Close()
+	return lines, c.finish()
+}
+
+// transfer opens a data connection, and sends the command for a transfer
+// over it, first sending REST to start at offset if it is not zero. Once
+// the caller is done with the data connection, it must close it and call
+// finish.
+func (c *conn) transfer(offset int64, format string, args ...interface{}) (net.Conn, error) {
+	dc, err := c.dataConn()
+	if err != nil {
+		return nil, err
+	}
+	if offset != 0 {
+		if _, err := c.cmd(350, "REST %d", offset); err != nil {
+			dc.Close()
+			return nil, err
+		}
+	}
+	if _, err := c.cmd(1, format, args...); err != nil {
+		dc.Close()
+		return nil, err
+	}
+	if c.tlsConfig != nil {
+		dc = tls.Client(dc, c.tlsConfig)
+	}
+	return dc, nil
+}
+
+// finish reads the response which ends a transfer, once its data connection
+// has been closed. Closing the data connection of a RETR early aborts it,
+// which servers report as a failure, so the caller may ignore the error.
+func (c *conn) finish() error {
+	_, _, err := c.text.ReadResponse(2)
+	return err
+}
+
+// dataConn opens a passive data connection, with EPSV if the server
+// supports it, and otherwise PASV. The address reported by PASV is ignored
+// in favour of the host of the control connection, as servers behind NAT
+// often report a private address.
+func (c *conn) dataConn() (net.Conn, error) {
+	var port int
+	var err error
+	if !c.noEPSV {
+		port, err = c.epsv()
+		if isSyntaxError(err) {
+			c.noEPSV = true
+		}
+	}
+	if c.noEPSV {
+		port, err = c.pasv()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return net.Dial("tcp", net.JoinHostPort(c.host, strconv.Itoa(port)))
+}
+
+// epsv returns the port from an EPSV response, which is of the form
+// "229 Entering Extended Passive Mode (|||port|)".
+func (c *conn) epsv() (int, error) {
+	_, msg, err := c.cmdMsg(229, "EPSV")
+	if err != nil {
+		return 0, err
+	}
+	start, end := strings.Index(msg, "(|||"), strings.LastIndex(msg, "|)")
+	if start == -1 || end < start {
+		return 0, fmt.Errorf("invalid EPSV response: %q", msg)
+	}
+	return strconv.Atoi(msg[start+4 : end])
+}
+
+// pasv returns the port from a PASV response, which is of the form
+// "227 Entering Passive Mode (h1,h2,h3,h4,p1,p2)".
+func (c *conn) pasv() (int, error) {
+	_, msg, err := c.cmdMsg(227, "PASV")
+	if err != nil {
+		return 0, err
+	}
+	start, end := strings.Index(msg, "("), strings.LastIndex(msg, ")")
+	if start == -1 || end < start {
+		return 0, fmt.Errorf("invalid PASV response: %q", msg)
+	}
+	fields := strings.Split(msg[start+1:end], ",")
+	if len(fields) != 6 {
+		return 0, fmt.Errorf("invalid PASV response: %q", msg)
+	}
+	p1, err1 := strconv.Atoi(fields[4])
+	p2, err2 := strconv.Atoi(fields[5])
+	if err1 != nil || err2 != nil {
+		return 0, fmt.Errorf("invalid PASV response: %q", msg)
+	}
+	return p1<<8 | p2, nil
+}
+
+// quit logs out and closes the connection.
+func (c *conn) quit() error {
+	c.cmd(0, "QUIT")
+	return c.text.Close()
+}
+
+// isSyntaxError reports whether err is a 5xx response for a command the
+// server does not recognise or implement.
+func isSyntaxError(err error) bool {
+	var e *textproto.Error
+	return errors.As(err, &e) && (e.Code == 500 || e.Code == 501 || e.Code == 502)
+}
+
+// isUnavailable reports whether err is a 550 response, which servers give
+// for files which do not exist, and for most other failures of a command
+// on a path.
+func isUnavailable(err error) bool {
+	var e *textproto.Error
+	return errors.As(err, &e) && e.Code == 550
+}
@@ -0,0 +1,177 @@
+package ftp
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ftpStatResult struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func (sr *ftpStatResult) Name() string {
+	return sr.name
+}
+
+func (sr *ftpStatResult) IsDir() bool {
+	return sr.mode.IsDir()
+}
+
+func (sr *ftpStatResult) Size() int64 {
+	if sr.IsDir() {
+		return 4096
+	}
+	return sr.size
+}
+
+func (sr *ftpStatResult) ModTime() time.Time {
+	return sr.modTime
+}
+
+func (sr *ftpStatResult) Mode() os.FileMode {
+	return sr.mode
+}
+
+func (sr *ftpStatResult) Sys() interface{} {
+	return nil
+}
+
+var months = map[string]time.Month{
+	"jan": time.January, "feb": time.February, "mar": time.March,
+	"apr": time.April, "may": time.May, "jun": time.June,
+	"jul": time.July, "aug": time.August, "sep": time.September,
+	"oct": time.October, "nov": time.November, "dec": time.December,
+}
+
+// parseListLine parses a line of a LIST response in the format of ls -l,
+// which nearly every server uses, such as
+//
+//	-rw-r--r--    1 owner    group        1234 Jan  2 15:04 name
+//
+// with or without the group. It reports false for lines which are not
+// entries, such as the "total" line, and for the "." and ".." entries. The
+// target of a symbolic link is dropped from its name. Times are taken to be
+// UTC, and only have the precision of a minute, or of a day for those more
+// than six months old, which are listed with a year rather than a time.
+func parseListLine(line string, now time.Time) (*ftpStatResult, bool) {
+	// the month follows the size, which follows the group, if there is one.
+	fields, rest := splitFields(line, 8)
+	i := 5
+	if !isListMonth(fields, i) {
+		fields, rest = splitFields(line, 7)
+		i = 4
+		if !isListMonth(fields, i) {
+			return nil, false
+		}
+	}
+	if len(fields[0]) < 10 {
+		return nil, false
+	}
+	size, err := strconv.ParseInt(fields[i-1], 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	modTime, ok := parseListTime(fields[i], fields[i+1], fields[i+2], now)
+	if !ok {
+		return nil, false
+	}
+
+	mode, ok := parseListMode(fields[0])
+	if !ok {
+		return nil, false
+	}
+	name := rest
+	if mode&os.ModeSymlink != 0 {
+		if j := strings.Index(name, " -> "); j != -1 {
+			name = name[:j]
+		}
+	}
+	if name == "" || name == "." || name == ".." {
+		return nil, false
+	}
+	return &ftpStatResult{name: name, size: size, mode: mode, modTime: modTime}, true
+}
+
+// isListMonth reports whether fields has a month at i, followed by the day
+// and time or year.
+func isListMonth(fields []string, i int) bool {
+	if len(fields) < i+3 {
+		return false
+	}
+	_, ok := months[strings.ToLower(fields[i])]
+	return ok
+}
+
+// splitFields returns the first n space separated fields of line, and the
+// rest of the line after the space following them, which may itself contain
+// spaces.
+func splitFields(line string, n int) ([]string, string) {
+	var fields []string
+	for len(fields) < n {
+		line = strings.TrimLeft(line, " ")
+		j := strings.IndexByte(line, ' ')
+		if j == -1 {
+			if line != "" {
+				fields = append(fields, line)
+			}
+			return fields, ""
+		}
+		fields = append(fields, line[:j])
+		line = line[j+1:]
+	}
+	return fields, line
+}
+
+// parseListTime parses the month, day and time or year of a LIST entry.
+// Entries with a time are from the last six months, so are given the year
+// which makes them no later than a day after now, allowing for time zones.
+func parseListTime(month, day, timeOrYear string, now time.Time) (time.Time, bool) {
+	m := months[strings.ToLower(month)]
+	d, err := strconv.Atoi(day)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if j := strings.IndexByte(timeOrYear, ':'); j != -1 {
+		hour, err1 := strconv.Atoi(timeOrYear[:j])
+		min, err2 := strconv.Atoi(timeOrYear[j+1:])
+		if err1 != nil || err2 != nil {
+			return time.Time{}, false
+		}
+		t := time.Date(now.Year(), m, d, hour, min, 0, 0, time.UTC)
+		if t.After(now.Add(24 * time.Hour)) {
+			t = t.AddDate(-1, 0, 0)
+		}
+		return t, true
+	}
+	year, err := strconv.Atoi(timeOrYear)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Date(year, m, d, 0, 0, 0, 0, time.UTC), true
+}
+
+// parseListMode parses the type and permissions of a LIST entry, such as
+// "drwxr-xr-x".
+func parseListMode(s string) (os.FileMode, bool) {
+	var mode os.FileMode
+	switch s[0] {
+	case '-':
+	case 'd':
+		mode |= os.ModeDir
+	case 'l':
+		mode |= os.ModeSymlink
+	default:
+		return 0, false
+	}
+	for i, c := range s[1:10] {
+		if c != '-' {
+			mode |= 1 << uint(8-i)
+		}
+	}
+	return mode, true
+}
@@ -0,0 +1,227 @@
+package ftp
+
+import (
+	"crypto/tls"
+	"io"
+	"io/ioutil"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+	"github.com/uw-labs/straw/internal/fakeftp"
+)
+
+// newTestStreamStore returns a store on a fake server serving a temporary
+// directory, with configure applied to the server before it is used.
+func newTestStreamStore(t *testing.T, configure func(*fakeftp.Server), query string) (*ftpStreamStore, *fakeftp.Server, string, func()) {
+	root, err := ioutil.TempDir("", "straw_ftp_test")
+	require.NoError(t, err)
+
+	srv, err := fakeftp.New(root, "test", "tiger")
+	require.NoError(t, err)
+	if configure != nil {
+		configure(srv)
+	}
+
+	u, err := url.Parse("ftp://test:tiger@" + srv.Addr() + "/?" + query)
+	require.NoError(t, err)
+
+	ss, err := newFTPStreamStore(u)
+	require.NoError(t, err)
+	return ss, srv, root, func() {
+		ss.Close()
+		srv.Close()
+		os.RemoveAll(root)
+	}
+}
+
+func writeTestFile(t *testing.T, ss straw.StreamStore, name string, data []byte) {
+	w, err := ss.CreateWriteCloser(name)
+	require.NoError(t, err)
+	_, err = w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+}
+
+func TestParseListLine(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		line string
+		want *ftpStatResult
+	}{{
+		line: "-rw-r--r--    1 owner    group        1234 Jan  2 15:04 a file",
+		want: &ftpStatResult{name: "a file", size: 1234, mode: 0644, modTime: time.Date(2020, 1, 2, 15, 4, 0, 0, time.UTC)},
+	}, {
+		// times later than now are from the previous year.
+		line: "-rw------- 1 owner group 5 Dec 31 23:59 old",
+		want: &ftpStatResult{name: "old", size: 5, mode: 0600, modTime: time.Date(2019, 12, 31, 23, 59, 0, 0, time.UTC)},
+	}, {
+		line: "drwxr-xr-x 2 owner 4096 Jun 30  2015 nogroup",
+		want: &ftpStatResult{name: "nogroup", size: 4096, mode: os.ModeDir | 0755, modTime: time.Date(2015, 6, 30, 0, 0, 0, 0, time.UTC)},
+	}, {
+		line: "lrwxrwxrwx 1 owner group 4 Feb  9 08:00 link -> file",
+		want: &ftpStatResult{name: "link", size: 4, mode: os.ModeSymlink | 0777, modTime: time.Date(2020, 2, 9, 8, 0, 0, 0, time.UTC)},
+	}, {
+		line: "total 12",
+	}, {
+		line: "drwxr-xr-x 2 owner group 4096 Jan  2 15:04 ..",
+	}, {
+		line: "not a listing",
+	}} {
+		got, ok := parseListLine(tc.line, now)
+		assert.Equal(tc.want != nil, ok, tc.line)
+		assert.Equal(tc.want, got, tc.line)
+	}
+}
+
+func TestStat(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, _, root, closeFn := newTestStreamStore(t, nil, "")
+	defer closeFn()
+	writeTestFile(t, ss, "/file", []byte("hello"))
+	mtime := time.Date(2015, 6, 30, 12, 34, 56, 0, time.UTC)
+	require.NoError(os.Chtimes(filepath.Join(root, "file"), mtime, mtime))
+
+	// the time is that from MDTM, to the second.
+	fi, err := ss.Stat("/file")
+	require.NoError(err)
+	assert.Equal("file", fi.Name())
+	assert.Equal(int64(5), fi.Size())
+	assert.True(mtime.Equal(fi.ModTime()))
+
+	fis, err := ss.Readdir("/")
+	require.NoError(err)
+	require.Len(fis, 1)
+	assert.True(mtime.Truncate(24 * time.Hour).Equal(fis[0].ModTime()))
+
+	require.NoError(os.Symlink("file", filepath.Join(root, "link")))
+	fi, err = ss.Lstat("/link")
+	require.NoError(err)
+	assert.Equal(os.ModeSymlink, fi.Mode()&os.ModeSymlink)
+	fi, err = ss.Stat("/link")
+	require.NoError(err)
+	assert.True(fi.Mode().IsRegular())
+	assert.Equal(int64(5), fi.Size())
+}
+
+func TestReadAtUsesRest(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, srv, _, closeFn := newTestStreamStore(t, nil, "")
+	defer closeFn()
+	writeTestFile(t, ss, "/file", []byte("0123456789"))
+
+	r, err := ss.OpenReadCloser("/file")
+	require.NoError(err)
+	defer r.Close()
+
+	buf := make([]byte, 4)
+	n, err := r.ReadAt(buf, 3)
+	require.NoError(err)
+	assert.Equal("3456", string(buf[:n]))
+	n, err = r.ReadAt(buf, 8)
+	assert.Equal(io.EOF, err)
+	assert.Equal("89", string(buf[:n]))
+
+	// a Read after a Seek resumes from the new offset.
+	_, err = r.Seek(6, io.SeekStart)
+	require.NoError(err)
+	n, err = r.Read(buf)
+	require.NoError(err)
+	assert.Equal("6789", string(buf[:n]))
+
+	var rests []string
+	for _, cmd := range srv.Commands() {
+		if strings.HasPrefix(cmd, "REST ") {
+			rests = append(rests, cmd)
+		}
+	}
+	assert.Equal([]string{"REST 3", "REST 8", "REST 6"}, rests)
+}
+
+func TestConcurrentReaders(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, _, _, closeFn := newTestStreamStore(t, nil, "")
+	defer closeFn()
+	writeTestFile(t, ss, "/a", []byte("aaaa"))
+	writeTestFile(t, ss, "/b", []byte("bbbb"))
+
+	// each open reader holds a connection of its own.
+	ra, err := ss.OpenReadCloser("/a")
+	require.NoError(err)
+	defer ra.Close()
+	rb, err := ss.OpenReadCloser("/b")
+	require.NoError(err)
+	defer rb.Close()
+
+	buf := make([]byte, 2)
+	for _, r := range []straw.StrawReader{ra, rb, ra, rb} {
+		_, err := io.ReadFull(r, buf)
+		require.NoError(err)
+	}
+	assert.Equal("bb", string(buf))
+
+	fis, err := ss.Readdir("/")
+	require.NoError(err)
+	assert.Len(fis, 2)
+}
+
+func TestPASV(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, _, _, closeFn := newTestStreamStore(t, func(srv *fakeftp.Server) { srv.DisableEPSV = true }, "")
+	defer closeFn()
+	writeTestFile(t, ss, "/file", []byte("hello"))
+
+	data, err := straw.ReadFile(ss, "/file")
+	require.NoError(err)
+	assert.Equal("hello", string(data))
+}
+
+func TestTLS(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// borrow the certificate of an httptest server.
+	hs := httptest.NewTLSServer(nil)
+	config := &tls.Config{Certificates: hs.TLS.Certificates}
+	hs.Close()
+
+	ss, srv, _, closeFn := newTestStreamStore(t, func(srv *fakeftp.Server) { srv.TLSConfig = config }, "tls=true&insecure_skip_verify=true")
+	defer closeFn()
+	writeTestFile(t, ss, "/file", []byte("hello"))
+
+	data, err := straw.ReadFile(ss, "/file")
+	require.NoError(err)
+	assert.Equal("hello", string(data))
+	assert.Contains(srv.Commands(), "AUTH TLS")
+	assert.Contains(srv.Commands(), "PROT P")
+}
+
+func TestLoginFailed(t *testing.T) {
+	_, srv, _, closeFn := newTestStreamStore(t, nil, "")
+	defer closeFn()
+
+	_, err := straw.Open("ftp://test:wrong@" + srv.Addr() + "/")
+	assert.Error(t, err)
+}
+
+func TestInvalidTLS(t *testing.T) {
+	_, err := straw.Open("ftp://localhost/?tls=maybe")
+	assert.Error(t, err)
+}
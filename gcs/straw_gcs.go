@@ -1,68 +1,302 @@
 package gcs
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"cloud.google.com/go/storage"
 	"github.com/uw-labs/straw"
+	"github.com/uw-labs/straw/internal/bytesize"
 	"google.golang.org/api/googleapi"
 	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 )
 
 var _ straw.StreamStore = &gcsStreamStore{}
+var _ straw.ContextStreamStore = &gcsStreamStore{}
+var _ straw.Taggable = &gcsStreamStore{}
+var _ straw.ExistsChecker = &gcsStreamStore{}
+var _ straw.AppendStore = &gcsStreamStore{}
+var _ straw.Checksummer = &gcsStreamStore{}
+var _ straw.PrefixLister = &gcsStreamStore{}
+var _ straw.ReaddirEacher = &gcsStreamStore{}
+var _ straw.Hasher = &gcsStreamStore{}
+
+// GCS has no native object tags, so tags are stored as custom metadata with
+// this prefix on the key.
+const tagMetadataPrefix = "tag-"
+
+// GCS has no Expires attribute, so the time set with straw.WithExpires is
+// stored in RFC 3339 format as custom metadata under this key.
+const expiresMetadataKey = "expires"
+
+// The permission bits set with Chmod are stored in octal as custom metadata
+// under this key. Files without it have mode 0644.
+const modeMetadataKey = "mode"
+
+// small_object_threshold is the size, such as "1MB", below which objects are
+// fetched whole on first read and served from memory thereafter.
+const smallObjectThresholdQueryParam = "small_object_threshold"
+
+// dir_mode selects how directories are represented. In "marker" mode (the
+// default) Mkdir creates a zero-byte object whose key ends in a slash, as the
+// Google Cloud console does for a new folder, and in "prefix" mode no objects
+// are created, and directories exist only by virtue of the objects beneath
+// them, as with tools which have no notion of directories. In prefix mode,
+// Mkdir therefore has no visible effect until a file is created within the
+// directory, and a directory vanishes once it no longer contains any files.
+// Whatever the mode, Stat and Readdir report a directory wherever there is a
+// marker or an object beneath it, so a bucket written in one mode, or by other
+// tools, reads the same in the other. Where other tools have made an object
+// and a directory of the same name, Stat reports the directory.
+const dirModeQueryParam = "dir_mode"
+
+const (
+	dirModeMarker = "marker"
+	dirModePrefix = "prefix"
+)
+
+// anonymous, if true, accesses the bucket without credentials, so only public
+// objects can be read, and the store cannot write.
+const anonymousQueryParam = "anonymous"
+
+// csek is a customer-supplied encryption key, a base64 encoded 256 bit AES
+// key, with which objects are encrypted when written, and which is needed to
+// read them. Standard and URL safe encodings are accepted, though a standard
+// encoding must be escaped in a URL.
+const csekQueryParam = "csek"
+
+// kmskey is the name of a Cloud KMS key, of the form
+// "projects/P/locations/L/keyRings/R/cryptoKeys/K", with which objects are
+// encrypted when written, in place of the default key of the bucket. Objects
+// are decrypted with their KMS key when read, so it is not needed to read.
+const kmsKeyQueryParam = "kmskey"
+
+// ErrEncryptionKey is returned, wrapped in an *os.PathError along with the
+// error from GCS, when reading an object encrypted with a customer-supplied
+// encryption key fails because the store was not given the key, or was given
+// a different one.
+var ErrEncryptionKey = errors.New("missing or wrong customer-supplied encryption key")
 
 func init() {
 	straw.Register("gs", func(u *url.URL) (straw.StreamStore, error) {
-		creds := u.Query().Get("credentialsfile")
-		if creds == "" {
-			return nil, fmt.Errorf("gs URLs must provide a `credentialsfile` parameter")
-		}
-		return newGCSStreamStore(creds, u.Host)
+		return newGCSStreamStore(u)
 	})
 }
 
-func newGCSStreamStore(credentialsFile string, bucket string) (*gcsStreamStore, error) {
+// GCSAnonymous returns an Option which accesses the bucket without
+// credentials, for reading public buckets. Operations which would modify the
+// bucket fail with an error satisfying os.IsPermission.
+func GCSAnonymous() straw.Option {
+	return straw.QueryOption(anonymousQueryParam, "true")
+}
+
+// GCSEncryptionKey returns an Option which encrypts the objects written with
+// key, a customer-supplied 256 bit AES key, which GCS does not store, so the
+// same key must be given to read them.
+func GCSEncryptionKey(key []byte) straw.Option {
+	return straw.QueryOption(csekQueryParam, base64.StdEncoding.EncodeToString(key))
+}
+
+// GCSKMSKey returns an Option which encrypts the objects written with the
+// named Cloud KMS key, of the form
+// "projects/P/locations/L/keyRings/R/cryptoKeys/K", rather than the default
+// key of the bucket.
+func GCSKMSKey(name string) straw.Option {
+	return straw.QueryOption(kmsKeyQueryParam, name)
+}
+
+// GCSDirMode returns an Option which selects how directories are represented,
+// either "marker" or "prefix", as described for the dir_mode query
+// parameter.
+func GCSDirMode(mode string) straw.Option {
+	return straw.QueryOption(dirModeQueryParam, mode)
+}
+
+// newGCSStreamStore opens the store described by u, with opts added to those
+// of the client, so tests can point it at a fake server.
+func newGCSStreamStore(u *url.URL, opts ...option.ClientOption) (*gcsStreamStore, error) {
+	q := u.Query()
+
+	var anonymous bool
+	if a := q.Get(anonymousQueryParam); a != "" {
+		var err error
+		if anonymous, err = strconv.ParseBool(a); err != nil {
+			return nil, fmt.Errorf("invalid %q query parameter: %w", anonymousQueryParam, err)
+		}
+	}
+
+	creds := q.Get("credentialsfile")
+	if creds == "" && !anonymous {
+		return nil, fmt.Errorf("gs URLs must provide a `credentialsfile` parameter, or set `anonymous=true`")
+	}
+
+	dirMode := q.Get(dirModeQueryParam)
+	switch dirMode {
+	case "":
+		dirMode = dirModeMarker
+	case dirModeMarker, dirModePrefix:
+	default:
+		return nil, fmt.Errorf("invalid %q query parameter: unknown mode %q", dirModeQueryParam, dirMode)
+	}
+
+	var threshold int64
+	if t := q.Get(smallObjectThresholdQueryParam); t != "" {
+		var err error
+		if threshold, err = bytesize.Parse(t); err != nil {
+			return nil, fmt.Errorf("invalid %q query parameter: %w", smallObjectThresholdQueryParam, err)
+		}
+	}
+
+	var csek []byte
+	if k := q.Get(csekQueryParam); k != "" {
+		var err error
+		if csek, err = base64.StdEncoding.DecodeString(k); err != nil {
+			if csek, err = base64.URLEncoding.DecodeString(k); err != nil {
+				return nil, fmt.Errorf("invalid %q query parameter: %w", csekQueryParam, err)
+			}
+		}
+		if len(csek) != 32 {
+			return nil, fmt.Errorf("invalid %q query parameter: key is %d bytes, not 32", csekQueryParam, len(csek))
+		}
+	}
+	kmsKey := q.Get(kmsKeyQueryParam)
+	if csek != nil && kmsKey != "" {
+		return nil, fmt.Errorf("the %q and %q query parameters cannot both be set", csekQueryParam, kmsKeyQueryParam)
+	}
+
 	ctx := context.Background()
-	gcsClient, err := storage.NewClient(ctx, option.WithCredentialsFile(credentialsFile))
+	auth := option.WithCredentialsFile(creds)
+	if anonymous {
+		auth = option.WithoutAuthentication()
+	}
+	gcsClient, err := storage.NewClient(ctx, append([]option.ClientOption{auth}, opts...)...)
 	if err != nil {
 		return nil, err
 	}
 
 	ss := &gcsStreamStore{
-		client: gcsClient,
-		bucket: bucket,
-		ctx:    ctx,
+		client:               gcsClient,
+		bucket:               u.Host,
+		ctx:                  ctx,
+		smallObjectThreshold: threshold,
+		dirMode:              dirMode,
+		anonymous:            anonymous,
+		csek:                 csek,
+		kmsKey:               kmsKey,
 	}
 
 	return ss, nil
 }
 
 type gcsStreamStore struct {
-	client *storage.Client
-	bucket string
-	ctx    context.Context
+	client               *storage.Client
+	bucket               string
+	ctx                  context.Context
+	smallObjectThreshold int64
+	dirMode              string
+	anonymous            bool
+	csek                 []byte
+	kmsKey               string
+}
+
+// object returns the handle of the named object, with the customer-supplied
+// encryption key of the store, if any.
+func (fs *gcsStreamStore) object(name string) *storage.ObjectHandle {
+	obj := fs.client.Bucket(fs.bucket).Object(name)
+	if fs.csek != nil {
+		obj = obj.Key(fs.csek)
+	}
+	return obj
+}
+
+// newWriter returns a writer to obj which encrypts with the KMS key of the
+// store, if any.
+func (fs *gcsStreamStore) newWriter(ctx context.Context, obj *storage.ObjectHandle) *storage.Writer {
+	w := obj.NewWriter(ctx)
+	w.KMSKeyName = fs.kmsKey
+	return w
+}
+
+// readError returns the error for op on name from an error reading an
+// object.
+func readError(op, name string, err error) error {
+	if err == storage.ErrObjectNotExist {
+		return &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+	}
+	if isEncryptionKeyError(err) {
+		return &os.PathError{Op: op, Path: name, Err: fmt.Errorf("%w: %v", ErrEncryptionKey, err)}
+	}
+	return err
+}
+
+// isEncryptionKeyError reports whether err is GCS rejecting a read for want
+// of the right customer-supplied encryption key.
+func isEncryptionKeyError(err error) bool {
+	e, ok := err.(*googleapi.Error)
+	if !ok || e.Code != http.StatusBadRequest {
+		return false
+	}
+	msg := strings.ToLower(e.Message + e.Body)
+	for _, item := range e.Errors {
+		msg += strings.ToLower(item.Reason + item.Message)
+	}
+	return strings.Contains(msg, "encryptionkey") || strings.Contains(msg, "encryption key")
+}
+
+// checkWritable returns an error for op on name if the store is anonymous, as
+// anonymous callers cannot modify a bucket.
+func (fs *gcsStreamStore) checkWritable(op, name string) error {
+	if fs.anonymous {
+		return &os.PathError{Op: op, Path: name, Err: os.ErrPermission}
+	}
+	return nil
 }
 
 func (fs *gcsStreamStore) Close() error {
 	return fs.client.Close()
 }
 
+func (fs *gcsStreamStore) Scheme() string {
+	return "gs"
+}
+
+// Lstat is Stat, as GCS has no symbolic links.
 func (fs *gcsStreamStore) Lstat(name string) (os.FileInfo, error) {
-	// GCS does not support symlinks
-	return fs.Stat(name)
+	return fs.LstatContext(fs.ctx, name)
+}
+
+func (fs *gcsStreamStore) LstatContext(ctx context.Context, name string) (os.FileInfo, error) {
+	return fs.StatContext(ctx, name)
 }
 
 func (fs *gcsStreamStore) Stat(name string) (os.FileInfo, error) {
-	name = fs.noSlashPrefix(name)
+	return fs.StatContext(fs.ctx, name)
+}
+
+func (fs *gcsStreamStore) StatContext(ctx context.Context, name string) (os.FileInfo, error) {
+	path, err := fs.cleanPath(name)
+	if err != nil {
+		return nil, err
+	}
+	name = fs.noSlashPrefix(path)
 	name = fs.noSlashSuffix(name)
 
 	if name == "" {
@@ -77,7 +311,7 @@ func (fs *gcsStreamStore) Stat(name string) (os.FileInfo, error) {
 		Prefix:    name,
 		Delimiter: "/",
 	}
-	iter := fs.client.Bucket(fs.bucket).Objects(fs.ctx, &input)
+	iter := fs.client.Bucket(fs.bucket).Objects(ctx, &input)
 
 	var matching []os.FileInfo
 
@@ -95,6 +329,8 @@ attrLoop:
 				name:    fs.lastElem(name),
 				modTime: attrs.Updated,
 				size:    attrs.Size,
+				perm:    objectMode(attrs),
+				info:    objectInfo(attrs),
 			})
 		} else if fs.noSlashSuffix(attrs.Prefix) == name {
 			matching = append(matching, &gcsStatResult{
@@ -106,35 +342,121 @@ attrLoop:
 		}
 	}
 
-	switch len(matching) {
-	case 0:
-		return nil, os.ErrNotExist
-	case 1:
-		return matching[0], nil
+	if len(matching) == 0 {
+		return nil, &os.PathError{Op: "stat", Path: path, Err: os.ErrNotExist}
+	}
+	// other tools can create both an object and a directory of the same
+	// name, in which case the directory is reported.
+	for _, fi := range matching {
+		if fi.IsDir() {
+			return fi, nil
+		}
+	}
+	return matching[0], nil
+}
+
+// IsDir determines whether name is a directory by fetching at most one
+// entry under the prefix, only falling back to reading the object attributes
+// when nothing exists under it.
+func (fs *gcsStreamStore) IsDir(name string) (bool, error) {
+	path, err := fs.cleanPath(name)
+	if err != nil {
+		return false, err
+	}
+	name = fs.noSlashPrefix(path)
+	name = fs.noSlashSuffix(name)
+
+	if name == "" {
+		return true, nil
+	}
+
+	input := storage.Query{
+		Prefix:    name + "/",
+		Delimiter: "/",
+	}
+	iter := fs.client.Bucket(fs.bucket).Objects(fs.ctx, &input)
+	iter.PageInfo().MaxSize = 1
+	_, err = iter.Next()
+	switch err {
+	case nil:
+		return true, nil
+	case iterator.Done:
+	default:
+		return false, err
+	}
+
+	if _, err := fs.object(name).Attrs(fs.ctx); err != nil {
+		if err == storage.ErrObjectNotExist {
+			return false, &os.PathError{Op: "stat", Path: path, Err: os.ErrNotExist}
+		}
+		return false, err
+	}
+	return false, nil
+}
+
+// Exists checks for an object by reading its attributes, which does not
+// fetch its content, only falling back to fetching at most one entry under
+// the prefix to check for a directory when there is no object.
+func (fs *gcsStreamStore) Exists(name string) (bool, error) {
+	path, err := fs.cleanPath(name)
+	if err != nil {
+		return false, err
+	}
+	name = fs.noSlashPrefix(path)
+	name = fs.noSlashSuffix(name)
+
+	if name == "" {
+		return true, nil
+	}
+
+	_, err = fs.object(name).Attrs(fs.ctx)
+	switch {
+	case err == nil:
+		return true, nil
+	case err != storage.ErrObjectNotExist:
+		return false, readError("stat", path, err)
+	}
+
+	iter := fs.client.Bucket(fs.bucket).Objects(fs.ctx, &storage.Query{Prefix: name + "/"})
+	iter.PageInfo().MaxSize = 1
+	switch _, err := iter.Next(); err {
+	case nil:
+		return true, nil
+	case iterator.Done:
+		return false, nil
 	default:
-		panic("bug?")
+		return false, err
 	}
 }
 
 func (fs *gcsStreamStore) OpenReadCloser(name string) (straw.StrawReader, error) {
-	fi, err := fs.Stat(name)
+	return fs.OpenReadCloserContext(fs.ctx, name)
+}
+
+func (fs *gcsStreamStore) OpenReadCloserContext(ctx context.Context, name string) (straw.StrawReader, error) {
+	name, err := fs.cleanPath(name)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := fs.StatContext(ctx, name)
 	if err != nil {
 		return nil, err
 	}
 	if fi.IsDir() {
-		return nil, fmt.Errorf("%s is a directory", name)
+		return nil, &os.PathError{Op: "open", Path: name, Err: straw.ErrIsDirectory}
 	}
 
 	nameNoSlash := fs.noSlashPrefix(name)
-	r, err := fs.client.Bucket(fs.bucket).Object(nameNoSlash).NewReader(fs.ctx)
+	if fi.Size() < fs.smallObjectThreshold {
+		return &gcsSmallReader{fs, nameNoSlash, ctx, nil}, nil
+	}
+
+	r, err := fs.object(nameNoSlash).NewReader(ctx)
 	if err != nil {
-		if err == storage.ErrObjectNotExist {
-			return nil, os.ErrNotExist
-		}
-		return nil, err
+		return nil, readError("open", name, err)
 	}
 
-	return &gcsReader{r, fs, nameNoSlash, fs.ctx, -1}, nil
+	return &gcsReader{r: r, ss: fs, objName: nameNoSlash, ctx: ctx, seek: -1, size: r.Attrs.Size}, nil
 }
 
 type gcsReader struct {
@@ -145,19 +467,31 @@ type gcsReader struct {
 
 	// -1 means don't seek
 	seek int64
+	// pos is the offset of the next Read, and size that of the object.
+	pos  int64
+	size int64
 }
 
-func (r *gcsReader) Seek(start int64, whence int) (int64, error) {
+// Seek sets the offset of the next Read, which opens a new range request
+// from there if it differs from the current offset.
+func (r *gcsReader) Seek(offset int64, whence int) (int64, error) {
 	switch whence {
 	case io.SeekStart:
-		if start < 0 {
-			return 0, errors.New("invalid seek position")
-		}
-		r.seek = start
-		return start, nil
+	case io.SeekCurrent:
+		offset += r.pos
+	case io.SeekEnd:
+		offset += r.size
 	default:
-		return 0, fmt.Errorf("seek %d not currently supported in s3 backend", whence)
+		return 0, fmt.Errorf("invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, errors.New("invalid seek position")
+	}
+	if offset != r.pos {
+		r.seek = offset
 	}
+	r.pos = offset
+	return offset, nil
 }
 
 func (r *gcsReader) Close() error {
@@ -165,33 +499,57 @@ func (r *gcsReader) Close() error {
 }
 
 func (r *gcsReader) Read(buf []byte) (int, error) {
-	if r.seek != -1 {
-		// we have a deferred seek to do before we read.
-		if err := r.Close(); err != nil {
-			return 0, err
+	if err := r.doSeek(); err != nil {
+		return 0, err
+	}
+	n, err := r.r.Read(buf)
+	r.pos += int64(n)
+	return n, err
+}
+
+// WriteTo streams the rest of the object to w, letting w read from it
+// directly if it implements io.ReaderFrom.
+func (r *gcsReader) WriteTo(w io.Writer) (int64, error) {
+	if err := r.doSeek(); err != nil {
+		if err == io.EOF {
+			return 0, nil
 		}
-		r.r = eofRdr
+		return 0, err
+	}
+	n, err := io.Copy(w, r.r)
+	r.pos += n
+	return n, err
+}
 
-		rdr, err := r.ss.client.Bucket(r.ss.bucket).Object(r.objName).NewRangeReader(r.ctx, r.seek, -1)
-		if err != nil {
-			if e, ok := err.(*googleapi.Error); ok {
-				if e.Code == 416 {
-					return 0, io.EOF
-				}
+// doSeek performs any deferred seek, opening a new range reader from the
+// seek offset, and returns io.EOF if that is beyond the end of the object.
+func (r *gcsReader) doSeek() error {
+	if r.seek == -1 {
+		return nil
+	}
+	if err := r.Close(); err != nil {
+		return err
+	}
+	r.r = eofRdr
+
+	rdr, err := r.ss.object(r.objName).NewRangeReader(r.ctx, r.seek, -1)
+	if err != nil {
+		if e, ok := err.(*googleapi.Error); ok {
+			if e.Code == 416 {
+				return io.EOF
 			}
-			return 0, err
 		}
-		r.r = rdr
-		r.seek = -1
+		return readError("read", r.objName, err)
 	}
-
-	return r.r.Read(buf)
+	r.r = rdr
+	r.seek = -1
+	return nil
 }
 
 func (r *gcsReader) ReadAt(buf []byte, start int64) (int, error) {
-	rdr, err := r.ss.client.Bucket(r.ss.bucket).Object(r.objName).NewRangeReader(r.ctx, start, int64(len(buf)))
+	rdr, err := r.ss.object(r.objName).NewRangeReader(r.ctx, start, int64(len(buf)))
 	if err != nil {
-		return 0, err
+		return 0, readError("read", r.objName, err)
 	}
 	defer rdr.Close()
 	i, err := io.ReadFull(rdr, buf)
@@ -202,22 +560,96 @@ func (r *gcsReader) ReadAt(buf []byte, start int64) (int, error) {
 	return i, err
 }
 
+// gcsSmallReader fetches the whole object on first use, and serves all reads
+// from memory after that.
+type gcsSmallReader struct {
+	ss      *gcsStreamStore
+	objName string
+	ctx     context.Context
+
+	r *bytes.Reader
+}
+
+func (r *gcsSmallReader) load() error {
+	if r.r != nil {
+		return nil
+	}
+	rdr, err := r.ss.object(r.objName).NewReader(r.ctx)
+	if err != nil {
+		return readError("read", r.objName, err)
+	}
+	defer rdr.Close()
+	data, err := ioutil.ReadAll(rdr)
+	if err != nil {
+		return err
+	}
+	r.r = bytes.NewReader(data)
+	return nil
+}
+
+func (r *gcsSmallReader) Read(buf []byte) (int, error) {
+	if err := r.load(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(buf)
+}
+
+func (r *gcsSmallReader) WriteTo(w io.Writer) (int64, error) {
+	if err := r.load(); err != nil {
+		return 0, err
+	}
+	return r.r.WriteTo(w)
+}
+
+func (r *gcsSmallReader) ReadAt(buf []byte, start int64) (int, error) {
+	if err := r.load(); err != nil {
+		return 0, err
+	}
+	return r.r.ReadAt(buf, start)
+}
+
+func (r *gcsSmallReader) Seek(offset int64, whence int) (int64, error) {
+	if err := r.load(); err != nil {
+		return 0, err
+	}
+	return r.r.Seek(offset, whence)
+}
+
+func (r *gcsSmallReader) Close() error {
+	return nil
+}
+
 func (fs *gcsStreamStore) Mkdir(name string, mode os.FileMode) error {
+	return fs.MkdirContext(fs.ctx, name, mode)
+}
+
+func (fs *gcsStreamStore) MkdirContext(ctx context.Context, name string, mode os.FileMode) error {
+	name, err := fs.cleanPath(name)
+	if err != nil {
+		return err
+	}
+	if err := fs.checkWritable("mkdir", name); err != nil {
+		return err
+	}
 	if !strings.HasSuffix(name, "/") {
 		name = name + "/"
 	}
 	name = fs.noSlashPrefix(name)
 
-	if err := fs.checkParentDir(name); err != nil {
+	if err := fs.checkParentDir(ctx, name); err != nil {
 		return err
 	}
 
-	if _, err := fs.Stat(name); err == nil {
+	if _, err := fs.StatContext(ctx, name); err == nil {
 		return fmt.Errorf("%s : file exists", name)
 	}
 
-	obj := fs.client.Bucket(fs.bucket).Object(name)
-	w := obj.NewWriter(fs.ctx)
+	if fs.dirMode == dirModePrefix {
+		// nothing to create, the directory is implied by its contents.
+		return nil
+	}
+
+	w := fs.newWriter(ctx, fs.object(name))
 
 	if _, err := w.Write([]byte{}); err != nil {
 		_ = w.Close()
@@ -226,56 +658,488 @@ func (fs *gcsStreamStore) Mkdir(name string, mode os.FileMode) error {
 	return w.Close()
 }
 
-func (fs *gcsStreamStore) checkParentDir(child string) error {
-	child = fs.noSlashPrefix(child)
-	child = fs.noSlashSuffix(child)
-
-	d, _ := filepath.Split(child)
+func (fs *gcsStreamStore) checkParentDir(ctx context.Context, child string) error {
+	d, _ := filepath.Split(fs.noSlashSuffix(fs.noSlashPrefix(child)))
 	if d != "" {
-		fi, err := fs.Stat(d)
+		fi, err := fs.StatContext(ctx, d)
 		if err != nil {
+			if fs.dirMode == dirModePrefix && os.IsNotExist(err) {
+				// directories are implied by the objects within them.
+				return nil
+			}
 			return err
 		}
 		if !fi.IsDir() {
-			return fmt.Errorf("%s not a directory", d)
+			return &os.PathError{Op: "open", Path: child, Err: straw.ErrNotDirectory}
 		}
 	}
 	return nil
 }
 
 func (fs *gcsStreamStore) Remove(name string) error {
-	fi, err := fs.Stat(name)
+	return fs.RemoveContext(fs.ctx, name)
+}
+
+func (fs *gcsStreamStore) RemoveContext(ctx context.Context, name string) error {
+	name, err := fs.cleanPath(name)
+	if err != nil {
+		return err
+	}
+	if err := fs.checkWritable("remove", name); err != nil {
+		return err
+	}
+	fi, err := fs.StatContext(ctx, name)
 	if err != nil {
 		return err
 	}
 	name = fs.noSlashPrefix(name)
 
 	if fi.IsDir() {
-		files, err := fs.Readdir(name)
+		files, err := fs.ReaddirContext(ctx, name)
 		if err != nil {
 			return err
 		}
 		if len(files) != 0 {
-			return fmt.Errorf("%s : directory not empty", name)
+			return &os.PathError{Op: "remove", Path: name, Err: straw.ErrDirectoryNotEmpty}
 		}
 		name = fs.fixTrailingSlash(name, true)
 	}
 
-	return fs.client.Bucket(fs.bucket).Object(name).Delete(fs.ctx)
+	return fs.object(name).Delete(ctx)
+}
+
+// removeAllConcurrency is how many deletes RemoveAll issues at once, as the
+// client has no batch delete.
+const removeAllConcurrency = 16
+
+// RemoveAll removes name and every object under it, issuing deletes
+// concurrently as the objects are listed.
+func (fs *gcsStreamStore) RemoveAll(name string) error {
+	name, err := fs.cleanPath(name)
+	if err != nil {
+		return err
+	}
+	if err := fs.checkWritable("remove", name); err != nil {
+		return err
+	}
+	key := fs.noSlashSuffix(fs.noSlashPrefix(name))
+
+	var prefix string
+	if key != "" {
+		prefix = key + "/"
+	}
+
+	bkt := fs.client.Bucket(fs.bucket)
+	keys := make(chan string)
+	errs := make(chan error, removeAllConcurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < removeAllConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var firstErr error
+			for k := range keys {
+				if err := bkt.Object(k).Delete(fs.ctx); err != nil && err != storage.ErrObjectNotExist && firstErr == nil {
+					firstErr = err
+				}
+			}
+			errs <- firstErr
+		}()
+	}
+
+	iter := bkt.Objects(fs.ctx, &storage.Query{Prefix: prefix})
+	var listErr error
+	for {
+		attrs, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			listErr = err
+			break
+		}
+		keys <- attrs.Name
+	}
+	if key != "" && listErr == nil {
+		// name may also be a file.
+		keys <- key
+	}
+	close(keys)
+	wg.Wait()
+	close(errs)
+
+	if listErr != nil {
+		return listErr
+	}
+	for err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (fs *gcsStreamStore) CreateWriteCloser(name string) (straw.StrawWriter, error) {
+	return fs.createWriteCloser(fs.ctx, name, straw.WriteOptions{})
+}
+
+func (fs *gcsStreamStore) CreateWriteCloserWithOptions(name string, opts straw.WriteOptions) (straw.StrawWriter, error) {
+	return fs.createWriteCloser(fs.ctx, name, opts)
+}
+
+// CreateWriteCloserContext creates the named object. If ctx is cancelled
+// before the writer is closed, the upload is abandoned and no object is
+// created.
+func (fs *gcsStreamStore) CreateWriteCloserContext(ctx context.Context, name string) (straw.StrawWriter, error) {
+	return fs.createWriteCloser(ctx, name, straw.WriteOptions{})
+}
+
+func (fs *gcsStreamStore) createWriteCloser(ctx context.Context, name string, opts straw.WriteOptions) (straw.StrawWriter, error) {
+	name, err := fs.cleanPath(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := fs.checkWritable("open", name); err != nil {
+		return nil, err
+	}
 	name = fs.noSlashPrefix(name)
 
-	if err := fs.checkParentDir(name); err != nil {
+	if err := fs.checkParentDir(ctx, name); err != nil {
+		return nil, err
+	}
+
+	if fi, err := fs.StatContext(ctx, name); err == nil && fi.IsDir() {
+		return nil, &os.PathError{Op: "open", Path: name, Err: straw.ErrIsDirectory}
+	}
+
+	obj, err := fs.preconditions(ctx, name, opts)
+	if err != nil {
+		return nil, err
+	}
+	w := fs.newWriter(ctx, obj)
+	w.ContentType = straw.ContentTypeFor(name, opts)
+	if len(opts.Metadata) != 0 || !opts.Expires.IsZero() {
+		w.Metadata = make(map[string]string, len(opts.Metadata)+1)
+		for k, v := range opts.Metadata {
+			w.Metadata[k] = v
+		}
+	}
+	if !opts.Expires.IsZero() {
+		w.Metadata[expiresMetadataKey] = opts.Expires.UTC().Format(time.RFC3339)
+	}
+	w.MD5 = opts.ContentMD5
+	return &gcsWriter{Writer: w, name: name}, nil
+}
+
+// preconditions returns the handle of the named object to write with opts,
+// with a DoesNotExist precondition for IfNotExists. GCS conditions writes on
+// generations rather than ETags, so for IfMatch the ETag is checked now, and
+// the write made conditional on the generation which had it.
+func (fs *gcsStreamStore) preconditions(ctx context.Context, name string, opts straw.WriteOptions) (*storage.ObjectHandle, error) {
+	obj := fs.object(name)
+	if opts.IfNotExists {
+		obj = obj.If(storage.Conditions{DoesNotExist: true})
+	}
+	if opts.IfMatch == "" {
+		return obj, nil
+	}
+	attrs, err := obj.Attrs(ctx)
+	if err == storage.ErrObjectNotExist || err == nil && attrs.Etag != strings.Trim(opts.IfMatch, `"`) {
+		return nil, &os.PathError{Op: "open", Path: name, Err: straw.ErrPreconditionFailed}
+	}
+	if err != nil {
 		return nil, err
 	}
+	return obj.If(storage.Conditions{GenerationMatch: attrs.Generation}), nil
+}
+
+// gcsWriter is a storage.Writer which fails with straw.ErrClosed once it has
+// been closed.
+type gcsWriter struct {
+	*storage.Writer
+	name   string
+	closed bool
+}
+
+func (w *gcsWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, straw.ErrClosed
+	}
+	return w.Writer.Write(p)
+}
 
-	if fi, err := fs.Stat(name); err == nil && fi.IsDir() {
-		return nil, fmt.Errorf("%s is a directory", name)
+func (w *gcsWriter) Close() error {
+	if w.closed {
+		return straw.ErrClosed
+	}
+	w.closed = true
+	err := w.Writer.Close()
+	if e, ok := err.(*googleapi.Error); ok && e.Code == http.StatusPreconditionFailed {
+		return &os.PathError{Op: "close", Path: w.name, Err: straw.ErrPreconditionFailed}
 	}
+	return err
+}
+
+// OpenFile opens the named file as described by straw.OpenFileBuffered, as
+// objects cannot be modified in place. os.O_CREATE|os.O_EXCL creates the
+// object with a precondition that it does not exist, so is atomic.
+func (fs *gcsStreamStore) OpenFile(name string, flag int, perm os.FileMode) (straw.StrawReadWriteCloser, error) {
+	return straw.OpenFileBuffered(fs, name, flag, fs.createExclusive)
+}
 
-	return fs.client.Bucket(fs.bucket).Object(name).NewWriter(fs.ctx), nil
+// createExclusive creates the named object, empty, failing if it already
+// exists.
+func (fs *gcsStreamStore) createExclusive(name string) error {
+	name, err := fs.cleanPath(name)
+	if err != nil {
+		return err
+	}
+	if err := fs.checkWritable("open", name); err != nil {
+		return err
+	}
+	if err := fs.checkParentDir(fs.ctx, name); err != nil {
+		return err
+	}
+	if fi, err := fs.StatContext(fs.ctx, name); err == nil && fi.IsDir() {
+		return &os.PathError{Op: "open", Path: name, Err: straw.ErrIsDirectory}
+	}
+
+	obj := fs.object(fs.noSlashPrefix(name))
+	w := fs.newWriter(fs.ctx, obj.If(storage.Conditions{DoesNotExist: true}))
+	if err := w.Close(); err != nil {
+		if e, ok := err.(*googleapi.Error); ok && e.Code == http.StatusPreconditionFailed {
+			return &os.PathError{Op: "open", Path: name, Err: os.ErrExist}
+		}
+		return err
+	}
+	return nil
+}
+
+// AppendWriteCloser returns a writer which appends to the named object,
+// creating it as CreateWriteCloser does if it does not exist. GCS cannot
+// append to objects, so what is written is uploaded to a temporary object
+// in the same directory, named with a leading "." and ending with ".tmp",
+// which on Close is composed with the existing object server side to
+// replace it, and then deleted.
+//
+// The existing content does not pass through this process, and composing
+// is charged as a single operation whatever the size of the object, but the
+// temporary object is visible in listings while the writer is open. The
+// object is only replaced if it has not changed since the writer was
+// opened, so of concurrent appends, all but the first to close fail with an
+// error wrapping straw.ErrPreconditionFailed rather than losing data.
+// Closing the writer without writing anything leaves the object as it was.
+func (fs *gcsStreamStore) AppendWriteCloser(name string) (straw.StrawWriter, error) {
+	name, err := fs.cleanPath(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := fs.checkWritable("open", name); err != nil {
+		return nil, err
+	}
+	key := fs.noSlashPrefix(name)
+	attrs, err := fs.object(key).Attrs(fs.ctx)
+	if err == storage.ErrObjectNotExist {
+		if fi, err := fs.StatContext(fs.ctx, name); err == nil && fi.IsDir() {
+			return nil, &os.PathError{Op: "open", Path: name, Err: straw.ErrIsDirectory}
+		}
+		return fs.createWriteCloser(fs.ctx, name, straw.WriteOptions{})
+	}
+	if err != nil {
+		return nil, readError("open", name, err)
+	}
+
+	var suffix [4]byte
+	if _, err := rand.Read(suffix[:]); err != nil {
+		return nil, err
+	}
+	dir, base := filepath.Split(key)
+	tmp := fs.object(dir + "." + base + "." + hex.EncodeToString(suffix[:]) + ".tmp")
+	ctx, cancel := context.WithCancel(fs.ctx)
+	return &gcsAppender{
+		fs:     fs,
+		name:   name,
+		attrs:  attrs,
+		tmp:    tmp,
+		w:      fs.newWriter(ctx, tmp.If(storage.Conditions{DoesNotExist: true})),
+		cancel: cancel,
+	}, nil
+}
+
+// gcsAppender uploads the data to append to an object to a temporary
+// object, as described by AppendWriteCloser.
+type gcsAppender struct {
+	fs *gcsStreamStore
+	// name is the path of the object, and attrs those it had when the
+	// writer was opened.
+	name    string
+	attrs   *storage.ObjectAttrs
+	tmp     *storage.ObjectHandle
+	w       *storage.Writer
+	cancel  context.CancelFunc
+	written bool
+	closed  bool
+}
+
+func (w *gcsAppender) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, straw.ErrClosed
+	}
+	if len(p) != 0 {
+		w.written = true
+	}
+	return w.w.Write(p)
+}
+
+func (w *gcsAppender) Close() error {
+	if w.closed {
+		return straw.ErrClosed
+	}
+	w.closed = true
+	if !w.written {
+		// abandon the upload, which has created nothing.
+		w.cancel()
+		w.w.Close()
+		return nil
+	}
+	defer w.cancel()
+	if err := w.w.Close(); err != nil {
+		return err
+	}
+	defer w.tmp.Delete(w.fs.ctx)
+
+	obj := w.fs.object(w.attrs.Name).If(storage.Conditions{GenerationMatch: w.attrs.Generation})
+	composer := obj.ComposerFrom(w.fs.object(w.attrs.Name), w.tmp)
+	composer.ContentType = w.attrs.ContentType
+	composer.ContentEncoding = w.attrs.ContentEncoding
+	composer.ContentLanguage = w.attrs.ContentLanguage
+	composer.ContentDisposition = w.attrs.ContentDisposition
+	composer.CacheControl = w.attrs.CacheControl
+	composer.Metadata = w.attrs.Metadata
+	composer.KMSKeyName = w.fs.kmsKey
+	if _, err := composer.Run(w.fs.ctx); err != nil {
+		if e, ok := err.(*googleapi.Error); ok && e.Code == http.StatusPreconditionFailed {
+			return &os.PathError{Op: "close", Path: w.name, Err: straw.ErrPreconditionFailed}
+		}
+		return err
+	}
+	return nil
+}
+
+// Copy copies src to dst server side. The content type and metadata of src
+// are preserved.
+func (fs *gcsStreamStore) Copy(src, dst string) error {
+	return fs.CopyContext(fs.ctx, src, dst)
+}
+
+func (fs *gcsStreamStore) CopyContext(ctx context.Context, src, dst string) error {
+	src, err := fs.cleanPath(src)
+	if err != nil {
+		return err
+	}
+	if err := fs.checkWritable("copy", dst); err != nil {
+		return err
+	}
+	dst, err = fs.cleanPath(dst)
+	if err != nil {
+		return err
+	}
+
+	fi, err := fs.StatContext(ctx, src)
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		return &os.PathError{Op: "copy", Path: src, Err: straw.ErrIsDirectory}
+	}
+
+	if err := fs.checkParentDir(ctx, dst); err != nil {
+		return err
+	}
+	if fi, err := fs.StatContext(ctx, dst); err == nil && fi.IsDir() {
+		return &os.PathError{Op: "copy", Path: dst, Err: straw.ErrIsDirectory}
+	}
+	dst = fs.noSlashPrefix(dst)
+
+	copier := fs.object(dst).CopierFrom(fs.object(fs.noSlashPrefix(src)))
+	copier.DestinationKMSKeyName = fs.kmsKey
+	_, err = copier.Run(ctx)
+	if err == storage.ErrObjectNotExist {
+		return &os.PathError{Op: "copy", Path: src, Err: os.ErrNotExist}
+	}
+	return err
+}
+
+// Chmod records the permission bits of mode in the metadata of the named
+// object, from which they are reported by Stat and Readdir. Directories are
+// not objects, so are not supported.
+func (fs *gcsStreamStore) Chmod(name string, mode os.FileMode) error {
+	return fs.ChmodContext(fs.ctx, name, mode)
+}
+
+// Truncate reads the whole object and rewrites it at the new size, as
+// described by straw.TruncateBuffered.
+func (fs *gcsStreamStore) Truncate(name string, size int64) error {
+	return straw.TruncateBuffered(fs, name, size)
+}
+
+func (fs *gcsStreamStore) ChmodContext(ctx context.Context, name string, mode os.FileMode) error {
+	name, err := fs.cleanPath(name)
+	if err != nil {
+		return err
+	}
+	if err := fs.checkWritable("chmod", name); err != nil {
+		return err
+	}
+	fi, err := fs.StatContext(ctx, name)
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		return straw.ErrNotSupported
+	}
+
+	obj := fs.object(fs.noSlashPrefix(name))
+	_, err = obj.Update(ctx, storage.ObjectAttrsToUpdate{Metadata: map[string]string{
+		modeMetadataKey: strconv.FormatUint(uint64(mode.Perm()), 8),
+	}})
+	if err == storage.ErrObjectNotExist {
+		return &os.PathError{Op: "chmod", Path: name, Err: os.ErrNotExist}
+	}
+	return err
+}
+
+// objectMode returns the permission bits recorded by Chmod for an object.
+func objectMode(attrs *storage.ObjectAttrs) os.FileMode {
+	if v, ok := attrs.Metadata[modeMetadataKey]; ok {
+		if m, err := strconv.ParseUint(v, 8, 32); err == nil {
+			return os.FileMode(m).Perm()
+		}
+	}
+	return 0644
+}
+
+func objectInfo(attrs *storage.ObjectAttrs) *straw.ObjectInfo {
+	info := &straw.ObjectInfo{
+		StorageClass: attrs.StorageClass,
+		ContentType:  attrs.ContentType,
+		ETag:         attrs.Etag,
+	}
+	if v, ok := attrs.Metadata[expiresMetadataKey]; ok {
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			info.Expires = t
+		}
+	}
+	for k, v := range attrs.Metadata {
+		if k == expiresMetadataKey || k == modeMetadataKey || strings.HasPrefix(k, tagMetadataPrefix) {
+			continue
+		}
+		if info.Metadata == nil {
+			info.Metadata = make(map[string]string)
+		}
+		info.Metadata[k] = v
+	}
+	return info
 }
 
 func (fs *gcsStreamStore) noSlashPrefix(s string) string {
@@ -305,52 +1169,250 @@ func (fs *gcsStreamStore) lastElem(s string) string {
 }
 
 func (fs *gcsStreamStore) Readdir(name string) ([]os.FileInfo, error) {
+	return fs.ReaddirContext(fs.ctx, name)
+}
+
+func (fs *gcsStreamStore) ReaddirContext(ctx context.Context, name string) ([]os.FileInfo, error) {
+	return fs.readdir(ctx, name, "")
+}
+
+// ListPrefix lists the entries of the named directory whose names begin with
+// prefix, listing only the objects under that prefix.
+func (fs *gcsStreamStore) ListPrefix(name, prefix string) ([]os.FileInfo, error) {
+	if strings.Contains(prefix, "/") {
+		return nil, fmt.Errorf("invalid prefix %q", prefix)
+	}
+	return fs.readdir(fs.ctx, name, prefix)
+}
+
+// ReaddirEach calls fn with each entry of the named directory as the listing
+// arrives, in the order listed. Returning an error from fn stops the
+// listing.
+func (fs *gcsStreamStore) ReaddirEach(name string, fn func(os.FileInfo) error) error {
+	return fs.readdirEach(fs.ctx, name, "", fn)
+}
+
+// readdir lists the entries of the named directory whose names begin with
+// prefix.
+func (fs *gcsStreamStore) readdir(ctx context.Context, name, prefix string) ([]os.FileInfo, error) {
+	var results []os.FileInfo
+	err := fs.readdirEach(ctx, name, prefix, func(fi os.FileInfo) error {
+		results = append(results, fi)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Name() < results[j].Name() })
+	return results, nil
+}
+
+// readdirEach calls fn with each entry of the named directory whose name
+// begins with prefix, as the listing arrives.
+func (fs *gcsStreamStore) readdirEach(ctx context.Context, name, prefix string, fn func(os.FileInfo) error) error {
+	name, err := fs.cleanPath(name)
+	if err != nil {
+		return err
+	}
 	if !strings.HasSuffix(name, "/") {
 		name = name + "/"
 	}
 	name = strings.TrimPrefix(name, "/")
 
-	var results []os.FileInfo
-
 	input := storage.Query{
-		Prefix:    name,
+		Prefix:    name + prefix,
 		Delimiter: "/",
 	}
-	iter := fs.client.Bucket(fs.bucket).Objects(fs.ctx, &input)
+	iter := fs.client.Bucket(fs.bucket).Objects(ctx, &input)
 
-attrLoop:
 	for {
 		attrs, err := iter.Next()
 		if err != nil {
 			if err == iterator.Done {
-				break attrLoop
+				return nil
 			}
-			return nil, err
+			return err
 		}
+		var result *gcsStatResult
 		if attrs.Name != "" {
-			if attrs.Name != name {
-				result := &gcsStatResult{
-					name:    strings.TrimPrefix(attrs.Name, name),
-					modTime: attrs.Updated,
-					size:    attrs.Size,
-				}
-				results = append(results, result)
+			if attrs.Name == name {
+				continue
+			}
+			result = &gcsStatResult{
+				name:    strings.TrimPrefix(attrs.Name, name),
+				modTime: attrs.Updated,
+				size:    attrs.Size,
+				perm:    objectMode(attrs),
+				info:    objectInfo(attrs),
 			}
 		} else if attrs.Prefix != "" {
-			result := &gcsStatResult{
+			result = &gcsStatResult{
 				name:  fs.noSlashSuffix(strings.TrimPrefix(attrs.Prefix, name)), // a bit confusing because prefix is used in different contexts here.
 				isDir: true,
 				// modTime: ??
 				size: 4096,
 			}
-			results = append(results, result)
 		} else {
 			panic("bug?")
 		}
+		if err := fn(result); err != nil {
+			return err
+		}
+	}
+}
 
+// ListDirs returns the names of the immediate subdirectories of name, using
+// only the prefixes of a delimited listing. Directories other than the root
+// which have nothing under them do not exist.
+func (fs *gcsStreamStore) ListDirs(name string) ([]string, error) {
+	path, err := fs.cleanPath(name)
+	if err != nil {
+		return nil, err
 	}
-	sort.Slice(results, func(i, j int) bool { return results[i].Name() < results[j].Name() })
-	return results, nil
+	name = fs.fixTrailingSlash(fs.noSlashPrefix(path), true)
+	if name == "/" {
+		name = ""
+	}
+
+	var dirs []string
+	var found bool
+
+	input := storage.Query{
+		Prefix:    name,
+		Delimiter: "/",
+	}
+	// only the name is needed to tell prefixes from objects.
+	if err := input.SetAttrSelection([]string{"Name"}); err != nil {
+		return nil, err
+	}
+	iter := fs.client.Bucket(fs.bucket).Objects(fs.ctx, &input)
+	for {
+		attrs, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		found = true
+		if attrs.Prefix != "" {
+			dirs = append(dirs, fs.noSlashSuffix(strings.TrimPrefix(attrs.Prefix, name)))
+		}
+	}
+	if !found && name != "" {
+		return nil, &os.PathError{Op: "readdir", Path: path, Err: os.ErrNotExist}
+	}
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+// SetTags replaces the tags on the named object. As GCS lacks native object
+// tags, they are stored as custom metadata entries prefixed with "tag-".
+func (fs *gcsStreamStore) SetTags(name string, tags map[string]string) error {
+	name, err := fs.cleanPath(name)
+	if err != nil {
+		return err
+	}
+	if err := fs.checkWritable("settags", name); err != nil {
+		return err
+	}
+	obj := fs.object(fs.noSlashPrefix(name))
+	attrs, err := obj.Attrs(fs.ctx)
+	if err != nil {
+		if err == storage.ErrObjectNotExist {
+			return &os.PathError{Op: "settags", Path: name, Err: os.ErrNotExist}
+		}
+		return err
+	}
+
+	metadata := make(map[string]string)
+	for k := range attrs.Metadata {
+		if strings.HasPrefix(k, tagMetadataPrefix) {
+			// metadata updates are merged, so tags which are no longer
+			// wanted are blanked out and ignored by GetTags.
+			metadata[k] = ""
+		}
+	}
+	for k, v := range tags {
+		metadata[tagMetadataPrefix+k] = v
+	}
+
+	_, err = obj.Update(fs.ctx, storage.ObjectAttrsToUpdate{Metadata: metadata})
+	return err
+}
+
+// GetTags returns the tags on the named object.
+func (fs *gcsStreamStore) GetTags(name string) (map[string]string, error) {
+	name, err := fs.cleanPath(name)
+	if err != nil {
+		return nil, err
+	}
+	attrs, err := fs.object(fs.noSlashPrefix(name)).Attrs(fs.ctx)
+	if err != nil {
+		if err == storage.ErrObjectNotExist {
+			return nil, &os.PathError{Op: "gettags", Path: name, Err: os.ErrNotExist}
+		}
+		return nil, err
+	}
+	tags := make(map[string]string)
+	for k, v := range attrs.Metadata {
+		if strings.HasPrefix(k, tagMetadataPrefix) && v != "" {
+			tags[strings.TrimPrefix(k, tagMetadataPrefix)] = v
+		}
+	}
+	return tags, nil
+}
+
+// Checksum returns the md5 or crc32c checksum GCS holds for the named
+// object. Composite objects have no md5, in which case ErrNotSupported is
+// returned so the caller can compute it instead.
+func (fs *gcsStreamStore) Checksum(name string, algo string) ([]byte, error) {
+	name, err := fs.cleanPath(name)
+	if err != nil {
+		return nil, err
+	}
+	if algo != "md5" && algo != "crc32c" {
+		return nil, straw.ErrNotSupported
+	}
+	attrs, err := fs.object(fs.noSlashPrefix(name)).Attrs(fs.ctx)
+	if err != nil {
+		if err == storage.ErrObjectNotExist {
+			return nil, &os.PathError{Op: "checksum", Path: name, Err: os.ErrNotExist}
+		}
+		return nil, err
+	}
+	if algo == "crc32c" {
+		sum := make([]byte, 4)
+		binary.BigEndian.PutUint32(sum, attrs.CRC32C)
+		return sum, nil
+	}
+	if len(attrs.MD5) == 0 {
+		return nil, straw.ErrNotSupported
+	}
+	return attrs.MD5, nil
+}
+
+// ContentHash returns the hex encoded md5 GCS holds for the named object,
+// or, for composite objects which have none, the hex encoded big-endian
+// crc32c.
+func (fs *gcsStreamStore) ContentHash(name string) (string, error) {
+	name, err := fs.cleanPath(name)
+	if err != nil {
+		return "", err
+	}
+	attrs, err := fs.object(fs.noSlashPrefix(name)).Attrs(fs.ctx)
+	if err != nil {
+		if err == storage.ErrObjectNotExist {
+			return "", &os.PathError{Op: "contenthash", Path: name, Err: os.ErrNotExist}
+		}
+		return "", err
+	}
+	if len(attrs.MD5) != 0 {
+		return hex.EncodeToString(attrs.MD5), nil
+	}
+	sum := make([]byte, 4)
+	binary.BigEndian.PutUint32(sum, attrs.CRC32C)
+	return hex.EncodeToString(sum), nil
 }
 
 var (
@@ -366,3 +1428,16 @@ func (r *eofReader) Read(buf []byte) (int, error) {
 func (r *eofReader) Close() error {
 	return nil
 }
+
+// cleanPath validates name with straw.CleanPath, mapping the current
+// directory onto the bucket root.
+func (fs *gcsStreamStore) cleanPath(name string) (string, error) {
+	name, err := straw.CleanPath(name)
+	if err != nil {
+		return "", err
+	}
+	if name == "." {
+		return "/", nil
+	}
+	return name, nil
+}
@@ -3,6 +3,8 @@ package gcs
 import (
 	"os"
 	"time"
+
+	"github.com/uw-labs/straw"
 )
 
 type gcsStatResult struct {
@@ -10,6 +12,8 @@ type gcsStatResult struct {
 	isDir   bool
 	modTime time.Time
 	size    int64
+	perm    os.FileMode
+	info    *straw.ObjectInfo
 }
 
 func (sr *gcsStatResult) Name() string {
@@ -32,9 +36,13 @@ func (sr *gcsStatResult) Mode() os.FileMode {
 	if sr.IsDir() {
 		return os.ModeDir | 0755
 	}
-	return 0644
+	return sr.perm
 }
 
+// Sys returns a *straw.ObjectInfo for files, or nil for directories.
 func (sr *gcsStatResult) Sys() interface{} {
-	return nil
+	if sr.info == nil {
+		return nil
+	}
+	return sr.info
 }
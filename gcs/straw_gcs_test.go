@@ -0,0 +1,872 @@
+package gcs
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+	"google.golang.org/api/option"
+)
+
+// fakeGCS is the bare minimum of the GCS JSON API needed to stat, list,
+// upload, compose and delete small objects, recording each request it is
+// sent. Objects may be added to objects directly, and are given a generation
+// when first seen.
+type fakeGCS struct {
+	lk       sync.Mutex
+	objects  map[string][]byte
+	attrs    map[string]fakeAttrs
+	requests []*http.Request
+
+	generation int64
+	// fail, if set, returns the status with which to fail a request, or 0
+	// to serve it.
+	fail func(r *http.Request) int
+}
+
+// fakeAttrs are the attributes of an object held by fakeGCS, other than its
+// name and content.
+type fakeAttrs struct {
+	Generation  int64             `json:"generation,string"`
+	ContentType string            `json:"contentType,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+}
+
+func (f *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	f.requests = append(f.requests, r)
+
+	w.Header().Set("Content-Type", "application/json")
+	if f.fail != nil {
+		if code := f.fail(r); code != 0 {
+			writeError(w, code)
+			return
+		}
+	}
+	const objects = "/b/bucket/o"
+	path := r.URL.Path
+	var name string
+	if i := strings.Index(path, objects+"/"); i >= 0 {
+		name = path[i+len(objects)+1:]
+	}
+	switch {
+	case r.Method == http.MethodPost && strings.HasSuffix(path, objects):
+		meta, data, err := readMultipartUpload(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !f.checkGeneration(w, r, meta.Name) {
+			return
+		}
+		f.put(meta.Name, data, meta.fakeAttrs)
+		f.writeObject(w, meta.Name)
+	case r.Method == http.MethodPost && strings.HasSuffix(name, "/compose"):
+		name = strings.TrimSuffix(name, "/compose")
+		var req struct {
+			Destination   fakeAttrs
+			SourceObjects []struct{ Name string }
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !f.checkGeneration(w, r, name) {
+			return
+		}
+		var data []byte
+		for _, src := range req.SourceObjects {
+			d, ok := f.objects[src.Name]
+			if !ok {
+				writeError(w, http.StatusNotFound)
+				return
+			}
+			data = append(data, d...)
+		}
+		f.put(name, data, req.Destination)
+		f.writeObject(w, name)
+	case r.Method == http.MethodDelete && name != "":
+		if _, ok := f.objects[name]; !ok {
+			writeError(w, http.StatusNotFound)
+			return
+		}
+		delete(f.objects, name)
+		delete(f.attrs, name)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet && strings.HasSuffix(path, objects):
+		prefix, delim := r.URL.Query().Get("prefix"), r.URL.Query().Get("delimiter")
+		var items []map[string]interface{}
+		var prefixes []string
+		seen := make(map[string]bool)
+		for name := range f.objects {
+			if !strings.HasPrefix(name, prefix) {
+				continue
+			}
+			if i := strings.Index(name[len(prefix):], delim); delim != "" && i >= 0 {
+				p := name[:len(prefix)+i+1]
+				if !seen[p] {
+					seen[p] = true
+					prefixes = append(prefixes, p)
+				}
+				continue
+			}
+			items = append(items, f.resource(name))
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"items": items, "prefixes": prefixes})
+	case r.Method == http.MethodGet && name != "":
+		if _, ok := f.objects[name]; !ok {
+			writeError(w, http.StatusNotFound)
+			return
+		}
+		f.writeObject(w, name)
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "/bucket/"):
+		f.serveContent(w, r, strings.TrimPrefix(path, "/bucket/"))
+	default:
+		http.Error(w, "unexpected request", http.StatusNotImplemented)
+	}
+}
+
+// serveContent serves the content of the object name, as the XML API which
+// the client reads objects from does, including single ranges.
+func (f *fakeGCS) serveContent(w http.ResponseWriter, r *http.Request, name string) {
+	data, ok := f.objects[name]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("X-Goog-Generation", strconv.FormatInt(f.attrsOf(name).Generation, 10))
+	w.Header().Set("X-Goog-Metageneration", "1")
+	rng := r.Header.Get("Range")
+	if rng == "" {
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Write(data)
+		return
+	}
+	size := int64(len(data))
+	var start, end int64
+	if n, _ := fmt.Sscanf(rng, "bytes=%d-%d", &start, &end); n < 2 {
+		end = size - 1
+	}
+	if end >= size {
+		end = size - 1
+	}
+	if start >= size {
+		w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+	w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, size))
+	w.Header().Set("Content-Length", strconv.FormatInt(end-start+1, 10))
+	w.WriteHeader(http.StatusPartialContent)
+	w.Write(data[start : end+1])
+}
+
+// checkGeneration fails the request with 412 if it has an ifGenerationMatch
+// condition which the object name does not meet, 0 meaning it must not
+// exist.
+func (f *fakeGCS) checkGeneration(w http.ResponseWriter, r *http.Request, name string) bool {
+	match := r.URL.Query().Get("ifGenerationMatch")
+	if match == "" {
+		return true
+	}
+	var generation int64
+	if _, ok := f.objects[name]; ok {
+		generation = f.attrsOf(name).Generation
+	}
+	if strconv.FormatInt(generation, 10) != match {
+		writeError(w, http.StatusPreconditionFailed)
+		return false
+	}
+	return true
+}
+
+func (f *fakeGCS) put(name string, data []byte, attrs fakeAttrs) {
+	f.generation++
+	attrs.Generation = f.generation
+	f.objects[name] = data
+	f.attrs[name] = attrs
+}
+
+// attrsOf returns the attributes of the object name, giving it a generation
+// if it was added directly.
+func (f *fakeGCS) attrsOf(name string) fakeAttrs {
+	attrs, ok := f.attrs[name]
+	if !ok {
+		f.generation++
+		attrs.Generation = f.generation
+		f.attrs[name] = attrs
+	}
+	return attrs
+}
+
+func (f *fakeGCS) resource(name string) map[string]interface{} {
+	attrs := f.attrsOf(name)
+	res := map[string]interface{}{
+		"kind":       "storage#object",
+		"bucket":     "bucket",
+		"name":       name,
+		"size":       strconv.Itoa(len(f.objects[name])),
+		"generation": strconv.FormatInt(attrs.Generation, 10),
+	}
+	if attrs.ContentType != "" {
+		res["contentType"] = attrs.ContentType
+	}
+	if attrs.Metadata != nil {
+		res["metadata"] = attrs.Metadata
+	}
+	return res
+}
+
+func (f *fakeGCS) writeObject(w http.ResponseWriter, name string) {
+	json.NewEncoder(w).Encode(f.resource(name))
+}
+
+func writeError(w http.ResponseWriter, code int) {
+	w.WriteHeader(code)
+	fmt.Fprintf(w, `{"error": {"code": %d, "message": %q}}`, code, http.StatusText(code))
+}
+
+func (f *fakeGCS) Requests() []*http.Request {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	return append([]*http.Request(nil), f.requests...)
+}
+
+// Object returns the content and attributes of the named object, if it
+// exists.
+func (f *fakeGCS) Object(name string) ([]byte, fakeAttrs, bool) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	data, ok := f.objects[name]
+	if !ok {
+		return nil, fakeAttrs{}, false
+	}
+	return data, f.attrsOf(name), true
+}
+
+// uploadMetadata is the metadata sent with a multipart upload.
+type uploadMetadata struct {
+	Name string
+	fakeAttrs
+}
+
+// readMultipartUpload returns the metadata and content of an object uploaded
+// in a single multipart request.
+func readMultipartUpload(r *http.Request) (uploadMetadata, []byte, error) {
+	var meta uploadMetadata
+	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return meta, nil, err
+	}
+	mr := multipart.NewReader(r.Body, params["boundary"])
+	p, err := mr.NextPart()
+	if err != nil {
+		return meta, nil, err
+	}
+	if err := json.NewDecoder(p).Decode(&meta); err != nil {
+		return meta, nil, err
+	}
+	if p, err = mr.NextPart(); err != nil {
+		return meta, nil, err
+	}
+	data, err := ioutil.ReadAll(p)
+	return meta, data, err
+}
+
+// newTestStreamStore returns a store for the bucket "bucket" of a fake GCS
+// server, configured by query, and a function to close both.
+func newTestStreamStore(t *testing.T, query string) (*gcsStreamStore, *fakeGCS, func()) {
+	fake := &fakeGCS{objects: make(map[string][]byte), attrs: make(map[string]fakeAttrs)}
+	srv := httptest.NewServer(fake)
+	u, err := url.Parse("gs://bucket/?anonymous=true&" + query)
+	require.NoError(t, err)
+	// objects are read from a host derived from the endpoint, without its
+	// port, so every request is sent to the fake.
+	client := &http.Client{Transport: redirectTransport(srv.Listener.Addr().String())}
+	ss, err := newGCSStreamStore(u, option.WithEndpoint(srv.URL+"/storage/v1/"), option.WithHTTPClient(client))
+	if err != nil {
+		srv.Close()
+		require.NoError(t, err)
+	}
+	// the fake needs no credentials, but the store must still write.
+	ss.anonymous = false
+	return ss, fake, func() {
+		ss.Close()
+		srv.Close()
+	}
+}
+
+// redirectTransport sends every request to host over plain HTTP.
+type redirectTransport string
+
+func (host redirectTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.URL.Scheme = "http"
+	r.URL.Host = string(host)
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+func TestEncryptionKeyOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	std := url.QueryEscape(base64.StdEncoding.EncodeToString(testKey))
+	for _, tc := range []struct {
+		query string
+		err   string
+	}{
+		{query: "csek=" + std},
+		{query: "csek=" + base64.URLEncoding.EncodeToString(testKey)},
+		{query: "kmskey=projects/p/locations/l/keyRings/r/cryptoKeys/k"},
+		{query: "csek=not-base64!", err: `invalid "csek" query parameter: illegal base64 data at input byte 10`},
+		{query: "csek=" + base64.URLEncoding.EncodeToString(testKey[:16]), err: `invalid "csek" query parameter: key is 16 bytes, not 32`},
+		{query: "csek=" + std + "&kmskey=projects/p/locations/l/keyRings/r/cryptoKeys/k", err: `the "csek" and "kmskey" query parameters cannot both be set`},
+	} {
+		u, err := url.Parse("gs://bucket/?anonymous=true&" + tc.query)
+		assert.NoError(err)
+		ss, err := newGCSStreamStore(u)
+		if tc.err != "" {
+			assert.EqualError(err, tc.err, tc.query)
+			continue
+		}
+		if assert.NoError(err, tc.query) {
+			ss.Close()
+		}
+	}
+}
+
+func TestCustomerSuppliedKey(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, fake, closeFn := newTestStreamStore(t, "csek="+base64.URLEncoding.EncodeToString(testKey))
+	defer closeFn()
+
+	w, err := ss.CreateWriteCloser("/a")
+	require.NoError(err)
+	_, err = w.Write([]byte("hello"))
+	require.NoError(err)
+	require.NoError(w.Close())
+	fi, err := ss.Stat("/a")
+	require.NoError(err)
+	assert.Equal(int64(5), fi.Size())
+
+	// the key is sent with the upload.
+	var sent int
+	for _, r := range fake.Requests() {
+		if r.Method == http.MethodPost {
+			assert.Equal(base64.StdEncoding.EncodeToString(testKey), r.Header.Get("X-Goog-Encryption-Key"), r.URL.String())
+			assert.Equal("AES256", r.Header.Get("X-Goog-Encryption-Algorithm"))
+			sent++
+		}
+	}
+	assert.Equal(1, sent)
+}
+
+func TestKMSKey(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	const key = "projects/p/locations/l/keyRings/r/cryptoKeys/k"
+	ss, fake, closeFn := newTestStreamStore(t, "kmskey="+key)
+	defer closeFn()
+
+	w, err := ss.CreateWriteCloser("/a")
+	require.NoError(err)
+	_, err = w.Write([]byte("hello"))
+	require.NoError(err)
+	require.NoError(w.Close())
+
+	var uploads int
+	for _, r := range fake.Requests() {
+		if r.Method == http.MethodPost {
+			assert.Equal(key, r.URL.Query().Get("kmsKeyName"))
+			assert.Empty(r.Header.Get("X-Goog-Encryption-Key"))
+			uploads++
+		}
+	}
+	assert.Equal(1, uploads)
+}
+
+func TestStatObjectAndDirectory(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, fake, closeFn := newTestStreamStore(t, "")
+	defer closeFn()
+
+	// other tools can create an object and a directory of the same name,
+	// which report the directory.
+	fake.objects["both"] = []byte{1}
+	fake.objects["both/f"] = []byte{1}
+	fi, err := ss.Stat("/both")
+	require.NoError(err)
+	assert.True(fi.IsDir())
+
+	fi, err = ss.Stat("/both/f")
+	require.NoError(err)
+	assert.False(fi.IsDir())
+}
+
+func TestIsDir(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, fake, closeFn := newTestStreamStore(t, "")
+	defer closeFn()
+
+	fake.objects["file"] = []byte{1}
+	fake.objects["marker/"] = nil
+	fake.objects["prefix/f"] = []byte{1}
+
+	for name, want := range map[string]bool{
+		"/file":   false,
+		"/marker": true,
+		"/prefix": true,
+	} {
+		isDir, err := ss.IsDir(name)
+		require.NoError(err, name)
+		assert.Equal(want, isDir, name)
+	}
+	_, err := ss.IsDir("/missing")
+	assert.True(os.IsNotExist(err))
+
+	// each listing asks for a single entry.
+	for _, r := range fake.Requests() {
+		if strings.HasSuffix(r.URL.Path, "/o") {
+			assert.Equal("1", r.URL.Query().Get("maxResults"))
+		}
+	}
+}
+
+func TestAppendWriteCloser(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, fake, closeFn := newTestStreamStore(t, "")
+	defer closeFn()
+
+	appendString := func(name, s string) error {
+		w, err := ss.AppendWriteCloser(name)
+		require.NoError(err)
+		_, err = w.Write([]byte(s))
+		require.NoError(err)
+		return w.Close()
+	}
+	tmpObjects := func() (names []string) {
+		fake.lk.Lock()
+		defer fake.lk.Unlock()
+		for name := range fake.objects {
+			if strings.HasSuffix(name, ".tmp") {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+	countRequests := func(method, suffix string) (n int) {
+		for _, r := range fake.Requests() {
+			if r.Method == method && strings.HasSuffix(r.URL.Path, suffix) {
+				n++
+			}
+		}
+		return n
+	}
+
+	// a missing object is created.
+	require.NoError(appendString("/a", "012"))
+	data, _, ok := fake.Object("a")
+	require.True(ok)
+	assert.Equal("012", string(data))
+	assert.Equal(0, countRequests(http.MethodPost, "/compose"))
+
+	// an existing object is composed with a temporary object holding what
+	// was written, keeping its attributes, only if it is unchanged.
+	fake.put("b", []byte("0123"), fakeAttrs{ContentType: "text/plain", Metadata: map[string]string{"owner": "me"}})
+	_, before, _ := fake.Object("b")
+	require.NoError(appendString("/b", "4567"))
+	data, attrs, _ := fake.Object("b")
+	assert.Equal("01234567", string(data))
+	assert.Equal("text/plain", attrs.ContentType)
+	assert.Equal(map[string]string{"owner": "me"}, attrs.Metadata)
+	assert.Empty(tmpObjects())
+
+	var composes, tmpUploads, tmpDeletes int
+	for _, r := range fake.Requests() {
+		switch {
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/b/compose"):
+			assert.Equal(strconv.FormatInt(before.Generation, 10), r.URL.Query().Get("ifGenerationMatch"))
+			composes++
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/o"):
+			// the temporary object is only created if it does not exist.
+			if q := r.URL.Query(); q.Get("ifGenerationMatch") != "" {
+				assert.Equal("0", q.Get("ifGenerationMatch"))
+				tmpUploads++
+			}
+		case r.Method == http.MethodDelete && strings.HasSuffix(r.URL.Path, ".tmp"):
+			assert.True(strings.HasPrefix(r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:], ".b."), r.URL.Path)
+			tmpDeletes++
+		}
+	}
+	assert.Equal(1, composes)
+	assert.Equal(1, tmpUploads)
+	assert.Equal(1, tmpDeletes)
+
+	// of concurrent appends, all but the first to close fail.
+	w1, err := ss.AppendWriteCloser("/b")
+	require.NoError(err)
+	w2, err := ss.AppendWriteCloser("/b")
+	require.NoError(err)
+	_, err = w1.Write([]byte("89"))
+	require.NoError(err)
+	_, err = w2.Write([]byte("ab"))
+	require.NoError(err)
+	require.NoError(w1.Close())
+	err = w2.Close()
+	assert.True(errors.Is(err, straw.ErrPreconditionFailed))
+	data, _, _ = fake.Object("b")
+	assert.Equal("0123456789", string(data))
+	assert.Empty(tmpObjects())
+
+	// closing without writing leaves the object alone.
+	composes = countRequests(http.MethodPost, "/compose")
+	w, err := ss.AppendWriteCloser("/b")
+	require.NoError(err)
+	require.NoError(w.Close())
+	assert.Equal(straw.ErrClosed, w.Close())
+	data, _, _ = fake.Object("b")
+	assert.Equal("0123456789", string(data))
+	assert.Equal(composes, countRequests(http.MethodPost, "/compose"))
+	assert.Empty(tmpObjects())
+
+	// directories cannot be appended to.
+	fake.put("dir/f", []byte{1}, fakeAttrs{})
+	_, err = ss.AppendWriteCloser("/dir")
+	assert.True(errors.Is(err, straw.ErrIsDirectory))
+}
+
+func TestRemoveAll(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, fake, closeFn := newTestStreamStore(t, "")
+	defer closeFn()
+
+	names := func() (names []string) {
+		fake.lk.Lock()
+		defer fake.lk.Unlock()
+		for name := range fake.objects {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return names
+	}
+	deletes := func() (keys []string) {
+		for _, r := range fake.Requests() {
+			if r.Method == http.MethodDelete {
+				keys = append(keys, r.URL.Path[strings.Index(r.URL.Path, "/o/")+3:])
+			}
+		}
+		sort.Strings(keys)
+		return keys
+	}
+	for _, name := range []string{"a", "a/", "a/1", "a/b/", "a/b/2", "ab"} {
+		fake.put(name, []byte(name), fakeAttrs{})
+	}
+
+	// everything under the name is deleted, and the name itself, which may
+	// also be a file. Keys which are already gone are ignored.
+	require.NoError(ss.RemoveAll("/a"))
+	assert.Equal([]string{"ab"}, names())
+	assert.Equal([]string{"a", "a/", "a/1", "a/b/", "a/b/2"}, deletes())
+
+	require.NoError(ss.RemoveAll("/ab"))
+	assert.Empty(names())
+	assert.NoError(ss.RemoveAll("/missing"))
+
+	// the root deletes everything, without deleting an empty key.
+	for _, name := range []string{"c", "d/", "d/1"} {
+		fake.put(name, []byte(name), fakeAttrs{})
+	}
+	before := len(deletes())
+	require.NoError(ss.RemoveAll("/"))
+	assert.Empty(names())
+	assert.Len(deletes(), before+3)
+
+	// failures to list or delete are returned.
+	fake.put("e/1", []byte{1}, fakeAttrs{})
+	fake.fail = func(r *http.Request) int {
+		if r.Method == http.MethodGet {
+			return http.StatusForbidden
+		}
+		return 0
+	}
+	before = len(deletes())
+	assert.Error(ss.RemoveAll("/e"))
+	assert.Len(deletes(), before)
+	assert.Equal([]string{"e/1"}, names())
+
+	fake.fail = func(r *http.Request) int {
+		if r.Method == http.MethodDelete {
+			return http.StatusForbidden
+		}
+		return 0
+	}
+	err := ss.RemoveAll("/e")
+	assert.Error(err)
+	assert.False(os.IsNotExist(err))
+	assert.Equal([]string{"e/1"}, names())
+}
+
+// objectGets returns the number of times the content of the object name was
+// fetched.
+func objectGets(fake *fakeGCS, name string) int {
+	var n int
+	for _, r := range fake.Requests() {
+		if r.Method == http.MethodGet && r.URL.Path == "/bucket/"+name {
+			n++
+		}
+	}
+	return n
+}
+
+func TestSmallObjectFetchedOnce(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, fake, closeFn := newTestStreamStore(t, "small_object_threshold=1KB")
+	defer closeFn()
+
+	data := []byte("0123456789")
+	fake.put("small", data, fakeAttrs{})
+
+	r, err := ss.OpenReadCloser("/small")
+	require.NoError(err)
+	_, ok := r.(*gcsSmallReader)
+	assert.True(ok)
+
+	buf := make([]byte, 4)
+	n, err := r.ReadAt(buf, 6)
+	assert.NoError(err)
+	assert.Equal(data[6:6+n], buf[:n])
+
+	n, err = r.Read(buf)
+	assert.NoError(err)
+	assert.Equal(data[:n], buf[:n])
+
+	_, err = r.Seek(2, io.SeekStart)
+	assert.NoError(err)
+	rest, err := ioutil.ReadAll(r)
+	assert.NoError(err)
+	assert.Equal(data[2:], rest)
+
+	n, err = r.ReadAt(buf, 8)
+	assert.Equal(io.EOF, err)
+	assert.Equal(data[8:], buf[:n])
+
+	assert.NoError(r.Close())
+	assert.Equal(1, objectGets(fake, "small"))
+
+	// objects at the threshold are read with ranged requests as needed.
+	ss.smallObjectThreshold = int64(len(data))
+	r, err = ss.OpenReadCloser("/small")
+	require.NoError(err)
+	_, ok = r.(*gcsSmallReader)
+	assert.False(ok)
+	rest, err = ioutil.ReadAll(r)
+	assert.NoError(err)
+	assert.Equal(data, rest)
+	assert.NoError(r.Close())
+	assert.Equal(2, objectGets(fake, "small"))
+}
+
+// writeTestFile writes data to the named file of ss.
+func writeTestFile(t *testing.T, ss *gcsStreamStore, name string, data []byte) {
+	w, err := ss.CreateWriteCloser(name)
+	require.NoError(t, err)
+	_, err = w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+}
+
+// readdirNames returns the names of the entries of the named directory.
+func readdirNames(t *testing.T, ss *gcsStreamStore, name string) []string {
+	fis, err := ss.Readdir(name)
+	require.NoError(t, err)
+	var names []string
+	for _, fi := range fis {
+		names = append(names, fi.Name())
+	}
+	return names
+}
+
+func TestDirModeMarker(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, fake, closeFn := newTestStreamStore(t, "dir_mode=marker")
+	defer closeFn()
+
+	require.NoError(ss.Mkdir("/a", 0755))
+	_, _, ok := fake.Object("a/")
+	assert.True(ok)
+
+	fi, err := ss.Stat("/a")
+	require.NoError(err)
+	assert.True(fi.IsDir())
+
+	writeTestFile(t, ss, "/a/f", []byte{1})
+	assert.Equal([]string{"a"}, readdirNames(t, ss, "/"))
+	assert.Equal([]string{"f"}, readdirNames(t, ss, "/a"))
+
+	assert.True(errors.Is(ss.Remove("/a"), straw.ErrDirectoryNotEmpty))
+	require.NoError(ss.Remove("/a/f"))
+
+	// the marker keeps the empty directory in existence.
+	fi, err = ss.Stat("/a")
+	require.NoError(err)
+	assert.True(fi.IsDir())
+
+	require.NoError(ss.Remove("/a"))
+	_, err = ss.Stat("/a")
+	assert.True(os.IsNotExist(err))
+	_, _, ok = fake.Object("a/")
+	assert.False(ok)
+
+	// files cannot be created in missing directories.
+	_, err = ss.CreateWriteCloser("/missing/f")
+	assert.True(os.IsNotExist(err))
+}
+
+func TestDirModePrefix(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, fake, closeFn := newTestStreamStore(t, "dir_mode=prefix")
+	defer closeFn()
+
+	require.NoError(ss.Mkdir("/a", 0755))
+	_, _, ok := fake.Object("a/")
+	assert.False(ok)
+
+	// an empty directory cannot be represented.
+	_, err := ss.Stat("/a")
+	assert.True(os.IsNotExist(err))
+
+	writeTestFile(t, ss, "/a/b/f", []byte{1})
+	assert.Equal([]string{"a"}, readdirNames(t, ss, "/"))
+	assert.Equal([]string{"b"}, readdirNames(t, ss, "/a"))
+	assert.Equal([]string{"f"}, readdirNames(t, ss, "/a/b"))
+
+	fi, err := ss.Stat("/a/b")
+	require.NoError(err)
+	assert.True(fi.IsDir())
+
+	assert.True(errors.Is(ss.Remove("/a/b"), straw.ErrDirectoryNotEmpty))
+	require.NoError(ss.Remove("/a/b/f"))
+
+	_, err = ss.Stat("/a")
+	assert.True(os.IsNotExist(err))
+	assert.Empty(readdirNames(t, ss, "/"))
+	assert.True(os.IsNotExist(ss.Remove("/a/b")))
+}
+
+func TestDirModeInterop(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	for _, mode := range []string{"marker", "prefix"} {
+		u := &url.URL{Scheme: "gs", Host: "bucket"}
+		GCSDirMode(mode)(u)
+
+		ss, fake, closeFn := newTestStreamStore(t, u.RawQuery)
+
+		// a folder made with the console, and one implied by its files,
+		// alongside keys which sort before its own.
+		fake.put("console/", nil, fakeAttrs{})
+		fake.put("implied-1", []byte{1}, fakeAttrs{})
+		fake.put("implied-2", []byte{1}, fakeAttrs{})
+		fake.put("implied/f", []byte{1}, fakeAttrs{})
+
+		assert.Equal([]string{"console", "implied", "implied-1", "implied-2"}, readdirNames(t, ss, "/"), mode)
+		for _, name := range []string{"/console", "/implied"} {
+			fi, err := ss.Stat(name)
+			require.NoError(err, mode)
+			assert.True(fi.IsDir(), mode)
+		}
+		assert.Empty(readdirNames(t, ss, "/console"), mode)
+		assert.Equal([]string{"f"}, readdirNames(t, ss, "/implied"), mode)
+
+		// an object and a directory of the same name report the directory.
+		fake.put("both", []byte{1}, fakeAttrs{})
+		fake.put("both-1", []byte{1}, fakeAttrs{})
+		fake.put("both/f", []byte{1}, fakeAttrs{})
+		fi, err := ss.Stat("/both")
+		require.NoError(err, mode)
+		assert.True(fi.IsDir(), mode)
+
+		require.NoError(ss.Remove("/console"), mode)
+		_, err = ss.Stat("/console")
+		assert.True(os.IsNotExist(err), mode)
+		closeFn()
+	}
+}
+
+func TestInvalidDirMode(t *testing.T) {
+	u, err := url.Parse("gs://bucket/?anonymous=true&dir_mode=folders")
+	require.NoError(t, err)
+	_, err = newGCSStreamStore(u)
+	assert.EqualError(t, err, `invalid "dir_mode" query parameter: unknown mode "folders"`)
+}
+
+func TestListDirs(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, fake, closeFn := newTestStreamStore(t, "")
+	defer closeFn()
+
+	dirs, err := ss.ListDirs("/")
+	require.NoError(err)
+	assert.Empty(dirs)
+
+	fake.put("a/f", []byte{1}, fakeAttrs{})
+	fake.put("a/y/f", []byte{1}, fakeAttrs{})
+	fake.put("a/x/", nil, fakeAttrs{})
+
+	dirs, err = ss.ListDirs("/a")
+	require.NoError(err)
+	assert.Equal([]string{"x", "y"}, dirs)
+	dirs, err = ss.ListDirs("/")
+	require.NoError(err)
+	assert.Equal([]string{"a"}, dirs)
+
+	// an empty directory with a marker exists, and a missing one fails as
+	// Readdir would.
+	dirs, err = ss.ListDirs("/a/x")
+	require.NoError(err)
+	assert.Empty(dirs)
+	_, err = ss.ListDirs("/missing")
+	assert.True(os.IsNotExist(err))
+	var pathErr *os.PathError
+	require.True(errors.As(err, &pathErr))
+	assert.Equal("/missing", pathErr.Path)
+}
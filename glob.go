@@ -0,0 +1,118 @@
+package straw
+
+import (
+	"os"
+	"path"
+	"strings"
+)
+
+// PrefixLister is implemented by stores that can list only the entries of a
+// directory whose names begin with a prefix, such as object stores which can
+// list the keys under a prefix. The prefix does not contain "/".
+type PrefixLister interface {
+	ListPrefix(name, prefix string) ([]os.FileInfo, error)
+}
+
+// Glob returns the names of all files and directories matching pattern, with
+// the syntax of path.Match, or nil if there are none. Only the directories
+// needed to resolve the pattern are listed, and if ss implements
+// PrefixLister, only the entries beginning with the literal text at the start
+// of each element of the pattern. The only possible error is
+// path.ErrBadPattern, when pattern is malformed, or an error listing a
+// directory other than it not existing.
+func Glob(ss StreamStore, pattern string) ([]string, error) {
+	// check the whole pattern, as Match only reports errors it reaches.
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	if !hasMeta(pattern) {
+		if _, err := ss.Lstat(pattern); err != nil {
+			if os.IsNotExist(err) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		return []string{pattern}, nil
+	}
+
+	dir, file := path.Split(pattern)
+	dir = cleanGlobPath(dir)
+
+	if !hasMeta(dir) {
+		return glob(ss, dir, file, nil)
+	}
+	// dir cannot be the same as pattern, as dir is shorter.
+	dirs, err := Glob(ss, dir)
+	if err != nil {
+		return nil, err
+	}
+	var matches []string
+	for _, d := range dirs {
+		if matches, err = glob(ss, d, file, matches); err != nil {
+			return nil, err
+		}
+	}
+	return matches, nil
+}
+
+// cleanGlobPath prepares dir for matching, as filepath.Glob does.
+func cleanGlobPath(dir string) string {
+	switch dir {
+	case "":
+		return "."
+	case "/":
+		return dir
+	default:
+		return dir[:len(dir)-1]
+	}
+}
+
+// glob appends to matches the entries of dir matching pattern, which has no
+// separators. dir not existing, or not being a directory, is not an error.
+func glob(ss StreamStore, dir, pattern string, matches []string) ([]string, error) {
+	fi, err := ss.Stat(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return matches, nil
+		}
+		return matches, err
+	}
+	if !fi.IsDir() {
+		return matches, nil
+	}
+
+	var fis []os.FileInfo
+	if pl, ok := ss.(PrefixLister); ok && literalPrefix(pattern) != "" {
+		fis, err = pl.ListPrefix(dir, literalPrefix(pattern))
+	} else {
+		fis, err = ss.Readdir(dir)
+	}
+	if err != nil {
+		return matches, err
+	}
+	for _, fi := range fis {
+		matched, err := path.Match(pattern, fi.Name())
+		if err != nil {
+			return matches, err
+		}
+		if matched {
+			matches = append(matches, path.Join(dir, fi.Name()))
+		}
+	}
+	return matches, nil
+}
+
+// hasMeta reports whether p contains any of the magic characters recognized
+// by path.Match.
+func hasMeta(p string) bool {
+	return strings.ContainsAny(p, `*?[\`)
+}
+
+// literalPrefix returns the text at the start of pattern before any magic
+// characters.
+func literalPrefix(pattern string) string {
+	if i := strings.IndexAny(pattern, `*?[\`); i >= 0 {
+		return pattern[:i]
+	}
+	return pattern
+}
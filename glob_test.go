@@ -0,0 +1,81 @@
+package straw_test
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+func newGlobTree(t *testing.T) straw.StreamStore {
+	ss, err := straw.Open("mem://")
+	require.NoError(t, err)
+	for _, dir := range []string{"/a", "/a/sub", "/b"} {
+		require.NoError(t, ss.Mkdir(dir, 0755))
+	}
+	for _, name := range []string{"/a/x.json", "/a/y.txt", "/a/sub/w.json", "/b/z.json", "/top.json"} {
+		require.NoError(t, putFile(ss, name, []byte{1}))
+	}
+	return ss
+}
+
+func TestGlob(t *testing.T) {
+	assert := assert.New(t)
+
+	ss := newGlobTree(t)
+
+	for pattern, expected := range map[string][]string{
+		"/*.json":      {"/top.json"},
+		"/a/*.json":    {"/a/x.json"},
+		"/*/*.json":    {"/a/x.json", "/b/z.json"},
+		"/*/*/*.json":  {"/a/sub/w.json"},
+		"/a/[xy].*":    {"/a/x.json", "/a/y.txt"},
+		"/?":           {"/a", "/b"},
+		"/a/x.json":    {"/a/x.json"},
+		"/a/*.csv":     nil,
+		"/missing/*":   nil,
+		"/a/x.json/*":  nil,
+		"/missing.txt": nil,
+	} {
+		matches, err := straw.Glob(ss, pattern)
+		assert.NoError(err, pattern)
+		assert.Equal(expected, matches, pattern)
+	}
+
+	_, err := straw.Glob(ss, "/a/[")
+	assert.Equal(path.ErrBadPattern, err)
+}
+
+// prefixListingStore records the prefixes it is asked to list.
+type prefixListingStore struct {
+	straw.StreamStore
+	prefixes []string
+}
+
+func (s *prefixListingStore) ListPrefix(name, prefix string) ([]os.FileInfo, error) {
+	s.prefixes = append(s.prefixes, name+":"+prefix)
+	fis, err := s.Readdir(name)
+	var matching []os.FileInfo
+	for _, fi := range fis {
+		if strings.HasPrefix(fi.Name(), prefix) {
+			matching = append(matching, fi)
+		}
+	}
+	return matching, err
+}
+
+func TestGlobPrefixLister(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss := &prefixListingStore{StreamStore: newGlobTree(t)}
+
+	matches, err := straw.Glob(ss, "/a*/x*.json")
+	require.NoError(err)
+	assert.Equal([]string{"/a/x.json"}, matches)
+	assert.Equal([]string{"/:a", "/a:x"}, ss.prefixes)
+}
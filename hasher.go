@@ -0,0 +1,44 @@
+package straw
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+)
+
+// Hasher is implemented by stores which can provide a hash of the content of
+// a file without it being read by the client, such as the ETag of an S3
+// object. The hash is a string in the store's own format, which changes
+// whenever the content does, so hashes can be compared with earlier hashes of
+// files from the same store to detect changes, but not with those from other
+// backends, or with checksums computed with Checksum.
+type Hasher interface {
+	ContentHash(name string) (string, error)
+}
+
+// ContentHash returns a hash of the content of the named file, using Hasher if
+// ss implements it, or otherwise by reading the file and returning the hex
+// encoded MD5 of its content.
+func ContentHash(ss StreamStore, name string) (string, error) {
+	if h, ok := ss.(Hasher); ok {
+		return h.ContentHash(name)
+	}
+	r, err := ss.OpenReadCloser(name)
+	if err != nil {
+		return "", err
+	}
+	sum, err := md5Hex(r)
+	if cerr := r.Close(); err == nil {
+		err = cerr
+	}
+	return sum, err
+}
+
+// md5Hex returns the hex encoded MD5 of the content of r.
+func md5Hex(r io.Reader) (string, error) {
+	h := md5.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,92 @@
+package straw_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+const helloMD5 = "5d41402abc4b2a76b9719d911017c592"
+
+func TestContentHashMem(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, _ := straw.Open("mem://")
+	writeContent(t, ss, "/a", []byte("hello"))
+	require.NoError(ss.Mkdir("/dir", 0755))
+
+	sum, err := straw.ContentHash(ss, "/a")
+	require.NoError(err)
+	assert.Equal(helloMD5, sum)
+
+	writeContent(t, ss, "/a", []byte("jello"))
+	sum, err = straw.ContentHash(ss, "/a")
+	require.NoError(err)
+	assert.NotEqual(helloMD5, sum)
+
+	_, err = straw.ContentHash(ss, "/missing")
+	assertPathError(t, err, "/missing", os.ErrNotExist)
+	_, err = straw.ContentHash(ss, "/dir")
+	assertPathError(t, err, "/dir", straw.ErrIsDirectory)
+}
+
+func TestContentHashOS(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "straw_hasher_test_")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "a")
+	require.NoError(ioutil.WriteFile(name, []byte("hello"), 0600))
+	mtime := time.Now().Add(-time.Hour)
+	require.NoError(os.Chtimes(name, mtime, mtime))
+
+	ss, err := straw.Open("file:///")
+	require.NoError(err)
+
+	sum, err := straw.ContentHash(ss, name)
+	require.NoError(err)
+	assert.Equal(helloMD5, sum)
+
+	// the hash is reused while the size and modification time are unchanged.
+	require.NoError(ioutil.WriteFile(name, []byte("jello"), 0600))
+	require.NoError(os.Chtimes(name, mtime, mtime))
+	sum, err = straw.ContentHash(ss, name)
+	require.NoError(err)
+	assert.Equal(helloMD5, sum)
+
+	require.NoError(os.Chtimes(name, time.Now(), time.Now()))
+	sum, err = straw.ContentHash(ss, name)
+	require.NoError(err)
+	assert.NotEqual(helloMD5, sum)
+
+	_, err = straw.ContentHash(ss, dir)
+	assertPathError(t, err, dir, straw.ErrIsDirectory)
+	_, err = straw.ContentHash(ss, filepath.Join(dir, "missing"))
+	assert.True(os.IsNotExist(err))
+}
+
+func TestContentHashFallback(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	mem, _ := straw.Open("mem://")
+	writeContent(t, mem, "/a", []byte("hello"))
+	rec := &TestRecordingStreamStore{wrapped: mem}
+
+	sum, err := straw.ContentHash(rec, "/a")
+	require.NoError(err)
+	assert.Equal(helloMD5, sum)
+	assert.Equal([]string{"OpenReadCloser /a"}, rec.Calls("OpenReadCloser"))
+
+	_, err = straw.ContentHash(rec, "/missing")
+	assert.True(os.IsNotExist(err))
+}
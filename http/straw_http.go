@@ -0,0 +1,406 @@
+// Package http provides a read-only StreamStore for files served over HTTP
+// and HTTPS, registered under the "http" and "https" schemes.
+//
+// Paths are resolved relative to the path of the store's URL, so a store
+// opened with "https://example.com/artifacts" reads "/v1/app.tar" from
+// "https://example.com/artifacts/v1/app.tar". Any query string on the URL is
+// sent with every request. Random access uses HTTP Range requests, so the
+// server must support them for ReadAt and Seek to work. HTTP has no notion of
+// directories, so Readdir, and all operations which modify the store, return
+// straw.ErrNotSupported.
+package http
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	nethttp "net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/uw-labs/straw"
+)
+
+var _ straw.StreamStore = &httpStreamStore{}
+var _ straw.ContextStreamStore = &httpStreamStore{}
+
+func init() {
+	for _, scheme := range []string{"http", "https"} {
+		scheme := scheme
+		straw.Register(scheme, func(u *url.URL) (straw.StreamStore, error) {
+			return newHTTPStreamStore(u, nethttp.DefaultClient)
+		})
+	}
+}
+
+func newHTTPStreamStore(u *url.URL, client *nethttp.Client) (*httpStreamStore, error) {
+	if u.Host == "" {
+		return nil, fmt.Errorf("%s URLs must provide a host", u.Scheme)
+	}
+	base := *u
+	base.Path = path.Clean("/" + base.Path)
+	base.RawPath = ""
+	base.Fragment = ""
+	return &httpStreamStore{client: client, base: &base}, nil
+}
+
+type httpStreamStore struct {
+	client *nethttp.Client
+	base   *url.URL
+}
+
+func (fs *httpStreamStore) Close() error {
+	fs.client.CloseIdleConnections()
+	return nil
+}
+
+func (fs *httpStreamStore) Scheme() string {
+	return fs.base.Scheme
+}
+
+// url returns the URL of the file name, which must already be clean.
+func (fs *httpStreamStore) url(name string) string {
+	u := *fs.base
+	u.Path = path.Join(u.Path, filepath.ToSlash(name))
+	return u.String()
+}
+
+// do issues a request for name, returning an *os.PathError for op wrapping
+// os.ErrNotExist or os.ErrPermission for the corresponding status codes, and
+// an error for any other status which is not 2xx or listed in accept.
+func (fs *httpStreamStore) do(ctx context.Context, op, method, name string, header nethttp.Header, accept ...int) (*nethttp.Response, error) {
+	req, err := nethttp.NewRequest(method, fs.url(name), nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := fs.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	for _, code := range accept {
+		if resp.StatusCode == code {
+			return resp, nil
+		}
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case nethttp.StatusNotFound, nethttp.StatusGone:
+		return nil, &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+	case nethttp.StatusUnauthorized, nethttp.StatusForbidden:
+		return nil, &os.PathError{Op: op, Path: name, Err: os.ErrPermission}
+	default:
+		return nil, fmt.Errorf("%s : unexpected HTTP status %q", name, resp.Status)
+	}
+}
+
+// Lstat is Stat, as HTTP has no symbolic links.
+func (fs *httpStreamStore) Lstat(name string) (os.FileInfo, error) {
+	return fs.LstatContext(context.Background(), name)
+}
+
+func (fs *httpStreamStore) LstatContext(ctx context.Context, name string) (os.FileInfo, error) {
+	return fs.StatContext(ctx, name)
+}
+
+func (fs *httpStreamStore) Stat(name string) (os.FileInfo, error) {
+	return fs.StatContext(context.Background(), name)
+}
+
+func (fs *httpStreamStore) StatContext(ctx context.Context, name string) (os.FileInfo, error) {
+	name, err := straw.CleanPath(name)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := fs.do(ctx, "stat", nethttp.MethodHead, name, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return newStatResult(name, resp), nil
+}
+
+func newStatResult(name string, resp *nethttp.Response) *httpStatResult {
+	sr := &httpStatResult{
+		name: filepath.Base(name),
+		size: resp.ContentLength,
+	}
+	if lm := resp.Header.Get("Last-Modified"); lm != "" {
+		sr.modTime, _ = nethttp.ParseTime(lm)
+	}
+	return sr
+}
+
+func (fs *httpStreamStore) OpenReadCloser(name string) (straw.StrawReader, error) {
+	return fs.OpenReadCloserContext(context.Background(), name)
+}
+
+func (fs *httpStreamStore) OpenReadCloserContext(ctx context.Context, name string) (straw.StrawReader, error) {
+	name, err := straw.CleanPath(name)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := fs.do(ctx, "open", nethttp.MethodGet, name, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &httpReader{
+		ctx:  ctx,
+		fs:   fs,
+		name: name,
+		size: resp.ContentLength,
+		body: resp.Body,
+	}, nil
+}
+
+func (fs *httpStreamStore) Readdir(name string) ([]os.FileInfo, error) {
+	return fs.ReaddirContext(context.Background(), name)
+}
+
+func (fs *httpStreamStore) ReaddirContext(ctx context.Context, name string) ([]os.FileInfo, error) {
+	return nil, straw.ErrNotSupported
+}
+
+func (fs *httpStreamStore) CreateWriteCloser(name string) (straw.StrawWriter, error) {
+	return fs.CreateWriteCloserContext(context.Background(), name)
+}
+
+func (fs *httpStreamStore) CreateWriteCloserContext(ctx context.Context, name string) (straw.StrawWriter, error) {
+	return nil, straw.ErrNotSupported
+}
+
+// OpenFile supports only os.O_RDONLY, opening the file as OpenReadCloser
+// does.
+func (fs *httpStreamStore) OpenFile(name string, flag int, perm os.FileMode) (straw.StrawReadWriteCloser, error) {
+	if flag != os.O_RDONLY {
+		return nil, straw.ErrNotSupported
+	}
+	return straw.OpenFileBuffered(fs, name, flag, nil)
+}
+
+func (fs *httpStreamStore) Mkdir(name string, mode os.FileMode) error {
+	return fs.MkdirContext(context.Background(), name, mode)
+}
+
+func (fs *httpStreamStore) MkdirContext(ctx context.Context, name string, mode os.FileMode) error {
+	return straw.ErrNotSupported
+}
+
+func (fs *httpStreamStore) Remove(name string) error {
+	return fs.RemoveContext(context.Background(), name)
+}
+
+func (fs *httpStreamStore) RemoveContext(ctx context.Context, name string) error {
+	return straw.ErrNotSupported
+}
+
+func (fs *httpStreamStore) Chmod(name string, mode os.FileMode) error {
+	return fs.ChmodContext(context.Background(), name, mode)
+}
+
+func (fs *httpStreamStore) ChmodContext(ctx context.Context, name string, mode os.FileMode) error {
+	return straw.ErrNotSupported
+}
+
+func (fs *httpStreamStore) Truncate(name string, size int64) error {
+	return straw.ErrNotSupported
+}
+
+func (fs *httpStreamStore) Copy(src, dst string) error {
+	return fs.CopyContext(context.Background(), src, dst)
+}
+
+func (fs *httpStreamStore) CopyContext(ctx context.Context, src, dst string) error {
+	return straw.ErrNotSupported
+}
+
+var _ straw.StrawReader = &httpReader{}
+
+// httpReader streams the body of a GET request. Seeking discards the body,
+// and the next Read issues a new GET for the range from the new offset.
+// ReadAt issues a ranged GET for each call.
+type httpReader struct {
+	ctx  context.Context
+	fs   *httpStreamStore
+	name string
+	size int64
+	pos  int64
+	body io.ReadCloser
+}
+
+func (r *httpReader) Read(buf []byte) (int, error) {
+	if r.fs == nil {
+		return 0, os.ErrClosed
+	}
+	if r.body == nil {
+		if r.size >= 0 && r.pos >= r.size {
+			return 0, io.EOF
+		}
+		body, err := r.openRange(r.pos, -1)
+		if err != nil {
+			return 0, err
+		}
+		r.body = body
+	}
+	n, err := r.body.Read(buf)
+	r.pos += int64(n)
+	return n, err
+}
+
+// WriteTo streams the rest of the body to w, letting w read from it directly
+// if it implements io.ReaderFrom.
+func (r *httpReader) WriteTo(w io.Writer) (int64, error) {
+	if r.fs == nil {
+		return 0, os.ErrClosed
+	}
+	if r.body == nil {
+		if r.size >= 0 && r.pos >= r.size {
+			return 0, nil
+		}
+		body, err := r.openRange(r.pos, -1)
+		if err != nil {
+			return 0, err
+		}
+		r.body = body
+	}
+	n, err := io.Copy(w, r.body)
+	r.pos += n
+	return n, err
+}
+
+func (r *httpReader) ReadAt(p []byte, off int64) (int, error) {
+	if r.fs == nil {
+		return 0, os.ErrClosed
+	}
+	if off < 0 {
+		return 0, fmt.Errorf("%s : negative offset", r.name)
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if r.size >= 0 && off >= r.size {
+		return 0, io.EOF
+	}
+	body, err := r.openRange(off, off+int64(len(p))-1)
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+	n, err := io.ReadFull(body, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// openRange returns the body of the file from offset start to end
+// inclusive, or to the end of the file if end is negative.
+func (r *httpReader) openRange(start, end int64) (io.ReadCloser, error) {
+	rng := "bytes=" + strconv.FormatInt(start, 10) + "-"
+	if end >= 0 {
+		rng += strconv.FormatInt(end, 10)
+	}
+	header := nethttp.Header{"Range": {rng}}
+	resp, err := r.fs.do(r.ctx, "read", nethttp.MethodGet, r.name, header, nethttp.StatusRequestedRangeNotSatisfiable)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case nethttp.StatusPartialContent:
+		return resp.Body, nil
+	case nethttp.StatusRequestedRangeNotSatisfiable:
+		resp.Body.Close()
+		return nethttp.NoBody, nil
+	default:
+		// the server ignored the range and is sending the whole file.
+		if _, err := io.CopyN(ioutil.Discard, resp.Body, start); err != nil {
+			resp.Body.Close()
+			if err == io.EOF {
+				return nethttp.NoBody, nil
+			}
+			return nil, err
+		}
+		return resp.Body, nil
+	}
+}
+
+func (r *httpReader) Seek(offset int64, whence int) (int64, error) {
+	if r.fs == nil {
+		return 0, os.ErrClosed
+	}
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = r.pos + offset
+	case io.SeekEnd:
+		if r.size < 0 {
+			return 0, fmt.Errorf("%s : cannot seek from end, size unknown", r.name)
+		}
+		pos = r.size + offset
+	default:
+		return 0, fmt.Errorf("%s : invalid whence %d", r.name, whence)
+	}
+	if pos < 0 {
+		return 0, fmt.Errorf("%s : negative position", r.name)
+	}
+	if pos != r.pos && r.body != nil {
+		r.body.Close()
+		r.body = nil
+	}
+	r.pos = pos
+	return pos, nil
+}
+
+func (r *httpReader) Close() error {
+	if r.fs == nil {
+		return os.ErrClosed
+	}
+	r.fs = nil
+	if r.body != nil {
+		return r.body.Close()
+	}
+	return nil
+}
+
+type httpStatResult struct {
+	name    string
+	modTime time.Time
+	size    int64
+}
+
+func (sr *httpStatResult) Name() string {
+	return sr.name
+}
+
+func (sr *httpStatResult) IsDir() bool {
+	return false
+}
+
+func (sr *httpStatResult) Size() int64 {
+	return sr.size
+}
+
+func (sr *httpStatResult) ModTime() time.Time {
+	return sr.modTime
+}
+
+func (sr *httpStatResult) Mode() os.FileMode {
+	return 0444
+}
+
+func (sr *httpStatResult) Sys() interface{} {
+	return nil
+}
@@ -0,0 +1,175 @@
+package http
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	nethttp "net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+var testModTime = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// newTestServer serves content at /files/data.bin, recording the Range
+// header of each request.
+func newTestServer(t *testing.T, content []byte) (straw.StreamStore, func() []string, func()) {
+	var (
+		lk     sync.Mutex
+		ranges []string
+	)
+	srv := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if r.URL.Path != "/files/data.bin" {
+			nethttp.NotFound(w, r)
+			return
+		}
+		lk.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		lk.Unlock()
+		nethttp.ServeContent(w, r, "data.bin", testModTime, bytes.NewReader(content))
+	}))
+	ss, err := straw.Open(srv.URL + "/files")
+	require.NoError(t, err)
+	return ss, func() []string {
+		lk.Lock()
+		defer lk.Unlock()
+		return append([]string(nil), ranges...)
+	}, srv.Close
+}
+
+func TestStat(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, _, done := newTestServer(t, []byte("0123456789"))
+	defer done()
+
+	fi, err := ss.Stat("/data.bin")
+	require.NoError(err)
+	assert.Equal("data.bin", fi.Name())
+	assert.Equal(int64(10), fi.Size())
+	assert.True(testModTime.Equal(fi.ModTime()))
+	assert.False(fi.IsDir())
+
+	_, err = ss.Stat("/missing")
+	assert.True(os.IsNotExist(err))
+}
+
+func TestOpenReadCloser(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, ranges, done := newTestServer(t, []byte("0123456789"))
+	defer done()
+
+	rc, err := ss.OpenReadCloser("/data.bin")
+	require.NoError(err)
+	defer rc.Close()
+	data, err := ioutil.ReadAll(rc)
+	require.NoError(err)
+	assert.Equal("0123456789", string(data))
+	assert.Equal([]string{""}, ranges())
+
+	_, err = ss.OpenReadCloser("/missing")
+	assert.True(os.IsNotExist(err))
+}
+
+func TestReadAt(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, ranges, done := newTestServer(t, []byte("0123456789"))
+	defer done()
+
+	rc, err := ss.OpenReadCloser("/data.bin")
+	require.NoError(err)
+	defer rc.Close()
+
+	buf := make([]byte, 3)
+	n, err := rc.ReadAt(buf, 4)
+	require.NoError(err)
+	assert.Equal("456", string(buf[:n]))
+
+	n, err = rc.ReadAt(buf, 8)
+	assert.Equal(io.EOF, err)
+	assert.Equal("89", string(buf[:n]))
+
+	_, err = rc.ReadAt(buf, 10)
+	assert.Equal(io.EOF, err)
+
+	assert.Equal([]string{"", "bytes=4-6", "bytes=8-10"}, ranges())
+}
+
+func TestSeek(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, ranges, done := newTestServer(t, []byte("0123456789"))
+	defer done()
+
+	rc, err := ss.OpenReadCloser("/data.bin")
+	require.NoError(err)
+	defer rc.Close()
+
+	buf := make([]byte, 2)
+	_, err = io.ReadFull(rc, buf)
+	require.NoError(err)
+	assert.Equal("01", string(buf))
+
+	pos, err := rc.Seek(-3, io.SeekEnd)
+	require.NoError(err)
+	assert.Equal(int64(7), pos)
+
+	data, err := ioutil.ReadAll(rc)
+	require.NoError(err)
+	assert.Equal("789", string(data))
+
+	assert.Equal([]string{"", "bytes=7-"}, ranges())
+}
+
+func TestWriteTo(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, ranges, done := newTestServer(t, []byte("0123456789"))
+	defer done()
+
+	rc, err := ss.OpenReadCloser("/data.bin")
+	require.NoError(err)
+	defer rc.Close()
+
+	_, err = rc.Seek(3, io.SeekStart)
+	require.NoError(err)
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, rc)
+	require.NoError(err)
+	assert.Equal(int64(7), n)
+	assert.Equal("3456789", buf.String())
+
+	n, err = rc.(io.WriterTo).WriteTo(&buf)
+	require.NoError(err)
+	assert.Equal(int64(0), n)
+
+	assert.Equal([]string{"", "bytes=3-"}, ranges())
+}
+
+func TestNotSupported(t *testing.T) {
+	assert := assert.New(t)
+
+	ss, _, done := newTestServer(t, nil)
+	defer done()
+
+	_, err := ss.CreateWriteCloser("/new")
+	assert.Equal(straw.ErrNotSupported, err)
+	assert.Equal(straw.ErrNotSupported, ss.Mkdir("/dir", 0755))
+	assert.Equal(straw.ErrNotSupported, ss.Remove("/data.bin"))
+	_, err = ss.Readdir("/")
+	assert.Equal(straw.ErrNotSupported, err)
+}
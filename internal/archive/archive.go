@@ -0,0 +1,301 @@
+// Package archive implements a read-only straw.StreamStore over an index of
+// the members of an archive, for the backends of archive formats such as tar
+// and zip. A backend adds each member to a Store, and the Store answers
+// Stat and Readdir from the index, synthesizing any directories which the
+// archive lacks entries for, and calls back to the backend to open members.
+package archive
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/uw-labs/straw"
+)
+
+var _ straw.StreamStore = &Store{}
+var _ straw.SymlinkStore = &Store{}
+var _ straw.Schemer = &Store{}
+
+// maxLinks is the number of symbolic links followed while resolving a path
+// before giving up, as Linux does.
+const maxLinks = 40
+
+var (
+	errNotLink      = errors.New("not a symbolic link")
+	errTooManyLinks = errors.New("too many levels of symbolic links")
+)
+
+// Member is an entry of an archive.
+type Member struct {
+	// Name is the path of the member within the archive. Leading "/" and
+	// "./" elements, and any trailing "/", are ignored.
+	Name string
+	// Info describes the member. Its mode determines whether it is a
+	// directory, a symbolic link or a regular file, and its Name is
+	// replaced with the last element of Name.
+	Info os.FileInfo
+	// Link is the destination of a symbolic link, which may be relative to
+	// the directory containing the member.
+	Link string
+	// Data is passed to the open function of the Store to open the member,
+	// and may be anything the backend needs to do so.
+	Data interface{}
+}
+
+// Store is a read-only StreamStore over the members of an archive.
+type Store struct {
+	scheme string
+	closer io.Closer
+	open   func(data interface{}) (straw.StrawReader, error)
+	root   *node
+}
+
+type node struct {
+	fi       os.FileInfo
+	link     string
+	data     interface{}
+	children map[string]*node
+}
+
+// New returns an empty Store for the backend with the URL scheme scheme,
+// which opens the regular file members added to it by calling open with
+// their Data, and closes closer, if it is not nil, when it is closed.
+func New(scheme string, closer io.Closer, open func(data interface{}) (straw.StrawReader, error)) *Store {
+	return &Store{
+		scheme: scheme,
+		closer: closer,
+		open:   open,
+		root:   newDir("/", time.Time{}),
+	}
+}
+
+func newDir(name string, modTime time.Time) *node {
+	return &node{
+		fi:       &fileInfo{name: name, mode: os.ModeDir | 0755, modTime: modTime},
+		children: make(map[string]*node),
+	}
+}
+
+// Add adds m to the index, creating any of its parent directories which
+// have not been added. A member replaces any earlier member with the same
+// name, except that a directory keeps its existing contents. Names which
+// would escape the root of the archive are rejected with an error wrapping
+// straw.ErrInvalidPath.
+func (s *Store) Add(m Member) error {
+	name, err := straw.CleanPath(strings.TrimLeft(m.Name, "/"))
+	if err != nil {
+		return err
+	}
+	if name == "." {
+		if m.Info.IsDir() {
+			return nil
+		}
+		return fmt.Errorf("archive member %q is not a directory", m.Name)
+	}
+	elems := strings.Split(name, "/")
+	dir := s.root
+	for i, elem := range elems[:len(elems)-1] {
+		child, ok := dir.children[elem]
+		if !ok {
+			child = newDir(elem, m.Info.ModTime())
+			dir.children[elem] = child
+		} else if !child.fi.IsDir() {
+			return fmt.Errorf("archive member %q: %q is not a directory", m.Name, strings.Join(elems[:i+1], "/"))
+		}
+		dir = child
+	}
+
+	base := elems[len(elems)-1]
+	n := &node{
+		fi:   &fileInfo{name: base, size: m.Info.Size(), mode: m.Info.Mode(), modTime: m.Info.ModTime(), sys: m.Info.Sys()},
+		link: m.Link,
+		data: m.Data,
+	}
+	if n.fi.IsDir() {
+		n.children = make(map[string]*node)
+		if old, ok := dir.children[base]; ok && old.fi.IsDir() {
+			n.children = old.children
+		}
+	}
+	dir.children[base] = n
+	return nil
+}
+
+// lookup returns the node for name, following symbolic links other than the
+// final element unless follow is set.
+func (s *Store) lookup(op, name string, follow bool) (*node, error) {
+	clean, err := straw.CleanPath(name)
+	if err != nil {
+		return nil, err
+	}
+	n, err := s.resolve(path.Join("/", clean), follow, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: op, Path: name, Err: err}
+	}
+	return n, nil
+}
+
+// resolve returns the node for the clean, absolute path p, links being the
+// number of symbolic links already followed to reach it.
+func (s *Store) resolve(p string, follow bool, links int) (*node, error) {
+	n := s.root
+	dir := "/"
+	elems := strings.Split(strings.TrimPrefix(p, "/"), "/")
+	if p == "/" {
+		elems = nil
+	}
+	for i, elem := range elems {
+		if !n.fi.IsDir() {
+			return nil, straw.ErrNotDirectory
+		}
+		child, ok := n.children[elem]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		last := i == len(elems)-1
+		if child.fi.Mode()&os.ModeSymlink != 0 && (follow || !last) {
+			if links++; links > maxLinks {
+				return nil, errTooManyLinks
+			}
+			target := child.link
+			if !path.IsAbs(target) {
+				target = path.Join(dir, target)
+			}
+			target = path.Join(append([]string{"/", target}, elems[i+1:]...)...)
+			return s.resolve(target, follow, links)
+		}
+		n = child
+		dir = path.Join(dir, elem)
+	}
+	return n, nil
+}
+
+// Scheme returns the URL scheme of the backend, such as "tar".
+func (s *Store) Scheme() string {
+	return s.scheme
+}
+
+func (s *Store) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
+
+func (s *Store) Lstat(name string) (os.FileInfo, error) {
+	n, err := s.lookup("lstat", name, false)
+	if err != nil {
+		return nil, err
+	}
+	return n.fi, nil
+}
+
+func (s *Store) Stat(name string) (os.FileInfo, error) {
+	n, err := s.lookup("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return n.fi, nil
+}
+
+func (s *Store) Readdir(name string) ([]os.FileInfo, error) {
+	n, err := s.lookup("readdir", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if !n.fi.IsDir() {
+		return nil, &os.PathError{Op: "readdir", Path: name, Err: straw.ErrNotDirectory}
+	}
+	res := make([]os.FileInfo, 0, len(n.children))
+	for _, child := range n.children {
+		res = append(res, child.fi)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name() < res[j].Name() })
+	return res, nil
+}
+
+func (s *Store) Readlink(name string) (string, error) {
+	n, err := s.lookup("readlink", name, false)
+	if err != nil {
+		return "", err
+	}
+	if n.fi.Mode()&os.ModeSymlink == 0 {
+		return "", &os.PathError{Op: "readlink", Path: name, Err: errNotLink}
+	}
+	return n.link, nil
+}
+
+func (s *Store) OpenReadCloser(name string) (straw.StrawReader, error) {
+	n, err := s.lookup("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if n.fi.IsDir() {
+		return nil, &os.PathError{Op: "open", Path: name, Err: straw.ErrIsDirectory}
+	}
+	r, err := s.open(n.data)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+	return r, nil
+}
+
+// OpenFile supports only os.O_RDONLY, opening the file as OpenReadCloser
+// does.
+func (s *Store) OpenFile(name string, flag int, perm os.FileMode) (straw.StrawReadWriteCloser, error) {
+	if flag != os.O_RDONLY {
+		return nil, straw.ErrNotSupported
+	}
+	return straw.OpenFileBuffered(s, name, flag, nil)
+}
+
+func (s *Store) CreateWriteCloser(name string) (straw.StrawWriter, error) {
+	return nil, straw.ErrNotSupported
+}
+
+func (s *Store) Mkdir(name string, mode os.FileMode) error {
+	return straw.ErrNotSupported
+}
+
+func (s *Store) Remove(name string) error {
+	return straw.ErrNotSupported
+}
+
+func (s *Store) Chmod(name string, mode os.FileMode) error {
+	return straw.ErrNotSupported
+}
+
+func (s *Store) Truncate(name string, size int64) error {
+	return straw.ErrNotSupported
+}
+
+func (s *Store) Symlink(oldname, newname string) error {
+	return straw.ErrNotSupported
+}
+
+func (s *Store) Copy(src, dst string) error {
+	return straw.ErrNotSupported
+}
+
+// fileInfo describes a member of an archive, or a directory synthesized for
+// one.
+type fileInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+	sys     interface{}
+}
+
+func (fi *fileInfo) Name() string       { return fi.name }
+func (fi *fileInfo) Size() int64        { return fi.size }
+func (fi *fileInfo) Mode() os.FileMode  { return fi.mode }
+func (fi *fileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *fileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi *fileInfo) Sys() interface{}   { return fi.sys }
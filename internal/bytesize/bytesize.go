@@ -0,0 +1,39 @@
+// Package bytesize parses human readable byte sizes used in query parameters.
+package bytesize
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var units = []struct {
+	suffix string
+	size   int64
+}{
+	{"KiB", 1 << 10},
+	{"MiB", 1 << 20},
+	{"GiB", 1 << 30},
+	{"KB", 1000},
+	{"MB", 1000 * 1000},
+	{"GB", 1000 * 1000 * 1000},
+	{"B", 1},
+}
+
+// Parse parses a size such as "1024", "512KB" or "1MiB". The decimal
+// suffixes KB, MB and GB are powers of 1000, and KiB, MiB and GiB are powers
+// of 1024.
+func Parse(s string) (int64, error) {
+	num, mult := strings.TrimSpace(s), int64(1)
+	for _, u := range units {
+		if strings.HasSuffix(num, u.suffix) {
+			num, mult = strings.TrimSpace(strings.TrimSuffix(num, u.suffix)), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * mult, nil
+}
@@ -0,0 +1,32 @@
+package bytesize
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	assert := assert.New(t)
+
+	for s, expected := range map[string]int64{
+		"0":     0,
+		"1024":  1024,
+		"10B":   10,
+		"512KB": 512000,
+		"1MB":   1000000,
+		"1 MiB": 1048576,
+		"2GiB":  2147483648,
+		"3KiB":  3072,
+		"1GB":   1000000000,
+	} {
+		n, err := Parse(s)
+		assert.NoError(err, s)
+		assert.Equal(expected, n, s)
+	}
+
+	for _, s := range []string{"", "MB", "-1", "1TB", "1.5MB"} {
+		_, err := Parse(s)
+		assert.EqualError(err, `invalid size "`+s+`"`)
+	}
+}
@@ -0,0 +1,464 @@
+// Package fakeftp provides an FTP server serving a local directory, which
+// implements the subset of the protocol used by the ftp backend, for use in
+// tests. Only passive mode is supported, and listings are in the format of
+// ls -l, with times in UTC.
+package fakeftp
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server is a fake FTP server, serving the files under a root directory to a
+// single user.
+type Server struct {
+	root     string
+	user     string
+	password string
+	listener net.Listener
+
+	// TLSConfig, if set before the first connection, allows clients to
+	// upgrade their connection with AUTH TLS.
+	TLSConfig *tls.Config
+	// DisableEPSV makes the server reject EPSV, so that clients must use
+	// PASV.
+	DisableEPSV bool
+
+	lk       sync.Mutex
+	commands []string
+}
+
+// New returns a Server listening on a local port, serving the files under
+// root to user, logging in with password.
+func New(root, user, password string) (*Server, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{root: root, user: user, password: password, listener: l}
+	go s.serve()
+	return s, nil
+}
+
+// Addr returns the address the server is listening on.
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close stops the server listening for connections.
+func (s *Server) Close() error {
+	return s.listener.Close()
+}
+
+// Commands returns the commands received so far, other than passwords, such
+// as "RETR /a/b".
+func (s *Server) Commands() []string {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+func (s *Server) serve() {
+	for {
+		nc, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(nc)
+	}
+}
+
+type session struct {
+	s        *Server
+	nc       net.Conn
+	text     *textproto.Conn
+	user     string
+	loggedIn bool
+	prot     bool
+	cwd      string
+	offset   int64
+	pasv     net.Listener
+}
+
+func (s *Server) handle(nc net.Conn) {
+	sess := &session{s: s, nc: nc, text: textproto.NewConn(nc), cwd: "/"}
+	defer func() {
+		if sess.pasv != nil {
+			sess.pasv.Close()
+		}
+		sess.text.Close()
+	}()
+	sess.reply(220, "fakeftp ready")
+	for {
+		line, err := sess.text.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd, arg := line, ""
+		if i := strings.IndexByte(line, ' '); i != -1 {
+			cmd, arg = line[:i], line[i+1:]
+		}
+		cmd = strings.ToUpper(cmd)
+		s.lk.Lock()
+		if cmd == "PASS" {
+			s.commands = append(s.commands, cmd)
+		} else {
+			s.commands = append(s.commands, line)
+		}
+		s.lk.Unlock()
+		if cmd == "QUIT" {
+			sess.reply(221, "bye")
+			return
+		}
+		sess.command(cmd, arg)
+	}
+}
+
+func (sess *session) reply(code int, format string, args ...interface{}) {
+	sess.text.PrintfLine("%d %s", code, fmt.Sprintf(format, args...))
+}
+
+func (sess *session) command(cmd, arg string) {
+	switch cmd {
+	case "AUTH":
+		if sess.s.TLSConfig == nil {
+			sess.reply(502, "TLS not configured")
+			return
+		}
+		sess.reply(234, "proceed with negotiation")
+		sess.nc = tls.Server(sess.nc, sess.s.TLSConfig)
+		sess.text = textproto.NewConn(sess.nc)
+		return
+	case "PBSZ":
+		sess.reply(200, "PBSZ=0")
+		return
+	case "PROT":
+		sess.prot = strings.ToUpper(arg) == "P"
+		sess.reply(200, "protection level set")
+		return
+	case "USER":
+		sess.user, sess.loggedIn = arg, false
+		sess.reply(331, "password required")
+		return
+	case "PASS":
+		if sess.user != sess.s.user || arg != sess.s.password {
+			sess.reply(530, "login incorrect")
+			return
+		}
+		sess.loggedIn = true
+		sess.reply(230, "logged in")
+		return
+	}
+	if !sess.loggedIn {
+		sess.reply(530, "not logged in")
+		return
+	}
+
+	switch cmd {
+	case "TYPE":
+		sess.reply(200, "type set")
+	case "SYST":
+		sess.reply(215, "UNIX Type: L8")
+	case "NOOP":
+		sess.reply(200, "ok")
+	case "PWD":
+		sess.reply(257, "%q is the current directory", sess.cwd)
+	case "CWD":
+		p := sess.path(arg)
+		if fi, err := os.Stat(sess.local(p)); err != nil || !fi.IsDir() {
+			sess.reply(550, "no such directory")
+			return
+		}
+		sess.cwd = p
+		sess.reply(250, "directory changed")
+	case "EPSV":
+		if sess.s.DisableEPSV {
+			sess.reply(502, "EPSV not implemented")
+			return
+		}
+		port, err := sess.listen()
+		if err != nil {
+			sess.reply(425, "%v", err)
+			return
+		}
+		sess.reply(229, "Entering Extended Passive Mode (|||%d|)", port)
+	case "PASV":
+		port, err := sess.listen()
+		if err != nil {
+			sess.reply(425, "%v", err)
+			return
+		}
+		sess.reply(227, "Entering Passive Mode (127,0,0,1,%d,%d)", port>>8, port&0xff)
+	case "REST":
+		offset, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil || offset < 0 {
+			sess.reply(501, "invalid offset")
+			return
+		}
+		sess.offset = offset
+		sess.reply(350, "restarting at %d", offset)
+	case "LIST":
+		sess.list(arg)
+	case "SIZE":
+		fi, err := os.Stat(sess.local(sess.path(arg)))
+		if err != nil || !fi.Mode().IsRegular() {
+			sess.reply(550, "not a file")
+			return
+		}
+		sess.reply(213, "%d", fi.Size())
+	case "MDTM":
+		fi, err := os.Stat(sess.local(sess.path(arg)))
+		if err != nil || !fi.Mode().IsRegular() {
+			sess.reply(550, "not a file")
+			return
+		}
+		sess.reply(213, "%s", fi.ModTime().UTC().Format("20060102150405"))
+	case "RETR":
+		sess.retr(arg)
+	case "STOR":
+		sess.stor(arg)
+	case "MKD":
+		if err := os.Mkdir(sess.local(sess.path(arg)), 0755); err != nil {
+			sess.reply(550, "%v", err)
+			return
+		}
+		sess.reply(257, "%q created", sess.path(arg))
+	case "RMD":
+		local := sess.local(sess.path(arg))
+		if fi, err := os.Lstat(local); err != nil || !fi.IsDir() {
+			sess.reply(550, "not a directory")
+			return
+		}
+		if err := os.Remove(local); err != nil {
+			sess.reply(550, "%v", err)
+			return
+		}
+		sess.reply(250, "directory removed")
+	case "DELE":
+		local := sess.local(sess.path(arg))
+		if fi, err := os.Lstat(local); err != nil || fi.IsDir() {
+			sess.reply(550, "not a file")
+			return
+		}
+		if err := os.Remove(local); err != nil {
+			sess.reply(550, "%v", err)
+			return
+		}
+		sess.reply(250, "file removed")
+	case "SITE":
+		sess.site(arg)
+	default:
+		sess.reply(502, "%s not implemented", cmd)
+	}
+}
+
+// path returns the absolute path on the server of arg, which may be relative
+// to the working directory.
+func (sess *session) path(arg string) string {
+	if !strings.HasPrefix(arg, "/") {
+		arg = path.Join(sess.cwd, arg)
+	}
+	return path.Clean(arg)
+}
+
+// local returns the path of the local file served at p.
+func (sess *session) local(p string) string {
+	return filepath.Join(sess.s.root, filepath.FromSlash(p))
+}
+
+// listen opens a listener for the next data connection, returning its port.
+func (sess *session) listen() (int, error) {
+	if sess.pasv != nil {
+		sess.pasv.Close()
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	sess.pasv = l
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// accept accepts the data connection for a transfer, having sent the
+// preliminary reply.
+func (sess *session) accept() (net.Conn, error) {
+	if sess.pasv == nil {
+		return nil, fmt.Errorf("use PASV or EPSV first")
+	}
+	defer func() {
+		sess.pasv.Close()
+		sess.pasv = nil
+	}()
+	sess.reply(150, "opening data connection")
+	dc, err := sess.pasv.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if sess.prot {
+		dc = tls.Server(dc, sess.s.TLSConfig)
+	}
+	return dc, nil
+}
+
+func (sess *session) list(arg string) {
+	p := sess.cwd
+	if arg != "" {
+		p = sess.path(arg)
+	}
+	local := sess.local(p)
+	fi, err := os.Lstat(local)
+	if err != nil {
+		sess.reply(550, "%v", err)
+		return
+	}
+	var lines []string
+	if fi.IsDir() {
+		f, err := os.Open(local)
+		if err != nil {
+			sess.reply(550, "%v", err)
+			return
+		}
+		names, err := f.Readdirnames(-1)
+		f.Close()
+		if err != nil {
+			sess.reply(550, "%v", err)
+			return
+		}
+		sort.Strings(names)
+		lines = append(lines, fmt.Sprintf("total %d", len(names)))
+		for _, name := range names {
+			if fi, err := os.Lstat(filepath.Join(local, name)); err == nil {
+				lines = append(lines, listLine(fi, local))
+			}
+		}
+	} else {
+		lines = append(lines, listLine(fi, filepath.Dir(local)))
+	}
+
+	dc, err := sess.accept()
+	if err != nil {
+		sess.reply(425, "%v", err)
+		return
+	}
+	for _, line := range lines {
+		if _, err := io.WriteString(dc, line+"\r\n"); err != nil {
+			dc.Close()
+			sess.reply(426, "%v", err)
+			return
+		}
+	}
+	dc.Close()
+	sess.reply(226, "transfer complete")
+}
+
+// listLine returns the line listing fi, a file in the local directory dir,
+// as ls -l would.
+func listLine(fi os.FileInfo, dir string) string {
+	mode := []byte("----------")
+	switch {
+	case fi.IsDir():
+		mode[0] = 'd'
+	case fi.Mode()&os.ModeSymlink != 0:
+		mode[0] = 'l'
+	}
+	const rwx = "rwxrwxrwx"
+	for i := 0; i < 9; i++ {
+		if fi.Mode()&(1<<uint(8-i)) != 0 {
+			mode[i+1] = rwx[i]
+		}
+	}
+	t := fi.ModTime().UTC()
+	stamp := t.Format("Jan _2 15:04")
+	if time.Since(t) > 180*24*time.Hour {
+		stamp = t.Format("Jan _2  2006")
+	}
+	name := fi.Name()
+	if mode[0] == 'l' {
+		if target, err := os.Readlink(filepath.Join(dir, name)); err == nil {
+			name += " -> " + target
+		}
+	}
+	return fmt.Sprintf("%s 1 owner group %12d %s %s", mode, fi.Size(), stamp, name)
+}
+
+func (sess *session) retr(arg string) {
+	offset := sess.offset
+	sess.offset = 0
+	f, err := os.Open(sess.local(sess.path(arg)))
+	if err != nil {
+		sess.reply(550, "%v", err)
+		return
+	}
+	defer f.Close()
+	if fi, err := f.Stat(); err != nil || fi.IsDir() {
+		sess.reply(550, "not a file")
+		return
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		sess.reply(550, "%v", err)
+		return
+	}
+	dc, err := sess.accept()
+	if err != nil {
+		sess.reply(425, "%v", err)
+		return
+	}
+	_, err = io.Copy(dc, f)
+	dc.Close()
+	if err != nil {
+		sess.reply(426, "transfer aborted: %v", err)
+		return
+	}
+	sess.reply(226, "transfer complete")
+}
+
+func (sess *session) stor(arg string) {
+	sess.offset = 0
+	f, err := os.OpenFile(sess.local(sess.path(arg)), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		sess.reply(550, "%v", err)
+		return
+	}
+	defer f.Close()
+	dc, err := sess.accept()
+	if err != nil {
+		sess.reply(425, "%v", err)
+		return
+	}
+	_, err = io.Copy(f, dc)
+	dc.Close()
+	if err != nil {
+		sess.reply(426, "transfer aborted: %v", err)
+		return
+	}
+	sess.reply(226, "transfer complete")
+}
+
+func (sess *session) site(arg string) {
+	fields := strings.SplitN(arg, " ", 3)
+	if len(fields) != 3 || strings.ToUpper(fields[0]) != "CHMOD" {
+		sess.reply(502, "SITE %s not implemented", arg)
+		return
+	}
+	mode, err := strconv.ParseUint(fields[1], 8, 32)
+	if err != nil {
+		sess.reply(501, "invalid mode")
+		return
+	}
+	if err := os.Chmod(sess.local(sess.path(fields[2])), os.FileMode(mode)); err != nil {
+		sess.reply(550, "%v", err)
+		return
+	}
+	sess.reply(200, "mode changed")
+}
@@ -0,0 +1,650 @@
+// Package fakes3 provides an in-memory implementation of the subset of the s3
+// REST API used by the s3 backend, for use in tests. Requests must use path
+// style addressing and are not authenticated.
+package fakes3
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// storedHeaders are the request headers recorded when an object is written,
+// and returned when it is read.
+var storedHeaders = []string{
+	"Cache-Control",
+	"Content-Disposition",
+	"Content-Encoding",
+	"Content-Type",
+	"Expires",
+	"X-Amz-Server-Side-Encryption",
+	"X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id",
+	"X-Amz-Storage-Class",
+}
+
+// Object is an object held by the Server.
+type Object struct {
+	Key          string
+	Data         []byte
+	LastModified time.Time
+	ETag         string
+	Header       http.Header
+	// Tags holds the tags of the object, set with the X-Amz-Tagging header
+	// or the tagging subresource.
+	Tags map[string]string
+}
+
+// Request records a request made to the Server.
+type Request struct {
+	Method string
+	Bucket string
+	Key    string
+	Query  string
+	Header http.Header
+}
+
+// Server is a fake s3 service, serving requests for a fixed set of buckets.
+type Server struct {
+	lk       sync.Mutex
+	buckets  map[string]map[string]*Object
+	uploads  map[string]*upload
+	requests []Request
+	nextID   int
+}
+
+type upload struct {
+	bucket string
+	key    string
+	header http.Header
+	parts  map[int][]byte
+}
+
+// New returns a Server holding the named empty buckets.
+func New(buckets ...string) *Server {
+	s := &Server{
+		buckets: make(map[string]map[string]*Object),
+		uploads: make(map[string]*upload),
+	}
+	for _, b := range buckets {
+		s.buckets[b] = make(map[string]*Object)
+	}
+	return s
+}
+
+// Requests returns the requests made so far.
+func (s *Server) Requests() []Request {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Object returns the named object.
+func (s *Server) Object(bucket, key string) (Object, bool) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	obj, ok := s.buckets[bucket][key]
+	if !ok {
+		return Object{}, false
+	}
+	return *obj, true
+}
+
+// PutObject stores an object directly, without going through the API.
+func (s *Server) PutObject(bucket, key string, data []byte) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	s.put(bucket, key, data, http.Header{})
+}
+
+func (s *Server) put(bucket, key string, data []byte, header http.Header) *Object {
+	sum := md5.Sum(data)
+	obj := &Object{
+		Key:          key,
+		Data:         data,
+		LastModified: time.Now().UTC().Truncate(time.Second),
+		ETag:         `"` + hex.EncodeToString(sum[:]) + `"`,
+		Header:       http.Header{},
+		Tags:         map[string]string{},
+	}
+	if tagging, err := url.ParseQuery(header.Get("X-Amz-Tagging")); err == nil {
+		for k := range tagging {
+			obj.Tags[k] = tagging.Get(k)
+		}
+	}
+	for _, h := range storedHeaders {
+		if v := header.Get(h); v != "" {
+			obj.Header.Set(h, v)
+		}
+	}
+	for h, v := range header {
+		if strings.HasPrefix(h, "X-Amz-Meta-") {
+			obj.Header[h] = v
+		}
+	}
+	s.buckets[bucket][key] = obj
+	return obj
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	bucketName, key := path, ""
+	if i := strings.Index(path, "/"); i != -1 {
+		bucketName, key = path[:i], path[i+1:]
+	}
+	s.requests = append(s.requests, Request{
+		Method: r.Method,
+		Bucket: bucketName,
+		Key:    key,
+		Query:  r.URL.RawQuery,
+		Header: r.Header.Clone(),
+	})
+
+	bucket, ok := s.buckets[bucketName]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	q := r.URL.Query()
+	switch {
+	case key == "" && r.Method == http.MethodGet:
+		s.list(w, r, bucketName, bucket)
+	case key == "" && r.Method == http.MethodPost && hasParam(q, "delete"):
+		s.deleteObjects(w, r, bucket)
+	case hasParam(q, "tagging"):
+		s.tagging(w, r, bucket, key)
+	case r.Method == http.MethodPost && hasParam(q, "uploads"):
+		s.createUpload(w, r, bucketName, key)
+	case r.Method == http.MethodPut && q.Get("uploadId") != "" && r.Header.Get("X-Amz-Copy-Source") != "":
+		s.uploadPartCopy(w, r)
+	case r.Method == http.MethodPut && q.Get("uploadId") != "":
+		s.uploadPart(w, r)
+	case r.Method == http.MethodPost && q.Get("uploadId") != "":
+		s.completeUpload(w, r)
+	case r.Method == http.MethodDelete && q.Get("uploadId") != "":
+		delete(s.uploads, q.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		s.get(w, r, bucket, key)
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		s.copyObject(w, r, bucketName, key)
+	case r.Method == http.MethodPut && !checkPreconditions(w, r, bucket[key]):
+	case r.Method == http.MethodPut:
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		if digest := r.Header.Get("Content-MD5"); digest != "" {
+			sum := md5.Sum(data)
+			if digest != base64.StdEncoding.EncodeToString(sum[:]) {
+				writeError(w, http.StatusBadRequest, "BadDigest")
+				return
+			}
+		}
+		obj := s.put(bucketName, key, data, r.Header)
+		w.Header().Set("ETag", obj.ETag)
+	case r.Method == http.MethodDelete:
+		delete(bucket, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request, bucket map[string]*Object, key string) {
+	obj, ok := bucket[key]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+	for h, v := range obj.Header {
+		w.Header()[h] = v
+	}
+	w.Header().Set("ETag", obj.ETag)
+	w.Header().Set("Last-Modified", obj.LastModified.Format(http.TimeFormat))
+
+	data := obj.Data
+	status := http.StatusOK
+	if rng := r.Header.Get("Range"); rng != "" {
+		start, end, ok := parseRange(rng, int64(len(data)))
+		if !ok {
+			writeError(w, http.StatusRequestedRangeNotSatisfiable, "InvalidRange")
+			return
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)))
+		data = data[start : end+1]
+		status = http.StatusPartialContent
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(status)
+	if r.Method == http.MethodGet {
+		w.Write(data)
+	}
+}
+
+// parseRange parses a single "bytes=start-end" or "bytes=start-" range.
+func parseRange(rng string, size int64) (int64, int64, bool) {
+	spec := strings.TrimPrefix(rng, "bytes=")
+	parts := strings.SplitN(spec, "-", 2)
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	start, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || start >= size {
+		return 0, 0, false
+	}
+	end := size - 1
+	if parts[1] != "" {
+		end, err = strconv.ParseInt(parts[1], 10, 64)
+		if err != nil || end < start {
+			return 0, 0, false
+		}
+		if end > size-1 {
+			end = size - 1
+		}
+	}
+	return start, end, true
+}
+
+type listContents struct {
+	Key          string
+	LastModified string
+	ETag         string
+	Size         int
+	StorageClass string
+}
+
+type listPrefix struct {
+	Prefix string
+}
+
+type listBucketResult struct {
+	XMLName               xml.Name `xml:"ListBucketResult"`
+	Name                  string
+	Prefix                string
+	Delimiter             string
+	MaxKeys               int
+	KeyCount              int
+	IsTruncated           bool
+	NextContinuationToken string         `xml:",omitempty"`
+	Contents              []listContents `xml:"Contents"`
+	CommonPrefixes        []listPrefix   `xml:"CommonPrefixes"`
+}
+
+func (s *Server) list(w http.ResponseWriter, r *http.Request, bucketName string, bucket map[string]*Object) {
+	q := r.URL.Query()
+	if hasParam(q, "location") {
+		w.Write([]byte(`<LocationConstraint xmlns="http://s3.amazonaws.com/doc/2006-03-01/"></LocationConstraint>`))
+		return
+	}
+
+	prefix := q.Get("prefix")
+	delimiter := q.Get("delimiter")
+	maxKeys := 1000
+	if mk := q.Get("max-keys"); mk != "" {
+		maxKeys, _ = strconv.Atoi(mk)
+	}
+	marker := q.Get("start-after")
+	if token := q.Get("continuation-token"); token != "" {
+		marker = token
+	}
+
+	var keys []string
+	for k := range bucket {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	result := listBucketResult{
+		Name:      bucketName,
+		Prefix:    prefix,
+		Delimiter: delimiter,
+		MaxKeys:   maxKeys,
+	}
+	last := ""
+	for _, k := range keys {
+		if k <= marker || (strings.HasSuffix(marker, delimiter) && delimiter != "" && strings.HasPrefix(k, marker)) {
+			continue
+		}
+		item := k
+		isPrefix := false
+		if delimiter != "" {
+			if i := strings.Index(k[len(prefix):], delimiter); i != -1 {
+				item = k[:len(prefix)+i+len(delimiter)]
+				isPrefix = true
+			}
+		}
+		if item == last {
+			continue
+		}
+		if result.KeyCount == maxKeys {
+			result.IsTruncated = true
+			result.NextContinuationToken = last
+			break
+		}
+		if isPrefix {
+			result.CommonPrefixes = append(result.CommonPrefixes, listPrefix{item})
+		} else {
+			obj := bucket[k]
+			storageClass := obj.Header.Get("X-Amz-Storage-Class")
+			if storageClass == "" {
+				storageClass = "STANDARD"
+			}
+			result.Contents = append(result.Contents, listContents{
+				Key:          k,
+				LastModified: obj.LastModified.Format(time.RFC3339),
+				ETag:         obj.ETag,
+				Size:         len(obj.Data),
+				StorageClass: storageClass,
+			})
+		}
+		result.KeyCount++
+		last = item
+	}
+	writeXML(w, result)
+}
+
+type deleteRequest struct {
+	Objects []struct {
+		Key string
+	} `xml:"Object"`
+}
+
+type deleteResult struct {
+	XMLName xml.Name `xml:"DeleteResult"`
+	Deleted []struct {
+		Key string
+	} `xml:"Deleted"`
+}
+
+func (s *Server) deleteObjects(w http.ResponseWriter, r *http.Request, bucket map[string]*Object) {
+	var req deleteRequest
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "MalformedXML")
+		return
+	}
+	var result deleteResult
+	for _, obj := range req.Objects {
+		delete(bucket, obj.Key)
+		result.Deleted = append(result.Deleted, struct{ Key string }{obj.Key})
+	}
+	writeXML(w, result)
+}
+
+type initiateResult struct {
+	XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+	Bucket   string
+	Key      string
+	UploadId string
+}
+
+func (s *Server) createUpload(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	s.nextID++
+	id := strconv.Itoa(s.nextID)
+	s.uploads[id] = &upload{
+		bucket: bucket,
+		key:    key,
+		header: r.Header.Clone(),
+		parts:  make(map[int][]byte),
+	}
+	writeXML(w, initiateResult{Bucket: bucket, Key: key, UploadId: id})
+}
+
+func (s *Server) uploadPart(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	up, ok := s.uploads[q.Get("uploadId")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchUpload")
+		return
+	}
+	partNumber, err := strconv.Atoi(q.Get("partNumber"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidArgument")
+		return
+	}
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "IncompleteBody")
+		return
+	}
+	up.parts[partNumber] = data
+	sum := md5.Sum(data)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+}
+
+type copyResult struct {
+	ETag         string
+	LastModified string
+}
+
+type copyObjectResult struct {
+	XMLName xml.Name `xml:"CopyObjectResult"`
+	copyResult
+}
+
+type copyPartResult struct {
+	XMLName xml.Name `xml:"CopyPartResult"`
+	copyResult
+}
+
+// copySource returns the object named by the X-Amz-Copy-Source header.
+func (s *Server) copySource(r *http.Request) (*Object, bool) {
+	src, err := url.PathUnescape(r.Header.Get("X-Amz-Copy-Source"))
+	if err != nil {
+		return nil, false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(src, "/"), "/", 2)
+	if len(parts) != 2 {
+		return nil, false
+	}
+	obj, ok := s.buckets[parts[0]][parts[1]]
+	return obj, ok
+}
+
+func (s *Server) copyObject(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	src, ok := s.copySource(r)
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+	header := r.Header.Clone()
+	if r.Header.Get("X-Amz-Tagging-Directive") != "REPLACE" {
+		header.Set("X-Amz-Tagging", encodeTags(src.Tags))
+	}
+	if r.Header.Get("X-Amz-Metadata-Directive") != "REPLACE" {
+		tagging := header.Get("X-Amz-Tagging")
+		header = src.Header.Clone()
+		for _, h := range []string{"X-Amz-Server-Side-Encryption", "X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", "X-Amz-Storage-Class"} {
+			header.Del(h)
+			if v := r.Header.Get(h); v != "" {
+				header.Set(h, v)
+			}
+		}
+		header.Set("X-Amz-Tagging", tagging)
+	}
+	obj := s.put(bucket, key, append([]byte(nil), src.Data...), header)
+	writeXML(w, copyObjectResult{copyResult: copyResult{ETag: obj.ETag, LastModified: obj.LastModified.Format(time.RFC3339)}})
+}
+
+func (s *Server) uploadPartCopy(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	up, ok := s.uploads[q.Get("uploadId")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchUpload")
+		return
+	}
+	partNumber, err := strconv.Atoi(q.Get("partNumber"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidArgument")
+		return
+	}
+	src, ok := s.copySource(r)
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+	data := src.Data
+	if rng := r.Header.Get("X-Amz-Copy-Source-Range"); rng != "" {
+		start, end, ok := parseRange(rng, int64(len(data)))
+		if !ok {
+			writeError(w, http.StatusRequestedRangeNotSatisfiable, "InvalidRange")
+			return
+		}
+		data = data[start : end+1]
+	}
+	up.parts[partNumber] = append([]byte(nil), data...)
+	sum := md5.Sum(data)
+	writeXML(w, copyPartResult{copyResult: copyResult{ETag: `"` + hex.EncodeToString(sum[:]) + `"`, LastModified: time.Now().UTC().Format(time.RFC3339)}})
+}
+
+type completeRequest struct {
+	Parts []struct {
+		PartNumber int
+	} `xml:"Part"`
+}
+
+type completeResult struct {
+	XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+	Bucket  string
+	Key     string
+	ETag    string
+}
+
+func (s *Server) completeUpload(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("uploadId")
+	up, ok := s.uploads[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchUpload")
+		return
+	}
+	var req completeRequest
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "MalformedXML")
+		return
+	}
+	if !checkPreconditions(w, r, s.buckets[up.bucket][up.key]) {
+		return
+	}
+	var data []byte
+	for _, part := range req.Parts {
+		p, ok := up.parts[part.PartNumber]
+		if !ok {
+			writeError(w, http.StatusBadRequest, "InvalidPart")
+			return
+		}
+		data = append(data, p...)
+	}
+	delete(s.uploads, id)
+	obj := s.put(up.bucket, up.key, data, up.header)
+	writeXML(w, completeResult{Bucket: up.bucket, Key: up.key, ETag: obj.ETag})
+}
+
+// checkPreconditions checks the If-None-Match and If-Match headers of a
+// write against obj, the existing object or nil, writing the error S3 would
+// and returning false if they are not met.
+func checkPreconditions(w http.ResponseWriter, r *http.Request, obj *Object) bool {
+	if r.Header.Get("If-None-Match") == "*" && obj != nil {
+		writeError(w, http.StatusPreconditionFailed, "PreconditionFailed")
+		return false
+	}
+	if etag := r.Header.Get("If-Match"); etag != "" {
+		if obj == nil {
+			writeError(w, http.StatusNotFound, "NoSuchKey")
+			return false
+		}
+		if etag != obj.ETag {
+			writeError(w, http.StatusPreconditionFailed, "PreconditionFailed")
+			return false
+		}
+	}
+	return true
+}
+
+type tagSet struct {
+	XMLName xml.Name `xml:"Tagging"`
+	Tags    []tag    `xml:"TagSet>Tag"`
+}
+
+type tag struct {
+	Key   string
+	Value string
+}
+
+// tagging gets or replaces the tags of an object.
+func (s *Server) tagging(w http.ResponseWriter, r *http.Request, bucket map[string]*Object, key string) {
+	obj, ok := bucket[key]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		var set tagSet
+		for k, v := range obj.Tags {
+			set.Tags = append(set.Tags, tag{Key: k, Value: v})
+		}
+		sort.Slice(set.Tags, func(i, j int) bool { return set.Tags[i].Key < set.Tags[j].Key })
+		writeXML(w, set)
+	case http.MethodPut:
+		var set tagSet
+		if err := xml.NewDecoder(r.Body).Decode(&set); err != nil {
+			writeError(w, http.StatusBadRequest, "MalformedXML")
+			return
+		}
+		obj.Tags = make(map[string]string)
+		for _, t := range set.Tags {
+			obj.Tags[t.Key] = t.Value
+		}
+	default:
+		writeError(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+// encodeTags returns tags in the form of the X-Amz-Tagging header.
+func encodeTags(tags map[string]string) string {
+	q := url.Values{}
+	for k, v := range tags {
+		q.Set(k, v)
+	}
+	return q.Encode()
+}
+
+// Uploads returns the number of multipart uploads in progress.
+func (s *Server) Uploads() int {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	return len(s.uploads)
+}
+
+func hasParam(q map[string][]string, name string) bool {
+	_, ok := q[name]
+	return ok
+}
+
+func writeXML(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
@@ -0,0 +1,23 @@
+package straw
+
+// DirChecker is implemented by stores that can determine whether a path is a
+// directory more cheaply than a full Stat, such as object stores that would
+// otherwise have to synthesize directory information.
+type DirChecker interface {
+	IsDir(name string) (bool, error)
+}
+
+// IsDir reports whether name is a directory. If nothing exists at name, the
+// returned error satisfies os.IsNotExist.
+// If ss implements DirChecker, its IsDir method is used, otherwise the result
+// is derived from Stat.
+func IsDir(ss StreamStore, name string) (bool, error) {
+	if dc, ok := ss.(DirChecker); ok {
+		return dc.IsDir(name)
+	}
+	fi, err := ss.Stat(name)
+	if err != nil {
+		return false, err
+	}
+	return fi.IsDir(), nil
+}
@@ -0,0 +1,29 @@
+package straw_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uw-labs/straw"
+)
+
+func TestIsDir(t *testing.T) {
+	assert := assert.New(t)
+
+	ss, _ := straw.Open("mem://")
+
+	ss.Mkdir("a", 0755)
+	writeFile(ss, "a/1")
+
+	isDir, err := straw.IsDir(ss, "a")
+	assert.NoError(err)
+	assert.True(isDir)
+
+	isDir, err = straw.IsDir(ss, "a/1")
+	assert.NoError(err)
+	assert.False(isDir)
+
+	_, err = straw.IsDir(ss, "a/2")
+	assert.True(os.IsNotExist(err))
+}
@@ -0,0 +1,30 @@
+package straw
+
+// DirLister is implemented by stores that can list the subdirectories of a
+// directory without enumerating the files within it, such as object stores
+// which can request only the common prefixes under a delimiter.
+type DirLister interface {
+	ListDirs(name string) ([]string, error)
+}
+
+// ListDirs returns the sorted names of the immediate subdirectories of the
+// named directory. Files are omitted. If the directory does not exist, the
+// returned error satisfies os.IsNotExist.
+// If ss implements DirLister, its ListDirs method is used, otherwise the
+// result is derived from Readdir.
+func ListDirs(ss StreamStore, name string) ([]string, error) {
+	if dl, ok := ss.(DirLister); ok {
+		return dl.ListDirs(name)
+	}
+	fis, err := ss.Readdir(name)
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for _, fi := range fis {
+		if fi.IsDir() {
+			dirs = append(dirs, fi.Name())
+		}
+	}
+	return dirs, nil
+}
@@ -0,0 +1,28 @@
+package straw_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uw-labs/straw"
+)
+
+func TestListDirs(t *testing.T) {
+	assert := assert.New(t)
+
+	ss, _ := straw.Open("mem://")
+
+	ss.Mkdir("a", 0755)
+	ss.Mkdir("a/y", 0755)
+	ss.Mkdir("a/x", 0755)
+	writeFile(ss, "a/1")
+	writeFile(ss, "a/x/2")
+
+	dirs, err := straw.ListDirs(ss, "a")
+	assert.NoError(err)
+	assert.Equal([]string{"x", "y"}, dirs)
+
+	dirs, err = straw.ListDirs(ss, "a/x")
+	assert.NoError(err)
+	assert.Empty(dirs)
+}
@@ -0,0 +1,258 @@
+package straw
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	_ StreamStore        = &loggingStreamStore{}
+	_ ContextStreamStore = &loggingStreamStore{}
+	_ OptionsWriter      = &loggingStreamStore{}
+	_ AppendStore        = &loggingStreamStore{}
+	_ AllRemover         = &loggingStreamStore{}
+	_ ExistsChecker      = &loggingStreamStore{}
+	_ DirChecker         = &loggingStreamStore{}
+	_ Taggable           = &loggingStreamStore{}
+	_ PresignStore       = &loggingStreamStore{}
+)
+
+// NewLoggingStore returns a StreamStore which wraps ss, logging each call to
+// logger with the method name, its arguments, how long it took and the error
+// it returned, if any. The content of reads and writes is never logged. If
+// logger is nil, calls are logged to standard error.
+// The optional interfaces which act on paths of ss, such as OptionsWriter
+// and ContextStreamStore, are implemented by passing each call to the
+// package function of the same name, so they are logged too.
+func NewLoggingStore(ss StreamStore, logger *log.Logger) StreamStore {
+	if logger == nil {
+		logger = log.New(os.Stderr, "", log.LstdFlags)
+	}
+	return &loggingStreamStore{ss, logger}
+}
+
+type loggingStreamStore struct {
+	ss     StreamStore
+	logger *log.Logger
+}
+
+func (fs *loggingStreamStore) Unwrap() StreamStore {
+	return fs.ss
+}
+
+func (fs *loggingStreamStore) Close() error {
+	start := time.Now()
+	err := fs.ss.Close()
+	fs.log("Close", start, err)
+	return err
+}
+
+func (fs *loggingStreamStore) OpenReadCloser(name string) (StrawReader, error) {
+	return fs.OpenReadCloserContext(context.Background(), name)
+}
+
+func (fs *loggingStreamStore) OpenReadCloserContext(ctx context.Context, name string) (StrawReader, error) {
+	start := time.Now()
+	r, err := OpenReadCloserContext(ctx, fs.ss, name)
+	fs.log("OpenReadCloser", start, err, name)
+	return r, err
+}
+
+func (fs *loggingStreamStore) CreateWriteCloser(name string) (StrawWriter, error) {
+	return fs.CreateWriteCloserContext(context.Background(), name)
+}
+
+func (fs *loggingStreamStore) CreateWriteCloserContext(ctx context.Context, name string) (StrawWriter, error) {
+	start := time.Now()
+	w, err := CreateWriteCloserContext(ctx, fs.ss, name)
+	fs.log("CreateWriteCloser", start, err, name)
+	return w, err
+}
+
+func (fs *loggingStreamStore) CreateWriteCloserWithOptions(name string, opts WriteOptions) (StrawWriter, error) {
+	start := time.Now()
+	w, err := createWriteCloser(fs.ss, name, opts)
+	fs.log("CreateWriteCloser", start, err, name)
+	return w, err
+}
+
+func (fs *loggingStreamStore) AppendWriteCloser(name string) (StrawWriter, error) {
+	start := time.Now()
+	w, err := AppendWriteCloser(fs.ss, name)
+	fs.log("AppendWriteCloser", start, err, name)
+	return w, err
+}
+
+func (fs *loggingStreamStore) OpenFile(name string, flag int, perm os.FileMode) (StrawReadWriteCloser, error) {
+	start := time.Now()
+	f, err := fs.ss.OpenFile(name, flag, perm)
+	fs.log("OpenFile", start, err, name, flag, perm)
+	return f, err
+}
+
+func (fs *loggingStreamStore) Lstat(path string) (os.FileInfo, error) {
+	return fs.LstatContext(context.Background(), path)
+}
+
+func (fs *loggingStreamStore) LstatContext(ctx context.Context, path string) (os.FileInfo, error) {
+	start := time.Now()
+	fi, err := LstatContext(ctx, fs.ss, path)
+	fs.log("Lstat", start, err, path)
+	return fi, err
+}
+
+func (fs *loggingStreamStore) Stat(path string) (os.FileInfo, error) {
+	return fs.StatContext(context.Background(), path)
+}
+
+func (fs *loggingStreamStore) StatContext(ctx context.Context, path string) (os.FileInfo, error) {
+	start := time.Now()
+	fi, err := StatContext(ctx, fs.ss, path)
+	fs.log("Stat", start, err, path)
+	return fi, err
+}
+
+func (fs *loggingStreamStore) Exists(name string) (bool, error) {
+	start := time.Now()
+	exists, err := Exists(fs.ss, name)
+	fs.log("Exists", start, err, name)
+	return exists, err
+}
+
+func (fs *loggingStreamStore) IsDir(name string) (bool, error) {
+	start := time.Now()
+	isDir, err := IsDir(fs.ss, name)
+	fs.log("IsDir", start, err, name)
+	return isDir, err
+}
+
+func (fs *loggingStreamStore) Readdir(path string) ([]os.FileInfo, error) {
+	return fs.ReaddirContext(context.Background(), path)
+}
+
+func (fs *loggingStreamStore) ReaddirContext(ctx context.Context, path string) ([]os.FileInfo, error) {
+	start := time.Now()
+	fis, err := ReaddirContext(ctx, fs.ss, path)
+	fs.log("Readdir", start, err, path)
+	return fis, err
+}
+
+func (fs *loggingStreamStore) Mkdir(path string, mode os.FileMode) error {
+	return fs.MkdirContext(context.Background(), path, mode)
+}
+
+func (fs *loggingStreamStore) MkdirContext(ctx context.Context, path string, mode os.FileMode) error {
+	start := time.Now()
+	err := MkdirContext(ctx, fs.ss, path, mode)
+	fs.log("Mkdir", start, err, path, mode)
+	return err
+}
+
+func (fs *loggingStreamStore) Remove(path string) error {
+	return fs.RemoveContext(context.Background(), path)
+}
+
+func (fs *loggingStreamStore) RemoveContext(ctx context.Context, path string) error {
+	start := time.Now()
+	err := RemoveContext(ctx, fs.ss, path)
+	fs.log("Remove", start, err, path)
+	return err
+}
+
+func (fs *loggingStreamStore) RemoveAll(path string) error {
+	start := time.Now()
+	err := RemoveAll(fs.ss, path)
+	fs.log("RemoveAll", start, err, path)
+	return err
+}
+
+func (fs *loggingStreamStore) Chmod(name string, mode os.FileMode) error {
+	return fs.ChmodContext(context.Background(), name, mode)
+}
+
+func (fs *loggingStreamStore) ChmodContext(ctx context.Context, name string, mode os.FileMode) error {
+	start := time.Now()
+	err := ChmodContext(ctx, fs.ss, name, mode)
+	fs.log("Chmod", start, err, name, mode)
+	return err
+}
+
+func (fs *loggingStreamStore) Truncate(name string, size int64) error {
+	start := time.Now()
+	err := fs.ss.Truncate(name, size)
+	fs.log("Truncate", start, err, name, size)
+	return err
+}
+
+func (fs *loggingStreamStore) Symlink(oldname, newname string) error {
+	start := time.Now()
+	err := Symlink(fs.ss, oldname, newname)
+	fs.log("Symlink", start, err, oldname, newname)
+	return err
+}
+
+func (fs *loggingStreamStore) Readlink(name string) (string, error) {
+	start := time.Now()
+	dest, err := Readlink(fs.ss, name)
+	fs.log("Readlink", start, err, name)
+	return dest, err
+}
+
+func (fs *loggingStreamStore) Copy(src, dst string) error {
+	return fs.CopyContext(context.Background(), src, dst)
+}
+
+func (fs *loggingStreamStore) CopyContext(ctx context.Context, src, dst string) error {
+	start := time.Now()
+	err := CopyContext(ctx, fs.ss, src, dst)
+	fs.log("Copy", start, err, src, dst)
+	return err
+}
+
+func (fs *loggingStreamStore) SetTags(name string, tags map[string]string) error {
+	start := time.Now()
+	err := setTags(fs.ss, name, tags)
+	fs.log("SetTags", start, err, name)
+	return err
+}
+
+func (fs *loggingStreamStore) GetTags(name string) (map[string]string, error) {
+	start := time.Now()
+	tags, err := getTags(fs.ss, name)
+	fs.log("GetTags", start, err, name)
+	return tags, err
+}
+
+func (fs *loggingStreamStore) PresignGetURL(name string, expiry time.Duration) (string, error) {
+	start := time.Now()
+	u, err := presignGetURL(fs.ss, name, expiry)
+	fs.log("PresignGetURL", start, err, name, expiry)
+	return u, err
+}
+
+func (fs *loggingStreamStore) PresignPutURL(name string, expiry time.Duration) (string, error) {
+	start := time.Now()
+	u, err := presignPutURL(fs.ss, name, expiry)
+	fs.log("PresignPutURL", start, err, name, expiry)
+	return u, err
+}
+
+// log logs a call to op with args, which started at start and returned err,
+// as a line such as "straw: Stat /a (1.5ms): file does not exist".
+func (fs *loggingStreamStore) log(op string, start time.Time, err error, args ...interface{}) {
+	var sb strings.Builder
+	sb.WriteString("straw: ")
+	sb.WriteString(op)
+	for _, arg := range args {
+		fmt.Fprintf(&sb, " %v", arg)
+	}
+	fmt.Fprintf(&sb, " (%s)", time.Since(start))
+	if err != nil {
+		fmt.Fprintf(&sb, ": %v", err)
+	}
+	fs.logger.Print(sb.String())
+}
@@ -0,0 +1,53 @@
+package straw_test
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+// testLogger returns a logger which writes to the test log.
+func testLogger(t *testing.T) *log.Logger {
+	return log.New(testLogWriter{t}, "", 0)
+}
+
+type testLogWriter struct {
+	t *testing.T
+}
+
+func (w testLogWriter) Write(p []byte) (int, error) {
+	w.t.Log(strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+}
+
+func TestLoggingStore(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var buf bytes.Buffer
+	mem, _ := straw.Open("mem://")
+	ss := straw.NewLoggingStore(mem, log.New(&buf, "", 0))
+
+	require.NoError(ss.Mkdir("/a", 0755))
+	w, err := ss.CreateWriteCloser("/a/1")
+	require.NoError(err)
+	_, err = w.Write([]byte("secret"))
+	require.NoError(err)
+	require.NoError(w.Close())
+	require.NoError(ss.Copy("/a/1", "/a/2"))
+	_, err = ss.Stat("/missing")
+	assert.Error(err)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Equal(4, len(lines))
+	assert.Regexp(`^straw: Mkdir /a -rwxr-xr-x \(.+\)$`, lines[0])
+	assert.Regexp(`^straw: CreateWriteCloser /a/1 \(.+\)$`, lines[1])
+	assert.Regexp(`^straw: Copy /a/1 /a/2 \(.+\)$`, lines[2])
+	assert.Regexp(`^straw: Stat /missing \(.+\): .+$`, lines[3])
+	assert.NotContains(buf.String(), "secret")
+}
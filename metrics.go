@@ -0,0 +1,238 @@
+package straw
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+var _ StreamStore = &metricsStreamStore{}
+
+// MetricsRecorder receives the measurements made by a store returned by
+// NewMetricsStore, for adapting to a metrics library. op is the name of the
+// StreamStore method called, such as "Stat". Its methods may be called
+// concurrently.
+type MetricsRecorder interface {
+	// ObserveLatency records how long a call to op took.
+	ObserveLatency(op string, d time.Duration)
+	// IncError counts a call to op which returned an error.
+	IncError(op string)
+}
+
+// BytesRecorder may be implemented by a MetricsRecorder to count the bytes
+// read from and written to files, with op "Read" or "Write".
+type BytesRecorder interface {
+	AddBytes(op string, n int64)
+}
+
+// NewMetricsStore returns a StreamStore which wraps ss, recording the latency
+// of each call, and whether it failed, with m. If m implements BytesRecorder,
+// the readers, writers and files returned are wrapped to count the bytes
+// passing through them, which hides any optional interfaces, such as
+// io.WriterTo, they implement other than io.WriterAt.
+func NewMetricsStore(ss StreamStore, m MetricsRecorder) StreamStore {
+	br, _ := m.(BytesRecorder)
+	return &metricsStreamStore{ss, m, br}
+}
+
+type metricsStreamStore struct {
+	ss    StreamStore
+	m     MetricsRecorder
+	bytes BytesRecorder
+}
+
+func (fs *metricsStreamStore) Unwrap() StreamStore {
+	return fs.ss
+}
+
+// record records a call to op, which started at start and returned err.
+func (fs *metricsStreamStore) record(op string, start time.Time, err error) {
+	fs.m.ObserveLatency(op, time.Since(start))
+	if err != nil {
+		fs.m.IncError(op)
+	}
+}
+
+func (fs *metricsStreamStore) Close() error {
+	start := time.Now()
+	err := fs.ss.Close()
+	fs.record("Close", start, err)
+	return err
+}
+
+func (fs *metricsStreamStore) OpenReadCloser(name string) (StrawReader, error) {
+	start := time.Now()
+	r, err := fs.ss.OpenReadCloser(name)
+	fs.record("OpenReadCloser", start, err)
+	if err != nil || fs.bytes == nil {
+		return r, err
+	}
+	return &metricsReader{r, fs.bytes}, nil
+}
+
+func (fs *metricsStreamStore) CreateWriteCloser(name string) (StrawWriter, error) {
+	start := time.Now()
+	w, err := fs.ss.CreateWriteCloser(name)
+	fs.record("CreateWriteCloser", start, err)
+	if err != nil || fs.bytes == nil {
+		return w, err
+	}
+	if wa, ok := w.(io.WriterAt); ok {
+		return &metricsWriterAt{metricsWriter{w, fs.bytes}, wa}, nil
+	}
+	return &metricsWriter{w, fs.bytes}, nil
+}
+
+func (fs *metricsStreamStore) OpenFile(name string, flag int, perm os.FileMode) (StrawReadWriteCloser, error) {
+	start := time.Now()
+	f, err := fs.ss.OpenFile(name, flag, perm)
+	fs.record("OpenFile", start, err)
+	if err != nil || fs.bytes == nil {
+		return f, err
+	}
+	return &metricsFile{f, fs.bytes}, nil
+}
+
+func (fs *metricsStreamStore) Lstat(path string) (os.FileInfo, error) {
+	start := time.Now()
+	fi, err := fs.ss.Lstat(path)
+	fs.record("Lstat", start, err)
+	return fi, err
+}
+
+func (fs *metricsStreamStore) Stat(path string) (os.FileInfo, error) {
+	start := time.Now()
+	fi, err := fs.ss.Stat(path)
+	fs.record("Stat", start, err)
+	return fi, err
+}
+
+func (fs *metricsStreamStore) Readdir(path string) ([]os.FileInfo, error) {
+	start := time.Now()
+	fis, err := fs.ss.Readdir(path)
+	fs.record("Readdir", start, err)
+	return fis, err
+}
+
+func (fs *metricsStreamStore) Mkdir(path string, mode os.FileMode) error {
+	start := time.Now()
+	err := fs.ss.Mkdir(path, mode)
+	fs.record("Mkdir", start, err)
+	return err
+}
+
+func (fs *metricsStreamStore) Remove(path string) error {
+	start := time.Now()
+	err := fs.ss.Remove(path)
+	fs.record("Remove", start, err)
+	return err
+}
+
+func (fs *metricsStreamStore) Chmod(name string, mode os.FileMode) error {
+	start := time.Now()
+	err := fs.ss.Chmod(name, mode)
+	fs.record("Chmod", start, err)
+	return err
+}
+
+func (fs *metricsStreamStore) Truncate(name string, size int64) error {
+	start := time.Now()
+	err := fs.ss.Truncate(name, size)
+	fs.record("Truncate", start, err)
+	return err
+}
+
+func (fs *metricsStreamStore) Symlink(oldname, newname string) error {
+	start := time.Now()
+	err := Symlink(fs.ss, oldname, newname)
+	fs.record("Symlink", start, err)
+	return err
+}
+
+func (fs *metricsStreamStore) Readlink(name string) (string, error) {
+	start := time.Now()
+	dest, err := Readlink(fs.ss, name)
+	fs.record("Readlink", start, err)
+	return dest, err
+}
+
+func (fs *metricsStreamStore) Copy(src, dst string) error {
+	start := time.Now()
+	err := fs.ss.Copy(src, dst)
+	fs.record("Copy", start, err)
+	return err
+}
+
+// metricsReader counts the bytes read from a StrawReader.
+type metricsReader struct {
+	StrawReader
+	bytes BytesRecorder
+}
+
+func (r *metricsReader) Read(p []byte) (int, error) {
+	n, err := r.StrawReader.Read(p)
+	r.bytes.AddBytes("Read", int64(n))
+	return n, err
+}
+
+func (r *metricsReader) ReadAt(p []byte, off int64) (int, error) {
+	n, err := r.StrawReader.ReadAt(p, off)
+	r.bytes.AddBytes("Read", int64(n))
+	return n, err
+}
+
+// metricsWriter counts the bytes written to a StrawWriter.
+type metricsWriter struct {
+	StrawWriter
+	bytes BytesRecorder
+}
+
+func (w *metricsWriter) Write(p []byte) (int, error) {
+	n, err := w.StrawWriter.Write(p)
+	w.bytes.AddBytes("Write", int64(n))
+	return n, err
+}
+
+// metricsWriterAt is a metricsWriter for writers which implement
+// io.WriterAt.
+type metricsWriterAt struct {
+	metricsWriter
+	wa io.WriterAt
+}
+
+func (w *metricsWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	n, err := w.wa.WriteAt(p, off)
+	w.bytes.AddBytes("Write", int64(n))
+	return n, err
+}
+
+// metricsFile counts the bytes read from and written to a file opened with
+// OpenFile.
+type metricsFile struct {
+	StrawReadWriteCloser
+	bytes BytesRecorder
+}
+
+func (f *metricsFile) Read(p []byte) (int, error) {
+	n, err := f.StrawReadWriteCloser.Read(p)
+	f.bytes.AddBytes("Read", int64(n))
+	return n, err
+}
+
+func (f *metricsFile) ReadAt(p []byte, off int64) (int, error) {
+	n, err := f.StrawReadWriteCloser.ReadAt(p, off)
+	f.bytes.AddBytes("Read", int64(n))
+	return n, err
+}
+
+func (f *metricsFile) Write(p []byte) (int, error) {
+	n, err := f.StrawReadWriteCloser.Write(p)
+	f.bytes.AddBytes("Write", int64(n))
+	return n, err
+}
+
+func (f *metricsFile) WriteAt(p []byte, off int64) (int, error) {
+	n, err := f.StrawReadWriteCloser.WriteAt(p, off)
+	f.bytes.AddBytes("Write", int64(n))
+	return n, err
+}
@@ -0,0 +1,120 @@
+package straw_test
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+type testRecorder struct {
+	lk      sync.Mutex
+	calls   map[string]int
+	errors  map[string]int
+	byteOps map[string]int64
+}
+
+func newTestRecorder() *testRecorder {
+	return &testRecorder{
+		calls:   make(map[string]int),
+		errors:  make(map[string]int),
+		byteOps: make(map[string]int64),
+	}
+}
+
+func (r *testRecorder) ObserveLatency(op string, d time.Duration) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	r.calls[op]++
+}
+
+func (r *testRecorder) IncError(op string) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	r.errors[op]++
+}
+
+type testBytesRecorder struct {
+	*testRecorder
+}
+
+func (r testBytesRecorder) AddBytes(op string, n int64) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	r.byteOps[op] += n
+}
+
+func TestMetricsStore(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	rec := newTestRecorder()
+	mem, _ := straw.Open("mem://")
+	ss := straw.NewMetricsStore(mem, testBytesRecorder{rec})
+
+	require.NoError(ss.Mkdir("/a", 0755))
+	w, err := ss.CreateWriteCloser("/a/1")
+	require.NoError(err)
+	_, err = w.Write([]byte("hello"))
+	require.NoError(err)
+	require.NoError(w.Close())
+
+	r, err := ss.OpenReadCloser("/a/1")
+	require.NoError(err)
+	data, err := ioutil.ReadAll(r)
+	require.NoError(err)
+	assert.Equal("hello", string(data))
+	require.NoError(r.Close())
+
+	f, err := ss.OpenFile("/a/1", os.O_RDWR, 0)
+	require.NoError(err)
+	_, err = f.WriteAt([]byte("J"), 0)
+	require.NoError(err)
+	_, err = f.Seek(0, io.SeekEnd)
+	require.NoError(err)
+	_, err = f.Write([]byte("!!"))
+	require.NoError(err)
+	buf := make([]byte, 2)
+	_, err = f.ReadAt(buf, 0)
+	require.NoError(err)
+	require.NoError(f.Close())
+
+	_, err = ss.Stat("/missing")
+	assert.Error(err)
+	_, err = ss.Stat("/a/1")
+	assert.NoError(err)
+
+	assert.Equal(1, rec.calls["Mkdir"])
+	assert.Equal(1, rec.calls["CreateWriteCloser"])
+	assert.Equal(1, rec.calls["OpenReadCloser"])
+	assert.Equal(1, rec.calls["OpenFile"])
+	assert.Equal(2, rec.calls["Stat"])
+	assert.Equal(map[string]int{"Stat": 1}, rec.errors)
+	assert.Equal(map[string]int64{"Read": 7, "Write": 8}, rec.byteOps)
+}
+
+func TestMetricsStoreWithoutBytes(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	rec := newTestRecorder()
+	mem, _ := straw.Open("mem://")
+	ss := straw.NewMetricsStore(mem, rec)
+
+	w, err := ss.CreateWriteCloser("/1")
+	require.NoError(err)
+	_, err = w.Write([]byte("hello"))
+	require.NoError(err)
+	require.NoError(w.Close())
+	assert.Error(ss.Remove("/missing"))
+
+	assert.Equal(1, rec.calls["CreateWriteCloser"])
+	assert.Equal(map[string]int{"Remove": 1}, rec.errors)
+	assert.Empty(rec.byteOps)
+}
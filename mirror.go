@@ -0,0 +1,274 @@
+package straw
+
+import (
+	"fmt"
+	"os"
+)
+
+var _ StreamStore = &mirrorStreamStore{}
+
+// MirrorOptions configures a store returned by NewMirrorStoreWithOptions.
+type MirrorOptions struct {
+	// RequireMirrors, if true, causes operations to fail if they fail on any
+	// mirror. Otherwise only failures on the primary are returned, and a
+	// mirror which fails part way through writing a file is no longer written
+	// to.
+	RequireMirrors bool
+	// ReadFallback, if true, causes reads of files which do not exist in the
+	// primary to be tried on each mirror in turn.
+	ReadFallback bool
+	// OnMirrorError, if non-nil, is called with each error returned by a
+	// mirror, named by op and the path it acted on, whether or not it causes
+	// the operation to fail.
+	OnMirrorError func(mirror StreamStore, op, name string, err error)
+}
+
+// NewMirrorStore returns a StreamStore which writes to primary and to each of
+// mirrors, and reads from primary. It is the same as
+// NewMirrorStoreWithOptions with the zero MirrorOptions, so failures on
+// mirrors are ignored.
+func NewMirrorStore(primary StreamStore, mirrors ...StreamStore) StreamStore {
+	return NewMirrorStoreWithOptions(MirrorOptions{}, primary, mirrors...)
+}
+
+// NewMirrorStoreWithOptions returns a StreamStore which writes to primary and
+// to each of mirrors, and reads from primary.
+//
+// CreateWriteCloser, Mkdir, Remove, Chmod, Truncate and Copy are performed on the primary
+// first, and fail without touching the mirrors if it fails. They are then
+// performed on each mirror, failing according to opts.RequireMirrors. Mkdir
+// of a directory which already exists, and Remove of a file which does not,
+// are not failures on a mirror, so mirrors may hold a subset of the primary.
+// Writers pass each Write to every target, and Close closes every target,
+// returning the error from the primary if there was one, otherwise the first
+// error from a mirror if opts.RequireMirrors is set. A failed write is not
+// rolled back, so files may be left complete on some stores and missing or
+// partial on others, and should be rewritten.
+//
+// Lstat, Stat, Readdir and OpenReadCloser use primary, falling back to the
+// mirrors for paths which do not exist if opts.ReadFallback is set.
+// Closing the returned store closes primary and every mirror.
+func NewMirrorStoreWithOptions(opts MirrorOptions, primary StreamStore, mirrors ...StreamStore) StreamStore {
+	return &mirrorStreamStore{primary, mirrors, opts}
+}
+
+type mirrorStreamStore struct {
+	primary StreamStore
+	mirrors []StreamStore
+	opts    MirrorOptions
+}
+
+func (fs *mirrorStreamStore) Unwrap() StreamStore {
+	return fs.primary
+}
+
+func (fs *mirrorStreamStore) Close() error {
+	err := fs.primary.Close()
+	for _, m := range fs.mirrors {
+		if merr := m.Close(); merr != nil && err == nil {
+			err = merr
+		}
+	}
+	return err
+}
+
+// mirrorErr reports err, returned by m for op on name, and returns it if it
+// should cause the operation to fail.
+func (fs *mirrorStreamStore) mirrorErr(m StreamStore, op, name string, err error) error {
+	if fs.opts.OnMirrorError != nil {
+		fs.opts.OnMirrorError(m, op, name, err)
+	}
+	if fs.opts.RequireMirrors {
+		return fmt.Errorf("mirror %s %s: %w", op, name, err)
+	}
+	return nil
+}
+
+// fanOut performs f on the primary and then on each mirror.
+func (fs *mirrorStreamStore) fanOut(op, name string, f func(ss StreamStore) error, ignore func(error) bool) error {
+	if err := f(fs.primary); err != nil {
+		return err
+	}
+	for _, m := range fs.mirrors {
+		if err := f(m); err != nil && !ignore(err) {
+			if err := fs.mirrorErr(m, op, name, err); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (fs *mirrorStreamStore) Mkdir(path string, mode os.FileMode) error {
+	return fs.fanOut("Mkdir", path, func(ss StreamStore) error {
+		return ss.Mkdir(path, mode)
+	}, os.IsExist)
+}
+
+func (fs *mirrorStreamStore) Remove(path string) error {
+	return fs.fanOut("Remove", path, func(ss StreamStore) error {
+		return ss.Remove(path)
+	}, os.IsNotExist)
+}
+
+func (fs *mirrorStreamStore) Chmod(name string, mode os.FileMode) error {
+	return fs.fanOut("Chmod", name, func(ss StreamStore) error {
+		return ss.Chmod(name, mode)
+	}, func(error) bool { return false })
+}
+
+func (fs *mirrorStreamStore) Truncate(name string, size int64) error {
+	return fs.fanOut("Truncate", name, func(ss StreamStore) error {
+		return ss.Truncate(name, size)
+	}, func(error) bool { return false })
+}
+
+func (fs *mirrorStreamStore) Copy(src, dst string) error {
+	return fs.fanOut("Copy", src, func(ss StreamStore) error {
+		return ss.Copy(src, dst)
+	}, func(error) bool { return false })
+}
+
+// read performs f on the primary, and on each mirror in turn while the path
+// does not exist, if fallback is enabled.
+func (fs *mirrorStreamStore) read(f func(ss StreamStore) error) error {
+	err := f(fs.primary)
+	if !fs.opts.ReadFallback {
+		return err
+	}
+	for _, m := range fs.mirrors {
+		if !os.IsNotExist(err) {
+			break
+		}
+		err = f(m)
+	}
+	return err
+}
+
+func (fs *mirrorStreamStore) OpenReadCloser(name string) (StrawReader, error) {
+	var r StrawReader
+	err := fs.read(func(ss StreamStore) (err error) {
+		r, err = ss.OpenReadCloser(name)
+		return err
+	})
+	return r, err
+}
+
+func (fs *mirrorStreamStore) Lstat(path string) (os.FileInfo, error) {
+	var fi os.FileInfo
+	err := fs.read(func(ss StreamStore) (err error) {
+		fi, err = ss.Lstat(path)
+		return err
+	})
+	return fi, err
+}
+
+func (fs *mirrorStreamStore) Stat(path string) (os.FileInfo, error) {
+	var fi os.FileInfo
+	err := fs.read(func(ss StreamStore) (err error) {
+		fi, err = ss.Stat(path)
+		return err
+	})
+	return fi, err
+}
+
+func (fs *mirrorStreamStore) Readdir(path string) ([]os.FileInfo, error) {
+	var fis []os.FileInfo
+	err := fs.read(func(ss StreamStore) (err error) {
+		fis, err = ss.Readdir(path)
+		return err
+	})
+	return fis, err
+}
+
+// OpenFile reads files opened read only as OpenReadCloser does. Files opened
+// for writing are held in memory and written to every store on Close, as
+// described by OpenFileBuffered, with os.O_EXCL applying to the primary.
+func (fs *mirrorStreamStore) OpenFile(name string, flag int, perm os.FileMode) (StrawReadWriteCloser, error) {
+	return OpenFileBuffered(fs, name, flag, func(name string) error {
+		f, err := fs.primary.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+		if err != nil {
+			return err
+		}
+		return f.Close()
+	})
+}
+
+func (fs *mirrorStreamStore) CreateWriteCloser(name string) (StrawWriter, error) {
+	w, err := fs.primary.CreateWriteCloser(name)
+	if err != nil {
+		return nil, err
+	}
+	mw := &mirrorWriter{fs: fs, name: name, primary: w}
+	for _, m := range fs.mirrors {
+		mirror, err := m.CreateWriteCloser(name)
+		if err != nil {
+			if err := fs.mirrorErr(m, "CreateWriteCloser", name, err); err != nil {
+				mw.abort()
+				return nil, err
+			}
+			continue
+		}
+		mw.mirrors = append(mw.mirrors, mirrorTarget{m, mirror})
+	}
+	return mw, nil
+}
+
+type mirrorTarget struct {
+	ss StreamStore
+	w  StrawWriter
+}
+
+// mirrorWriter writes to the primary and to each mirror which has not yet
+// failed.
+type mirrorWriter struct {
+	fs      *mirrorStreamStore
+	name    string
+	primary StrawWriter
+	mirrors []mirrorTarget
+}
+
+func (w *mirrorWriter) Write(p []byte) (int, error) {
+	n, err := w.primary.Write(p)
+	if err != nil {
+		return n, err
+	}
+	live := make([]mirrorTarget, 0, len(w.mirrors))
+	for i, t := range w.mirrors {
+		if _, err := t.w.Write(p); err != nil {
+			t.w.Close()
+			if err := w.fs.mirrorErr(t.ss, "Write", w.name, err); err != nil {
+				w.mirrors = append(live, w.mirrors[i+1:]...)
+				return n, err
+			}
+			continue
+		}
+		live = append(live, t)
+	}
+	w.mirrors = live
+	return n, nil
+}
+
+// Close closes the primary and every live mirror. Closing again returns the
+// error of closing the primary again, which reports that it is closed.
+func (w *mirrorWriter) Close() error {
+	err := w.primary.Close()
+	for _, t := range w.mirrors {
+		if merr := t.w.Close(); merr != nil {
+			merr = w.fs.mirrorErr(t.ss, "Close", w.name, merr)
+			if err == nil {
+				err = merr
+			}
+		}
+	}
+	w.mirrors = nil
+	return err
+}
+
+// abort closes every target, ignoring errors.
+func (w *mirrorWriter) abort() {
+	w.primary.Close()
+	for _, t := range w.mirrors {
+		t.w.Close()
+	}
+}
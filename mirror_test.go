@@ -0,0 +1,93 @@
+package straw_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+func TestMirrorStore(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	primary, _ := straw.Open("mem://")
+	mirror, _ := straw.Open("mem://")
+	ss := straw.NewMirrorStore(primary, mirror)
+
+	require.NoError(ss.Mkdir("/dir", 0755))
+	writeContent(t, ss, "/dir/a", []byte("a"))
+	require.NoError(ss.Copy("/dir/a", "/dir/b"))
+
+	for _, s := range []straw.StreamStore{primary, mirror} {
+		for _, name := range []string{"/dir/a", "/dir/b"} {
+			data, err := straw.ReadFile(s, name)
+			require.NoError(err)
+			assert.Equal("a", string(data))
+		}
+	}
+
+	require.NoError(ss.Remove("/dir/a"))
+	for _, s := range []straw.StreamStore{primary, mirror} {
+		_, err := s.Stat("/dir/a")
+		assert.True(os.IsNotExist(err))
+	}
+
+	// mirrors may hold a subset of the primary.
+	writeContent(t, primary, "/only-primary", []byte("p"))
+	assert.NoError(ss.Remove("/only-primary"))
+}
+
+func TestMirrorStoreReadFallback(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	primary, _ := straw.Open("mem://")
+	mirror, _ := straw.Open("mem://")
+	writeContent(t, mirror, "/a", []byte("a"))
+
+	_, err := straw.ReadFile(straw.NewMirrorStore(primary, mirror), "/a")
+	assert.True(os.IsNotExist(err))
+
+	ss := straw.NewMirrorStoreWithOptions(straw.MirrorOptions{ReadFallback: true}, primary, mirror)
+	data, err := straw.ReadFile(ss, "/a")
+	require.NoError(err)
+	assert.Equal("a", string(data))
+
+	_, err = ss.Stat("/b")
+	assert.True(os.IsNotExist(err))
+}
+
+func TestMirrorStoreMirrorFailure(t *testing.T) {
+	assert := assert.New(t)
+
+	errClose := errors.New("close failed")
+	primary, _ := straw.Open("mem://")
+	mem, _ := straw.Open("mem://")
+	mirror := &failingCloseStreamStore{mem, errClose}
+
+	var reported []string
+	onErr := func(m straw.StreamStore, op, name string, err error) {
+		assert.Equal(mirror, m)
+		reported = append(reported, op+" "+name)
+	}
+
+	ss := straw.NewMirrorStoreWithOptions(straw.MirrorOptions{OnMirrorError: onErr}, primary, mirror)
+	w, err := ss.CreateWriteCloser("/a")
+	assert.NoError(err)
+	assert.NoError(w.Close())
+	assert.Equal([]string{"Close /a"}, reported)
+
+	ss = straw.NewMirrorStoreWithOptions(straw.MirrorOptions{RequireMirrors: true, OnMirrorError: onErr}, primary, mirror)
+	w, err = ss.CreateWriteCloser("/b")
+	assert.NoError(err)
+	assert.True(errors.Is(w.Close(), errClose))
+	assert.Equal([]string{"Close /a", "Close /b"}, reported)
+
+	// the primary is written regardless.
+	_, err = primary.Stat("/b")
+	assert.NoError(err)
+}
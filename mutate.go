@@ -0,0 +1,46 @@
+package straw
+
+// MutateOption configures helpers which modify a store, such as RemoveAll and
+// Sync.
+type MutateOption func(*mutateOptions)
+
+type mutateOptions struct {
+	dryRun           func(op, path string)
+	deleteExtraneous bool
+}
+
+// DryRun returns a MutateOption which causes a helper to report each
+// operation it would perform to fn, rather than performing it. Operations
+// which only read from the store are still performed, so the reported plan
+// reflects the real state of the store.
+func DryRun(fn func(op, path string)) MutateOption {
+	return func(o *mutateOptions) {
+		o.dryRun = fn
+	}
+}
+
+// DeleteExtraneous returns a MutateOption which causes Sync to remove files
+// and directories from the destination which do not exist in the source.
+func DeleteExtraneous() MutateOption {
+	return func(o *mutateOptions) {
+		o.deleteExtraneous = true
+	}
+}
+
+func newMutateOptions(opts []MutateOption) *mutateOptions {
+	o := &mutateOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// do performs f, the operation op on path, unless running in dry-run mode,
+// in which case the operation is only reported.
+func (o *mutateOptions) do(op, path string, f func() error) error {
+	if o.dryRun != nil {
+		o.dryRun(op, path)
+		return nil
+	}
+	return f()
+}
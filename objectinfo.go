@@ -0,0 +1,40 @@
+package straw
+
+import "time"
+
+// ObjectInfo holds attributes of files on object stores which have no
+// counterpart in os.FileInfo. A *ObjectInfo is returned by the Sys method of
+// the os.FileInfo values that the s3 and gcs backends return for files, and
+// nil for directories, so callers can type assert it as they would the Sys
+// of a local file:
+//
+//	if info, ok := fi.Sys().(*straw.ObjectInfo); ok {
+//
+// The gcs backend fills it in from the object attributes returned by Stat
+// and Readdir, and Sys never makes a request. S3 listings include only the
+// ETag and storage class, so for files from both Stat and Readdir the s3
+// backend fetches the rest, and the mode set by Chmod, with a HEAD request
+// the first time Sys or Mode is called, using the context passed to
+// StatContext or ReaddirContext. Stat and Readdir make no request per file.
+// If the HEAD request fails, Sys reports only the ETag and storage class and
+// Mode reports 0644.
+//
+// The other backends return what they would otherwise: the local filesystem
+// returns the Sys of the os.FileInfo, such as a *syscall.Stat_t, sftp a
+// *sftp.FileStat, and mem nil.
+type ObjectInfo struct {
+	// Expires is when the object should be considered stale, or the zero
+	// time if it was not set.
+	Expires time.Time
+	// StorageClass is the storage class of the object, such as
+	// "STANDARD_IA" on s3 or "NEARLINE" on gcs.
+	StorageClass string
+	// ContentType is the MIME type the object is served with.
+	ContentType string
+	// ETag is the entity tag of the object, without quotes.
+	ETag string
+	// Metadata holds the user metadata of the object, as set with
+	// WithMetadata, with lower case keys on s3. Entries which straw uses
+	// itself, such as for the mode set by Chmod, are not included.
+	Metadata map[string]string
+}
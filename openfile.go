@@ -0,0 +1,238 @@
+package straw
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+var (
+	errNotOpenForReading = errors.New("file not open for reading")
+	errNotOpenForWriting = errors.New("file not open for writing")
+	errNegativeSize      = errors.New("negative size")
+)
+
+// OpenFileBuffered implements OpenFile for stores which can only write whole
+// files, in terms of the other methods of ss. Files opened read only are
+// read directly with OpenReadCloser. Files opened for writing are held in
+// memory, loaded with their existing content unless os.O_TRUNC is given,
+// and written with CreateWriteCloser on Close if they were modified or
+// created. A file created by OpenFile exists, empty, as soon as it is opened.
+//
+// If createExclusive is non-nil it is used to create the file for
+// os.O_CREATE|os.O_EXCL, and must atomically create an empty file, failing
+// with an error satisfying os.IsExist if the file already exists. Otherwise
+// the file is checked for and created in separate steps, which may race with
+// other writers.
+func OpenFileBuffered(ss StreamStore, name string, flag int, createExclusive func(name string) error) (StrawReadWriteCloser, error) {
+	access := flag & (os.O_RDONLY | os.O_WRONLY | os.O_RDWR)
+	f := &bufferedFile{
+		ss:       ss,
+		name:     name,
+		readable: access != os.O_WRONLY,
+		writable: access != os.O_RDONLY,
+		append:   flag&os.O_APPEND != 0,
+	}
+
+	fi, err := ss.Stat(name)
+	switch {
+	case err == nil:
+		if flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL {
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrExist}
+		}
+		if fi.IsDir() {
+			return nil, &os.PathError{Op: "open", Path: name, Err: ErrIsDirectory}
+		}
+		if !f.writable {
+			r, err := ss.OpenReadCloser(name)
+			if err != nil {
+				return nil, err
+			}
+			return &readOnlyFile{r, name}, nil
+		}
+		if flag&os.O_TRUNC != 0 {
+			f.dirty = true
+			return f, nil
+		}
+		if f.data, err = ReadFile(ss, name); err != nil {
+			return nil, err
+		}
+		return f, nil
+	case os.IsNotExist(err):
+		if flag&os.O_CREATE == 0 {
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+		}
+		if flag&os.O_EXCL != 0 && createExclusive != nil {
+			err = createExclusive(name)
+		} else {
+			err = WriteFile(ss, name, nil, 0)
+		}
+		if err != nil {
+			return nil, err
+		}
+		// the store may have created something other than an empty file,
+		// such as a placeholder, so write the file properly on Close.
+		f.dirty = f.writable
+		return f, nil
+	default:
+		return nil, err
+	}
+}
+
+// TruncateBuffered implements Truncate for stores which can only write whole
+// files, by reading the content of name into memory and writing it back with
+// CreateWriteCloser at the new size. The file is replaced rather than
+// modified, so concurrent writes may be lost, and readers may see either the
+// old or new content.
+func TruncateBuffered(ss StreamStore, name string, size int64) error {
+	if size < 0 {
+		return &os.PathError{Op: "truncate", Path: name, Err: errNegativeSize}
+	}
+	fi, err := ss.Stat(name)
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		return &os.PathError{Op: "truncate", Path: name, Err: ErrIsDirectory}
+	}
+	if fi.Size() == size {
+		return nil
+	}
+	var data []byte
+	if size > 0 {
+		if data, err = ReadFile(ss, name); err != nil {
+			return err
+		}
+	}
+	return WriteFile(ss, name, resize(data, size), 0)
+}
+
+// resize returns data cut to size, or extended to it with zeros. A cut slice
+// has no spare capacity, so appending to it cannot overwrite the discarded
+// content still held by readers.
+func resize(data []byte, size int64) []byte {
+	if size <= int64(len(data)) {
+		return data[:size:size]
+	}
+	return append(data, make([]byte, size-int64(len(data)))...)
+}
+
+// writeAt copies p into data at off, extending data as needed and filling
+// any gap between its end and off with zeros.
+func writeAt(data, p []byte, off int64) []byte {
+	if end := off + int64(len(p)); end > int64(len(data)) {
+		data = resize(data, end)
+	}
+	copy(data[off:], p)
+	return data
+}
+
+// readOnlyFile is a file opened with os.O_RDONLY.
+type readOnlyFile struct {
+	StrawReader
+	name string
+}
+
+func (f *readOnlyFile) Write([]byte) (int, error) {
+	return 0, &os.PathError{Op: "write", Path: f.name, Err: errNotOpenForWriting}
+}
+
+func (f *readOnlyFile) WriteAt([]byte, int64) (int, error) {
+	return 0, &os.PathError{Op: "write", Path: f.name, Err: errNotOpenForWriting}
+}
+
+// bufferedFile holds the content of a file opened for writing in memory.
+type bufferedFile struct {
+	ss       StreamStore
+	name     string
+	readable bool
+	writable bool
+	append   bool
+
+	data   []byte
+	pos    int64
+	dirty  bool
+	closed bool
+}
+
+func (f *bufferedFile) Read(p []byte) (int, error) {
+	n, err := f.ReadAt(p, f.pos)
+	f.pos += int64(n)
+	return n, err
+}
+
+func (f *bufferedFile) ReadAt(p []byte, off int64) (int, error) {
+	if f.closed {
+		return 0, ErrClosed
+	}
+	if !f.readable {
+		return 0, &os.PathError{Op: "read", Path: f.name, Err: errNotOpenForReading}
+	}
+	if off < 0 {
+		return 0, &os.PathError{Op: "readat", Path: f.name, Err: errors.New("negative offset")}
+	}
+	if off >= int64(len(f.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *bufferedFile) Seek(offset int64, whence int) (int64, error) {
+	if f.closed {
+		return 0, ErrClosed
+	}
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.pos
+	case io.SeekEnd:
+		offset += int64(len(f.data))
+	default:
+		return 0, &os.PathError{Op: "seek", Path: f.name, Err: errors.New("invalid whence")}
+	}
+	if offset < 0 {
+		return 0, &os.PathError{Op: "seek", Path: f.name, Err: errors.New("negative position")}
+	}
+	f.pos = offset
+	return offset, nil
+}
+
+func (f *bufferedFile) Write(p []byte) (int, error) {
+	if f.append {
+		f.pos = int64(len(f.data))
+	}
+	n, err := f.WriteAt(p, f.pos)
+	f.pos += int64(n)
+	return n, err
+}
+
+func (f *bufferedFile) WriteAt(p []byte, off int64) (int, error) {
+	if f.closed {
+		return 0, ErrClosed
+	}
+	if !f.writable {
+		return 0, &os.PathError{Op: "write", Path: f.name, Err: errNotOpenForWriting}
+	}
+	if off < 0 {
+		return 0, &os.PathError{Op: "writeat", Path: f.name, Err: errors.New("negative offset")}
+	}
+	f.data = writeAt(f.data, p, off)
+	f.dirty = true
+	return len(p), nil
+}
+
+// Close writes the content of the file to the store if it was modified.
+func (f *bufferedFile) Close() error {
+	if f.closed {
+		return ErrClosed
+	}
+	f.closed = true
+	if !f.dirty {
+		return nil
+	}
+	return WriteFile(f.ss, f.name, f.data, 0)
+}
@@ -0,0 +1,22 @@
+package straw
+
+import "os"
+
+// OpenFirst tries to open each of names in turn, returning a reader for the
+// first that exists along with its name. If none of them exist, the returned
+// error satisfies os.IsNotExist. Any other error encountered stops the search
+// and is returned immediately.
+func OpenFirst(ss StreamStore, names ...string) (StrawReader, string, error) {
+	err := os.ErrNotExist
+	for _, name := range names {
+		var r StrawReader
+		r, err = ss.OpenReadCloser(name)
+		if err == nil {
+			return r, name, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, "", err
+		}
+	}
+	return nil, "", err
+}
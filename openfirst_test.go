@@ -0,0 +1,69 @@
+package straw_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+func TestOpenFirst(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, _ := straw.Open("mem://")
+	ss.Mkdir("/etc", 0755)
+	writeContent(t, ss, "/etc/second.conf", []byte("second"))
+	writeContent(t, ss, "/etc/third.conf", []byte("third"))
+
+	r, name, err := straw.OpenFirst(ss, "/etc/first.conf", "/etc/second.conf", "/etc/third.conf")
+	require.NoError(err)
+	assert.Equal("/etc/second.conf", name)
+	data, err := ioutil.ReadAll(r)
+	assert.NoError(err)
+	assert.Equal("second", string(data))
+	assert.NoError(r.Close())
+}
+
+func TestOpenFirstNoneExist(t *testing.T) {
+	assert := assert.New(t)
+
+	ss, _ := straw.Open("mem://")
+
+	r, name, err := straw.OpenFirst(ss, "/a", "/b")
+	assert.True(os.IsNotExist(err))
+	assert.Nil(r)
+	assert.Equal("", name)
+
+	_, _, err = straw.OpenFirst(ss)
+	assert.True(os.IsNotExist(err))
+}
+
+type failingOpenStreamStore struct {
+	straw.StreamStore
+	err error
+}
+
+func (fs *failingOpenStreamStore) OpenReadCloser(name string) (straw.StrawReader, error) {
+	if name == "/broken" {
+		return nil, fs.err
+	}
+	return fs.StreamStore.OpenReadCloser(name)
+}
+
+func TestOpenFirstStopsOnError(t *testing.T) {
+	assert := assert.New(t)
+
+	ss, _ := straw.Open("mem://")
+	writeFile(ss, "/b")
+	someError := errors.New("permission denied")
+
+	r, name, err := straw.OpenFirst(&failingOpenStreamStore{ss, someError}, "/a", "/broken", "/b")
+	assert.Equal(someError, err)
+	assert.Nil(r)
+	assert.Equal("", name)
+}
@@ -0,0 +1,37 @@
+package straw
+
+import "time"
+
+// PresignStore is implemented by stores which can generate URLs granting
+// temporary access to a file without credentials, so that clients can
+// transfer its content directly to or from the backend. The s3 backend
+// implements it, and gcs may do so with signed URLs.
+type PresignStore interface {
+	// PresignGetURL returns a URL from which the named file can be
+	// downloaded with a GET request until expiry has passed. The file need
+	// not exist when the URL is generated.
+	PresignGetURL(name string, expiry time.Duration) (string, error)
+	// PresignPutURL returns a URL to which the named file can be uploaded
+	// with a PUT request until expiry has passed.
+	PresignPutURL(name string, expiry time.Duration) (string, error)
+}
+
+// presignGetURL calls the PresignGetURL method of ss, returning
+// ErrNotSupported if ss does not implement PresignStore.
+func presignGetURL(ss StreamStore, name string, expiry time.Duration) (string, error) {
+	ps, ok := ss.(PresignStore)
+	if !ok {
+		return "", ErrNotSupported
+	}
+	return ps.PresignGetURL(name, expiry)
+}
+
+// presignPutURL calls the PresignPutURL method of ss, returning
+// ErrNotSupported if ss does not implement PresignStore.
+func presignPutURL(ss StreamStore, name string, expiry time.Duration) (string, error) {
+	ps, ok := ss.(PresignStore)
+	if !ok {
+		return "", ErrNotSupported
+	}
+	return ps.PresignPutURL(name, expiry)
+}
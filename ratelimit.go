@@ -0,0 +1,261 @@
+package straw
+
+import (
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+var _ StreamStore = &rateLimitedStreamStore{}
+
+// NewRateLimitedStore returns a StreamStore which wraps ss, limiting the
+// bytes read from and written to its files, together, to bytesPerSec. The
+// limit is shared by every file opened through the returned store, and
+// allows a burst of up to one second's worth of bytes after a quiet period.
+// Copy is not limited, since the data need not pass through this process.
+func NewRateLimitedStore(ss StreamStore, bytesPerSec int64) StreamStore {
+	l := newRateLimiter(bytesPerSec)
+	return &rateLimitedStreamStore{ss, l, l}
+}
+
+// NewReadWriteRateLimitedStore is like NewRateLimitedStore, but limits the
+// bytes read and the bytes written separately. A limit which is zero or less
+// leaves that direction unlimited.
+func NewReadWriteRateLimitedStore(ss StreamStore, readBytesPerSec, writeBytesPerSec int64) StreamStore {
+	return &rateLimitedStreamStore{ss, newRateLimiter(readBytesPerSec), newRateLimiter(writeBytesPerSec)}
+}
+
+// rateLimiter is a token bucket holding up to rate tokens, each allowing one
+// byte, which refills at rate tokens per second. A nil *rateLimiter doesn't
+// limit anything.
+type rateLimiter struct {
+	lk     sync.Mutex
+	rate   int64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(bytesPerSec int64) *rateLimiter {
+	if bytesPerSec <= 0 {
+		return nil
+	}
+	return &rateLimiter{rate: bytesPerSec, tokens: float64(bytesPerSec), last: time.Now()}
+}
+
+// burst returns the most bytes which should be transferred in one go, so
+// that callers don't wait for longer than a second at a time.
+func (l *rateLimiter) burst(n int) int {
+	if l != nil && int64(n) > l.rate {
+		return int(l.rate)
+	}
+	return n
+}
+
+// wait takes n tokens, sleeping for as long as the bucket is in debt
+// afterwards. Taking tokens before sleeping means that concurrent callers
+// queue up behind each other rather than all waking at once.
+func (l *rateLimiter) wait(n int) {
+	if l == nil || n <= 0 {
+		return
+	}
+	l.lk.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * float64(l.rate)
+	if l.tokens > float64(l.rate) {
+		l.tokens = float64(l.rate)
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	debt := -l.tokens
+	l.lk.Unlock()
+	if debt > 0 {
+		time.Sleep(time.Duration(debt / float64(l.rate) * float64(time.Second)))
+	}
+}
+
+type rateLimitedStreamStore struct {
+	ss    StreamStore
+	read  *rateLimiter
+	write *rateLimiter
+}
+
+func (fs *rateLimitedStreamStore) Unwrap() StreamStore {
+	return fs.ss
+}
+
+func (fs *rateLimitedStreamStore) Close() error {
+	return fs.ss.Close()
+}
+
+func (fs *rateLimitedStreamStore) OpenReadCloser(name string) (StrawReader, error) {
+	r, err := fs.ss.OpenReadCloser(name)
+	if err != nil {
+		return nil, err
+	}
+	return &rateLimitedReader{r, fs.read}, nil
+}
+
+func (fs *rateLimitedStreamStore) CreateWriteCloser(name string) (StrawWriter, error) {
+	w, err := fs.ss.CreateWriteCloser(name)
+	if err != nil {
+		return nil, err
+	}
+	if wa, ok := w.(io.WriterAt); ok {
+		return &rateLimitedWriterAt{rateLimitedWriter{w, fs.write}, wa}, nil
+	}
+	return &rateLimitedWriter{w, fs.write}, nil
+}
+
+func (fs *rateLimitedStreamStore) OpenFile(name string, flag int, perm os.FileMode) (StrawReadWriteCloser, error) {
+	f, err := fs.ss.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &rateLimitedFile{f, fs.read, fs.write}, nil
+}
+
+func (fs *rateLimitedStreamStore) Lstat(path string) (os.FileInfo, error) {
+	return fs.ss.Lstat(path)
+}
+
+func (fs *rateLimitedStreamStore) Stat(path string) (os.FileInfo, error) {
+	return fs.ss.Stat(path)
+}
+
+func (fs *rateLimitedStreamStore) Readdir(path string) ([]os.FileInfo, error) {
+	return fs.ss.Readdir(path)
+}
+
+func (fs *rateLimitedStreamStore) Mkdir(path string, mode os.FileMode) error {
+	return fs.ss.Mkdir(path, mode)
+}
+
+func (fs *rateLimitedStreamStore) Remove(path string) error {
+	return fs.ss.Remove(path)
+}
+
+func (fs *rateLimitedStreamStore) Chmod(name string, mode os.FileMode) error {
+	return fs.ss.Chmod(name, mode)
+}
+
+func (fs *rateLimitedStreamStore) Truncate(name string, size int64) error {
+	return fs.ss.Truncate(name, size)
+}
+
+func (fs *rateLimitedStreamStore) Symlink(oldname, newname string) error {
+	return Symlink(fs.ss, oldname, newname)
+}
+
+func (fs *rateLimitedStreamStore) Readlink(name string) (string, error) {
+	return Readlink(fs.ss, name)
+}
+
+func (fs *rateLimitedStreamStore) Copy(src, dst string) error {
+	return fs.ss.Copy(src, dst)
+}
+
+// limitedRead reads into p with read, at most a burst at a time, then waits
+// for the bytes read.
+func limitedRead(l *rateLimiter, p []byte, read func([]byte) (int, error)) (int, error) {
+	n, err := read(p[:l.burst(len(p))])
+	l.wait(n)
+	return n, err
+}
+
+// limitedWrite writes p with write a burst at a time, waiting before each
+// burst.
+func limitedWrite(l *rateLimiter, p []byte, write func([]byte) (int, error)) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := p[:l.burst(len(p))]
+		l.wait(len(chunk))
+		n, err := write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+type rateLimitedReader struct {
+	StrawReader
+	l *rateLimiter
+}
+
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	return limitedRead(r.l, p, r.StrawReader.Read)
+}
+
+// ReadAt must fill p unless it fails, so it reads a burst at a time until
+// it does.
+func (r *rateLimitedReader) ReadAt(p []byte, off int64) (int, error) {
+	return readAtLimited(r.l, p, off, r.StrawReader.ReadAt)
+}
+
+func readAtLimited(l *rateLimiter, p []byte, off int64, readAt func([]byte, int64) (int, error)) (int, error) {
+	read := 0
+	for read < len(p) {
+		n, err := limitedRead(l, p[read:], func(b []byte) (int, error) {
+			return readAt(b, off+int64(read))
+		})
+		read += n
+		if err != nil {
+			return read, err
+		}
+	}
+	return read, nil
+}
+
+type rateLimitedWriter struct {
+	StrawWriter
+	l *rateLimiter
+}
+
+func (w *rateLimitedWriter) Write(p []byte) (int, error) {
+	return limitedWrite(w.l, p, w.StrawWriter.Write)
+}
+
+// rateLimitedWriterAt is a rateLimitedWriter for writers which implement
+// io.WriterAt.
+type rateLimitedWriterAt struct {
+	rateLimitedWriter
+	wa io.WriterAt
+}
+
+func (w *rateLimitedWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	return writeAtLimited(w.l, p, off, w.wa.WriteAt)
+}
+
+func writeAtLimited(l *rateLimiter, p []byte, off int64, writeAt func([]byte, int64) (int, error)) (int, error) {
+	written := 0
+	return limitedWrite(l, p, func(b []byte) (int, error) {
+		n, err := writeAt(b, off+int64(written))
+		written += n
+		return n, err
+	})
+}
+
+type rateLimitedFile struct {
+	StrawReadWriteCloser
+	read  *rateLimiter
+	write *rateLimiter
+}
+
+func (f *rateLimitedFile) Read(p []byte) (int, error) {
+	return limitedRead(f.read, p, f.StrawReadWriteCloser.Read)
+}
+
+func (f *rateLimitedFile) ReadAt(p []byte, off int64) (int, error) {
+	return readAtLimited(f.read, p, off, f.StrawReadWriteCloser.ReadAt)
+}
+
+func (f *rateLimitedFile) Write(p []byte) (int, error) {
+	return limitedWrite(f.write, p, f.StrawReadWriteCloser.Write)
+}
+
+func (f *rateLimitedFile) WriteAt(p []byte, off int64) (int, error) {
+	return writeAtLimited(f.write, p, off, f.StrawReadWriteCloser.WriteAt)
+}
@@ -0,0 +1,81 @@
+package straw_test
+
+import (
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+func TestRateLimitedStore(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	mem, _ := straw.Open("mem://")
+	ss := straw.NewRateLimitedStore(mem, 4000)
+
+	// The first second's worth is allowed as a burst, after which the
+	// writes and reads share the limit.
+	start := time.Now()
+	writeContent(t, ss, "/1", make([]byte, 3000))
+	r, err := ss.OpenReadCloser("/1")
+	require.NoError(err)
+	data, err := ioutil.ReadAll(r)
+	require.NoError(err)
+	require.NoError(r.Close())
+	elapsed := time.Since(start)
+
+	assert.Len(data, 3000)
+	assert.True(elapsed >= 400*time.Millisecond, "took %v", elapsed)
+	assert.True(elapsed < 2*time.Second, "took %v", elapsed)
+}
+
+func TestRateLimitedStoreSharedAcrossHandles(t *testing.T) {
+	assert := assert.New(t)
+
+	mem, _ := straw.Open("mem://")
+	ss := straw.NewRateLimitedStore(mem, 4000)
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for _, name := range []string{"/1", "/2", "/3"} {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			assert.NoError(straw.WriteFile(ss, name, make([]byte, 2000), 0644))
+		}(name)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	assert.True(elapsed >= 400*time.Millisecond, "took %v", elapsed)
+}
+
+func TestReadWriteRateLimitedStore(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	mem, _ := straw.Open("mem://")
+	ss := straw.NewReadWriteRateLimitedStore(mem, 0, 4000)
+
+	start := time.Now()
+	writeContent(t, ss, "/1", make([]byte, 6000))
+	assert.True(time.Since(start) >= 400*time.Millisecond, "took %v", time.Since(start))
+
+	// Reads are unlimited.
+	start = time.Now()
+	for i := 0; i < 3; i++ {
+		r, err := ss.OpenReadCloser("/1")
+		require.NoError(err)
+		buf := make([]byte, 6000)
+		n, err := r.ReadAt(buf, 0)
+		require.NoError(err)
+		assert.Equal(6000, n)
+		require.NoError(r.Close())
+	}
+	assert.True(time.Since(start) < 200*time.Millisecond, "took %v", time.Since(start))
+}
@@ -0,0 +1,182 @@
+package straw
+
+import (
+	"io"
+	"sync"
+)
+
+// readAheadChunks is the number of pieces the buffer of a read ahead reader
+// is divided into, so that the consumer can take one while the rest fill.
+const readAheadChunks = 4
+
+// defaultReadAheadSize is the buffer size used when NewReadAheadReader is
+// given one which is not positive.
+const defaultReadAheadSize = 1 << 20
+
+// NewReadAheadReader returns a StrawReader which reads r sequentially in the
+// background, up to bufSize bytes ahead of the caller's Reads, so that the
+// latency of fetching each piece of r, such as a network round trip, is
+// overlapped with the caller's processing of the last. ReadAt is passed
+// straight to r, and Seek discards what has been read ahead and stops
+// reading ahead until the next Read, so random access is not slowed down by
+// reading data which is never used. Errors from r, including io.EOF, are
+// returned by Read once the data read before them has been consumed. Close
+// stops the background reads and closes r.
+//
+// The reader returned is not safe for concurrent use, and r must not be
+// used directly while it is open.
+func NewReadAheadReader(r StrawReader, bufSize int) StrawReader {
+	if bufSize <= 0 {
+		bufSize = defaultReadAheadSize
+	}
+	chunkSize := bufSize / readAheadChunks
+	if chunkSize < 1 {
+		chunkSize = 1
+	}
+	return &readAheadReader{r: r, chunkSize: chunkSize}
+}
+
+type readAheadChunk struct {
+	buf  []byte
+	data []byte
+	err  error
+}
+
+type readAheadReader struct {
+	r         StrawReader
+	chunkSize int
+
+	// lk serializes calls on r between the background reader and ReadAt.
+	lk sync.Mutex
+
+	// chunks carries what the background reader has read, and free returns
+	// the buffers of consumed chunks to it. stop is closed to end it, and
+	// done is closed once it has.
+	chunks chan readAheadChunk
+	free   chan []byte
+	stop   chan struct{}
+	done   chan struct{}
+
+	cur     readAheadChunk // the chunk being consumed
+	err     error          // the error which ended the background reads
+	started bool
+	closed  bool
+}
+
+// start begins reading ahead from the current position of r.
+func (ra *readAheadReader) start() {
+	ra.chunks = make(chan readAheadChunk, readAheadChunks)
+	ra.free = make(chan []byte, readAheadChunks)
+	for i := 0; i < readAheadChunks; i++ {
+		ra.free <- make([]byte, ra.chunkSize)
+	}
+	ra.stop = make(chan struct{})
+	ra.done = make(chan struct{})
+	ra.started = true
+	go ra.readAhead(ra.chunks, ra.free, ra.stop, ra.done)
+}
+
+func (ra *readAheadReader) readAhead(chunks chan<- readAheadChunk, free <-chan []byte, stop, done chan struct{}) {
+	defer close(done)
+	for {
+		var buf []byte
+		select {
+		case buf = <-free:
+		case <-stop:
+			return
+		}
+		ra.lk.Lock()
+		n, err := io.ReadAtLeast(ra.r, buf, 1)
+		ra.lk.Unlock()
+		select {
+		case chunks <- readAheadChunk{buf: buf, data: buf[:n], err: err}:
+		case <-stop:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// halt stops reading ahead, returning the number of bytes which were read
+// from r but not consumed.
+func (ra *readAheadReader) halt() int64 {
+	if !ra.started {
+		return 0
+	}
+	close(ra.stop)
+	<-ra.done
+	unread := int64(len(ra.cur.data))
+	for drained := false; !drained; {
+		select {
+		case c := <-ra.chunks:
+			unread += int64(len(c.data))
+		default:
+			drained = true
+		}
+	}
+	ra.cur = readAheadChunk{}
+	ra.err = nil
+	ra.started = false
+	return unread
+}
+
+func (ra *readAheadReader) Read(p []byte) (int, error) {
+	if ra.closed {
+		return 0, ErrClosed
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if !ra.started {
+		ra.start()
+	}
+	for len(ra.cur.data) == 0 {
+		if ra.cur.err != nil {
+			ra.err = ra.cur.err
+		}
+		if ra.err != nil {
+			return 0, ra.err
+		}
+		if ra.cur.buf != nil {
+			ra.free <- ra.cur.buf
+		}
+		ra.cur = <-ra.chunks
+	}
+	n := copy(p, ra.cur.data)
+	ra.cur.data = ra.cur.data[n:]
+	return n, nil
+}
+
+func (ra *readAheadReader) ReadAt(p []byte, off int64) (int, error) {
+	if ra.closed {
+		return 0, ErrClosed
+	}
+	ra.lk.Lock()
+	defer ra.lk.Unlock()
+	return ra.r.ReadAt(p, off)
+}
+
+func (ra *readAheadReader) Seek(offset int64, whence int) (int64, error) {
+	if ra.closed {
+		return 0, ErrClosed
+	}
+	// r is ahead of the caller by what was read but not consumed, so is put
+	// back first, leaving it where the caller expects if the seek fails.
+	if unread := ra.halt(); unread != 0 {
+		if _, err := ra.r.Seek(-unread, io.SeekCurrent); err != nil {
+			return 0, err
+		}
+	}
+	return ra.r.Seek(offset, whence)
+}
+
+func (ra *readAheadReader) Close() error {
+	if ra.closed {
+		return ErrClosed
+	}
+	ra.closed = true
+	ra.halt()
+	return ra.r.Close()
+}
@@ -0,0 +1,160 @@
+package straw_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+// countingStrawReader is a StrawReader over a byte slice which records how
+// far it has been read, and fails with err once it reaches failAt, if err is
+// set.
+type countingStrawReader struct {
+	*bytes.Reader
+	lk     sync.Mutex
+	read   int64
+	failAt int64
+	err    error
+}
+
+func (r *countingStrawReader) Read(p []byte) (int, error) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	if r.err != nil {
+		pos, _ := r.Reader.Seek(0, io.SeekCurrent)
+		if pos >= r.failAt {
+			return 0, r.err
+		}
+		if max := r.failAt - pos; int64(len(p)) > max {
+			p = p[:max]
+		}
+	}
+	n, err := r.Reader.Read(p)
+	r.read += int64(n)
+	return n, err
+}
+
+func (r *countingStrawReader) Close() error {
+	return nil
+}
+
+func (r *countingStrawReader) bytesRead() int64 {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	return r.read
+}
+
+func randomData(n int) []byte {
+	data := make([]byte, n)
+	rand.New(rand.NewSource(1)).Read(data)
+	return data
+}
+
+func TestReadAheadReader(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	data := randomData(100000)
+	src := &countingStrawReader{Reader: bytes.NewReader(data)}
+	r := straw.NewReadAheadReader(src, 4096)
+
+	buf := make([]byte, 10)
+	_, err := io.ReadFull(r, buf)
+	require.NoError(err)
+	assert.Equal(data[:10], buf)
+
+	// the background reads fill the buffer ahead of the caller.
+	deadline := time.Now().Add(5 * time.Second)
+	for src.bytesRead() < 4096 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.True(src.bytesRead() >= 4096, "read ahead %d bytes", src.bytesRead())
+	assert.True(src.bytesRead() <= 4096+1024, "read ahead %d bytes", src.bytesRead())
+
+	rest, err := ioutil.ReadAll(r)
+	require.NoError(err)
+	assert.Equal(data[10:], rest)
+	n, err := r.Read(buf)
+	assert.Equal(0, n)
+	assert.Equal(io.EOF, err)
+
+	require.NoError(r.Close())
+	assert.Equal(straw.ErrClosed, r.Close())
+}
+
+func TestReadAheadReaderSeek(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	data := randomData(100000)
+	r := straw.NewReadAheadReader(&countingStrawReader{Reader: bytes.NewReader(data)}, 4096)
+	defer r.Close()
+
+	buf := make([]byte, 100)
+	_, err := io.ReadFull(r, buf)
+	require.NoError(err)
+
+	// seeking relative to the caller's position, not how far has been read
+	// ahead.
+	pos, err := r.Seek(50, io.SeekCurrent)
+	require.NoError(err)
+	assert.Equal(int64(150), pos)
+	_, err = io.ReadFull(r, buf)
+	require.NoError(err)
+	assert.Equal(data[150:250], buf)
+
+	pos, err = r.Seek(0, io.SeekCurrent)
+	require.NoError(err)
+	assert.Equal(int64(250), pos)
+
+	pos, err = r.Seek(-100, io.SeekEnd)
+	require.NoError(err)
+	assert.Equal(int64(len(data)-100), pos)
+	rest, err := ioutil.ReadAll(r)
+	require.NoError(err)
+	assert.Equal(data[len(data)-100:], rest)
+
+	_, err = r.Seek(20, io.SeekStart)
+	require.NoError(err)
+	_, err = io.ReadFull(r, buf)
+	require.NoError(err)
+	assert.Equal(data[20:120], buf)
+
+	// a failed seek leaves the position where it was.
+	_, err = r.Seek(-1000, io.SeekCurrent)
+	assert.Error(err)
+	_, err = io.ReadFull(r, buf)
+	require.NoError(err)
+	assert.Equal(data[120:220], buf)
+
+	n, err := r.ReadAt(buf, 5000)
+	require.NoError(err)
+	assert.Equal(data[5000:5000+n], buf[:n])
+	_, err = io.ReadFull(r, buf)
+	require.NoError(err)
+	assert.Equal(data[220:320], buf)
+}
+
+func TestReadAheadReaderError(t *testing.T) {
+	assert := assert.New(t)
+
+	data := randomData(10000)
+	broken := errors.New("broken")
+	r := straw.NewReadAheadReader(&countingStrawReader{Reader: bytes.NewReader(data), failAt: 5000, err: broken}, 1024)
+	defer r.Close()
+
+	got, err := ioutil.ReadAll(r)
+	assert.Equal(broken, err)
+	assert.Equal(data[:5000], got)
+	_, err = r.Read(make([]byte, 1))
+	assert.Equal(broken, err)
+}
@@ -0,0 +1,34 @@
+package straw
+
+import (
+	"os"
+)
+
+// ReaddirEacher is implemented by stores which list directories in pages,
+// such as object stores, and can pass each entry to a callback as its page
+// arrives, rather than holding the whole listing in memory as Readdir does.
+type ReaddirEacher interface {
+	ReaddirEach(name string, fn func(os.FileInfo) error) error
+}
+
+// ReaddirEach calls fn with each entry of the named directory in ss, stopping
+// at the first error returned by fn, which is returned. If ss implements
+// ReaddirEacher, entries are passed in the order they are listed, which is
+// not necessarily sorted, and returning an error from fn stops any further
+// pages being fetched. Otherwise the directory is read with Readdir and its
+// entries passed in order.
+func ReaddirEach(ss StreamStore, name string, fn func(os.FileInfo) error) error {
+	if re, ok := ss.(ReaddirEacher); ok {
+		return re.ReaddirEach(name, fn)
+	}
+	fis, err := ss.Readdir(name)
+	if err != nil {
+		return err
+	}
+	for _, fi := range fis {
+		if err := fn(fi); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package straw_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+func TestReaddirEach(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, _ := straw.Open("mem://")
+	for _, name := range []string{"/c", "/a", "/b"} {
+		writeContent(t, ss, name, []byte{1})
+	}
+
+	var names []string
+	require.NoError(straw.ReaddirEach(ss, "/", func(fi os.FileInfo) error {
+		names = append(names, fi.Name())
+		return nil
+	}))
+	assert.Equal([]string{"a", "b", "c"}, names)
+
+	stop := errors.New("stop")
+	names = nil
+	err := straw.ReaddirEach(ss, "/", func(fi os.FileInfo) error {
+		names = append(names, fi.Name())
+		return stop
+	})
+	assert.Equal(stop, err)
+	assert.Equal([]string{"a"}, names)
+
+	err = straw.ReaddirEach(ss, "/missing", func(os.FileInfo) error { return nil })
+	assert.True(os.IsNotExist(err))
+}
@@ -0,0 +1,55 @@
+//go:build go1.23
+
+package straw
+
+import (
+	"errors"
+	"iter"
+	"os"
+)
+
+// errStopIter is returned to ReaddirEach by the callback of ReaddirIter when
+// the loop over the iterator ends early.
+var errStopIter = errors.New("iteration stopped")
+
+// ReaddirIter returns an iterator over the entries of the named directory in
+// ss, for use with range:
+//
+//	seq, err := straw.ReaddirIter(ss, "/dir")
+//	...
+//	for fi, err := range seq {
+//
+// If ss implements ReaddirEacher, such as the s3 and gcs backends, the
+// directory is listed as the iteration proceeds, each entry being yielded as
+// its page arrives, and breaking out of the loop stops any further pages
+// being fetched. Errors listing the directory are then yielded with a nil
+// FileInfo, ending the iteration, and the error returned by ReaddirIter
+// itself is always nil. Otherwise the directory is read with Readdir when
+// ReaddirIter is called, returning any error then, and the iterator yields
+// its entries, in order.
+func ReaddirIter(ss StreamStore, name string) (iter.Seq2[os.FileInfo, error], error) {
+	if re, ok := ss.(ReaddirEacher); ok {
+		return func(yield func(os.FileInfo, error) bool) {
+			err := re.ReaddirEach(name, func(fi os.FileInfo) error {
+				if !yield(fi, nil) {
+					return errStopIter
+				}
+				return nil
+			})
+			if err != nil && err != errStopIter {
+				yield(nil, err)
+			}
+		}, nil
+	}
+	fis, err := ss.Readdir(name)
+	if err != nil {
+		return nil, err
+	}
+	return func(yield func(os.FileInfo, error) bool) {
+		for _, fi := range fis {
+			if !yield(fi, nil) {
+				return
+			}
+		}
+	}, nil
+}
@@ -0,0 +1,104 @@
+//go:build go1.23
+
+package straw_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+// pagedStore lists directories a page of two entries at a time through
+// ReaddirEach, counting the pages fetched.
+type pagedStore struct {
+	straw.StreamStore
+	pages int
+	err   error
+}
+
+func (ps *pagedStore) ReaddirEach(name string, fn func(os.FileInfo) error) error {
+	fis, err := ps.Readdir(name)
+	if err != nil {
+		return err
+	}
+	for len(fis) != 0 {
+		ps.pages++
+		page := fis[:min(2, len(fis))]
+		fis = fis[len(page):]
+		for _, fi := range page {
+			if err := fn(fi); err != nil {
+				return err
+			}
+		}
+	}
+	return ps.err
+}
+
+func TestReaddirIter(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, _ := straw.Open("mem://")
+	for _, name := range []string{"/c", "/a", "/b", "/d", "/e"} {
+		writeContent(t, ss, name, []byte{1})
+	}
+
+	seq, err := straw.ReaddirIter(ss, "/")
+	require.NoError(err)
+	var names []string
+	for fi, err := range seq {
+		require.NoError(err)
+		names = append(names, fi.Name())
+	}
+	assert.Equal([]string{"a", "b", "c", "d", "e"}, names)
+
+	_, err = straw.ReaddirIter(ss, "/missing")
+	assert.True(os.IsNotExist(err))
+}
+
+func TestReaddirIterPaged(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	mem, _ := straw.Open("mem://")
+	for _, name := range []string{"/c", "/a", "/b", "/d", "/e"} {
+		writeContent(t, mem, name, []byte{1})
+	}
+	ps := &pagedStore{StreamStore: mem}
+
+	seq, err := straw.ReaddirIter(ps, "/")
+	require.NoError(err)
+	assert.Equal(0, ps.pages)
+	var names []string
+	for fi, err := range seq {
+		require.NoError(err)
+		names = append(names, fi.Name())
+	}
+	assert.Equal([]string{"a", "b", "c", "d", "e"}, names)
+	assert.Equal(3, ps.pages)
+
+	// breaking out of the loop fetches no more pages.
+	ps.pages = 0
+	for fi := range seq {
+		if fi.Name() == "a" {
+			break
+		}
+	}
+	assert.Equal(1, ps.pages)
+
+	// errors listing are yielded, ending the iteration.
+	broken := errors.New("broken")
+	ps.err = broken
+	var errs []error
+	for fi, err := range seq {
+		if err != nil {
+			assert.Nil(fi)
+			errs = append(errs, err)
+		}
+	}
+	assert.Equal([]error{broken}, errs)
+}
@@ -0,0 +1,51 @@
+package straw
+
+import (
+	"bytes"
+	"os"
+)
+
+// ReadFile reads the named file and returns its contents. A successful call
+// returns err == nil, not err == io.EOF. The buffer is sized from Stat where
+// possible, avoiding reallocations as the file is read.
+// This is the straw equivalent of os.ReadFile in the standard library.
+func ReadFile(ss StreamStore, name string) ([]byte, error) {
+	var size int64
+	if fi, err := ss.Stat(name); err == nil {
+		size = fi.Size()
+	}
+
+	r, err := ss.OpenReadCloser(name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	// bytes.MinRead more than the expected size, as ReadFrom grows the
+	// buffer whenever it has less free space than that, including for the
+	// read which finds the end of the file.
+	buf := bytes.NewBuffer(make([]byte, 0, size+bytes.MinRead))
+	if _, err := buf.ReadFrom(r); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteFile writes data to the named file, creating it if necessary and
+// replacing its contents otherwise. The parent directory must already exist,
+// as WriteFile does not create it; use MkdirAll first if needed.
+// perm is ignored, and accepted only for symmetry with os.WriteFile: files
+// are created with the default permissions of the store. Use Chmod
+// afterwards to set the mode of a file.
+// This is the straw equivalent of os.WriteFile in the standard library.
+func WriteFile(ss StreamStore, name string, data []byte, perm os.FileMode) error {
+	w, err := ss.CreateWriteCloser(name)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
@@ -0,0 +1,38 @@
+package straw_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+func TestReadWriteFile(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, _ := straw.Open("mem://")
+
+	require.NoError(straw.WriteFile(ss, "/a", []byte("hello"), 0644))
+	data, err := straw.ReadFile(ss, "/a")
+	require.NoError(err)
+	assert.Equal("hello", string(data))
+
+	require.NoError(straw.WriteFile(ss, "/a", []byte("bye"), 0644))
+	data, err = straw.ReadFile(ss, "/a")
+	require.NoError(err)
+	assert.Equal("bye", string(data))
+
+	require.NoError(straw.WriteFile(ss, "/empty", nil, 0644))
+	data, err = straw.ReadFile(ss, "/empty")
+	require.NoError(err)
+	assert.Empty(data)
+
+	_, err = straw.ReadFile(ss, "/missing")
+	assert.True(os.IsNotExist(err))
+
+	// parent directories are not created.
+	assert.Error(straw.WriteFile(ss, "/d/a", []byte("x"), 0644))
+}
@@ -0,0 +1,86 @@
+package straw
+
+import "os"
+
+var _ StreamStore = &readOnlyStreamStore{}
+
+// NewReadOnlyStore returns a StreamStore which wraps ss, allowing it to be
+// read but not modified. The methods which would modify it, including
+// OpenFile with any flag other than os.O_RDONLY, return an *os.PathError
+// wrapping ErrReadOnly without calling ss.
+func NewReadOnlyStore(ss StreamStore) StreamStore {
+	return &readOnlyStreamStore{ss}
+}
+
+type readOnlyStreamStore struct {
+	ss StreamStore
+}
+
+// Scheme reports the scheme of the store beneath. The store is deliberately
+// not a Wrapper, since unwrapping it would give write access.
+func (fs *readOnlyStreamStore) Scheme() string {
+	return Scheme(fs.ss)
+}
+
+func readOnlyError(op, name string) error {
+	return &os.PathError{Op: op, Path: name, Err: ErrReadOnly}
+}
+
+func (fs *readOnlyStreamStore) Close() error {
+	return fs.ss.Close()
+}
+
+func (fs *readOnlyStreamStore) OpenReadCloser(name string) (StrawReader, error) {
+	return fs.ss.OpenReadCloser(name)
+}
+
+func (fs *readOnlyStreamStore) CreateWriteCloser(name string) (StrawWriter, error) {
+	return nil, readOnlyError("create", name)
+}
+
+func (fs *readOnlyStreamStore) OpenFile(name string, flag int, perm os.FileMode) (StrawReadWriteCloser, error) {
+	if flag != os.O_RDONLY {
+		return nil, readOnlyError("open", name)
+	}
+	return fs.ss.OpenFile(name, flag, perm)
+}
+
+func (fs *readOnlyStreamStore) Lstat(path string) (os.FileInfo, error) {
+	return fs.ss.Lstat(path)
+}
+
+func (fs *readOnlyStreamStore) Stat(path string) (os.FileInfo, error) {
+	return fs.ss.Stat(path)
+}
+
+func (fs *readOnlyStreamStore) Readdir(path string) ([]os.FileInfo, error) {
+	return fs.ss.Readdir(path)
+}
+
+func (fs *readOnlyStreamStore) Mkdir(path string, mode os.FileMode) error {
+	return readOnlyError("mkdir", path)
+}
+
+func (fs *readOnlyStreamStore) Remove(path string) error {
+	return readOnlyError("remove", path)
+}
+
+func (fs *readOnlyStreamStore) Chmod(name string, mode os.FileMode) error {
+	return readOnlyError("chmod", name)
+}
+
+func (fs *readOnlyStreamStore) Truncate(name string, size int64) error {
+	return readOnlyError("truncate", name)
+}
+
+func (fs *readOnlyStreamStore) Symlink(oldname, newname string) error {
+	return readOnlyError("symlink", newname)
+}
+
+func (fs *readOnlyStreamStore) Readlink(name string) (string, error) {
+	return Readlink(fs.ss, name)
+}
+
+func (fs *readOnlyStreamStore) Copy(src, dst string) error {
+	return readOnlyError("copy", dst)
+}
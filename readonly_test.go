@@ -0,0 +1,72 @@
+package straw_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+func TestReadOnlyStore(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	mem, _ := straw.Open("mem://")
+	require.NoError(mem.Mkdir("/a", 0755))
+	writeContent(t, mem, "/a/1", []byte("hello"))
+	ss := straw.NewReadOnlyStore(mem)
+
+	r, err := ss.OpenReadCloser("/a/1")
+	require.NoError(err)
+	data, err := ioutil.ReadAll(r)
+	require.NoError(err)
+	require.NoError(r.Close())
+	assert.Equal("hello", string(data))
+
+	f, err := ss.OpenFile("/a/1", os.O_RDONLY, 0)
+	require.NoError(err)
+	require.NoError(f.Close())
+
+	fis, err := ss.Readdir("/a")
+	require.NoError(err)
+	assert.Len(fis, 1)
+	_, err = ss.Stat("/a/1")
+	assert.NoError(err)
+
+	_, err = ss.CreateWriteCloser("/a/2")
+	assert.True(errors.Is(err, straw.ErrReadOnly), "%v", err)
+	_, err = ss.OpenFile("/a/1", os.O_RDWR, 0)
+	assert.True(errors.Is(err, straw.ErrReadOnly), "%v", err)
+	for _, err := range []error{
+		ss.Mkdir("/b", 0755),
+		ss.Remove("/a/1"),
+		ss.Chmod("/a/1", 0600),
+		ss.Truncate("/a/1", 0),
+		ss.Copy("/a/1", "/a/2"),
+		straw.Symlink(ss, "/a/1", "/a/3"),
+	} {
+		assert.True(errors.Is(err, straw.ErrReadOnly), "%v", err)
+	}
+
+	data, err = straw.ReadFile(mem, "/a/1")
+	require.NoError(err)
+	assert.Equal("hello", string(data))
+	fis, err = mem.Readdir("/a")
+	require.NoError(err)
+	assert.Len(fis, 1)
+}
+
+func TestReadOnlyStoreCannotBeUnwrapped(t *testing.T) {
+	assert := assert.New(t)
+
+	mem, _ := straw.Open("mem://")
+	ss := straw.NewReadOnlyStore(mem)
+
+	_, ok := ss.(straw.Wrapper)
+	assert.False(ok)
+	assert.Equal("mem", straw.Scheme(ss))
+}
@@ -2,6 +2,7 @@ package straw
 
 import (
 	"net/url"
+	"sort"
 	"sync"
 )
 
@@ -10,14 +11,41 @@ var (
 	backends   = make(map[string]func(url *url.URL) (StreamStore, error))
 )
 
-func Register(scheme string, sinkFunc func(url *url.URL) (StreamStore, error)) {
+// Register makes a backend available to Open under scheme, so that
+// Open(scheme+"://...") calls opener with the parsed URL, after any Options
+// have been applied to it. The built in backends register themselves this
+// way, in the init functions of their packages, and Register is safe to call
+// from an init function, or concurrently with Open. Register panics if
+// scheme is empty, if opener is nil, or if a backend is already registered
+// under scheme.
+func Register(scheme string, opener func(url *url.URL) (StreamStore, error)) {
 	backendsLk.Lock()
 	defer backendsLk.Unlock()
-	if sinkFunc == nil {
-		panic("straw: sink function is nil")
+	if scheme == "" {
+		panic("straw: Register called with an empty scheme")
+	}
+	if opener == nil {
+		panic("straw: Register called with a nil opener for scheme " + scheme)
 	}
 	if _, dup := backends[scheme]; dup {
-		panic("straw: RegisterSink called more than once for sink " + scheme)
+		panic("straw: Register called twice for scheme " + scheme)
+	}
+	backends[scheme] = opener
+}
+
+// Schemes returns the sorted schemes which backends are registered under.
+func Schemes() []string {
+	backendsLk.RLock()
+	defer backendsLk.RUnlock()
+	return schemes()
+}
+
+// schemes returns the sorted registered schemes, with backendsLk held.
+func schemes() []string {
+	res := make([]string, 0, len(backends))
+	for scheme := range backends {
+		res = append(res, scheme)
 	}
-	backends[scheme] = sinkFunc
+	sort.Strings(res)
+	return res
 }
@@ -0,0 +1,60 @@
+package straw_test
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+func TestRegister(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	mem, _ := straw.Open("mem://")
+	var opened *url.URL
+	straw.Register("registertest", func(u *url.URL) (straw.StreamStore, error) {
+		opened = u
+		return mem, nil
+	})
+
+	ss, err := straw.Open("registertest://host/path?a=b", straw.QueryOption("c", "d"))
+	require.NoError(err)
+	assert.Equal(mem, ss)
+	require.NotNil(opened)
+	assert.Equal("host", opened.Host)
+	assert.Equal("/path", opened.Path)
+	assert.Equal("b", opened.Query().Get("a"))
+	assert.Equal("d", opened.Query().Get("c"))
+
+	opener := func(u *url.URL) (straw.StreamStore, error) { return mem, nil }
+	assert.PanicsWithValue("straw: Register called twice for scheme registertest", func() {
+		straw.Register("registertest", opener)
+	})
+	assert.PanicsWithValue("straw: Register called twice for scheme file", func() {
+		straw.Register("file", opener)
+	})
+	assert.Panics(func() { straw.Register("", opener) })
+	assert.Panics(func() { straw.Register("registertest_nil", nil) })
+
+	_, err = straw.Open("unregistered://")
+	assert.Error(err)
+}
+
+func TestSchemes(t *testing.T) {
+	assert := assert.New(t)
+
+	schemes := straw.Schemes()
+	assert.True(sort.StringsAreSorted(schemes), "%v", schemes)
+	assert.Contains(schemes, "file")
+	assert.Contains(schemes, "mem")
+
+	_, err := straw.Open("s33://bucket")
+	if assert.Error(err) {
+		assert.Equal(`unknown scheme "s33"; known schemes: `+strings.Join(schemes, ", "), err.Error())
+	}
+}
@@ -0,0 +1,58 @@
+package straw
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// AllRemover is implemented by stores which can remove a tree more
+// efficiently than one file at a time, such as object stores supporting
+// batch deletes.
+type AllRemover interface {
+	RemoveAll(path string) error
+}
+
+// RemoveAll removes path and any children it contains. It removes everything
+// it can but returns the first error it encounters. If the path does not
+// exist, RemoveAll returns nil (no error).
+// If ss implements AllRemover, its RemoveAll method is used, except in
+// dry-run mode.
+// This is the straw equivalent of os.RemoveAll in the standard library.
+func RemoveAll(ss StreamStore, path string, opts ...MutateOption) error {
+	o := newMutateOptions(opts)
+	if ar, ok := ss.(AllRemover); ok && o.dryRun == nil {
+		return ar.RemoveAll(path)
+	}
+	return removeAll(ss, path, o)
+}
+
+func removeAll(ss StreamStore, path string, o *mutateOptions) error {
+	fi, err := ss.Lstat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var firstErr error
+	if fi.IsDir() {
+		children, err := ss.Readdir(path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		for _, child := range children {
+			if err := removeAll(ss, filepath.Join(path, child.Name()), o); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	err = o.do("Remove", path, func() error {
+		return ss.Remove(path)
+	})
+	if err != nil && !os.IsNotExist(err) && firstErr == nil {
+		firstErr = err
+	}
+	return firstErr
+}
@@ -0,0 +1,75 @@
+package straw_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+func TestRemoveAll(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, _ := straw.Open("mem://")
+
+	require.NoError(straw.MkdirAll(ss, "/a/b/c", 0755))
+	writeFile(ss, "/a/1")
+	writeFile(ss, "/a/b/2")
+	writeFile(ss, "/d")
+
+	assert.NoError(straw.RemoveAll(ss, "/a"))
+
+	_, err := ss.Stat("/a")
+	assert.True(os.IsNotExist(err))
+
+	fis, err := ss.Readdir("/")
+	assert.NoError(err)
+	require.Equal(1, len(fis))
+	assert.Equal("d", fis[0].Name())
+}
+
+func TestRemoveAllFile(t *testing.T) {
+	assert := assert.New(t)
+
+	ss, _ := straw.Open("mem://")
+	writeFile(ss, "/a")
+
+	assert.NoError(straw.RemoveAll(ss, "/a"))
+
+	_, err := ss.Stat("/a")
+	assert.True(os.IsNotExist(err))
+}
+
+func TestRemoveAllNotExist(t *testing.T) {
+	assert := assert.New(t)
+
+	ss, _ := straw.Open("mem://")
+
+	assert.NoError(straw.RemoveAll(ss, "/does/not/exist"))
+}
+
+func TestRemoveAllDryRun(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, _ := straw.Open("mem://")
+	rec := &TestRecordingStreamStore{wrapped: ss}
+
+	require.NoError(straw.MkdirAll(ss, "/a/b", 0755))
+	writeFile(ss, "/a/1")
+	writeFile(ss, "/a/b/2")
+
+	var planned []string
+	err := straw.RemoveAll(rec, "/a", straw.DryRun(func(op, path string) {
+		planned = append(planned, op+" "+path)
+	}))
+	assert.NoError(err)
+	assert.Equal([]string{"Remove /a/1", "Remove /a/b/2", "Remove /a/b", "Remove /a"}, planned)
+	assert.Empty(rec.Calls("Remove"))
+
+	_, err = ss.Stat("/a/b/2")
+	assert.NoError(err)
+}
@@ -0,0 +1,128 @@
+package straw
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"time"
+)
+
+// RetryOptions configures how operations which fail are retried.
+type RetryOptions struct {
+	// MaxAttempts is the maximum number of times an operation is attempted,
+	// including the first. Values below 2 disable retries.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry, which is doubled
+	// for each subsequent retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between retries, if non-zero.
+	MaxBackoff time.Duration
+	// MaxElapsed, if non-zero, stops retries once this much time has passed
+	// since the first attempt.
+	MaxElapsed time.Duration
+	// Retryable reports whether an error may be retried. If nil, only errors
+	// for which IsTransient reports true are retried; a Retryable can widen
+	// that by calling IsTransient itself. Errors satisfying os.IsNotExist are
+	// never retried.
+	Retryable func(error) bool
+}
+
+// NewRetryStore returns a StreamStore which wraps ss, retrying operations
+// which fail according to opts. Only operations which are safe to repeat,
+// Lstat, Stat, Readdir, OpenReadCloser, Remove, Exists, IsDir and GetTags,
+// are retried, with the delay between attempts growing exponentially.
+// Operations given a context, through the functions such as StatContext,
+// stop retrying when it is done. Reads and writes on open files are not
+// retried.
+func NewRetryStore(ss StreamStore, opts RetryOptions) StreamStore {
+	return &opStreamStore{ss, retrying(opts)}
+}
+
+// idempotentOps are the operations which are safe to repeat.
+var idempotentOps = map[string]bool{
+	"Lstat":          true,
+	"Stat":           true,
+	"Readdir":        true,
+	"OpenReadCloser": true,
+	"Remove":         true,
+	"Exists":         true,
+	"IsDir":          true,
+	"GetTags":        true,
+}
+
+func (o RetryOptions) retryable(err error) bool {
+	if os.IsNotExist(err) {
+		return false
+	}
+	if o.Retryable != nil {
+		return o.Retryable(err)
+	}
+	return IsTransient(err)
+}
+
+// IsTransient reports whether err is likely to pass if the operation is
+// repeated: timeouts, including ErrTimeout and context.DeadlineExceeded,
+// errors such as those of the net package which report themselves
+// temporary, and errors from object stores with a 5xx or 429 HTTP status or
+// an AWS throttling code. Permanent failures, such as for permissions or for
+// files which are directories, are not transient.
+func IsTransient(err error) bool {
+	if errors.Is(err, ErrTimeout) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var timeout interface{ Timeout() bool }
+	if errors.As(err, &timeout) && timeout.Timeout() {
+		return true
+	}
+	var temporary interface{ Temporary() bool }
+	if errors.As(err, &temporary) && temporary.Temporary() {
+		return true
+	}
+	var status interface{ StatusCode() int }
+	if errors.As(err, &status) {
+		if code := status.StatusCode(); code >= 500 || code == http.StatusTooManyRequests {
+			return true
+		}
+	}
+	var coded interface{ Code() string }
+	if errors.As(err, &coded) {
+		switch coded.Code() {
+		case "Throttling", "ThrottlingException", "SlowDown", "RequestLimitExceeded", "RequestTimeout":
+			return true
+		}
+	}
+	return false
+}
+
+// retrying returns an opFunc which retries idempotent operations according
+// to opts, until their context is done.
+func retrying(opts RetryOptions) opFunc {
+	return func(ctx context.Context, op, name string, f func() (interface{}, error)) (interface{}, error) {
+		if !idempotentOps[op] {
+			return f()
+		}
+		start := time.Now()
+		backoff := opts.InitialBackoff
+		for attempt := 1; ; attempt++ {
+			v, err := f()
+			if err == nil || attempt >= opts.MaxAttempts || !opts.retryable(err) || ctx.Err() != nil {
+				return v, err
+			}
+			if opts.MaxElapsed != 0 && time.Since(start)+backoff > opts.MaxElapsed {
+				return v, err
+			}
+			t := time.NewTimer(backoff)
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				t.Stop()
+				return v, err
+			}
+			backoff *= 2
+			if opts.MaxBackoff != 0 && backoff > opts.MaxBackoff {
+				backoff = opts.MaxBackoff
+			}
+		}
+	}
+}
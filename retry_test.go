@@ -0,0 +1,129 @@
+package straw_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uw-labs/straw"
+)
+
+func TestRetryStore(t *testing.T) {
+	assert := assert.New(t)
+
+	mem, _ := straw.Open("mem://")
+	writeFile(mem, "/a")
+	flaky := &flakyStreamStore{StreamStore: mem, failures: 2}
+	ss := straw.NewRetryStore(flaky, straw.RetryOptions{MaxAttempts: 3, InitialBackoff: time.Millisecond})
+
+	_, err := ss.Stat("/a")
+	assert.NoError(err)
+	assert.Equal(3, flaky.stats)
+
+	// missing files are not retried.
+	_, err = ss.Stat("/b")
+	assert.True(os.IsNotExist(err))
+	assert.Equal(4, flaky.stats)
+}
+
+func TestRetryStoreGivesUp(t *testing.T) {
+	assert := assert.New(t)
+
+	mem, _ := straw.Open("mem://")
+	writeFile(mem, "/a")
+	flaky := &flakyStreamStore{StreamStore: mem, failures: 5}
+	ss := straw.NewRetryStore(flaky, straw.RetryOptions{MaxAttempts: 3, InitialBackoff: time.Millisecond})
+
+	_, err := ss.Stat("/a")
+	assert.True(errors.Is(err, errTransient))
+	assert.Equal(3, flaky.stats)
+}
+
+func TestRetryStoreRetryable(t *testing.T) {
+	assert := assert.New(t)
+
+	mem, _ := straw.Open("mem://")
+	writeFile(mem, "/a")
+	flaky := &flakyStreamStore{StreamStore: mem, failures: 2}
+	ss := straw.NewRetryStore(flaky, straw.RetryOptions{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		Retryable:      func(err error) bool { return false },
+	})
+
+	_, err := ss.Stat("/a")
+	assert.True(errors.Is(err, errTransient))
+	assert.Equal(1, flaky.stats)
+}
+
+func TestRetryStorePermanentErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	mem, _ := straw.Open("mem://")
+	writeFile(mem, "/a")
+	for _, err := range []error{
+		&os.PathError{Op: "stat", Path: "/a", Err: os.ErrPermission},
+		&os.PathError{Op: "stat", Path: "/a", Err: straw.ErrIsDirectory},
+		&os.PathError{Op: "stat", Path: "/a", Err: straw.ErrNotDirectory},
+		&os.PathError{Op: "stat", Path: "/a", Err: straw.ErrDirectoryNotEmpty},
+		errors.New("unknown"),
+	} {
+		flaky := &flakyStreamStore{StreamStore: mem, failures: 2, err: err}
+		ss := straw.NewRetryStore(flaky, straw.RetryOptions{MaxAttempts: 3, InitialBackoff: time.Millisecond})
+		_, serr := ss.Stat("/a")
+		assert.Equal(err, serr)
+		assert.Equal(1, flaky.stats, "%v", err)
+	}
+
+	// a Retryable can widen what is retried.
+	flaky := &flakyStreamStore{StreamStore: mem, failures: 2, err: errors.New("unknown")}
+	ss := straw.NewRetryStore(flaky, straw.RetryOptions{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		Retryable:      func(err error) bool { return straw.IsTransient(err) || err.Error() == "unknown" },
+	})
+	_, err := ss.Stat("/a")
+	assert.NoError(err)
+	assert.Equal(3, flaky.stats)
+}
+
+// httpError is an error with an HTTP status, as those of the AWS SDK are.
+type httpError int
+
+func (e httpError) Error() string   { return fmt.Sprintf("status %d", int(e)) }
+func (e httpError) StatusCode() int { return int(e) }
+func (e httpError) Code() string    { return "" }
+
+// codedError is an error with an AWS error code.
+type codedError string
+
+func (e codedError) Error() string { return string(e) }
+func (e codedError) Code() string  { return string(e) }
+
+func TestIsTransient(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, tc := range []struct {
+		err       error
+		transient bool
+	}{
+		{errTransient, true},
+		{fmt.Errorf("stat /a: %w", straw.ErrTimeout), true},
+		{context.DeadlineExceeded, true},
+		{httpError(503), true},
+		{httpError(429), true},
+		{codedError("SlowDown"), true},
+		{httpError(403), false},
+		{codedError("AccessDenied"), false},
+		{context.Canceled, false},
+		{os.ErrPermission, false},
+		{straw.ErrIsDirectory, false},
+		{errors.New("unknown"), false},
+	} {
+		assert.Equal(tc.transient, straw.IsTransient(tc.err), "%v", tc.err)
+	}
+}
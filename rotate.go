@@ -0,0 +1,125 @@
+package straw
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// RotateOptions controls when a writer returned by NewRotatingWriter starts a
+// new segment. Zero values disable the corresponding limit.
+type RotateOptions struct {
+	// MaxBytes is the size at which a segment is finalized. A single Write
+	// is never split across segments, so a segment only exceeds MaxBytes
+	// if one Write does.
+	MaxBytes int64
+	// MaxAge is how long after its first Write a segment is finalized. The
+	// age is checked on each Write, so an idle segment is not finalized
+	// until the next Write or Close.
+	MaxAge time.Duration
+	// Suffix is appended to the name of each segment, e.g. ".log".
+	Suffix string
+}
+
+// NewRotatingWriter returns a writer which appends to a sequence of segment
+// files in dir, named by the UTC time of their first write so that they sort
+// in the order written. Each segment is accumulated in memory and written
+// whole when it is finalized, so that no store ever needs to support
+// appending and readers never observe a partial segment. Data is therefore
+// only durable once its segment has been finalized, either by reaching a
+// limit in opts or by Close.
+func NewRotatingWriter(ss StreamStore, dir string, opts RotateOptions) (io.WriteCloser, error) {
+	if opts.MaxBytes < 0 || opts.MaxAge < 0 {
+		return nil, errors.New("rotate options must not be negative")
+	}
+	return &rotatingWriter{ss: ss, dir: dir, opts: opts}, nil
+}
+
+type rotatingWriter struct {
+	lk     sync.Mutex
+	ss     StreamStore
+	dir    string
+	opts   RotateOptions
+	buf    bytes.Buffer
+	start  time.Time
+	last   string
+	closed bool
+}
+
+func (rw *rotatingWriter) Write(p []byte) (int, error) {
+	rw.lk.Lock()
+	defer rw.lk.Unlock()
+
+	if rw.closed {
+		return 0, ErrClosed
+	}
+
+	if rw.buf.Len() != 0 {
+		full := rw.opts.MaxBytes > 0 && int64(rw.buf.Len()+len(p)) > rw.opts.MaxBytes
+		old := rw.opts.MaxAge > 0 && time.Since(rw.start) >= rw.opts.MaxAge
+		if full || old {
+			if err := rw.rotate(); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	if rw.buf.Len() == 0 {
+		rw.start = time.Now()
+	}
+	rw.buf.Write(p)
+
+	if rw.opts.MaxBytes > 0 && int64(rw.buf.Len()) >= rw.opts.MaxBytes {
+		if err := rw.rotate(); err != nil {
+			return len(p), err
+		}
+	}
+	return len(p), nil
+}
+
+// rotate writes out the current segment, if there is one.
+func (rw *rotatingWriter) rotate() error {
+	if rw.buf.Len() == 0 {
+		return nil
+	}
+
+	name := filepath.Join(rw.dir, rw.start.UTC().Format("20060102T150405.000000000Z")+rw.opts.Suffix)
+	for name <= rw.last {
+		// keep names unique and ordered, even with a coarse clock.
+		rw.start = rw.start.Add(time.Nanosecond)
+		name = filepath.Join(rw.dir, rw.start.UTC().Format("20060102T150405.000000000Z")+rw.opts.Suffix)
+	}
+
+	w, err := rw.ss.CreateWriteCloser(name)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(rw.buf.Bytes()); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	rw.last = name
+	rw.buf.Reset()
+	return nil
+}
+
+// Close finalizes the current segment.
+func (rw *rotatingWriter) Close() error {
+	rw.lk.Lock()
+	defer rw.lk.Unlock()
+
+	if rw.closed {
+		return nil
+	}
+	if err := rw.rotate(); err != nil {
+		return err
+	}
+	rw.closed = true
+	return nil
+}
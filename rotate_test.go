@@ -0,0 +1,81 @@
+package straw_test
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+func readSegments(t *testing.T, ss straw.StreamStore, dir string) []string {
+	require := require.New(t)
+
+	fis, err := ss.Readdir(dir)
+	require.NoError(err)
+	var segments []string
+	for _, fi := range fis {
+		r, err := ss.OpenReadCloser(dir + "/" + fi.Name())
+		require.NoError(err)
+		data, err := ioutil.ReadAll(r)
+		require.NoError(err)
+		require.NoError(r.Close())
+		segments = append(segments, string(data))
+	}
+	return segments
+}
+
+func TestRotatingWriterMaxBytes(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, _ := straw.Open("mem://")
+	require.NoError(ss.Mkdir("/logs", 0755))
+
+	w, err := straw.NewRotatingWriter(ss, "/logs", straw.RotateOptions{MaxBytes: 10, Suffix: ".log"})
+	require.NoError(err)
+
+	for _, rec := range []string{"aaaa", "bbbb", "cccc", "dddd", "eeeeeeeeeeee", "ffff"} {
+		n, err := w.Write([]byte(rec))
+		require.NoError(err)
+		assert.Equal(len(rec), n)
+	}
+	assert.Equal([]string{"aaaabbbb", "ccccdddd", "eeeeeeeeeeee"}, readSegments(t, ss, "/logs"))
+
+	require.NoError(w.Close())
+	assert.Equal([]string{"aaaabbbb", "ccccdddd", "eeeeeeeeeeee", "ffff"}, readSegments(t, ss, "/logs"))
+
+	fis, err := ss.Readdir("/logs")
+	require.NoError(err)
+	for _, fi := range fis {
+		assert.True(strings.HasSuffix(fi.Name(), ".log"), fi.Name())
+	}
+
+	_, err = w.Write([]byte("g"))
+	assert.Error(err)
+}
+
+func TestRotatingWriterMaxAge(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, _ := straw.Open("mem://")
+	require.NoError(ss.Mkdir("/logs", 0755))
+
+	w, err := straw.NewRotatingWriter(ss, "/logs", straw.RotateOptions{MaxAge: 10 * time.Millisecond})
+	require.NoError(err)
+
+	_, err = w.Write([]byte("a"))
+	require.NoError(err)
+	_, err = w.Write([]byte("b"))
+	require.NoError(err)
+	time.Sleep(20 * time.Millisecond)
+	_, err = w.Write([]byte("c"))
+	require.NoError(err)
+	require.NoError(w.Close())
+
+	assert.Equal([]string{"ab", "c"}, readSegments(t, ss, "/logs"))
+}
@@ -0,0 +1,81 @@
+package s3
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/uw-labs/straw"
+)
+
+// The "b2" scheme opens a Backblaze B2 bucket through its S3 compatible API,
+// as in "b2://keyID:applicationKey@bucket/prefix/?region=us-west-004". The
+// region, which B2 shows alongside the bucket's endpoint, is required, and
+// selects the endpoint "https://s3.<region>.backblazeb2.com" unless the
+// endpoint query parameter overrides it. The application key ID and key are
+// taken from the userinfo of the URL, or from the B2_APPLICATION_KEY_ID and
+// B2_APPLICATION_KEY environment variables if the URL has none. Path style
+// addressing is always used. A path in the URL confines the store to that
+// prefix of the bucket, as NewSubStore does. Since B2 buckets are usually
+// filled by tools which don't create directory markers, dir_mode defaults to
+// "prefix". All the other query parameters of the s3 scheme are supported.
+const (
+	b2KeyIDEnv = "B2_APPLICATION_KEY_ID"
+	b2KeyEnv   = "B2_APPLICATION_KEY"
+)
+
+func init() {
+	straw.Register("b2", newB2StreamStore)
+}
+
+func newB2StreamStore(u *url.URL) (straw.StreamStore, error) {
+	if u.Host == "" {
+		return nil, fmt.Errorf("b2 URLs must provide a bucket")
+	}
+	q := u.Query()
+	region := q.Get(regionQueryParam)
+	if region == "" {
+		return nil, fmt.Errorf("b2 URLs must provide a %q query parameter, such as %q", regionQueryParam, "us-west-004")
+	}
+	endpoint := q.Get(endpointQueryParam)
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".backblazeb2.com"
+	}
+
+	keyID, key := os.Getenv(b2KeyIDEnv), os.Getenv(b2KeyEnv)
+	if u.User != nil {
+		keyID = u.User.Username()
+		key, _ = u.User.Password()
+	}
+	if keyID == "" || key == "" {
+		return nil, fmt.Errorf("b2 URLs must provide an application key ID and key as userinfo, or in %s and %s", b2KeyIDEnv, b2KeyEnv)
+	}
+
+	sess, err := session.NewSession(&aws.Config{
+		Credentials: credentials.NewStaticCredentials(keyID, key, ""),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if q.Get(dirModeQueryParam) == "" {
+		q.Set(dirModeQueryParam, dirModePrefix)
+	}
+	q.Set(endpointQueryParam, endpoint)
+	q.Set(forcePathStyleQueryParam, "true")
+	s3u := &url.URL{Scheme: "s3", Host: u.Host, RawQuery: q.Encode()}
+	ss, err := news3StreamStoreWithSession(sess, s3u)
+	if err != nil {
+		return nil, err
+	}
+	ss.scheme = "b2"
+
+	if prefix := path.Clean("/" + u.Path); prefix != "/" {
+		return straw.NewSubStore(ss, prefix), nil
+	}
+	return ss, nil
+}
@@ -0,0 +1,96 @@
+package s3
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+	"github.com/uw-labs/straw/internal/fakes3"
+)
+
+func TestB2(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	srv := fakes3.New(testBucket)
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	ss, err := straw.Open("b2://keyid:appkey@"+testBucket+"/data/?region=us-west-004", S3Endpoint(ts.URL))
+	require.NoError(err)
+	defer ss.Close()
+	assert.Equal("b2", straw.Scheme(ss))
+
+	require.NoError(straw.WriteFile(ss, "/f", []byte("hello"), 0644))
+	obj, ok := srv.Object(testBucket, "data/f")
+	require.True(ok)
+	assert.Equal("hello", string(obj.Data))
+	fis, err := ss.Readdir("/")
+	require.NoError(err)
+	require.Len(fis, 1)
+	assert.Equal("f", fis[0].Name())
+
+	reqs := srv.Requests()
+	require.NotEmpty(reqs)
+	auth := reqs[len(reqs)-1].Header.Get("Authorization")
+	assert.Contains(auth, "Credential=keyid/")
+	assert.Contains(auth, "/us-west-004/s3/")
+}
+
+func TestB2Endpoint(t *testing.T) {
+	require := require.New(t)
+
+	u, err := url.Parse("b2://keyid:appkey@" + testBucket + "?region=eu-central-003")
+	require.NoError(err)
+	ss, err := newB2StreamStore(u)
+	require.NoError(err)
+	svc := ss.(*s3StreamStore).s3
+	assert.Equal(t, "https://s3.eu-central-003.backblazeb2.com", svc.Endpoint)
+	assert.True(t, *svc.Config.S3ForcePathStyle)
+	assert.Equal(t, dirModePrefix, ss.(*s3StreamStore).dirMode)
+}
+
+func TestB2CredentialsFromEnvironment(t *testing.T) {
+	require := require.New(t)
+
+	for _, k := range []string{b2KeyIDEnv, b2KeyEnv} {
+		old, ok := os.LookupEnv(k)
+		if ok {
+			defer os.Setenv(k, old)
+		} else {
+			defer os.Unsetenv(k)
+		}
+	}
+	os.Unsetenv(b2KeyIDEnv)
+	os.Unsetenv(b2KeyEnv)
+
+	_, err := straw.Open("b2://" + testBucket + "?region=us-west-004")
+	require.Error(err)
+	assert.True(t, strings.Contains(err.Error(), b2KeyIDEnv), err.Error())
+
+	os.Setenv(b2KeyIDEnv, "envid")
+	os.Setenv(b2KeyEnv, "envkey")
+	ss, err := straw.Open("b2://" + testBucket + "?region=us-west-004")
+	require.NoError(err)
+	creds, err := ss.(*s3StreamStore).sess.Config.Credentials.Get()
+	require.NoError(err)
+	assert.Equal(t, "envid", creds.AccessKeyID)
+	assert.Equal(t, "envkey", creds.SecretAccessKey)
+}
+
+func TestB2InvalidURLs(t *testing.T) {
+	for _, u := range []string{
+		"b2://keyid:appkey@" + testBucket,
+		"b2://keyid:appkey@?region=us-west-004",
+		"b2://keyid@" + testBucket + "?region=us-west-004",
+		"b2://keyid:appkey@" + testBucket + "?region=us-west-004&dir_mode=bogus",
+	} {
+		_, err := straw.Open(u)
+		assert.Error(t, err, u)
+	}
+}
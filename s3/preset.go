@@ -0,0 +1,103 @@
+package s3
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/uw-labs/straw"
+)
+
+// provider configures the store for a known S3 compatible service, filling
+// in the endpoint, from the region where the service has one per region, and
+// the addressing style the service needs, as in
+// "s3://bucket/?provider=spaces&region=nyc3". The endpoint, region and
+// force_path_style query parameters override the values of the preset.
+const providerQueryParam = "provider"
+
+// compatiblePreset holds the settings for an S3 compatible service.
+type compatiblePreset struct {
+	// endpoint returns the endpoint for region, or "" if the service has no
+	// standard endpoint, so one must be given.
+	endpoint func(region string) string
+	// region is used if none is given. If it is "", a region is required.
+	region         string
+	forcePathStyle bool
+}
+
+var compatiblePresets = map[string]compatiblePreset{
+	// DigitalOcean Spaces, whose regions are data centres, such as "nyc3".
+	"spaces": {
+		endpoint: func(region string) string { return "https://" + region + ".digitaloceanspaces.com" },
+	},
+	"wasabi": {
+		endpoint: func(region string) string { return "https://s3." + region + ".wasabisys.com" },
+		region:   "us-east-1",
+	},
+	// Linode (Akamai) Object Storage, whose regions are cluster IDs, such
+	// as "us-east-1".
+	"linode": {
+		endpoint: func(region string) string { return "https://" + region + ".linodeobjects.com" },
+	},
+	// MinIO is self hosted, so the endpoint must be given.
+	"minio": {
+		endpoint:       func(string) string { return "" },
+		region:         "us-east-1",
+		forcePathStyle: true,
+	},
+}
+
+// compatibleProviders returns the names of the known providers, sorted.
+func compatibleProviders() []string {
+	names := make([]string, 0, len(compatiblePresets))
+	for name := range compatiblePresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CompatiblePreset returns an Option which configures the store for the S3
+// compatible service provider, as the provider query parameter does. The
+// supported providers are "spaces" (DigitalOcean Spaces), "wasabi", "linode"
+// and "minio". Spaces and Linode require the region to be given, such as with
+// S3Region, and MinIO requires the endpoint to be given with S3Endpoint.
+func CompatiblePreset(provider string) (straw.Option, error) {
+	if _, ok := compatiblePresets[provider]; !ok {
+		return nil, unknownProviderError(provider)
+	}
+	return straw.QueryOption(providerQueryParam, provider), nil
+}
+
+func unknownProviderError(provider string) error {
+	return fmt.Errorf("unknown provider %q; supported providers: %s", provider, strings.Join(compatibleProviders(), ", "))
+}
+
+// applyProvider sets the query parameters in q which the preset of the named
+// provider determines, unless they are already set.
+func applyProvider(q url.Values, provider string) error {
+	preset, ok := compatiblePresets[provider]
+	if !ok {
+		return fmt.Errorf("invalid %q query parameter: %w", providerQueryParam, unknownProviderError(provider))
+	}
+	region := q.Get(regionQueryParam)
+	if region == "" {
+		if preset.region == "" {
+			return fmt.Errorf("provider %q requires a %q query parameter", provider, regionQueryParam)
+		}
+		region = preset.region
+		q.Set(regionQueryParam, region)
+	}
+	if q.Get(endpointQueryParam) == "" {
+		endpoint := preset.endpoint(region)
+		if endpoint == "" {
+			return fmt.Errorf("provider %q requires an %q query parameter", provider, endpointQueryParam)
+		}
+		q.Set(endpointQueryParam, endpoint)
+	}
+	if preset.forcePathStyle && q.Get(forcePathStyleQueryParam) == "" {
+		q.Set(forcePathStyleQueryParam, "true")
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package s3
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+func TestCompatiblePreset(t *testing.T) {
+	sess, err := session.NewSession(&aws.Config{
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+	})
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		query, endpoint, region string
+		pathStyle               bool
+	}{
+		{"provider=spaces&region=nyc3", "https://nyc3.digitaloceanspaces.com", "nyc3", false},
+		{"provider=wasabi", "https://s3.us-east-1.wasabisys.com", "us-east-1", false},
+		{"provider=wasabi&region=eu-central-1", "https://s3.eu-central-1.wasabisys.com", "eu-central-1", false},
+		{"provider=linode&region=us-east-1", "https://us-east-1.linodeobjects.com", "us-east-1", false},
+		{"provider=minio&endpoint=http://localhost:9000", "http://localhost:9000", "us-east-1", true},
+		{"provider=spaces&region=nyc3&endpoint=https://cdn.example.com", "https://cdn.example.com", "nyc3", false},
+	} {
+		t.Run(tc.query, func(t *testing.T) {
+			ss, err := news3StreamStoreWithSession(sess, &url.URL{Scheme: "s3", Host: testBucket, RawQuery: tc.query})
+			require.NoError(t, err)
+			assert.Equal(t, tc.endpoint, ss.s3.Endpoint)
+			assert.Equal(t, tc.region, aws.StringValue(ss.s3.Config.Region))
+			assert.Equal(t, tc.pathStyle, aws.BoolValue(ss.s3.Config.S3ForcePathStyle))
+		})
+	}
+}
+
+func TestCompatiblePresetErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := CompatiblePreset("acme")
+	assert.EqualError(err, `unknown provider "acme"; supported providers: linode, minio, spaces, wasabi`)
+
+	_, err = straw.Open("s3://" + testBucket + "/?provider=acme")
+	assert.EqualError(err, `invalid "provider" query parameter: unknown provider "acme"; supported providers: linode, minio, spaces, wasabi`)
+	_, err = straw.Open("s3://" + testBucket + "/?provider=spaces")
+	assert.EqualError(err, `provider "spaces" requires a "region" query parameter`)
+	_, err = straw.Open("s3://" + testBucket + "/?provider=minio")
+	assert.EqualError(err, `provider "minio" requires an "endpoint" query parameter`)
+
+	opt, err := CompatiblePreset("spaces")
+	require.NoError(t, err)
+	ss, err := straw.Open("s3://"+testBucket+"/", opt, S3Region("ams3"))
+	require.NoError(t, err)
+	assert.Equal("https://ams3.digitaloceanspaces.com", ss.(*s3StreamStore).s3.Endpoint)
+}
@@ -0,0 +1,82 @@
+package s3
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/defaults"
+	"github.com/uw-labs/straw"
+)
+
+// profile selects a named profile of the shared AWS config and credentials
+// files, such as ~/.aws/config, from which the credentials and region are
+// loaded, in place of the default profile. The region, endpoint and
+// credential query parameters override the values of the profile.
+const profileQueryParam = "profile"
+
+// S3Profile returns an Option which loads the credentials and region from the
+// named profile of the shared AWS config and credentials files, as the
+// AWS_PROFILE environment variable does. Opening the store fails if neither
+// file defines the profile.
+func S3Profile(name string) straw.Option {
+	return straw.QueryOption(profileQueryParam, name)
+}
+
+// checkProfile returns an error if the named profile is defined in neither
+// the shared credentials file nor the shared config file. The SDK would
+// otherwise silently fall back to the default credential chain.
+func checkProfile(profile string) error {
+	files := []struct {
+		name     string
+		sections []string
+	}{
+		{envOr("AWS_SHARED_CREDENTIALS_FILE", defaults.SharedCredentialsFilename()), []string{profile}},
+		{envOr("AWS_CONFIG_FILE", defaults.SharedConfigFilename()), []string{"profile " + profile, profile}},
+	}
+	for _, f := range files {
+		ok, err := hasSection(f.name, f.sections)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+	}
+	return fmt.Errorf("profile %q is not defined in %s or %s", profile, files[0].name, files[1].name)
+}
+
+// hasSection reports whether the ini file name has a section with one of the
+// given names. A missing file has no sections.
+func hasSection(name string, sections []string) (bool, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "[") || !strings.HasSuffix(line, "]") {
+			continue
+		}
+		section := strings.Join(strings.Fields(line[1:len(line)-1]), " ")
+		for _, s := range sections {
+			if section == s {
+				return true, nil
+			}
+		}
+	}
+	return false, scanner.Err()
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
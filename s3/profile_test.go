@@ -0,0 +1,105 @@
+package s3
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+// setEnv sets the environment variables in env, unsetting those which are
+// empty, and returns a function restoring their previous values.
+func setEnv(env map[string]string) func() {
+	var restore []func()
+	for k, v := range env {
+		k := k
+		if old, ok := os.LookupEnv(k); ok {
+			restore = append(restore, func() { os.Setenv(k, old) })
+		} else {
+			restore = append(restore, func() { os.Unsetenv(k) })
+		}
+		if v == "" {
+			os.Unsetenv(k)
+		} else {
+			os.Setenv(k, v)
+		}
+	}
+	return func() {
+		for _, f := range restore {
+			f()
+		}
+	}
+}
+
+func TestProfile(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "straw_s3_profile_test_")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+	config := filepath.Join(dir, "config")
+	credentials := filepath.Join(dir, "credentials")
+	require.NoError(ioutil.WriteFile(config, []byte(strings.Join([]string{
+		"[default]",
+		"region = us-east-1",
+		"[profile dev]",
+		"region = eu-west-2",
+		"aws_access_key_id = devconfigid",
+		"aws_secret_access_key = devconfigsecret",
+	}, "\n")), 0600))
+	require.NoError(ioutil.WriteFile(credentials, []byte(strings.Join([]string{
+		"[default]",
+		"aws_access_key_id = defaultid",
+		"aws_secret_access_key = defaultsecret",
+		"[ staging ]",
+		"aws_access_key_id = stagingid",
+		"aws_secret_access_key = stagingsecret",
+	}, "\n")), 0600))
+	defer setEnv(map[string]string{
+		"AWS_CONFIG_FILE":             config,
+		"AWS_SHARED_CREDENTIALS_FILE": credentials,
+		"AWS_PROFILE":                 "",
+		"AWS_ACCESS_KEY_ID":           "",
+		"AWS_SECRET_ACCESS_KEY":       "",
+		"AWS_SESSION_TOKEN":           "",
+		"AWS_REGION":                  "",
+	})()
+
+	// the region is given, so that it is not looked up.
+	for _, tc := range []struct {
+		rawurl string
+		id     string
+		region string
+	}{
+		{"s3://" + testBucket + "/?region=eu-west-1", "defaultid", "eu-west-1"},
+		{"s3://" + testBucket + "/?region=eu-west-1&profile=staging", "stagingid", "eu-west-1"},
+		{"s3://" + testBucket + "/?endpoint=http://localhost:9000&profile=dev", "devconfigid", "eu-west-2"},
+		{"s3://" + testBucket + "/?endpoint=http://localhost:9000&profile=dev&region=us-west-1", "devconfigid", "us-west-1"},
+		{"s3://" + testBucket + "/?region=eu-west-1&profile=dev&access_key=id&secret_key=secret", "id", "eu-west-1"},
+	} {
+		ss, err := straw.Open(tc.rawurl)
+		require.NoError(err, tc.rawurl)
+		s3ss := ss.(*s3StreamStore)
+		creds, err := s3ss.s3.Config.Credentials.Get()
+		require.NoError(err, tc.rawurl)
+		assert.Equal(tc.id, creds.AccessKeyID, tc.rawurl)
+		assert.Equal(tc.region, aws.StringValue(s3ss.s3.Config.Region), tc.rawurl)
+	}
+
+	ss, err := straw.Open("s3://"+testBucket+"/?region=eu-west-1", S3Profile("staging"))
+	require.NoError(err)
+	creds, err := ss.(*s3StreamStore).s3.Config.Credentials.Get()
+	require.NoError(err)
+	assert.Equal("stagingid", creds.AccessKeyID)
+
+	_, err = straw.Open("s3://" + testBucket + "/?region=eu-west-1&profile=missing")
+	require.Error(err)
+	assert.Contains(err.Error(), `invalid "profile" query parameter: profile "missing" is not defined in `)
+}
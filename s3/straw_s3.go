@@ -1,60 +1,368 @@
 package s3
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/uw-labs/straw"
+	"github.com/uw-labs/straw/internal/bytesize"
 )
 
 var _ straw.StreamStore = &s3StreamStore{}
+var _ straw.Taggable = &s3StreamStore{}
+var _ straw.ExistsChecker = &s3StreamStore{}
+var _ straw.AppendStore = &s3StreamStore{}
+var _ straw.ContextStreamStore = &s3StreamStore{}
+var _ straw.PresignStore = &s3StreamStore{}
+var _ straw.PrefixLister = &s3StreamStore{}
+var _ straw.ReaddirEacher = &s3StreamStore{}
+var _ straw.Hasher = &s3StreamStore{}
+
+// The permission bits set with Chmod are stored in octal as user metadata
+// under this key. Objects without it have mode 0644.
+const modeMetadataKey = "mode"
+
+// small_object_threshold is the size, such as "1MB", below which objects are
+// fetched whole on first read and served from memory thereafter.
+const smallObjectThresholdQueryParam = "small_object_threshold"
+
+// dir_mode selects how directories are represented. In "marker" mode (the
+// default) Mkdir creates a zero-byte object whose key ends in a slash, as the
+// AWS S3 console does for a new folder, and in "prefix" mode no objects are
+// created, and directories exist only by virtue of the objects beneath them,
+// as with tools which have no notion of directories. In prefix mode, Mkdir
+// therefore has no visible effect until a file is created within the
+// directory, and a directory vanishes once it no longer contains any files.
+// Whatever the mode, Stat and Readdir report a directory wherever there is a
+// marker or an object beneath it, so a bucket written in one mode, or by other
+// tools, reads the same in the other. Where other tools have made an object
+// and a directory of the same name, Stat reports the directory.
+const dirModeQueryParam = "dir_mode"
+
+const (
+	dirModeMarker = "marker"
+	dirModePrefix = "prefix"
+)
+
+// endpoint overrides the URL of the S3 API, such as for MinIO or another S3
+// compatible service, and force_path_style, if true, addresses the bucket in
+// the path of each request rather than in the host name.
+const (
+	endpointQueryParam       = "endpoint"
+	forcePathStyleQueryParam = "force_path_style"
+)
+
+// part_size is the size, such as "16MiB", of the parts that large files are
+// uploaded in, which must be at least 5MiB, and upload_concurrency is the
+// number of parts of each file uploaded in parallel. By default the values
+// of the s3manager package are used.
+const (
+	partSizeQueryParam          = "part_size"
+	uploadConcurrencyQueryParam = "upload_concurrency"
+)
+
+// sse is the server side encryption applied to written objects, either
+// "AES256" or "aws:kms", and kmskeyid is the ID of the KMS key used with
+// "aws:kms". Without kmskeyid, the AWS managed key is used.
+const (
+	sseQueryParam      = "sse"
+	kmsKeyIDQueryParam = "kmskeyid"
+)
+
+// storageclass is the S3 storage class, such as "STANDARD_IA", that files are
+// written with. By default the storage class of the bucket is used.
+const storageClassQueryParam = "storageclass"
+
+// region is the AWS region of the bucket. If it is not given, and no custom
+// endpoint is in use, the region is looked up when the store is opened, so
+// that buckets outside the region configured for the process are reachable.
+const regionQueryParam = "region"
+
+// access_key, secret_key and session_token are credentials to sign requests
+// with, in place of those found by the default AWS credential chain. The
+// session token is only needed for temporary credentials. The access key and
+// secret key may instead be given as the userinfo of the URL, as in
+// "s3://AKID:SECRET@bucket/".
+const (
+	accessKeyQueryParam    = "access_key"
+	secretKeyQueryParam    = "secret_key"
+	sessionTokenQueryParam = "session_token"
+)
+
+// regionLookupTimeout bounds the request made to find the region of a bucket.
+const regionLookupTimeout = 10 * time.Second
+
+// bucketRegion returns the region of bucket, starting the search in hint. It
+// is a variable so that tests can avoid contacting AWS.
+var bucketRegion = func(ctx context.Context, sess *session.Session, bucket, hint string) (string, error) {
+	return s3manager.GetBucketRegion(ctx, sess, bucket, hint)
+}
 
 func init() {
 	straw.Register("s3", func(u *url.URL) (straw.StreamStore, error) {
-		return news3StreamStore(u.Host, u.Query().Get("sse"))
+		return news3StreamStore(u)
 	})
 }
 
-func news3StreamStore(bucket string, sseType string) (*s3StreamStore, error) {
-	sess, err := session.NewSessionWithOptions(
-		session.Options{
-			SharedConfigState: session.SharedConfigEnable,
-		},
-	)
+// S3Endpoint returns an Option which sends requests to the S3 compatible
+// service at url instead of AWS.
+func S3Endpoint(url string) straw.Option {
+	return straw.QueryOption(endpointQueryParam, url)
+}
+
+// S3Region returns an Option which sets the region of the bucket, rather than
+// looking it up when the store is opened.
+func S3Region(region string) straw.Option {
+	return straw.QueryOption(regionQueryParam, region)
+}
+
+// S3Credentials returns an Option which signs requests with the given
+// credentials, rather than those found by the default AWS credential chain,
+// such as from the environment or an instance role. sessionToken is only
+// needed for temporary credentials, and may be empty.
+func S3Credentials(accessKey, secretKey, sessionToken string) straw.Option {
+	return func(u *url.URL) {
+		straw.QueryOption(accessKeyQueryParam, accessKey)(u)
+		straw.QueryOption(secretKeyQueryParam, secretKey)(u)
+		if sessionToken != "" {
+			straw.QueryOption(sessionTokenQueryParam, sessionToken)(u)
+		}
+	}
+}
+
+// S3PartSize returns an Option which uploads large files in parts of the given
+// number of bytes, which must be at least 5MiB. Each concurrent part is held
+// in memory while it is uploaded.
+func S3PartSize(bytes int64) straw.Option {
+	return straw.QueryOption(partSizeQueryParam, strconv.FormatInt(bytes, 10))
+}
+
+// S3UploadConcurrency returns an Option which uploads up to n parts of each
+// large file in parallel.
+func S3UploadConcurrency(n int) straw.Option {
+	return straw.QueryOption(uploadConcurrencyQueryParam, strconv.Itoa(n))
+}
+
+// S3ServerSideEncoding returns an Option which encrypts written objects server
+// side with sse, either "AES256" or "aws:kms".
+func S3ServerSideEncoding(sse string) straw.Option {
+	return straw.QueryOption(sseQueryParam, sse)
+}
+
+// S3KMSKeyID returns an Option which encrypts written objects with the KMS key
+// keyID, which may be a key ID, key ARN or alias ARN. It implies
+// S3ServerSideEncoding("aws:kms"), and is an error with any other encoding.
+func S3KMSKeyID(keyID string) straw.Option {
+	return straw.QueryOption(kmsKeyIDQueryParam, keyID)
+}
+
+// S3StorageClass returns an Option which writes files with the given storage
+// class, which must be one of the values of the s3.StorageClass enum, such as
+// "STANDARD_IA" or "GLACIER".
+func S3StorageClass(class string) straw.Option {
+	return straw.QueryOption(storageClassQueryParam, class)
+}
+
+// S3DirMode returns an Option which selects how directories are represented,
+// either "marker" or "prefix", as described for the dir_mode query
+// parameter.
+func S3DirMode(mode string) straw.Option {
+	return straw.QueryOption(dirModeQueryParam, mode)
+}
+
+// S3ForcePathStyle returns an Option which, if force is true, addresses the
+// bucket in the path of each request rather than in the host name, as MinIO
+// requires.
+func S3ForcePathStyle(force bool) straw.Option {
+	return straw.QueryOption(forcePathStyleQueryParam, strconv.FormatBool(force))
+}
+
+func news3StreamStore(u *url.URL) (*s3StreamStore, error) {
+	opts := session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	}
+	if profile := u.Query().Get(profileQueryParam); profile != "" {
+		if err := checkProfile(profile); err != nil {
+			return nil, fmt.Errorf("invalid %q query parameter: %w", profileQueryParam, err)
+		}
+		opts.Profile = profile
+	}
+	sess, err := session.NewSessionWithOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return news3StreamStoreWithSession(sess, u)
+}
+
+func news3StreamStoreWithSession(sess *session.Session, u *url.URL) (*s3StreamStore, error) {
+	q := u.Query()
+
+	if provider := q.Get(providerQueryParam); provider != "" {
+		if err := applyProvider(q, provider); err != nil {
+			return nil, err
+		}
+	}
+
+	dirMode := q.Get(dirModeQueryParam)
+	switch dirMode {
+	case "":
+		dirMode = dirModeMarker
+	case dirModeMarker, dirModePrefix:
+	default:
+		return nil, fmt.Errorf("invalid %q query parameter: unknown mode %q", dirModeQueryParam, dirMode)
+	}
+
+	var threshold int64
+	if t := q.Get(smallObjectThresholdQueryParam); t != "" {
+		var err error
+		if threshold, err = bytesize.Parse(t); err != nil {
+			return nil, fmt.Errorf("invalid %q query parameter: %w", smallObjectThresholdQueryParam, err)
+		}
+	}
+
+	storageClass := q.Get(storageClassQueryParam)
+	if storageClass != "" && !validStorageClass(storageClass) {
+		return nil, fmt.Errorf("invalid %q query parameter: unknown storage class %q", storageClassQueryParam, storageClass)
+	}
+
+	var partSize int64
+	if p := q.Get(partSizeQueryParam); p != "" {
+		var err error
+		if partSize, err = bytesize.Parse(p); err != nil {
+			return nil, fmt.Errorf("invalid %q query parameter: %w", partSizeQueryParam, err)
+		}
+		if partSize < s3manager.MinUploadPartSize {
+			return nil, fmt.Errorf("invalid %q query parameter: %d bytes is less than the minimum of %d", partSizeQueryParam, partSize, s3manager.MinUploadPartSize)
+		}
+	}
+
+	var concurrency int
+	if c := q.Get(uploadConcurrencyQueryParam); c != "" {
+		var err error
+		if concurrency, err = strconv.Atoi(c); err != nil || concurrency < 1 {
+			return nil, fmt.Errorf("invalid %q query parameter: %q is not a positive integer", uploadConcurrencyQueryParam, c)
+		}
+	}
+
+	sseType := q.Get(sseQueryParam)
+	kmsKeyID := q.Get(kmsKeyIDQueryParam)
+	if kmsKeyID != "" {
+		switch sseType {
+		case "":
+			sseType = s3.ServerSideEncryptionAwsKms
+		case s3.ServerSideEncryptionAwsKms:
+		default:
+			return nil, fmt.Errorf("invalid %q query parameter: a KMS key requires %q encryption, not %q", kmsKeyIDQueryParam, s3.ServerSideEncryptionAwsKms, sseType)
+		}
+	}
+
+	cfg := aws.NewConfig()
+	creds, err := staticCredentials(u, q)
 	if err != nil {
 		return nil, err
 	}
+	if creds != nil {
+		cfg.Credentials = creds
+	}
+	if f := q.Get(forcePathStyleQueryParam); f != "" {
+		force, err := strconv.ParseBool(f)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %q query parameter: %w", forcePathStyleQueryParam, err)
+		}
+		cfg.S3ForcePathStyle = aws.Bool(force)
+	}
+	endpoint := q.Get(endpointQueryParam)
+	if endpoint != "" {
+		cfg.Endpoint = aws.String(endpoint)
+	}
+	if region := q.Get(regionQueryParam); region != "" {
+		cfg.Region = aws.String(region)
+	} else if endpoint == "" && aws.StringValue(sess.Config.Endpoint) == "" {
+		// if the lookup fails, the configured region is used, and any
+		// mismatch is reported by the first request.
+		hint := aws.StringValue(sess.Config.Region)
+		if hint == "" {
+			hint = "us-east-1"
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), regionLookupTimeout)
+		if region, err := bucketRegion(ctx, sess, u.Host, hint); err == nil {
+			cfg.Region = aws.String(region)
+		}
+		cancel()
+	}
 
-	svc := s3.New(sess)
+	svc := s3.New(sess, cfg)
 
 	ss := &s3StreamStore{
-		sess:    sess,
-		s3:      svc,
-		bucket:  bucket,
-		sseType: sseType,
+		scheme:               "s3",
+		sess:                 sess,
+		s3:                   svc,
+		bucket:               u.Host,
+		sseType:              sseType,
+		kmsKeyID:             kmsKeyID,
+		storageClass:         storageClass,
+		partSize:             partSize,
+		uploadConcurrency:    concurrency,
+		smallObjectThreshold: threshold,
+		dirMode:              dirMode,
 	}
 
 	return ss, nil
 }
 
+// staticCredentials returns the credentials given in the query parameters
+// q, or the userinfo, of u, or nil if there are none. The secret key is
+// never included in errors.
+func staticCredentials(u *url.URL, q url.Values) (*credentials.Credentials, error) {
+	accessKey, secretKey := q.Get(accessKeyQueryParam), q.Get(secretKeyQueryParam)
+	if accessKey == "" && secretKey == "" && u.User != nil {
+		accessKey = u.User.Username()
+		secretKey, _ = u.User.Password()
+	}
+	sessionToken := q.Get(sessionTokenQueryParam)
+	if accessKey == "" && secretKey == "" && sessionToken == "" {
+		return nil, nil
+	}
+	if accessKey == "" || secretKey == "" {
+		return nil, fmt.Errorf("s3 credentials must include both an access key and a secret key, with the %q and %q query parameters or as userinfo", accessKeyQueryParam, secretKeyQueryParam)
+	}
+	return credentials.NewStaticCredentials(accessKey, secretKey, sessionToken), nil
+}
+
 type s3StreamStore struct {
-	sess    *session.Session
-	s3      *s3.S3
-	bucket  string
-	sseType string
+	scheme               string
+	sess                 *session.Session
+	s3                   *s3.S3
+	bucket               string
+	sseType              string
+	kmsKeyID             string
+	storageClass         string
+	partSize             int64
+	uploadConcurrency    int
+	smallObjectThreshold int64
+	dirMode              string
 }
 
 func (fs *s3StreamStore) Close() error {
@@ -62,13 +370,29 @@ func (fs *s3StreamStore) Close() error {
 	return nil
 }
 
+func (fs *s3StreamStore) Scheme() string {
+	return fs.scheme
+}
+
+// Lstat is Stat, as S3 has no symbolic links.
 func (fs *s3StreamStore) Lstat(name string) (os.FileInfo, error) {
-	// S3 does not support symlinks
-	return fs.Stat(name)
+	return fs.LstatContext(context.Background(), name)
+}
+
+func (fs *s3StreamStore) LstatContext(ctx context.Context, name string) (os.FileInfo, error) {
+	return fs.StatContext(ctx, name)
 }
 
 func (fs *s3StreamStore) Stat(name string) (os.FileInfo, error) {
-	name = fs.noSlashPrefix(name)
+	return fs.StatContext(context.Background(), name)
+}
+
+func (fs *s3StreamStore) StatContext(ctx context.Context, name string) (os.FileInfo, error) {
+	path, err := fs.cleanPath(name)
+	if err != nil {
+		return nil, err
+	}
+	name = fs.noSlashPrefix(path)
 	name = fs.noSlashSuffix(name)
 
 	if name == "" {
@@ -85,7 +409,7 @@ func (fs *s3StreamStore) Stat(name string) (os.FileInfo, error) {
 		Prefix:    aws.String(name),
 		Delimiter: aws.String("/"),
 	}
-	out, err := fs.s3.ListObjectsV2(input)
+	out, err := fs.s3.ListObjectsV2WithContext(ctx, input)
 	if err != nil {
 		return nil, err
 	}
@@ -95,9 +419,11 @@ func (fs *s3StreamStore) Stat(name string) (os.FileInfo, error) {
 	for _, cont := range out.Contents {
 		if *cont.Key == name {
 			matching = append(matching, &s3StatResult{
-				name:    fs.lastElem(*cont.Key),
-				modTime: *cont.LastModified,
-				size:    *cont.Size,
+				name:         fs.lastElem(*cont.Key),
+				modTime:      *cont.LastModified,
+				size:         *cont.Size,
+				etag:         aws.StringValue(cont.ETag),
+				storageClass: aws.StringValue(cont.StorageClass),
 			})
 		}
 	}
@@ -113,14 +439,154 @@ func (fs *s3StreamStore) Stat(name string) (os.FileInfo, error) {
 		}
 	}
 
-	switch len(matching) {
-	case 0:
-		return nil, os.ErrNotExist
-	case 2:
-		panic("bug?")
-	default:
-		return matching[0], nil
+	if (len(matching) == 0 || !matching[len(matching)-1].IsDir()) && aws.BoolValue(out.IsTruncated) {
+		// keys such as name+"-1" sort before name+"/", so may have filled
+		// the listing before the directory was reached.
+		out, err := fs.s3.ListObjectsV2WithContext(ctx, &s3.ListObjectsV2Input{
+			Bucket:  aws.String(fs.bucket),
+			MaxKeys: aws.Int64(1),
+			Prefix:  aws.String(name + "/"),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(out.Contents) != 0 {
+			matching = append(matching, &s3StatResult{
+				name:  fs.lastElem(name),
+				isDir: true,
+				size:  4096,
+			})
+		}
+	}
+
+	if len(matching) == 0 {
+		return nil, &os.PathError{Op: "stat", Path: path, Err: os.ErrNotExist}
+	}
+	// other tools can create both an object and a directory of the same
+	// name, in which case the directory, listed last, is reported.
+	result := matching[len(matching)-1].(*s3StatResult)
+	if !result.isDir {
+		result.lazyHead = fs.lazyHead(ctx, name)
+	}
+	return result, nil
+}
+
+// lazyHead returns a function which fetches the attributes of the object key
+// with ctx the first time it is called, for the os.FileInfo of a file, as
+// listings do not include its metadata and content type.
+func (fs *s3StreamStore) lazyHead(ctx context.Context, key string) func() *s3.HeadObjectOutput {
+	var once sync.Once
+	var head *s3.HeadObjectOutput
+	return func() *s3.HeadObjectOutput {
+		once.Do(func() {
+			out, err := fs.s3.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+				Bucket: aws.String(fs.bucket),
+				Key:    aws.String(key),
+			})
+			if err == nil {
+				head = out
+			}
+		})
+		return head
+	}
+}
+
+// IsDir determines whether name is a directory with a single delimited
+// listing, only falling back to a HEAD request when nothing exists under the
+// prefix.
+func (fs *s3StreamStore) IsDir(name string) (bool, error) {
+	path, err := fs.cleanPath(name)
+	if err != nil {
+		return false, err
+	}
+	name = fs.noSlashPrefix(path)
+	name = fs.noSlashSuffix(name)
+
+	if name == "" {
+		return true, nil
+	}
+
+	input := &s3.ListObjectsV2Input{
+		Bucket:    aws.String(fs.bucket),
+		MaxKeys:   aws.Int64(1),
+		Prefix:    aws.String(name + "/"),
+		Delimiter: aws.String("/"),
+	}
+	out, err := fs.s3.ListObjectsV2(input)
+	if err != nil {
+		return false, err
+	}
+	if len(out.Contents) != 0 || len(out.CommonPrefixes) != 0 {
+		return true, nil
+	}
+
+	_, err = fs.s3.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(name),
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return false, &os.PathError{Op: "stat", Path: path, Err: os.ErrNotExist}
+		}
+		return false, err
+	}
+	return false, nil
+}
+
+// Exists checks for an object with a HEAD request, which does not fetch its
+// content, only falling back to a listing of at most one key to check for a
+// directory when there is no object.
+func (fs *s3StreamStore) Exists(name string) (bool, error) {
+	path, err := fs.cleanPath(name)
+	if err != nil {
+		return false, err
+	}
+	name = fs.noSlashPrefix(path)
+	name = fs.noSlashSuffix(name)
+
+	if name == "" {
+		return true, nil
+	}
+
+	_, err = fs.s3.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(name),
+	})
+	if err == nil {
+		return true, nil
+	}
+	if !isNotFound(err) {
+		return false, err
+	}
+
+	out, err := fs.s3.ListObjectsV2(&s3.ListObjectsV2Input{
+		Bucket:  aws.String(fs.bucket),
+		MaxKeys: aws.Int64(1),
+		Prefix:  aws.String(name + "/"),
+	})
+	if err != nil {
+		return false, err
+	}
+	return len(out.Contents) != 0, nil
+}
+
+// isNotImplemented reports whether err is an s3 error indicating that the
+// service does not support the request, as some S3 compatible services
+// reject those for features they lack.
+func isNotImplemented(err error) bool {
+	e, ok := err.(awserr.RequestFailure)
+	return ok && (e.StatusCode() == http.StatusNotImplemented || e.Code() == "NotImplemented")
+}
+
+// isNotFound reports whether err is an s3 error indicating a missing object.
+func isNotFound(err error) bool {
+	if e, ok := err.(awserr.RequestFailure); ok && e.StatusCode() == 404 {
+		return true
+	}
+	if e, ok := err.(awserr.Error); ok {
+		return e.Code() == s3.ErrCodeNoSuchKey || e.Code() == "NotFound"
 	}
+	return false
 }
 
 type s3StatResult struct {
@@ -128,6 +594,15 @@ type s3StatResult struct {
 	isDir   bool
 	modTime time.Time
 	size    int64
+
+	// lazyHead fetches the attributes of files the first time Mode or Sys
+	// needs them, returning nil if they could not be fetched. It is nil for
+	// directories.
+	lazyHead func() *s3.HeadObjectOutput
+
+	// etag and storageClass are those reported by the listing.
+	etag         string
+	storageClass string
 }
 
 func (sr *s3StatResult) Name() string {
@@ -146,24 +621,90 @@ func (sr *s3StatResult) ModTime() time.Time {
 	return sr.modTime
 }
 
+// Mode reports the permission bits set with Chmod for files, which the first
+// call to Mode or Sys fetches with a HEAD request, using the context of the
+// Stat or Readdir which returned the file. It reports 0644 if the request
+// fails.
 func (sr *s3StatResult) Mode() os.FileMode {
 	if sr.IsDir() {
 		return os.ModeDir | 0755
 	}
+	if head := sr.lazyHead(); head != nil {
+		return objectMode(head.Metadata)
+	}
 	return 0644
 }
 
+// Sys returns a *straw.ObjectInfo for files, or nil for directories. The
+// first call to Mode or Sys fetches the attributes which listings do not
+// include with a HEAD request. If it fails, only the ETag and storage class
+// of the listing are reported.
 func (sr *s3StatResult) Sys() interface{} {
-	return nil
+	if sr.isDir {
+		return nil
+	}
+	info := &straw.ObjectInfo{
+		StorageClass: sr.storageClass,
+		ETag:         strings.Trim(sr.etag, `"`),
+	}
+	head := sr.lazyHead()
+	if head == nil {
+		return info
+	}
+	info.ContentType = aws.StringValue(head.ContentType)
+	info.ETag = strings.Trim(aws.StringValue(head.ETag), `"`)
+	if head.Expires != nil {
+		if t, err := http.ParseTime(*head.Expires); err == nil {
+			info.Expires = t
+		}
+	}
+	if info.StorageClass == "" {
+		// HEAD omits the storage class of STANDARD objects.
+		info.StorageClass = aws.StringValue(head.StorageClass)
+		if info.StorageClass == "" {
+			info.StorageClass = s3.StorageClassStandard
+		}
+	}
+	for k, v := range head.Metadata {
+		if k = strings.ToLower(k); k != modeMetadataKey {
+			if info.Metadata == nil {
+				info.Metadata = make(map[string]string)
+			}
+			info.Metadata[k] = aws.StringValue(v)
+		}
+	}
+	return info
+}
+
+// objectMode returns the permission bits recorded by Chmod in the metadata of
+// an object.
+func objectMode(metadata map[string]*string) os.FileMode {
+	for k, v := range metadata {
+		// the SDK canonicalises the case of metadata keys it returns.
+		if strings.EqualFold(k, modeMetadataKey) && v != nil {
+			if m, err := strconv.ParseUint(*v, 8, 32); err == nil {
+				return os.FileMode(m).Perm()
+			}
+		}
+	}
+	return 0644
 }
 
 func (fs *s3StreamStore) OpenReadCloser(name string) (straw.StrawReader, error) {
-	fi, err := fs.Stat(name)
+	return fs.OpenReadCloserContext(context.Background(), name)
+}
+
+func (fs *s3StreamStore) OpenReadCloserContext(ctx context.Context, name string) (straw.StrawReader, error) {
+	name, err := fs.cleanPath(name)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := fs.StatContext(ctx, name)
 	if err != nil {
 		return nil, err
 	}
 	if fi.IsDir() {
-		return nil, fmt.Errorf("%s is a directory", name)
+		return nil, &os.PathError{Op: "open", Path: name, Err: straw.ErrIsDirectory}
 	}
 
 	input := s3.GetObjectInput{
@@ -171,68 +712,109 @@ func (fs *s3StreamStore) OpenReadCloser(name string) (straw.StrawReader, error)
 		Key:    aws.String(name),
 	}
 
-	out, err := fs.s3.GetObject(&input)
+	if fi.Size() < fs.smallObjectThreshold {
+		return &s3SmallReader{ctx: ctx, s3: fs.s3, input: input}, nil
+	}
+
+	out, err := fs.s3.GetObjectWithContext(ctx, &input)
 	if err != nil {
 		if e, ok := err.(awserr.Error); ok {
 			if e.Code() == s3.ErrCodeNoSuchKey {
-				return nil, os.ErrNotExist
+				return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
 			}
 		}
 		return nil, err
 	}
-	return &s3Reader{out.Body, fs.s3, input, -1}, nil
+	return &s3Reader{rc: out.Body, ctx: ctx, s3: fs.s3, input: input, seek: -1, size: aws.Int64Value(out.ContentLength)}, nil
 }
 
 type s3Reader struct {
 	rc io.ReadCloser
 
+	ctx   context.Context
 	s3    *s3.S3
 	input s3.GetObjectInput
 
 	// -1 means don't seek
 	seek int64
+	// pos is the offset of the next Read, and size that of the object.
+	pos  int64
+	size int64
 }
 
-func (r *s3Reader) Seek(start int64, whence int) (int64, error) {
+// Seek sets the offset of the next Read, which opens a new range request
+// from there if it differs from the current offset.
+func (r *s3Reader) Seek(offset int64, whence int) (int64, error) {
 	switch whence {
 	case io.SeekStart:
-		if start < 0 {
-			return 0, errors.New("invalid seek position")
-		}
-		r.seek = start
-		return start, nil
+	case io.SeekCurrent:
+		offset += r.pos
+	case io.SeekEnd:
+		offset += r.size
 	default:
-		return 0, fmt.Errorf("seek %d not currently supported in s3 backend", whence)
+		return 0, fmt.Errorf("invalid whence %d", whence)
 	}
+	if offset < 0 {
+		return 0, errors.New("invalid seek position")
+	}
+	if offset != r.pos {
+		r.seek = offset
+	}
+	r.pos = offset
+	return offset, nil
 }
 
 func (r *s3Reader) Read(buf []byte) (int, error) {
-	if r.seek != -1 {
-		// we have a deferred seek to do before we read.
-		err := r.rc.Close()
-		if err != nil {
-			return 0, err
+	if err := r.doSeek(); err != nil {
+		return 0, err
+	}
+	n, err := r.rc.Read(buf)
+	r.pos += int64(n)
+	return n, err
+}
+
+// WriteTo streams the rest of the object body to w, letting w read from it
+// directly if it implements io.ReaderFrom.
+func (r *s3Reader) WriteTo(w io.Writer) (int64, error) {
+	if err := r.doSeek(); err != nil {
+		if err == io.EOF {
+			return 0, nil
 		}
-		r.rc = eofRdr
+		return 0, err
+	}
+	n, err := io.Copy(w, r.rc)
+	r.pos += n
+	return n, err
+}
 
-		r.input.Range = aws.String(fmt.Sprintf("bytes=%d-", r.seek))
-		out, err := r.s3.GetObject(&r.input)
-		if err != nil {
-			if e, ok := err.(awserr.Error); ok {
-				if e.Code() == s3.ErrCodeNoSuchKey {
-					return 0, os.ErrNotExist
-				}
-				if e.Code() == "InvalidRange" {
-					return 0, io.EOF
-				}
+// doSeek performs any deferred seek, opening a new range request from the
+// seek offset, and returns io.EOF if that is beyond the end of the object.
+func (r *s3Reader) doSeek() error {
+	if r.seek == -1 {
+		return nil
+	}
+	err := r.rc.Close()
+	if err != nil {
+		return err
+	}
+	r.rc = eofRdr
+
+	r.input.Range = aws.String(fmt.Sprintf("bytes=%d-", r.seek))
+	out, err := r.s3.GetObjectWithContext(r.ctx, &r.input)
+	if err != nil {
+		if e, ok := err.(awserr.Error); ok {
+			if e.Code() == s3.ErrCodeNoSuchKey {
+				return &os.PathError{Op: "read", Path: aws.StringValue(r.input.Key), Err: os.ErrNotExist}
+			}
+			if e.Code() == "InvalidRange" {
+				return io.EOF
 			}
-			return 0, err
 		}
-		r.rc = out.Body
-		r.seek = -1
+		return err
 	}
-
-	return r.rc.Read(buf)
+	r.rc = out.Body
+	r.seek = -1
+	return nil
 }
 
 func (r *s3Reader) Close() error {
@@ -242,11 +824,11 @@ func (r *s3Reader) Close() error {
 func (r *s3Reader) ReadAt(buf []byte, start int64) (int, error) {
 	end := int64(len(buf)) + start - 1
 	r.input.Range = aws.String(fmt.Sprintf("bytes=%d-%d", start, end))
-	out, err := r.s3.GetObject(&r.input)
+	out, err := r.s3.GetObjectWithContext(r.ctx, &r.input)
 	if err != nil {
 		if e, ok := err.(awserr.Error); ok {
 			if e.Code() == s3.ErrCodeNoSuchKey {
-				return 0, os.ErrNotExist
+				return 0, &os.PathError{Op: "read", Path: aws.StringValue(r.input.Key), Err: os.ErrNotExist}
 			}
 		}
 		return 0, err
@@ -271,19 +853,94 @@ func (r *s3Reader) ReadAt(buf []byte, start int64) (int, error) {
 	}
 }
 
+// s3SmallReader fetches the whole object on first use, and serves all reads
+// from memory after that.
+type s3SmallReader struct {
+	ctx   context.Context
+	s3    *s3.S3
+	input s3.GetObjectInput
+
+	r *bytes.Reader
+}
+
+func (r *s3SmallReader) load() error {
+	if r.r != nil {
+		return nil
+	}
+	out, err := r.s3.GetObjectWithContext(r.ctx, &r.input)
+	if err != nil {
+		if isNotFound(err) {
+			return &os.PathError{Op: "read", Path: aws.StringValue(r.input.Key), Err: os.ErrNotExist}
+		}
+		return err
+	}
+	defer out.Body.Close()
+	data, err := ioutil.ReadAll(out.Body)
+	if err != nil {
+		return err
+	}
+	r.r = bytes.NewReader(data)
+	return nil
+}
+
+func (r *s3SmallReader) Read(buf []byte) (int, error) {
+	if err := r.load(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(buf)
+}
+
+func (r *s3SmallReader) WriteTo(w io.Writer) (int64, error) {
+	if err := r.load(); err != nil {
+		return 0, err
+	}
+	return r.r.WriteTo(w)
+}
+
+func (r *s3SmallReader) ReadAt(buf []byte, start int64) (int, error) {
+	if err := r.load(); err != nil {
+		return 0, err
+	}
+	return r.r.ReadAt(buf, start)
+}
+
+func (r *s3SmallReader) Seek(offset int64, whence int) (int64, error) {
+	if err := r.load(); err != nil {
+		return 0, err
+	}
+	return r.r.Seek(offset, whence)
+}
+
+func (r *s3SmallReader) Close() error {
+	return nil
+}
+
 func (fs *s3StreamStore) Mkdir(name string, mode os.FileMode) error {
+	return fs.MkdirContext(context.Background(), name, mode)
+}
+
+func (fs *s3StreamStore) MkdirContext(ctx context.Context, name string, mode os.FileMode) error {
+	name, err := fs.cleanPath(name)
+	if err != nil {
+		return err
+	}
 	if !strings.HasSuffix(name, "/") {
 		name = name + "/"
 	}
 
-	if err := fs.checkParentDir(name); err != nil {
+	if err := fs.checkParentDir(ctx, name); err != nil {
 		return err
 	}
 
-	if _, err := fs.Stat(name); err == nil {
+	if _, err := fs.StatContext(ctx, name); err == nil {
 		return fmt.Errorf("%s : file exists", name)
 	}
 
+	if fs.dirMode == dirModePrefix {
+		// nothing to create, the directory is implied by its contents.
+		return nil
+	}
+
 	input := &s3.PutObjectInput{
 		Bucket:      aws.String(fs.bucket),
 		Key:         aws.String(name),
@@ -293,40 +950,52 @@ func (fs *s3StreamStore) Mkdir(name string, mode os.FileMode) error {
 	if fs.sseType != "" {
 		input.ServerSideEncryption = aws.String(fs.sseType)
 	}
+	if fs.kmsKeyID != "" {
+		input.SSEKMSKeyId = aws.String(fs.kmsKeyID)
+	}
 
-	_, err := fs.s3.PutObject(input)
+	_, err = fs.s3.PutObjectWithContext(ctx, input)
 	return err
 }
 
-func (fs *s3StreamStore) checkParentDir(child string) error {
-	child = fs.noSlashPrefix(child)
-	child = fs.noSlashSuffix(child)
-
-	d, _ := filepath.Split(child)
+func (fs *s3StreamStore) checkParentDir(ctx context.Context, child string) error {
+	d, _ := filepath.Split(fs.noSlashSuffix(fs.noSlashPrefix(child)))
 	if d != "" {
-		fi, err := fs.Stat(d)
+		fi, err := fs.StatContext(ctx, d)
 		if err != nil {
+			if fs.dirMode == dirModePrefix && os.IsNotExist(err) {
+				// directories are implied by the objects within them.
+				return nil
+			}
 			return err
 		}
 		if !fi.IsDir() {
-			return fmt.Errorf("%s not a directory", d)
+			return &os.PathError{Op: "open", Path: child, Err: straw.ErrNotDirectory}
 		}
 	}
 	return nil
 }
 
 func (fs *s3StreamStore) Remove(name string) error {
-	fi, err := fs.Stat(name)
+	return fs.RemoveContext(context.Background(), name)
+}
+
+func (fs *s3StreamStore) RemoveContext(ctx context.Context, name string) error {
+	name, err := fs.cleanPath(name)
+	if err != nil {
+		return err
+	}
+	fi, err := fs.StatContext(ctx, name)
 	if err != nil {
 		return err
 	}
 	if fi.IsDir() {
-		files, err := fs.Readdir(name)
+		files, err := fs.ReaddirContext(ctx, name)
 		if err != nil {
 			return err
 		}
 		if len(files) != 0 {
-			return fmt.Errorf("%s : directory not empty", name)
+			return &os.PathError{Op: "remove", Path: name, Err: straw.ErrDirectoryNotEmpty}
 		}
 	}
 
@@ -334,49 +1003,814 @@ func (fs *s3StreamStore) Remove(name string) error {
 		Bucket: aws.String(fs.bucket),
 		Key:    aws.String(fs.fixTrailingSlash(name, fi.IsDir())),
 	}
-	_, err = fs.s3.DeleteObject(input)
+	_, err = fs.s3.DeleteObjectWithContext(ctx, input)
 	return err
 }
 
+// RemoveAll removes name and every object under it, deleting the objects
+// found by each page of the listing with a single batch request.
+func (fs *s3StreamStore) RemoveAll(name string) error {
+	name, err := fs.cleanPath(name)
+	if err != nil {
+		return err
+	}
+	key := fs.noSlashSuffix(fs.noSlashPrefix(name))
+
+	var prefix string
+	if key != "" {
+		prefix = key + "/"
+	}
+
+	var delErr error
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(fs.bucket),
+		Prefix: aws.String(prefix),
+	}
+	err = fs.s3.ListObjectsV2Pages(input, func(out *s3.ListObjectsV2Output, last bool) bool {
+		var keys []string
+		for _, content := range out.Contents {
+			keys = append(keys, *content.Key)
+		}
+		delErr = fs.deleteObjects(keys)
+		return delErr == nil
+	})
+	if err != nil {
+		return err
+	}
+	if delErr != nil {
+		return delErr
+	}
+
+	if key == "" {
+		return nil
+	}
+	// name may also be a file.
+	return fs.deleteObjects([]string{key})
+}
+
+// deleteObjects deletes up to 1000 objects with a single request.
+func (fs *s3StreamStore) deleteObjects(keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	var objects []*s3.ObjectIdentifier
+	for _, key := range keys {
+		objects = append(objects, &s3.ObjectIdentifier{Key: aws.String(key)})
+	}
+	out, err := fs.s3.DeleteObjects(&s3.DeleteObjectsInput{
+		Bucket: aws.String(fs.bucket),
+		Delete: &s3.Delete{
+			Objects: objects,
+			Quiet:   aws.Bool(true),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if len(out.Errors) != 0 {
+		e := out.Errors[0]
+		return fmt.Errorf("deleting %s : %s", aws.StringValue(e.Key), aws.StringValue(e.Message))
+	}
+	return nil
+}
+
 func (fs *s3StreamStore) CreateWriteCloser(name string) (straw.StrawWriter, error) {
+	return fs.createWriteCloser(context.Background(), name, straw.WriteOptions{})
+}
+
+func (fs *s3StreamStore) CreateWriteCloserWithOptions(name string, opts straw.WriteOptions) (straw.StrawWriter, error) {
+	return fs.createWriteCloser(context.Background(), name, opts)
+}
+
+// CreateWriteCloserContext creates the named object. If ctx is cancelled
+// before the writer is closed, the upload fails, and any multipart upload
+// begun is aborted.
+func (fs *s3StreamStore) CreateWriteCloserContext(ctx context.Context, name string) (straw.StrawWriter, error) {
+	return fs.createWriteCloser(ctx, name, straw.WriteOptions{})
+}
+
+func (fs *s3StreamStore) createWriteCloser(ctx context.Context, name string, opts straw.WriteOptions) (straw.StrawWriter, error) {
+	name, err := fs.cleanPath(name)
+	if err != nil {
+		return nil, err
+	}
 	name = fs.noSlashPrefix(name)
 
-	if err := fs.checkParentDir(name); err != nil {
+	if err := fs.checkParentDir(ctx, name); err != nil {
 		return nil, err
 	}
 
-	if fi, err := fs.Stat(name); err == nil && fi.IsDir() {
-		return nil, fmt.Errorf("%s is a directory", name)
+	if fi, err := fs.StatContext(ctx, name); err == nil && fi.IsDir() {
+		return nil, &os.PathError{Op: "open", Path: name, Err: straw.ErrIsDirectory}
 	}
 
-	uploader := s3manager.NewUploaderWithClient(fs.s3)
+	// the parts of a failed multipart upload are left for us to abort, as the
+	// uploader would abort using ctx, which may be why the upload failed.
+	uploader := s3manager.NewUploaderWithClient(fs.s3, func(u *s3manager.Uploader) {
+		u.LeavePartsOnError = true
+		if fs.partSize != 0 {
+			u.PartSize = fs.partSize
+		}
+		if fs.uploadConcurrency != 0 {
+			u.Concurrency = fs.uploadConcurrency
+		}
+		if opts.HasPreconditions() {
+			u.RequestOptions = append(u.RequestOptions, preconditionHeaders(opts))
+		}
+	})
 
-	pr, pw := io.Pipe()
+	body := newUploadBody()
 
 	input := &s3manager.UploadInput{
-		Body:   pr,
-		Key:    aws.String(name),
-		Bucket: aws.String(fs.bucket),
+		Body:         body,
+		Key:          aws.String(name),
+		Bucket:       aws.String(fs.bucket),
+		StorageClass: fs.storageClassOr(nil),
 	}
 
 	if fs.sseType != "" {
 		input.ServerSideEncryption = aws.String(fs.sseType)
 	}
+	if fs.kmsKeyID != "" {
+		input.SSEKMSKeyId = aws.String(fs.kmsKeyID)
+	}
+	if !opts.Expires.IsZero() {
+		input.Expires = aws.Time(opts.Expires)
+	}
+	if contentType := straw.ContentTypeFor(name, opts); contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+	if len(opts.Metadata) != 0 {
+		input.Metadata = aws.StringMap(opts.Metadata)
+	}
+	if opts.ContentMD5 != nil {
+		// the uploader only sends this with uploads of a single part.
+		input.ContentMD5 = aws.String(base64.StdEncoding.EncodeToString(opts.ContentMD5))
+	}
 
 	errCh := make(chan error, 1)
+	done := make(chan struct{})
 
 	go func() {
-		_, err := uploader.Upload(input)
+		select {
+		case <-ctx.Done():
+			// unblock the uploader, and any write in progress.
+			body.CloseWithError(ctx.Err())
+		case <-done:
+		}
+	}()
+
+	go func() {
+		_, err := uploader.UploadWithContext(ctx, input)
+		close(done)
+		if err != nil {
+			// fail further writes rather than leaving them blocked.
+			body.CloseWithError(err)
+			if mf, ok := err.(s3manager.MultiUploadFailure); ok {
+				fs.s3.AbortMultipartUpload(&s3.AbortMultipartUploadInput{
+					Bucket:   input.Bucket,
+					Key:      input.Key,
+					UploadId: aws.String(mf.UploadID()),
+				})
+			}
+			if isPreconditionFailed(err, opts) {
+				err = &os.PathError{Op: "close", Path: name, Err: straw.ErrPreconditionFailed}
+			}
+		}
 		errCh <- err
 	}()
 
 	ul := &s3uploader{
-		errCh,
-		pw,
+		ctx:   ctx,
+		errCh: errCh,
+		wc:    body,
 	}
 	return ul, nil
 }
 
+// preconditionHeaders returns a request.Option which adds the conditional
+// write headers for opts to the requests which create the object, being
+// PutObject for uploads of a single part and CompleteMultipartUpload
+// otherwise.
+func preconditionHeaders(opts straw.WriteOptions) request.Option {
+	return func(r *request.Request) {
+		switch r.Operation.Name {
+		case "PutObject", "CompleteMultipartUpload":
+		default:
+			return
+		}
+		if opts.IfNotExists {
+			r.HTTPRequest.Header.Set("If-None-Match", "*")
+		}
+		if opts.IfMatch != "" {
+			r.HTTPRequest.Header.Set("If-Match", `"`+strings.Trim(opts.IfMatch, `"`)+`"`)
+		}
+	}
+}
+
+// isPreconditionFailed reports whether err, from an upload with opts, means
+// that the preconditions of opts were not met. S3 reports an If-Match for a
+// missing object as not found rather than as a failed precondition.
+func isPreconditionFailed(err error, opts straw.WriteOptions) bool {
+	for err != nil {
+		if e, ok := err.(awserr.RequestFailure); ok {
+			switch e.StatusCode() {
+			case http.StatusPreconditionFailed:
+				return true
+			case http.StatusNotFound:
+				return opts.IfMatch != ""
+			}
+			return false
+		}
+		e, ok := err.(awserr.Error)
+		if !ok {
+			return false
+		}
+		// the failures of multipart uploads wrap that of the request.
+		err = e.OrigErr()
+	}
+	return false
+}
+
+// OpenFile opens the named file as described by straw.OpenFileBuffered, as
+// objects cannot be modified in place. os.O_CREATE|os.O_EXCL creates the
+// object with a conditional PutObject, so is atomic.
+func (fs *s3StreamStore) OpenFile(name string, flag int, perm os.FileMode) (straw.StrawReadWriteCloser, error) {
+	return straw.OpenFileBuffered(fs, name, flag, fs.createExclusive)
+}
+
+// createExclusive creates the named object, empty, failing if it already
+// exists.
+func (fs *s3StreamStore) createExclusive(name string) error {
+	name, err := fs.cleanPath(name)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	if err := fs.checkParentDir(ctx, fs.noSlashPrefix(name)); err != nil {
+		return err
+	}
+	if fi, err := fs.StatContext(ctx, name); err == nil && fi.IsDir() {
+		return &os.PathError{Op: "open", Path: name, Err: straw.ErrIsDirectory}
+	}
+
+	input := &s3.PutObjectInput{
+		Bucket:       aws.String(fs.bucket),
+		Key:          aws.String(fs.noSlashPrefix(name)),
+		Body:         bytes.NewReader(nil),
+		StorageClass: fs.storageClassOr(nil),
+	}
+	if fs.sseType != "" {
+		input.ServerSideEncryption = aws.String(fs.sseType)
+	}
+	if fs.kmsKeyID != "" {
+		input.SSEKMSKeyId = aws.String(fs.kmsKeyID)
+	}
+	req, _ := fs.s3.PutObjectRequest(input)
+	req.HTTPRequest.Header.Set("If-None-Match", "*")
+	if err := req.Send(); err != nil {
+		if e, ok := err.(awserr.RequestFailure); ok && e.StatusCode() == http.StatusPreconditionFailed {
+			return &os.PathError{Op: "open", Path: name, Err: os.ErrExist}
+		}
+		return err
+	}
+	return nil
+}
+
+// minPartSize is the minimum size of every part of a multipart upload but
+// the last. AppendWriteCloser only copies existing objects at least this
+// large server side, reading and uploading smaller ones again, and Flush
+// only uploads a part once this much is buffered.
+var minPartSize int64 = s3manager.MinUploadPartSize
+
+// AppendWriteCloser returns a writer which appends to the named object,
+// creating it as CreateWriteCloser does if it does not exist. S3 cannot
+// append to objects, so on Close the object is replaced by the result of a
+// multipart upload, the first parts of which are copied server side from
+// the existing object with UploadPartCopy, followed by the data written.
+//
+// The existing content does not pass through this process, but each append
+// is charged as a rewrite of the whole object, and takes longer the larger
+// the object is. As every part but the last must be at least 5MiB, an
+// existing object smaller than that is downloaded and uploaded again ahead
+// of the data written. Closing the writer without writing anything leaves
+// the object as it was. Appends are not atomic: of concurrent appends to the
+// same object, only the last to close takes effect.
+func (fs *s3StreamStore) AppendWriteCloser(name string) (straw.StrawWriter, error) {
+	ctx := context.Background()
+	name, err := fs.cleanPath(name)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := fs.StatContext(ctx, name)
+	if os.IsNotExist(err) {
+		return fs.createWriteCloser(ctx, name, straw.WriteOptions{})
+	}
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		return nil, &os.PathError{Op: "open", Path: name, Err: straw.ErrIsDirectory}
+	}
+	key := fs.noSlashPrefix(name)
+
+	w := &s3Appender{fs: fs, ctx: ctx, key: key, partSize: fs.partSize}
+	if w.partSize == 0 {
+		w.partSize = s3manager.DefaultUploadPartSize
+	}
+	if fi.Size() < minPartSize {
+		r, err := fs.OpenReadCloserContext(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		w.buf, err = ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// an empty, rather than nil, metadata keeps the storage class.
+	w.uploadID, err = fs.createUploadFrom(ctx, key, key, map[string]*string{})
+	if err != nil {
+		return nil, err
+	}
+	if fi.Size() >= minPartSize {
+		copySource := (&url.URL{Path: fs.bucket + "/" + key}).EscapedPath()
+		w.parts, err = fs.copyPartsInto(ctx, copySource, key, w.uploadID, fi.Size(), minPartSize)
+		if err != nil {
+			fs.abortUpload(key, w.uploadID)
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// s3Appender writes the parts of a multipart upload which replaces an
+// object with itself and the data written, as described by
+// AppendWriteCloser. Data is buffered until there is a part's worth.
+type s3Appender struct {
+	fs       *s3StreamStore
+	ctx      context.Context
+	key      string
+	uploadID *string
+	partSize int64
+	parts    []*s3.CompletedPart
+	buf      []byte
+	written  bool
+	closed   bool
+	// err is the error which aborted the upload, if any.
+	err error
+}
+
+func (w *s3Appender) Write(data []byte) (int, error) {
+	if w.closed {
+		return 0, straw.ErrClosed
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	w.buf = append(w.buf, data...)
+	w.written = true
+	for int64(len(w.buf)) >= w.partSize {
+		if err := w.uploadPart(w.buf[:w.partSize]); err != nil {
+			return 0, err
+		}
+		w.buf = append(w.buf[:0], w.buf[w.partSize:]...)
+	}
+	return len(data), nil
+}
+
+// uploadPart uploads data as the next part, aborting the upload if it
+// fails.
+func (w *s3Appender) uploadPart(data []byte) error {
+	num := aws.Int64(int64(len(w.parts) + 1))
+	out, err := w.fs.s3.UploadPartWithContext(w.ctx, &s3.UploadPartInput{
+		Bucket:     aws.String(w.fs.bucket),
+		Key:        aws.String(w.key),
+		UploadId:   w.uploadID,
+		PartNumber: num,
+		Body:       bytes.NewReader(data),
+	})
+	if err != nil {
+		w.fs.abortUpload(w.key, w.uploadID)
+		w.err = err
+		return err
+	}
+	w.parts = append(w.parts, &s3.CompletedPart{ETag: out.ETag, PartNumber: num})
+	return nil
+}
+
+// Flush uploads the data buffered as a part of the upload, if there is at
+// least the 5MiB S3 allows for a part which is not the last, so that it is
+// no longer held in memory. Otherwise it does nothing.
+func (w *s3Appender) Flush() error {
+	if w.closed {
+		return straw.ErrClosed
+	}
+	if w.err != nil {
+		return w.err
+	}
+	if int64(len(w.buf)) < minPartSize {
+		return nil
+	}
+	if err := w.uploadPart(w.buf); err != nil {
+		return err
+	}
+	w.buf = w.buf[:0]
+	return nil
+}
+
+func (w *s3Appender) Close() error {
+	if w.closed {
+		return straw.ErrClosed
+	}
+	w.closed = true
+	if w.err != nil {
+		return w.err
+	}
+	if !w.written {
+		w.fs.abortUpload(w.key, w.uploadID)
+		return nil
+	}
+	if len(w.buf) != 0 {
+		if err := w.uploadPart(w.buf); err != nil {
+			return err
+		}
+	}
+	_, err := w.fs.s3.CompleteMultipartUploadWithContext(w.ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(w.fs.bucket),
+		Key:             aws.String(w.key),
+		UploadId:        w.uploadID,
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: w.parts},
+	})
+	if err != nil {
+		w.fs.abortUpload(w.key, w.uploadID)
+	}
+	return err
+}
+
+// Copy copies src to dst server side. The content type and user metadata of
+// src are preserved. Objects too large for a single CopyObject request are
+// copied in parts.
+func (fs *s3StreamStore) Copy(src, dst string) error {
+	return fs.CopyContext(context.Background(), src, dst)
+}
+
+func (fs *s3StreamStore) CopyContext(ctx context.Context, src, dst string) error {
+	src, err := fs.cleanPath(src)
+	if err != nil {
+		return err
+	}
+	dst, err = fs.cleanPath(dst)
+	if err != nil {
+		return err
+	}
+
+	fi, err := fs.StatContext(ctx, src)
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		return &os.PathError{Op: "copy", Path: src, Err: straw.ErrIsDirectory}
+	}
+
+	if err := fs.checkParentDir(ctx, dst); err != nil {
+		return err
+	}
+	if fi, err := fs.StatContext(ctx, dst); err == nil && fi.IsDir() {
+		return &os.PathError{Op: "copy", Path: dst, Err: straw.ErrIsDirectory}
+	}
+	dst = fs.noSlashPrefix(dst)
+
+	copySource := (&url.URL{Path: fs.bucket + "/" + fs.noSlashPrefix(src)}).EscapedPath()
+	if fi.Size() > maxCopyObjectSize {
+		return fs.copyParts(ctx, copySource, fs.noSlashPrefix(src), dst, fi.Size(), nil)
+	}
+
+	input := &s3.CopyObjectInput{
+		Bucket:       aws.String(fs.bucket),
+		Key:          aws.String(dst),
+		CopySource:   aws.String(copySource),
+		StorageClass: fs.storageClassOr(nil),
+	}
+	if fs.sseType != "" {
+		input.ServerSideEncryption = aws.String(fs.sseType)
+	}
+	if fs.kmsKeyID != "" {
+		input.SSEKMSKeyId = aws.String(fs.kmsKeyID)
+	}
+	if _, err := fs.s3.CopyObjectWithContext(ctx, input); err != nil {
+		if isNotFound(err) {
+			return &os.PathError{Op: "copy", Path: src, Err: os.ErrNotExist}
+		}
+		return err
+	}
+	return nil
+}
+
+// maxCopyObjectSize is the size of the largest object which can be copied
+// with CopyObject, above which copyParts is used.
+var maxCopyObjectSize int64 = 5 << 30
+
+// copyPartSize is the size of each part copied by copyParts.
+var copyPartSize int64 = 512 << 20
+
+// copyParts copies the object with the given key to dst using a multipart
+// upload, each part of which is copied from copySource server side.
+func (fs *s3StreamStore) copyParts(ctx context.Context, copySource, key, dst string, size int64, metadata map[string]*string) error {
+	uploadID, err := fs.createUploadFrom(ctx, key, dst, metadata)
+	if err != nil {
+		return err
+	}
+	parts, err := fs.copyPartsInto(ctx, copySource, dst, uploadID, size, 0)
+	if err != nil {
+		// not bound to ctx, which may be why the copy failed.
+		fs.abortUpload(dst, uploadID)
+		return err
+	}
+	_, err = fs.s3.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(fs.bucket),
+		Key:             aws.String(dst),
+		UploadId:        uploadID,
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: parts},
+	})
+	if err != nil {
+		fs.abortUpload(dst, uploadID)
+		return err
+	}
+	return nil
+}
+
+// createUploadFrom begins a multipart upload to dst, returning its ID.
+// Unlike CopyObject, a multipart upload does not carry over the attributes
+// or tags of the source, so those of the object with the given key are
+// read and set explicitly, with any entries in metadata replacing those of
+// the source. A nil metadata gives dst the storage class of the store, and
+// otherwise dst is taken to be a rewrite of the source, so keeps its class.
+func (fs *s3StreamStore) createUploadFrom(ctx context.Context, key, dst string, metadata map[string]*string) (*string, error) {
+	head, err := fs.s3.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return nil, &os.PathError{Op: "copy", Path: "/" + key, Err: os.ErrNotExist}
+		}
+		return nil, err
+	}
+
+	create := &s3.CreateMultipartUploadInput{
+		Bucket:             aws.String(fs.bucket),
+		Key:                aws.String(dst),
+		CacheControl:       head.CacheControl,
+		ContentDisposition: head.ContentDisposition,
+		ContentEncoding:    head.ContentEncoding,
+		ContentType:        head.ContentType,
+		Metadata:           mergeMetadata(head.Metadata, metadata),
+	}
+	if fs.sseType != "" {
+		create.ServerSideEncryption = aws.String(fs.sseType)
+	}
+	if fs.kmsKeyID != "" {
+		create.SSEKMSKeyId = aws.String(fs.kmsKeyID)
+	}
+	tagging, err := fs.s3.GetObjectTaggingWithContext(ctx, &s3.GetObjectTaggingInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(key),
+	})
+	switch {
+	case err == nil:
+		if len(tagging.TagSet) != 0 {
+			tags := url.Values{}
+			for _, tag := range tagging.TagSet {
+				tags.Set(aws.StringValue(tag.Key), aws.StringValue(tag.Value))
+			}
+			create.Tagging = aws.String(tags.Encode())
+		}
+	case isNotImplemented(err):
+		// some S3 compatible services have no tags to copy.
+	default:
+		return nil, err
+	}
+	if metadata == nil {
+		create.StorageClass = fs.storageClassOr(nil)
+	} else {
+		create.StorageClass = fs.storageClassOr(head.StorageClass)
+	}
+	upload, err := fs.s3.CreateMultipartUploadWithContext(ctx, create)
+	if err != nil {
+		return nil, err
+	}
+	return upload.UploadId, nil
+}
+
+// copyPartsInto copies the first size bytes of copySource into the upload
+// to dst, as parts of copyPartSize numbered from 1. A last part which would
+// be smaller than minLast is merged into the one before it.
+func (fs *s3StreamStore) copyPartsInto(ctx context.Context, copySource, dst string, uploadID *string, size, minLast int64) ([]*s3.CompletedPart, error) {
+	var parts []*s3.CompletedPart
+	for off, num := int64(0), int64(1); off < size; num++ {
+		end := off + copyPartSize - 1
+		if end >= size || size-end-1 < minLast {
+			end = size - 1
+		}
+		out, err := fs.s3.UploadPartCopyWithContext(ctx, &s3.UploadPartCopyInput{
+			Bucket:          aws.String(fs.bucket),
+			Key:             aws.String(dst),
+			UploadId:        uploadID,
+			PartNumber:      aws.Int64(num),
+			CopySource:      aws.String(copySource),
+			CopySourceRange: aws.String(fmt.Sprintf("bytes=%d-%d", off, end)),
+		})
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, &s3.CompletedPart{ETag: out.CopyPartResult.ETag, PartNumber: aws.Int64(num)})
+		off = end + 1
+	}
+	return parts, nil
+}
+
+// abortUpload aborts the multipart upload to key. It is not bound to a
+// context, as the one the upload was made with may be why it failed.
+func (fs *s3StreamStore) abortUpload(key string, uploadID *string) {
+	fs.s3.AbortMultipartUpload(&s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(fs.bucket),
+		Key:      aws.String(key),
+		UploadId: uploadID,
+	})
+}
+
+// mergeMetadata returns the user metadata of an object with the entries of
+// update added, replacing existing entries regardless of the case of their
+// keys.
+func mergeMetadata(metadata, update map[string]*string) map[string]*string {
+	if len(update) == 0 {
+		return metadata
+	}
+	merged := make(map[string]*string)
+	for k, v := range metadata {
+		merged[strings.ToLower(k)] = v
+	}
+	for k, v := range update {
+		merged[strings.ToLower(k)] = v
+	}
+	return merged
+}
+
+// Chmod records the permission bits of mode in the metadata of the named
+// object, from which they are reported by Stat. As S3 metadata cannot be
+// changed in place, the object is copied over itself, preserving its other
+// attributes. Directories are not supported.
+func (fs *s3StreamStore) Chmod(name string, mode os.FileMode) error {
+	return fs.ChmodContext(context.Background(), name, mode)
+}
+
+// Truncate reads the whole object and rewrites it at the new size, as
+// described by straw.TruncateBuffered.
+func (fs *s3StreamStore) Truncate(name string, size int64) error {
+	return straw.TruncateBuffered(fs, name, size)
+}
+
+func (fs *s3StreamStore) ChmodContext(ctx context.Context, name string, mode os.FileMode) error {
+	name, err := fs.cleanPath(name)
+	if err != nil {
+		return err
+	}
+	fi, err := fs.StatContext(ctx, name)
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		return straw.ErrNotSupported
+	}
+	key := fs.noSlashPrefix(name)
+	metadata := map[string]*string{
+		modeMetadataKey: aws.String(strconv.FormatUint(uint64(mode.Perm()), 8)),
+	}
+
+	copySource := (&url.URL{Path: fs.bucket + "/" + key}).EscapedPath()
+	if fi.Size() > maxCopyObjectSize {
+		return fs.copyParts(ctx, copySource, key, key, fi.Size(), metadata)
+	}
+
+	head, err := fs.s3.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return &os.PathError{Op: "chmod", Path: name, Err: os.ErrNotExist}
+		}
+		return err
+	}
+	input := &s3.CopyObjectInput{
+		Bucket:             aws.String(fs.bucket),
+		Key:                aws.String(key),
+		CopySource:         aws.String(copySource),
+		MetadataDirective:  aws.String(s3.MetadataDirectiveReplace),
+		CacheControl:       head.CacheControl,
+		ContentDisposition: head.ContentDisposition,
+		ContentEncoding:    head.ContentEncoding,
+		ContentLanguage:    head.ContentLanguage,
+		ContentType:        head.ContentType,
+		Metadata:           mergeMetadata(head.Metadata, metadata),
+		StorageClass:       fs.storageClassOr(head.StorageClass),
+	}
+	if head.Expires != nil {
+		if t, err := http.ParseTime(*head.Expires); err == nil {
+			input.Expires = aws.Time(t)
+		}
+	}
+	if fs.sseType != "" {
+		input.ServerSideEncryption = aws.String(fs.sseType)
+	}
+	if fs.kmsKeyID != "" {
+		input.SSEKMSKeyId = aws.String(fs.kmsKeyID)
+	}
+	if _, err := fs.s3.CopyObjectWithContext(ctx, input); err != nil {
+		if isNotFound(err) {
+			return &os.PathError{Op: "chmod", Path: name, Err: os.ErrNotExist}
+		}
+		return err
+	}
+	return nil
+}
+
+// ContentHash returns the ETag of the named object, without its quotes. The
+// ETag is the hex encoded MD5 of the content for objects uploaded in a single
+// part without SSE-KMS, but otherwise is only an opaque value which changes
+// with the content.
+func (fs *s3StreamStore) ContentHash(name string) (string, error) {
+	name, err := fs.cleanPath(name)
+	if err != nil {
+		return "", err
+	}
+	head, err := fs.s3.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(fs.noSlashPrefix(name)),
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return "", &os.PathError{Op: "contenthash", Path: name, Err: os.ErrNotExist}
+		}
+		return "", err
+	}
+	return strings.Trim(aws.StringValue(head.ETag), `"`), nil
+}
+
+// PresignGetURL returns a presigned URL for downloading the named object,
+// signed with the credentials and region of the store.
+func (fs *s3StreamStore) PresignGetURL(name string, expiry time.Duration) (string, error) {
+	name, err := fs.cleanPath(name)
+	if err != nil {
+		return "", err
+	}
+	req, _ := fs.s3.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(fs.noSlashPrefix(name)),
+	})
+	return req.Presign(expiry)
+}
+
+// PresignPutURL returns a presigned URL for uploading the named object,
+// signed with the credentials and region of the store. The server side
+// encryption and storage class options of the store are not applied to
+// objects uploaded with it, as the uploader would have to send them as
+// signed headers.
+func (fs *s3StreamStore) PresignPutURL(name string, expiry time.Duration) (string, error) {
+	name, err := fs.cleanPath(name)
+	if err != nil {
+		return "", err
+	}
+	req, _ := fs.s3.PutObjectRequest(&s3.PutObjectInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(fs.noSlashPrefix(name)),
+	})
+	return req.Presign(expiry)
+}
+
+// storageClassOr returns the configured storage class, or class if none is
+// configured.
+func (fs *s3StreamStore) storageClassOr(class *string) *string {
+	if fs.storageClass != "" {
+		return aws.String(fs.storageClass)
+	}
+	return class
+}
+
+func validStorageClass(class string) bool {
+	switch class {
+	case s3.StorageClassStandard, s3.StorageClassReducedRedundancy,
+		s3.StorageClassStandardIa, s3.StorageClassOnezoneIa,
+		s3.StorageClassIntelligentTiering, s3.StorageClassGlacier,
+		s3.StorageClassDeepArchive:
+		return true
+	}
+	return false
+}
+
 func (fs *s3StreamStore) noSlashPrefix(s string) string {
 	if strings.HasPrefix(s, "/") {
 		return s[1:]
@@ -410,15 +1844,158 @@ func (fs *s3StreamStore) lastElem(s string) string {
 }
 
 type s3uploader struct {
-	errCh chan error
-	wc    io.WriteCloser
+	ctx    context.Context
+	errCh  chan error
+	wc     *uploadBody
+	closed bool
 }
 
 func (wc *s3uploader) Write(data []byte) (int, error) {
+	if wc.closed {
+		return 0, straw.ErrClosed
+	}
+	if err := wc.ctx.Err(); err != nil {
+		return 0, err
+	}
 	return wc.wc.Write(data)
 }
 
+// ReadFrom uploads the content of r, which the uploader reads directly into
+// its part buffers.
+func (wc *s3uploader) ReadFrom(r io.Reader) (int64, error) {
+	if wc.closed {
+		return 0, straw.ErrClosed
+	}
+	if err := wc.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return wc.wc.ReadFrom(r)
+}
+
+// uploadBody is the Body of an upload. It works like an io.Pipe, except that
+// rather than copying written data, it hands the uploader a reader for each
+// Write or ReadFrom in turn, and waits for it to be read to the end, so that
+// a reader given to ReadFrom is read straight into the uploader's buffers.
+type uploadBody struct {
+	srcs    chan io.Reader
+	done    chan error
+	closing chan struct{}
+	once    sync.Once
+	// readErr and writeErr are returned once closing is closed.
+	readErr, writeErr error
+
+	cur io.Reader
+}
+
+func newUploadBody() *uploadBody {
+	return &uploadBody{
+		srcs:    make(chan io.Reader),
+		done:    make(chan error, 1),
+		closing: make(chan struct{}),
+	}
+}
+
+// Read reads from the reader currently handed over, moving on to the next
+// when it is exhausted. An error from a reader is returned to the ReadFrom
+// call it came from, rather than failing the upload.
+func (b *uploadBody) Read(p []byte) (int, error) {
+	for {
+		if b.cur == nil {
+			select {
+			case <-b.closing:
+				return 0, b.readErr
+			default:
+			}
+			select {
+			case b.cur = <-b.srcs:
+			case <-b.closing:
+				return 0, b.readErr
+			}
+		}
+		n, err := b.cur.Read(p)
+		if err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			b.cur = nil
+			b.done <- err
+		}
+		if n > 0 || len(p) == 0 {
+			return n, nil
+		}
+	}
+}
+
+func (b *uploadBody) Write(p []byte) (int, error) {
+	if err := b.send(bytes.NewReader(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (b *uploadBody) ReadFrom(r io.Reader) (int64, error) {
+	cr := &countingReader{r: r}
+	err := b.send(cr)
+	return cr.n, err
+}
+
+// send hands r to the reader, and waits until it has been read to the end.
+func (b *uploadBody) send(r io.Reader) error {
+	select {
+	case <-b.closing:
+		return b.writeErr
+	default:
+	}
+	select {
+	case b.srcs <- r:
+	case <-b.closing:
+		return b.writeErr
+	}
+	select {
+	case err := <-b.done:
+		return err
+	case <-b.closing:
+		return b.writeErr
+	}
+}
+
+// Close ends the body, so that the uploader reads io.EOF once it has read
+// everything written.
+func (b *uploadBody) Close() error {
+	return b.CloseWithError(nil)
+}
+
+// CloseWithError fails further reads and writes with err, or ends the body
+// as Close does if err is nil.
+func (b *uploadBody) CloseWithError(err error) error {
+	b.once.Do(func() {
+		b.readErr, b.writeErr = err, err
+		if err == nil {
+			b.readErr, b.writeErr = io.EOF, io.ErrClosedPipe
+		}
+		close(b.closing)
+	})
+	return nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+// Close completes the upload, returning its result. Closing again returns
+// straw.ErrClosed, rather than waiting for a result which has been taken.
 func (wc *s3uploader) Close() error {
+	if wc.closed {
+		return straw.ErrClosed
+	}
+	wc.closed = true
 	err := wc.wc.Close()
 	if err != nil {
 		return err
@@ -427,6 +2004,52 @@ func (wc *s3uploader) Close() error {
 }
 
 func (fs *s3StreamStore) Readdir(name string) ([]os.FileInfo, error) {
+	return fs.ReaddirContext(context.Background(), name)
+}
+
+func (fs *s3StreamStore) ReaddirContext(ctx context.Context, name string) ([]os.FileInfo, error) {
+	return fs.readdir(ctx, name, "")
+}
+
+// ListPrefix lists the entries of the named directory whose names begin with
+// prefix, listing only the keys under that prefix.
+func (fs *s3StreamStore) ListPrefix(name, prefix string) ([]os.FileInfo, error) {
+	if strings.Contains(prefix, "/") {
+		return nil, fmt.Errorf("invalid prefix %q", prefix)
+	}
+	return fs.readdir(context.Background(), name, prefix)
+}
+
+// ReaddirEach calls fn with each entry of the named directory as each page of
+// the listing arrives, entries of each page being passed in the order they
+// are listed, files and then directories. Returning an error from fn stops
+// the listing.
+func (fs *s3StreamStore) ReaddirEach(name string, fn func(os.FileInfo) error) error {
+	return fs.readdirEach(context.Background(), name, "", fn)
+}
+
+// readdir lists the entries of the named directory whose names begin with
+// prefix.
+func (fs *s3StreamStore) readdir(ctx context.Context, name, prefix string) ([]os.FileInfo, error) {
+	var results []os.FileInfo
+	err := fs.readdirEach(ctx, name, prefix, func(fi os.FileInfo) error {
+		results = append(results, fi)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Name() < results[j].Name() })
+	return results, nil
+}
+
+// readdirEach calls fn with each entry of the named directory whose name
+// begins with prefix, a page of the listing at a time.
+func (fs *s3StreamStore) readdirEach(ctx context.Context, name, prefix string, fn func(os.FileInfo) error) error {
+	name, err := fs.cleanPath(name)
+	if err != nil {
+		return err
+	}
 
 	if !strings.HasSuffix(name, "/") {
 		name = name + "/"
@@ -435,26 +2058,29 @@ func (fs *s3StreamStore) Readdir(name string) ([]os.FileInfo, error) {
 		name = name[1:]
 	}
 
-	var results []os.FileInfo
-
 	input := &s3.ListObjectsV2Input{
 		Bucket:    aws.String(fs.bucket),
-		Prefix:    aws.String(name),
+		Prefix:    aws.String(name + prefix),
 		Delimiter: aws.String("/"),
 	}
 	for {
-		out, err := fs.s3.ListObjectsV2(input)
+		out, err := fs.s3.ListObjectsV2WithContext(ctx, input)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		for _, content := range out.Contents {
 			if *content.Key != name {
 				result := &s3StatResult{
-					name:    strings.TrimPrefix(*content.Key, name),
-					modTime: *content.LastModified,
-					size:    *content.Size,
+					name:         strings.TrimPrefix(*content.Key, name),
+					modTime:      *content.LastModified,
+					size:         *content.Size,
+					lazyHead:     fs.lazyHead(ctx, *content.Key),
+					etag:         aws.StringValue(content.ETag),
+					storageClass: aws.StringValue(content.StorageClass),
+				}
+				if err := fn(result); err != nil {
+					return err
 				}
-				results = append(results, result)
 			}
 		}
 		for _, prefix := range out.CommonPrefixes {
@@ -464,18 +2090,111 @@ func (fs *s3StreamStore) Readdir(name string) ([]os.FileInfo, error) {
 				// modTime: ??
 				size: 4096,
 			}
-			results = append(results, result)
+			if err := fn(result); err != nil {
+				return err
+			}
 		}
 
 		if !*out.IsTruncated {
-			sort.Slice(results, func(i, j int) bool { return results[i].Name() < results[j].Name() })
-			return results, nil
+			return nil
 		}
 
 		input.ContinuationToken = out.NextContinuationToken
 	}
 }
 
+// ListDirs returns the names of the immediate subdirectories of name, using
+// only the common prefixes of a delimited listing. Directories other than the
+// root which have nothing under them do not exist.
+func (fs *s3StreamStore) ListDirs(name string) ([]string, error) {
+	path, err := fs.cleanPath(name)
+	if err != nil {
+		return nil, err
+	}
+	name = fs.fixTrailingSlash(fs.noSlashPrefix(path), true)
+	if name == "/" {
+		name = ""
+	}
+
+	var dirs []string
+	var found bool
+
+	input := &s3.ListObjectsV2Input{
+		Bucket:    aws.String(fs.bucket),
+		Prefix:    aws.String(name),
+		Delimiter: aws.String("/"),
+	}
+	err = fs.s3.ListObjectsV2Pages(input, func(out *s3.ListObjectsV2Output, last bool) bool {
+		if len(out.Contents) != 0 || len(out.CommonPrefixes) != 0 {
+			found = true
+		}
+		for _, prefix := range out.CommonPrefixes {
+			dirs = append(dirs, fs.noSlashSuffix(strings.TrimPrefix(*prefix.Prefix, name)))
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found && name != "" {
+		return nil, &os.PathError{Op: "readdir", Path: path, Err: os.ErrNotExist}
+	}
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+// SetTags replaces the tags on the named object using the s3 object tagging
+// API.
+func (fs *s3StreamStore) SetTags(name string, tags map[string]string) error {
+	name, err := fs.cleanPath(name)
+	if err != nil {
+		return err
+	}
+	// not nil, as an empty set is needed to remove all the tags.
+	tagSet := []*s3.Tag{}
+	for k, v := range tags {
+		tagSet = append(tagSet, &s3.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+	sort.Slice(tagSet, func(i, j int) bool { return *tagSet[i].Key < *tagSet[j].Key })
+
+	input := &s3.PutObjectTaggingInput{
+		Bucket:  aws.String(fs.bucket),
+		Key:     aws.String(fs.noSlashPrefix(name)),
+		Tagging: &s3.Tagging{TagSet: tagSet},
+	}
+	if _, err := fs.s3.PutObjectTagging(input); err != nil {
+		if isNotFound(err) {
+			return &os.PathError{Op: "settags", Path: name, Err: os.ErrNotExist}
+		}
+		return err
+	}
+	return nil
+}
+
+// GetTags returns the tags on the named object.
+func (fs *s3StreamStore) GetTags(name string) (map[string]string, error) {
+	name, err := fs.cleanPath(name)
+	if err != nil {
+		return nil, err
+	}
+	input := &s3.GetObjectTaggingInput{
+		Bucket: aws.String(fs.bucket),
+		Key:    aws.String(fs.noSlashPrefix(name)),
+	}
+	out, err := fs.s3.GetObjectTagging(input)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, &os.PathError{Op: "gettags", Path: name, Err: os.ErrNotExist}
+		}
+		return nil, err
+	}
+	tags := make(map[string]string)
+	for _, tag := range out.TagSet {
+		tags[*tag.Key] = *tag.Value
+	}
+	return tags, nil
+}
+
 var (
 	eofRdr = &eofReader{}
 )
@@ -489,3 +2208,16 @@ func (r *eofReader) Read(buf []byte) (int, error) {
 func (r *eofReader) Close() error {
 	return nil
 }
+
+// cleanPath validates name with straw.CleanPath, mapping the current
+// directory onto the bucket root.
+func (fs *s3StreamStore) cleanPath(name string) (string, error) {
+	name, err := straw.CleanPath(name)
+	if err != nil {
+		return "", err
+	}
+	if name == "." {
+		return "/", nil
+	}
+	return name, nil
+}
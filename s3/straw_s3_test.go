@@ -0,0 +1,1511 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+	"github.com/uw-labs/straw/internal/fakes3"
+)
+
+const testBucket = "test-bucket"
+
+func newTestStreamStore(t *testing.T, query string) (*s3StreamStore, *fakes3.Server, func()) {
+	srv := fakes3.New(testBucket)
+	ts := httptest.NewServer(srv)
+
+	sess, err := session.NewSession(&aws.Config{
+		Endpoint:         aws.String(ts.URL),
+		Region:           aws.String("us-east-1"),
+		S3ForcePathStyle: aws.Bool(true),
+		Credentials:      credentials.NewStaticCredentials("id", "secret", ""),
+		MaxRetries:       aws.Int(0),
+	})
+	require.NoError(t, err)
+
+	u, err := url.Parse("s3://" + testBucket + "/?" + query)
+	require.NoError(t, err)
+
+	ss, err := news3StreamStoreWithSession(sess, u)
+	require.NoError(t, err)
+	return ss, srv, ts.Close
+}
+
+func objectGets(srv *fakes3.Server, key string) int {
+	count := 0
+	for _, req := range srv.Requests() {
+		if req.Method == "GET" && req.Key == key {
+			count++
+		}
+	}
+	return count
+}
+
+func TestSmallObjectFetchedOnce(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, srv, closeFn := newTestStreamStore(t, "small_object_threshold=1KB")
+	defer closeFn()
+
+	data := []byte("0123456789")
+	srv.PutObject(testBucket, "small", data)
+
+	r, err := ss.OpenReadCloser("/small")
+	require.NoError(err)
+
+	buf := make([]byte, 4)
+	n, err := r.ReadAt(buf, 6)
+	assert.NoError(err)
+	assert.Equal(data[6:6+n], buf[:n])
+
+	n, err = r.Read(buf)
+	assert.NoError(err)
+	assert.Equal(data[:n], buf[:n])
+
+	_, err = r.Seek(2, io.SeekStart)
+	assert.NoError(err)
+	rest, err := ioutil.ReadAll(r)
+	assert.NoError(err)
+	assert.Equal(data[2:], rest)
+
+	n, err = r.ReadAt(buf, 8)
+	assert.Equal(io.EOF, err)
+	assert.Equal(data[8:], buf[:n])
+
+	assert.NoError(r.Close())
+	assert.Equal(1, objectGets(srv, "small"))
+}
+
+func TestLargeObjectUsesRangedReads(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, srv, closeFn := newTestStreamStore(t, "small_object_threshold=8B")
+	defer closeFn()
+
+	data := []byte("0123456789")
+	srv.PutObject(testBucket, "large", data)
+
+	r, err := ss.OpenReadCloser("/large")
+	require.NoError(err)
+
+	buf := make([]byte, 4)
+	n, err := r.ReadAt(buf, 6)
+	assert.NoError(err)
+	assert.Equal(data[6:6+n], buf[:n])
+
+	all, err := ioutil.ReadAll(r)
+	assert.NoError(err)
+	assert.Equal(data, all)
+
+	assert.NoError(r.Close())
+	assert.Equal(2, objectGets(srv, "large"))
+}
+
+func TestLargeObjectSeekWhence(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, srv, closeFn := newTestStreamStore(t, "small_object_threshold=8B")
+	defer closeFn()
+
+	data := []byte("0123456789")
+	srv.PutObject(testBucket, "large", data)
+
+	r, err := ss.OpenReadCloser("/large")
+	require.NoError(err)
+	defer r.Close()
+
+	buf := make([]byte, 2)
+	pos, err := r.Seek(-3, io.SeekEnd)
+	require.NoError(err)
+	assert.Equal(int64(7), pos)
+	_, err = io.ReadFull(r, buf)
+	require.NoError(err)
+	assert.Equal("78", string(buf))
+
+	pos, err = r.Seek(-6, io.SeekCurrent)
+	require.NoError(err)
+	assert.Equal(int64(3), pos)
+	_, err = io.ReadFull(r, buf)
+	require.NoError(err)
+	assert.Equal("34", string(buf))
+
+	// seeking to the current offset keeps reading the open body.
+	gets := objectGets(srv, "large")
+	_, err = r.Seek(0, io.SeekCurrent)
+	require.NoError(err)
+	_, err = io.ReadFull(r, buf)
+	require.NoError(err)
+	assert.Equal("56", string(buf))
+	assert.Equal(gets, objectGets(srv, "large"))
+
+	_, err = r.Seek(-8, io.SeekCurrent)
+	assert.Error(err)
+	_, err = r.Seek(1, io.SeekEnd)
+	require.NoError(err)
+	_, err = r.Read(buf)
+	assert.Equal(io.EOF, err)
+}
+
+func TestWriteTo(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, srv, closeFn := newTestStreamStore(t, "small_object_threshold=8B")
+	defer closeFn()
+
+	srv.PutObject(testBucket, "large", []byte("0123456789"))
+	srv.PutObject(testBucket, "small", []byte("0123"))
+
+	for name, expected := range map[string]string{"/large": "3456789", "/small": "3"} {
+		r, err := ss.OpenReadCloser(name)
+		require.NoError(err)
+		_, err = r.Seek(3, io.SeekStart)
+		require.NoError(err)
+		var buf bytes.Buffer
+		n, err := r.(io.WriterTo).WriteTo(&buf)
+		require.NoError(err)
+		assert.Equal(int64(len(expected)), n, name)
+		assert.Equal(expected, buf.String(), name)
+		require.NoError(r.Close())
+	}
+
+	r, err := ss.OpenReadCloser("/large")
+	require.NoError(err)
+	defer r.Close()
+	_, err = r.Seek(20, io.SeekStart)
+	require.NoError(err)
+	n, err := r.(io.WriterTo).WriteTo(ioutil.Discard)
+	assert.NoError(err)
+	assert.Equal(int64(0), n)
+}
+
+func TestInvalidSmallObjectThreshold(t *testing.T) {
+	_, err := news3StreamStoreWithSession(session.Must(session.NewSession()), &url.URL{
+		Scheme:   "s3",
+		Host:     testBucket,
+		RawQuery: "small_object_threshold=lots",
+	})
+	assert.EqualError(t, err, `invalid "small_object_threshold" query parameter: invalid size "lots"`)
+}
+
+func TestEndpointOption(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	srv := fakes3.New(testBucket)
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	sess, err := session.NewSession(&aws.Config{
+		Region:      aws.String("us-east-1"),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+		MaxRetries:  aws.Int(0),
+	})
+	require.NoError(err)
+
+	u, err := url.Parse("s3://" + testBucket + "/")
+	require.NoError(err)
+	S3Endpoint(ts.URL)(u)
+	S3ForcePathStyle(true)(u)
+
+	ss, err := news3StreamStoreWithSession(sess, u)
+	require.NoError(err)
+
+	writeTestFile(t, ss, "/f", []byte("hello"))
+	obj, ok := srv.Object(testBucket, "f")
+	require.True(ok)
+	assert.Equal("hello", string(obj.Data))
+}
+
+func TestCredentials(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	sess, err := session.NewSession(&aws.Config{
+		Credentials: credentials.NewStaticCredentials("chainid", "chainsecret", ""),
+	})
+	require.NoError(err)
+
+	for _, tc := range []struct {
+		rawurl       string
+		id, secret   string
+		sessionToken string
+	}{
+		{"s3://" + testBucket + "/?region=eu-west-1", "chainid", "chainsecret", ""},
+		{"s3://id:secret@" + testBucket + "/?region=eu-west-1", "id", "secret", ""},
+		{"s3://" + testBucket + "/?region=eu-west-1&access_key=id&secret_key=secret&session_token=token", "id", "secret", "token"},
+		{"s3://other:other@" + testBucket + "/?region=eu-west-1&access_key=id&secret_key=secret", "id", "secret", ""},
+	} {
+		u, err := url.Parse(tc.rawurl)
+		require.NoError(err)
+		ss, err := news3StreamStoreWithSession(sess, u)
+		require.NoError(err, tc.rawurl)
+		creds, err := ss.s3.Config.Credentials.Get()
+		require.NoError(err)
+		assert.Equal(tc.id, creds.AccessKeyID, tc.rawurl)
+		assert.Equal(tc.secret, creds.SecretAccessKey, tc.rawurl)
+		assert.Equal(tc.sessionToken, creds.SessionToken, tc.rawurl)
+	}
+
+	u := &url.URL{Scheme: "s3", Host: testBucket, RawQuery: "region=eu-west-1"}
+	S3Credentials("id", "secret", "")(u)
+	ss, err := news3StreamStoreWithSession(sess, u)
+	require.NoError(err)
+	creds, err := ss.s3.Config.Credentials.Get()
+	require.NoError(err)
+	assert.Equal("id", creds.AccessKeyID)
+	assert.Equal("secret", creds.SecretAccessKey)
+
+	for _, rawurl := range []string{
+		"s3://" + testBucket + "/?region=eu-west-1&secret_key=hunter2",
+		"s3://id@" + testBucket + "/?region=eu-west-1",
+		"s3://:hunter2@" + testBucket + "/?region=eu-west-1",
+	} {
+		_, err := straw.Open(rawurl)
+		if assert.Error(err, rawurl) {
+			assert.NotContains(err.Error(), "hunter2")
+		}
+	}
+}
+
+func TestRegion(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var lookups []string
+	defer func(f func(context.Context, *session.Session, string, string) (string, error)) {
+		bucketRegion = f
+	}(bucketRegion)
+	bucketRegion = func(ctx context.Context, sess *session.Session, bucket, hint string) (string, error) {
+		lookups = append(lookups, bucket+" "+hint)
+		return "eu-west-1", nil
+	}
+
+	sess, err := session.NewSession(&aws.Config{
+		Region:      aws.String("us-east-1"),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+	})
+	require.NoError(err)
+
+	ss, err := news3StreamStoreWithSession(sess, &url.URL{Scheme: "s3", Host: testBucket})
+	require.NoError(err)
+	assert.Equal("eu-west-1", aws.StringValue(ss.s3.Config.Region))
+	assert.Equal([]string{testBucket + " us-east-1"}, lookups)
+
+	u := &url.URL{Scheme: "s3", Host: testBucket}
+	S3Region("ap-south-1")(u)
+	ss, err = news3StreamStoreWithSession(sess, u)
+	require.NoError(err)
+	assert.Equal("ap-south-1", aws.StringValue(ss.s3.Config.Region))
+
+	// no lookup is made for custom endpoints.
+	u = &url.URL{Scheme: "s3", Host: testBucket}
+	S3Endpoint("http://localhost:9000")(u)
+	ss, err = news3StreamStoreWithSession(sess, u)
+	require.NoError(err)
+	assert.Equal("us-east-1", aws.StringValue(ss.s3.Config.Region))
+	assert.Len(lookups, 1)
+}
+
+func TestStorageClass(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, srv, closeFn := newTestStreamStore(t, "storageclass=STANDARD_IA")
+	defer closeFn()
+
+	writeTestFile(t, ss, "/f", []byte{1})
+	require.NoError(ss.Copy("/f", "/g"))
+
+	var puts []fakes3.Request
+	for _, req := range srv.Requests() {
+		if req.Method == http.MethodPut {
+			puts = append(puts, req)
+		}
+	}
+	require.Len(puts, 2)
+	for _, put := range puts {
+		assert.Equal("STANDARD_IA", put.Header.Get("X-Amz-Storage-Class"))
+	}
+}
+
+func TestKMSKeyID(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	u, _ := url.Parse("s3://" + testBucket + "/")
+	S3KMSKeyID("alias/straw")(u)
+	ss, srv, closeFn := newTestStreamStore(t, u.RawQuery)
+	defer closeFn()
+
+	writeTestFile(t, ss, "/f", []byte{1})
+	require.NoError(ss.Mkdir("/dir", 0755))
+
+	var puts []fakes3.Request
+	for _, req := range srv.Requests() {
+		if req.Method == http.MethodPut {
+			puts = append(puts, req)
+		}
+	}
+	require.Len(puts, 2)
+	for _, put := range puts {
+		assert.Equal("aws:kms", put.Header.Get("X-Amz-Server-Side-Encryption"))
+		assert.Equal("alias/straw", put.Header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"))
+	}
+
+	_, err := news3StreamStoreWithSession(session.Must(session.NewSession()), &url.URL{
+		Scheme:   "s3",
+		Host:     testBucket,
+		RawQuery: "sse=AES256&kmskeyid=alias/straw",
+	})
+	assert.EqualError(err, `invalid "kmskeyid" query parameter: a KMS key requires "aws:kms" encryption, not "AES256"`)
+}
+
+func TestPresign(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, srv, closeFn := newTestStreamStore(t, "")
+	defer closeFn()
+
+	put, err := ss.PresignPutURL("/dir/f", 5*time.Minute)
+	require.NoError(err)
+	assert.Contains(put, "X-Amz-Expires=300")
+	req, err := http.NewRequest(http.MethodPut, put, strings.NewReader("hello"))
+	require.NoError(err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(err)
+	resp.Body.Close()
+	assert.Equal(http.StatusOK, resp.StatusCode)
+
+	obj, ok := srv.Object(testBucket, "dir/f")
+	require.True(ok)
+	assert.Equal("hello", string(obj.Data))
+
+	get, err := ss.PresignGetURL("/dir/f", time.Minute)
+	require.NoError(err)
+	assert.Contains(get, "X-Amz-Signature=")
+	resp, err = http.Get(get)
+	require.NoError(err)
+	data, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(err)
+	assert.Equal("hello", string(data))
+
+	_, err = ss.PresignGetURL("/../f", time.Minute)
+	assert.True(errors.Is(err, straw.ErrInvalidPath))
+}
+
+func TestInvalidStorageClass(t *testing.T) {
+	_, err := news3StreamStoreWithSession(session.Must(session.NewSession()), &url.URL{
+		Scheme:   "s3",
+		Host:     testBucket,
+		RawQuery: "storageclass=COLD",
+	})
+	assert.EqualError(t, err, `invalid "storageclass" query parameter: unknown storage class "COLD"`)
+}
+
+func TestInvalidForcePathStyle(t *testing.T) {
+	_, err := news3StreamStoreWithSession(session.Must(session.NewSession()), &url.URL{
+		Scheme:   "s3",
+		Host:     testBucket,
+		RawQuery: "force_path_style=sometimes",
+	})
+	assert.EqualError(t, err, `invalid "force_path_style" query parameter: strconv.ParseBool: parsing "sometimes": invalid syntax`)
+}
+
+func writeTestFile(t *testing.T, ss *s3StreamStore, name string, data []byte) {
+	w, err := ss.CreateWriteCloser(name)
+	require.NoError(t, err)
+	_, err = w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+}
+
+func readdirNames(t *testing.T, ss *s3StreamStore, name string) []string {
+	fis, err := ss.Readdir(name)
+	require.NoError(t, err)
+	var names []string
+	for _, fi := range fis {
+		names = append(names, fi.Name())
+	}
+	return names
+}
+
+func TestDirModeMarker(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, srv, closeFn := newTestStreamStore(t, "dir_mode=marker")
+	defer closeFn()
+
+	require.NoError(ss.Mkdir("/a", 0755))
+	_, ok := srv.Object(testBucket, "a/")
+	assert.True(ok)
+
+	fi, err := ss.Stat("/a")
+	require.NoError(err)
+	assert.True(fi.IsDir())
+
+	writeTestFile(t, ss, "/a/f", []byte{1})
+	assert.Equal([]string{"a"}, readdirNames(t, ss, "/"))
+	assert.Equal([]string{"f"}, readdirNames(t, ss, "/a"))
+
+	assert.True(errors.Is(ss.Remove("/a"), straw.ErrDirectoryNotEmpty))
+	require.NoError(ss.Remove("/a/f"))
+
+	// the marker keeps the empty directory in existence.
+	fi, err = ss.Stat("/a")
+	require.NoError(err)
+	assert.True(fi.IsDir())
+
+	require.NoError(ss.Remove("/a"))
+	_, err = ss.Stat("/a")
+	assert.True(os.IsNotExist(err))
+	_, ok = srv.Object(testBucket, "a/")
+	assert.False(ok)
+}
+
+func TestDirModePrefix(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, srv, closeFn := newTestStreamStore(t, "dir_mode=prefix")
+	defer closeFn()
+
+	require.NoError(ss.Mkdir("/a", 0755))
+	_, ok := srv.Object(testBucket, "a/")
+	assert.False(ok)
+
+	// an empty directory cannot be represented.
+	_, err := ss.Stat("/a")
+	assert.True(os.IsNotExist(err))
+
+	writeTestFile(t, ss, "/a/b/f", []byte{1})
+	assert.Equal([]string{"a"}, readdirNames(t, ss, "/"))
+	assert.Equal([]string{"b"}, readdirNames(t, ss, "/a"))
+	assert.Equal([]string{"f"}, readdirNames(t, ss, "/a/b"))
+
+	fi, err := ss.Stat("/a/b")
+	require.NoError(err)
+	assert.True(fi.IsDir())
+
+	assert.True(errors.Is(ss.Remove("/a/b"), straw.ErrDirectoryNotEmpty))
+	require.NoError(ss.Remove("/a/b/f"))
+
+	_, err = ss.Stat("/a")
+	assert.True(os.IsNotExist(err))
+	assert.Empty(readdirNames(t, ss, "/"))
+	assert.True(os.IsNotExist(ss.Remove("/a/b")))
+}
+
+func TestDirModeInterop(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	for _, mode := range []string{"marker", "prefix"} {
+		u := &url.URL{Scheme: "s3", Host: testBucket}
+		S3DirMode(mode)(u)
+
+		ss, srv, closeFn := newTestStreamStore(t, u.RawQuery)
+
+		// a folder made with the console, and one implied by its files,
+		// behind keys which sort before its own.
+		srv.PutObject(testBucket, "console/", nil)
+		srv.PutObject(testBucket, "implied-1", []byte{1})
+		srv.PutObject(testBucket, "implied-2", []byte{1})
+		srv.PutObject(testBucket, "implied/f", []byte{1})
+
+		assert.Equal([]string{"console", "implied", "implied-1", "implied-2"}, readdirNames(t, ss, "/"), mode)
+		for _, name := range []string{"/console", "/implied"} {
+			fi, err := ss.Stat(name)
+			require.NoError(err, mode)
+			assert.True(fi.IsDir(), mode)
+		}
+		assert.Empty(readdirNames(t, ss, "/console"), mode)
+		assert.Equal([]string{"f"}, readdirNames(t, ss, "/implied"), mode)
+
+		// an object and a directory of the same name, also hidden behind
+		// other keys, report the directory.
+		srv.PutObject(testBucket, "both", []byte{1})
+		srv.PutObject(testBucket, "both-1", []byte{1})
+		srv.PutObject(testBucket, "both/f", []byte{1})
+		fi, err := ss.Stat("/both")
+		require.NoError(err, mode)
+		assert.True(fi.IsDir(), mode)
+
+		require.NoError(ss.Remove("/console"), mode)
+		_, err = ss.Stat("/console")
+		assert.True(os.IsNotExist(err), mode)
+		closeFn()
+	}
+}
+
+func TestInvalidDirMode(t *testing.T) {
+	_, err := news3StreamStoreWithSession(session.Must(session.NewSession()), &url.URL{
+		Scheme:   "s3",
+		Host:     testBucket,
+		RawQuery: "dir_mode=folders",
+	})
+	assert.EqualError(t, err, `invalid "dir_mode" query parameter: unknown mode "folders"`)
+}
+
+func TestListDirs(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, _, closeFn := newTestStreamStore(t, "dir_mode=prefix")
+	defer closeFn()
+
+	writeTestFile(t, ss, "/a/f", []byte{1})
+	writeTestFile(t, ss, "/a/y/f", []byte{1})
+	writeTestFile(t, ss, "/a/x/f", []byte{1})
+
+	dirs, err := ss.ListDirs("/a")
+	require.NoError(err)
+	assert.Equal([]string{"x", "y"}, dirs)
+
+	dirs, err = ss.ListDirs("/")
+	require.NoError(err)
+	assert.Equal([]string{"a"}, dirs)
+
+	// directories with only files have no subdirectories, and missing ones
+	// fail as Readdir would.
+	dirs, err = ss.ListDirs("/a/x")
+	require.NoError(err)
+	assert.Empty(dirs)
+	_, err = ss.ListDirs("/missing")
+	assert.True(os.IsNotExist(err))
+	var pathErr *os.PathError
+	require.True(errors.As(err, &pathErr))
+	assert.Equal("/missing", pathErr.Path)
+}
+
+func TestGlobListsPrefix(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, srv, closeFn := newTestStreamStore(t, "dir_mode=prefix")
+	defer closeFn()
+
+	writeTestFile(t, ss, "/logs/app-1.json", []byte{1})
+	writeTestFile(t, ss, "/logs/app-2.txt", []byte{1})
+	writeTestFile(t, ss, "/logs/db-1.json", []byte{1})
+	writeTestFile(t, ss, "/logs/apps/f", []byte{1})
+
+	fis, err := ss.ListPrefix("/logs", "app")
+	require.NoError(err)
+	var names []string
+	for _, fi := range fis {
+		names = append(names, fi.Name())
+	}
+	assert.Equal([]string{"app-1.json", "app-2.txt", "apps"}, names)
+
+	matches, err := straw.Glob(ss, "/logs/app-*.json")
+	require.NoError(err)
+	assert.Equal([]string{"/logs/app-1.json"}, matches)
+
+	var listed []string
+	for _, req := range srv.Requests() {
+		if req.Method == http.MethodGet && strings.Contains(req.Query, "list-type=2") {
+			q, err := url.ParseQuery(req.Query)
+			require.NoError(err)
+			listed = append(listed, q.Get("prefix"))
+		}
+	}
+	assert.Contains(listed, "logs/app-")
+	assert.NotContains(listed, "logs/")
+
+	_, err = ss.ListPrefix("/logs", "a/b")
+	assert.Error(err)
+}
+
+func TestReaddirEach(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, srv, closeFn := newTestStreamStore(t, "")
+	defer closeFn()
+
+	for i := 0; i < 1005; i++ {
+		putTestObject(t, ss, fmt.Sprintf("d/%04d", i), []byte{1})
+	}
+	putTestObject(t, ss, "d/sub/f", []byte{1})
+
+	listRequests := func() int {
+		n := 0
+		for _, req := range srv.Requests() {
+			if req.Method == http.MethodGet && strings.Contains(req.Query, "list-type=2") {
+				n++
+			}
+		}
+		return n
+	}
+
+	var names []string
+	require.NoError(ss.ReaddirEach("/d", func(fi os.FileInfo) error {
+		names = append(names, fi.Name())
+		return nil
+	}))
+	assert.Len(names, 1006)
+	assert.Contains(names, "sub")
+	assert.Equal(2, listRequests())
+
+	// stopping early fetches no more pages.
+	stop := errors.New("stop")
+	err := ss.ReaddirEach("/d", func(fi os.FileInfo) error {
+		return stop
+	})
+	assert.Equal(stop, err)
+	assert.Equal(3, listRequests())
+}
+
+func TestWriteWithExpires(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, srv, closeFn := newTestStreamStore(t, "")
+	defer closeFn()
+
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	w, err := straw.CreateWriteCloser(ss, "/f", straw.WithExpires(expires))
+	require.NoError(err)
+	_, err = w.Write([]byte{1})
+	require.NoError(err)
+	require.NoError(w.Close())
+
+	var puts []fakes3.Request
+	for _, req := range srv.Requests() {
+		if req.Method == http.MethodPut {
+			puts = append(puts, req)
+		}
+	}
+	require.Len(puts, 1)
+	assert.Equal("Wed, 02 Jan 2030 03:04:05 GMT", puts[0].Header.Get("Expires"))
+
+	fi, err := ss.Stat("/f")
+	require.NoError(err)
+	info, ok := fi.Sys().(*straw.ObjectInfo)
+	require.True(ok)
+	assert.True(expires.Equal(info.Expires))
+
+	fi, err = ss.Stat("/")
+	require.NoError(err)
+	assert.Nil(fi.Sys())
+}
+
+func TestWriteContentTypeAndMetadata(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, srv, closeFn := newTestStreamStore(t, "")
+	defer closeFn()
+
+	writeTestFile(t, ss, "/index.html", []byte("<p>"))
+	obj, ok := srv.Object(testBucket, "index.html")
+	require.True(ok)
+	assert.Equal("text/html; charset=utf-8", obj.Header.Get("Content-Type"))
+
+	w, err := straw.CreateWriteCloser(ss, "/data.json",
+		straw.WithContentType("application/x-custom"),
+		straw.WithMetadata(map[string]string{"owner": "me"}))
+	require.NoError(err)
+	require.NoError(w.Close())
+
+	obj, ok = srv.Object(testBucket, "data.json")
+	require.True(ok)
+	assert.Equal("application/x-custom", obj.Header.Get("Content-Type"))
+	assert.Equal("me", obj.Header.Get("X-Amz-Meta-Owner"))
+}
+
+func TestWriteContentMD5(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, srv, closeFn := newTestStreamStore(t, "")
+	defer closeFn()
+
+	putTestObject(t, ss, "a", []byte("old"))
+	sum := md5.Sum([]byte("hello"))
+
+	w, err := straw.CreateWriteCloser(ss, "/a", straw.WithContentMD5(sum[:]))
+	require.NoError(err)
+	_, err = w.Write([]byte("jello"))
+	require.NoError(err)
+	assert.Error(w.Close())
+
+	// the SumWriter checks the content before writing it, so the existing
+	// object is kept.
+	sw, err := straw.CreateSumWriter(ss, "/a", "md5", sum[:])
+	require.NoError(err)
+	_, err = sw.Write([]byte("jello"))
+	require.NoError(err)
+	assert.True(errors.Is(sw.Close(), straw.ErrChecksumMismatch))
+
+	obj, ok := srv.Object(testBucket, "a")
+	require.True(ok)
+	assert.Equal("old", string(obj.Data))
+
+	sw, err = straw.CreateSumWriter(ss, "/a", "md5", sum[:])
+	require.NoError(err)
+	_, err = sw.Write([]byte("hello"))
+	require.NoError(err)
+	require.NoError(sw.Close())
+	assert.Equal(sum[:], sw.Sum())
+
+	obj, ok = srv.Object(testBucket, "a")
+	require.True(ok)
+	assert.Equal("hello", string(obj.Data))
+}
+
+func TestWritePreconditions(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, srv, closeFn := newTestStreamStore(t, "")
+	defer closeFn()
+
+	write := func(name string, data []byte, opts ...straw.WriteOption) error {
+		w, err := straw.CreateWriteCloser(ss, name, opts...)
+		require.NoError(err)
+		_, err = w.Write(data)
+		require.NoError(err)
+		return w.Close()
+	}
+
+	require.NoError(write("/lock", []byte("a"), straw.IfNotExists()))
+	err := write("/lock", []byte("b"), straw.IfNotExists())
+	assert.True(errors.Is(err, straw.ErrPreconditionFailed))
+
+	fi, err := ss.Stat("/lock")
+	require.NoError(err)
+	etag := fi.Sys().(*straw.ObjectInfo).ETag
+	require.NoError(write("/lock", []byte("c"), straw.IfMatch(etag)))
+	err = write("/lock", []byte("d"), straw.IfMatch(etag))
+	assert.True(errors.Is(err, straw.ErrPreconditionFailed))
+	err = write("/missing", []byte("e"), straw.IfMatch(etag))
+	assert.True(errors.Is(err, straw.ErrPreconditionFailed))
+
+	obj, ok := srv.Object(testBucket, "lock")
+	require.True(ok)
+	assert.Equal("c", string(obj.Data))
+
+	// multipart uploads are conditional on completion.
+	big := make([]byte, 6<<20)
+	err = write("/lock", big, straw.IfNotExists())
+	assert.True(errors.Is(err, straw.ErrPreconditionFailed))
+	require.NoError(write("/big", big, straw.IfNotExists()))
+	assert.Equal(0, srv.Uploads())
+
+	obj, ok = srv.Object(testBucket, "lock")
+	require.True(ok)
+	assert.Equal("c", string(obj.Data))
+}
+
+func TestExists(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, srv, closeFn := newTestStreamStore(t, "")
+	defer closeFn()
+
+	putTestObject(t, ss, "a", []byte("hello"))
+	putTestObject(t, ss, "dir/b", []byte("hello"))
+
+	for name, want := range map[string]bool{
+		"/":      true,
+		"/a":     true,
+		"/dir":   true,
+		"/dir/":  true,
+		"/dir/b": true,
+		"/b":     false,
+		"/di":    false,
+	} {
+		exists, err := straw.Exists(ss, name)
+		require.NoError(err, name)
+		assert.Equal(want, exists, name)
+	}
+
+	for _, req := range srv.Requests() {
+		assert.False(req.Method == http.MethodGet && req.Key != "", "object was fetched")
+	}
+}
+
+func TestIsDir(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, srv, closeFn := newTestStreamStore(t, "")
+	defer closeFn()
+
+	putTestObject(t, ss, "file", []byte("hello"))
+	srv.PutObject(testBucket, "marker/", nil)
+	putTestObject(t, ss, "prefix/f", []byte("hello"))
+
+	for name, want := range map[string]bool{
+		"/":       true,
+		"/file":   false,
+		"/marker": true,
+		"/prefix": true,
+	} {
+		isDir, err := straw.IsDir(ss, name)
+		require.NoError(err, name)
+		assert.Equal(want, isDir, name)
+	}
+	_, err := straw.IsDir(ss, "/missing")
+	assert.True(os.IsNotExist(err))
+
+	// directories are found by listing, without a HEAD request.
+	var heads []string
+	for _, req := range srv.Requests() {
+		if req.Method == http.MethodHead {
+			heads = append(heads, req.Key)
+		}
+	}
+	assert.Equal([]string{"file", "missing"}, heads)
+}
+
+func TestObjectInfo(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, srv, closeFn := newTestStreamStore(t, "storageclass=STANDARD_IA")
+	defer closeFn()
+
+	w, err := straw.CreateWriteCloser(ss, "/data.json",
+		straw.WithContentType("application/x-custom"),
+		straw.WithMetadata(map[string]string{"Owner": "me"}))
+	require.NoError(err)
+	_, err = w.Write([]byte("hello"))
+	require.NoError(err)
+	require.NoError(w.Close())
+	require.NoError(ss.Chmod("/data.json", 0600))
+	putTestObject(t, ss, "plain", []byte("hello"))
+
+	// neither Stat nor Readdir makes a request per file.
+	heads := countRequests(srv, http.MethodHead)
+	fi, err := ss.Stat("/data.json")
+	require.NoError(err)
+	fis, err := ss.Readdir("/")
+	require.NoError(err)
+	require.Len(fis, 2)
+	assert.Equal(heads, countRequests(srv, http.MethodHead))
+
+	// the attributes are fetched once, when first needed, and files from
+	// Stat and Readdir report the same.
+	want := &straw.ObjectInfo{
+		StorageClass: "STANDARD_IA",
+		ContentType:  "application/x-custom",
+		ETag:         "5d41402abc4b2a76b9719d911017c592",
+		Metadata:     map[string]string{"owner": "me"},
+	}
+	assert.Equal(os.FileMode(0600), fi.Mode())
+	assert.Equal(want, fi.Sys())
+	assert.Equal(heads+1, countRequests(srv, http.MethodHead))
+	assert.Equal(want, fis[0].Sys())
+	assert.Equal(os.FileMode(0600), fis[0].Mode())
+	assert.Equal(&straw.ObjectInfo{
+		StorageClass: "STANDARD",
+		ContentType:  "text/plain",
+		ETag:         "5d41402abc4b2a76b9719d911017c592",
+		Metadata:     map[string]string{"owner": "me"},
+	}, fis[1].Sys())
+	assert.Equal(heads+3, countRequests(srv, http.MethodHead))
+
+	// the request uses the context of Stat, and if it fails only what the
+	// listing reports is.
+	type ctxKey struct{}
+	var headCtx context.Context
+	ss.s3.Handlers.Validate.PushBack(func(r *request.Request) {
+		if r.Operation.Name == "HeadObject" {
+			headCtx = r.Context()
+			r.Error = errors.New("head failed")
+		}
+	})
+	ctx := context.WithValue(context.Background(), ctxKey{}, "stat")
+	fi, err = ss.StatContext(ctx, "/data.json")
+	require.NoError(err)
+	assert.Nil(headCtx)
+	assert.Equal(&straw.ObjectInfo{
+		StorageClass: "STANDARD_IA",
+		ETag:         "5d41402abc4b2a76b9719d911017c592",
+	}, fi.Sys())
+	assert.Equal(os.FileMode(0644), fi.Mode())
+	require.NotNil(headCtx)
+	assert.Equal("stat", headCtx.Value(ctxKey{}))
+}
+
+// countRequests returns the number of requests made to srv with method.
+func countRequests(srv *fakes3.Server, method string) int {
+	var n int
+	for _, req := range srv.Requests() {
+		if req.Method == method {
+			n++
+		}
+	}
+	return n
+}
+
+func putTestObject(t *testing.T, ss *s3StreamStore, key string, data []byte) {
+	_, err := ss.s3.PutObject(&s3.PutObjectInput{
+		Bucket:      aws.String(testBucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("text/plain"),
+		Metadata:    map[string]*string{"Owner": aws.String("me")},
+	})
+	require.NoError(t, err)
+}
+
+func TestCopy(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, srv, closeFn := newTestStreamStore(t, "")
+	defer closeFn()
+
+	putTestObject(t, ss, "a", []byte("hello"))
+	writeTestFile(t, ss, "/b", []byte("old"))
+
+	require.NoError(ss.Copy("/a", "/b"))
+
+	obj, ok := srv.Object(testBucket, "b")
+	require.True(ok)
+	assert.Equal("hello", string(obj.Data))
+	assert.Equal("text/plain", obj.Header.Get("Content-Type"))
+	assert.Equal("me", obj.Header.Get("X-Amz-Meta-Owner"))
+
+	for _, req := range srv.Requests() {
+		assert.False(req.Method == http.MethodGet && req.Key == "a", "source was downloaded")
+	}
+}
+
+func TestCopyParts(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	defer func(size, part int64) {
+		maxCopyObjectSize, copyPartSize = size, part
+	}(maxCopyObjectSize, copyPartSize)
+	maxCopyObjectSize, copyPartSize = 4, 3
+
+	ss, srv, closeFn := newTestStreamStore(t, "")
+	defer closeFn()
+
+	putTestObject(t, ss, "a", []byte("0123456789"))
+
+	require.NoError(ss.Copy("/a", "/b"))
+
+	obj, ok := srv.Object(testBucket, "b")
+	require.True(ok)
+	assert.Equal("0123456789", string(obj.Data))
+	assert.Equal("text/plain", obj.Header.Get("Content-Type"))
+	assert.Equal("me", obj.Header.Get("X-Amz-Meta-Owner"))
+	assert.Equal(0, srv.Uploads())
+
+	// an upload which cannot be completed is aborted.
+	ss.s3.Handlers.Validate.PushBack(func(r *request.Request) {
+		if r.Operation.Name == "CompleteMultipartUpload" {
+			r.Error = errors.New("complete failed")
+		}
+	})
+	assert.EqualError(ss.Copy("/a", "/c"), "complete failed")
+	_, ok = srv.Object(testBucket, "c")
+	assert.False(ok)
+	assert.Equal(0, srv.Uploads())
+}
+
+func TestAppendWriteCloser(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	defer func(min, part int64) {
+		minPartSize, copyPartSize = min, part
+	}(minPartSize, copyPartSize)
+	minPartSize, copyPartSize = 4, 4
+
+	ss, srv, closeFn := newTestStreamStore(t, "")
+	defer closeFn()
+
+	appendString := func(name, s string) {
+		w, err := straw.AppendWriteCloser(ss, name)
+		require.NoError(err)
+		_, err = w.Write([]byte(s))
+		require.NoError(err)
+		require.NoError(w.Close())
+	}
+
+	// a missing object is created.
+	appendString("/a", "012")
+	obj, ok := srv.Object(testBucket, "a")
+	require.True(ok)
+	assert.Equal("012", string(obj.Data))
+
+	// writers other than those appending to an existing object send each
+	// part once it is full, so cannot be flushed.
+	cw, err := straw.AppendWriteCloser(ss, "/c")
+	require.NoError(err)
+	_, ok = cw.(straw.Flusher)
+	assert.False(ok)
+	require.NoError(cw.Close())
+
+	// an object smaller than a part is uploaded again. Small parts are
+	// written as they fill up.
+	ss.partSize = 4
+	appendString("/a", "3456")
+	obj, _ = srv.Object(testBucket, "a")
+	assert.Equal("0123456", string(obj.Data))
+
+	// larger objects are copied, in parts of at least minPartSize.
+	putTestObject(t, ss, "b", []byte("0123456789"))
+	require.NoError(ss.SetTags("/b", map[string]string{"owner": "me"}))
+	appendString("/b", "abcdefghij")
+	obj, _ = srv.Object(testBucket, "b")
+	assert.Equal("0123456789abcdefghij", string(obj.Data))
+	assert.Equal("text/plain", obj.Header.Get("Content-Type"))
+	assert.Equal("me", obj.Header.Get("X-Amz-Meta-Owner"))
+	assert.Equal(map[string]string{"owner": "me"}, obj.Tags)
+
+	var ranges []string
+	for _, req := range srv.Requests() {
+		assert.False(req.Method == http.MethodGet && req.Key == "b" && req.Query == "", "object was downloaded")
+		if rng := req.Header.Get("X-Amz-Copy-Source-Range"); rng != "" && req.Key == "b" {
+			ranges = append(ranges, rng)
+		}
+	}
+	assert.Equal([]string{"bytes=0-3", "bytes=4-9"}, ranges)
+
+	// closing without writing leaves the object alone.
+	w, err := straw.AppendWriteCloser(ss, "/b")
+	require.NoError(err)
+	require.NoError(w.Close())
+	obj, _ = srv.Object(testBucket, "b")
+	assert.Equal("0123456789abcdefghij", string(obj.Data))
+	assert.Equal(0, srv.Uploads())
+	assert.Equal(straw.ErrClosed, w.Close())
+
+	// flushing uploads a part once there is enough for one.
+	w, err = straw.AppendWriteCloser(ss, "/b")
+	require.NoError(err)
+	countParts := func() (n int) {
+		for _, req := range srv.Requests() {
+			if req.Method == http.MethodPut && req.Key == "b" && strings.Contains(req.Query, "partNumber") && req.Header.Get("X-Amz-Copy-Source") == "" {
+				n++
+			}
+		}
+		return n
+	}
+	before := countParts()
+	_, err = w.Write([]byte("kl"))
+	require.NoError(err)
+	require.NoError(w.(straw.Flusher).Flush())
+	assert.Equal(before, countParts())
+	_, err = w.Write([]byte("mn"))
+	require.NoError(err)
+	require.NoError(w.(straw.Flusher).Flush())
+	assert.Equal(before+1, countParts())
+	require.NoError(w.Close())
+	obj, _ = srv.Object(testBucket, "b")
+	assert.Equal("0123456789abcdefghijklmn", string(obj.Data))
+
+	require.NoError(ss.Mkdir("/dir", 0755))
+	_, err = straw.AppendWriteCloser(ss, "/dir")
+	assert.True(errors.Is(err, straw.ErrIsDirectory))
+}
+
+func TestTags(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	defer func(size, part int64) {
+		maxCopyObjectSize, copyPartSize = size, part
+	}(maxCopyObjectSize, copyPartSize)
+
+	ss, _, closeFn := newTestStreamStore(t, "")
+	defer closeFn()
+
+	putTestObject(t, ss, "a", []byte("0123456789"))
+	tags := map[string]string{"class": "archive", "owner": "me & you"}
+	require.NoError(ss.SetTags("/a", tags))
+
+	got, err := ss.GetTags("/a")
+	require.NoError(err)
+	assert.Equal(tags, got)
+
+	// tags survive copies, whether or not they are made in parts, and
+	// Chmod, which copies the object over itself.
+	require.NoError(ss.Copy("/a", "/b"))
+	maxCopyObjectSize, copyPartSize = 4, 3
+	require.NoError(ss.Copy("/a", "/c"))
+	require.NoError(ss.Chmod("/c", 0600))
+	for _, name := range []string{"/b", "/c"} {
+		got, err := ss.GetTags(name)
+		require.NoError(err)
+		assert.Equal(tags, got, name)
+	}
+
+	require.NoError(ss.SetTags("/a", nil))
+	got, err = ss.GetTags("/a")
+	require.NoError(err)
+	assert.Empty(got)
+
+	assert.True(os.IsNotExist(ss.SetTags("/missing", tags)))
+	_, err = ss.GetTags("/missing")
+	assert.True(os.IsNotExist(err))
+}
+
+func TestCopyDirectory(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, _, closeFn := newTestStreamStore(t, "")
+	defer closeFn()
+
+	require.NoError(ss.Mkdir("/d", 0755))
+	writeTestFile(t, ss, "/f", []byte{1})
+
+	assert.True(errors.Is(ss.Copy("/d", "/e"), straw.ErrIsDirectory))
+	assert.True(errors.Is(ss.Copy("/f", "/d"), straw.ErrIsDirectory))
+}
+
+func TestChmod(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, srv, closeFn := newTestStreamStore(t, "")
+	defer closeFn()
+
+	putTestObject(t, ss, "a", []byte("hello"))
+	require.NoError(ss.Mkdir("/dir", 0755))
+
+	fi, err := ss.Stat("/a")
+	require.NoError(err)
+	assert.Equal(os.FileMode(0644), fi.Mode())
+
+	require.NoError(ss.Chmod("/a", 0600))
+
+	fi, err = ss.Stat("/a")
+	require.NoError(err)
+	assert.Equal(os.FileMode(0600), fi.Mode())
+
+	obj, ok := srv.Object(testBucket, "a")
+	require.True(ok)
+	assert.Equal("hello", string(obj.Data))
+	assert.Equal("text/plain", obj.Header.Get("Content-Type"))
+	assert.Equal("me", obj.Header.Get("X-Amz-Meta-Owner"))
+
+	assert.Equal(straw.ErrNotSupported, ss.Chmod("/dir", 0700))
+	assert.True(os.IsNotExist(ss.Chmod("/missing", 0600)))
+}
+
+func TestContentHash(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, _, closeFn := newTestStreamStore(t, "")
+	defer closeFn()
+
+	putTestObject(t, ss, "a", []byte("hello"))
+
+	sum, err := ss.ContentHash("/a")
+	require.NoError(err)
+	assert.Equal("5d41402abc4b2a76b9719d911017c592", sum)
+
+	putTestObject(t, ss, "a", []byte("jello"))
+	changed, err := straw.ContentHash(ss, "/a")
+	require.NoError(err)
+	assert.NotEqual(sum, changed)
+
+	_, err = ss.ContentHash("/missing")
+	assert.True(errors.Is(err, os.ErrNotExist))
+	var pe *os.PathError
+	require.True(errors.As(err, &pe))
+	assert.Equal("/missing", pe.Path)
+}
+
+func TestSectionReader(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, srv, closeFn := newTestStreamStore(t, "")
+	defer closeFn()
+
+	putTestObject(t, ss, "a", []byte("0123456789"))
+
+	r, err := ss.OpenReadCloser("/a")
+	require.NoError(err)
+	defer r.Close()
+	sr := straw.NewSectionReader(r, 2, 5)
+
+	before := objectGets(srv, "a")
+	data, err := ioutil.ReadAll(sr)
+	require.NoError(err)
+	assert.Equal("23456", string(data))
+	// the section is read with one range request.
+	assert.Equal(before+1, objectGets(srv, "a"))
+}
+
+func TestTruncate(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, srv, closeFn := newTestStreamStore(t, "")
+	defer closeFn()
+
+	putTestObject(t, ss, "a", []byte("hello"))
+
+	require.NoError(ss.Truncate("/a", 2))
+	obj, ok := srv.Object(testBucket, "a")
+	require.True(ok)
+	assert.Equal("he", string(obj.Data))
+
+	require.NoError(ss.Truncate("/a", 4))
+	obj, ok = srv.Object(testBucket, "a")
+	require.True(ok)
+	assert.Equal([]byte{'h', 'e', 0, 0}, obj.Data)
+
+	require.NoError(ss.Mkdir("/dir", 0755))
+	assert.Error(ss.Truncate("/dir", 0))
+	assert.True(os.IsNotExist(ss.Truncate("/missing", 0)))
+}
+
+func TestOpenFileExclusive(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, srv, closeFn := newTestStreamStore(t, "")
+	defer closeFn()
+
+	f, err := ss.OpenFile("/a", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	require.NoError(err)
+	_, ok := srv.Object(testBucket, "a")
+	assert.True(ok)
+	_, err = f.Write([]byte("hello"))
+	assert.NoError(err)
+	require.NoError(f.Close())
+
+	// an object created by another writer after the existence check is
+	// caught by the conditional PutObject.
+	err = ss.createExclusive("/a")
+	assert.True(os.IsExist(err))
+
+	obj, ok := srv.Object(testBucket, "a")
+	require.True(ok)
+	assert.Equal("hello", string(obj.Data))
+}
+
+func TestCancelUploadAbortsMultipartUpload(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, srv, closeFn := newTestStreamStore(t, "")
+	defer closeFn()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w, err := ss.CreateWriteCloserContext(ctx, "/big")
+	require.NoError(err)
+
+	// more than one part, so a multipart upload is begun.
+	_, err = w.Write(make([]byte, s3manager.DefaultUploadPartSize+1))
+	require.NoError(err)
+
+	cancel()
+	_, err = w.Write([]byte{1})
+	assert.Equal(context.Canceled, err)
+	assert.Error(w.Close())
+
+	var aborts int
+	for _, req := range srv.Requests() {
+		if req.Method == http.MethodDelete && strings.Contains(req.Query, "uploadId") {
+			aborts++
+		}
+	}
+	assert.Equal(1, aborts)
+	assert.Equal(0, srv.Uploads())
+	_, ok := srv.Object(testBucket, "big")
+	assert.False(ok)
+}
+
+func TestPartSize(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	u, _ := url.Parse("s3://" + testBucket + "/")
+	S3PartSize(6 << 20)(u)
+	S3UploadConcurrency(2)(u)
+	ss, srv, closeFn := newTestStreamStore(t, u.RawQuery)
+	defer closeFn()
+
+	data := make([]byte, 13<<20)
+	data[len(data)-1] = 1
+	writeTestFile(t, ss, "/big", data)
+
+	var parts int
+	for _, req := range srv.Requests() {
+		if req.Method == http.MethodPut && strings.Contains(req.Query, "partNumber") {
+			parts++
+		}
+	}
+	assert.Equal(3, parts)
+	obj, ok := srv.Object(testBucket, "big")
+	require.True(ok)
+	assert.Equal(data, obj.Data)
+}
+
+func TestWriterReadFrom(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	u, _ := url.Parse("s3://" + testBucket + "/")
+	S3PartSize(6 << 20)(u)
+	ss, srv, closeFn := newTestStreamStore(t, u.RawQuery)
+	defer closeFn()
+
+	data := make([]byte, 13<<20)
+	data[len(data)-1] = 1
+
+	w, err := ss.CreateWriteCloser("/big")
+	require.NoError(err)
+	_, err = w.Write([]byte("head"))
+	require.NoError(err)
+	n, err := w.(io.ReaderFrom).ReadFrom(struct{ io.Reader }{bytes.NewReader(data)})
+	require.NoError(err)
+	assert.Equal(int64(len(data)), n)
+
+	// an error reading the source is returned by ReadFrom, and what was read
+	// before it is kept.
+	failing := io.MultiReader(strings.NewReader("ab"), errReader{errors.New("broken")})
+	n, err = w.(io.ReaderFrom).ReadFrom(failing)
+	assert.EqualError(err, "broken")
+	assert.Equal(int64(2), n)
+	require.NoError(w.Close())
+
+	obj, ok := srv.Object(testBucket, "big")
+	require.True(ok)
+	assert.Equal(append(append([]byte("head"), data...), "ab"...), obj.Data)
+}
+
+func TestWriterCloseTwice(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, srv, closeFn := newTestStreamStore(t, "")
+	defer closeFn()
+
+	w, err := ss.CreateWriteCloser("/f")
+	require.NoError(err)
+	_, err = w.Write([]byte("data"))
+	require.NoError(err)
+	require.NoError(w.Close())
+	puts := len(srv.Requests())
+
+	done := make(chan error)
+	go func() { done <- w.Close() }()
+	select {
+	case err := <-done:
+		assert.Equal(straw.ErrClosed, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("second Close blocked")
+	}
+	_, err = w.Write([]byte("more"))
+	assert.Equal(straw.ErrClosed, err)
+	_, err = w.(io.ReaderFrom).ReadFrom(strings.NewReader("more"))
+	assert.Equal(straw.ErrClosed, err)
+	assert.Equal(puts, len(srv.Requests()))
+
+	obj, ok := srv.Object(testBucket, "f")
+	require.True(ok)
+	assert.Equal("data", string(obj.Data))
+}
+
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+func TestInvalidPartSize(t *testing.T) {
+	for query, msg := range map[string]string{
+		"part_size=1MiB":         `invalid "part_size" query parameter: 1048576 bytes is less than the minimum of 5242880`,
+		"part_size=big":          `invalid "part_size" query parameter: invalid size "big"`,
+		"upload_concurrency=0":   `invalid "upload_concurrency" query parameter: "0" is not a positive integer`,
+		"upload_concurrency=two": `invalid "upload_concurrency" query parameter: "two" is not a positive integer`,
+	} {
+		_, err := news3StreamStoreWithSession(session.Must(session.NewSession()), &url.URL{
+			Scheme:   "s3",
+			Host:     testBucket,
+			RawQuery: query,
+		})
+		assert.EqualError(t, err, msg)
+	}
+}
+
+func TestCancelledStat(t *testing.T) {
+	ss, _, closeFn := newTestStreamStore(t, "")
+	defer closeFn()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := ss.StatContext(ctx, "/a")
+	assert.Error(t, err)
+}
+
+func TestRemoveAll(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, srv, closeFn := newTestStreamStore(t, "")
+	defer closeFn()
+
+	require.NoError(ss.Mkdir("/a", 0755))
+	require.NoError(ss.Mkdir("/a/b", 0755))
+	writeTestFile(t, ss, "/a/1", []byte{1})
+	writeTestFile(t, ss, "/a/b/2", []byte{2})
+	writeTestFile(t, ss, "/ab", []byte{3})
+
+	require.NoError(straw.RemoveAll(ss, "/a"))
+	assert.Equal([]string{"ab"}, readdirNames(t, ss, "/"))
+
+	var deletes []fakes3.Request
+	for _, req := range srv.Requests() {
+		if req.Method == http.MethodDelete || req.Method == http.MethodPost {
+			deletes = append(deletes, req)
+		}
+	}
+	// one batch for the listing, and one for the name itself.
+	require.Len(deletes, 2)
+	for _, req := range deletes {
+		assert.Equal("delete=", req.Query)
+	}
+
+	require.NoError(straw.RemoveAll(ss, "/ab"))
+	assert.Empty(readdirNames(t, ss, "/"))
+
+	assert.NoError(straw.RemoveAll(ss, "/missing"))
+}
@@ -0,0 +1,30 @@
+package straw
+
+// Schemer is implemented by backends to report the URL scheme they are
+// registered under.
+type Schemer interface {
+	Scheme() string
+}
+
+// Wrapper is implemented by stores which wrap another StreamStore to add
+// behaviour, such as the one returned by NewTransformReader.
+type Wrapper interface {
+	Unwrap() StreamStore
+}
+
+// Scheme returns the URL scheme of the backend behind ss, such as "s3" or
+// "file", looking through any wrappers. This can be used, for example, to
+// determine whether two stores share a backend. An empty string is returned
+// if the backend does not implement Schemer.
+func Scheme(ss StreamStore) string {
+	for {
+		switch s := ss.(type) {
+		case Schemer:
+			return s.Scheme()
+		case Wrapper:
+			ss = s.Unwrap()
+		default:
+			return ""
+		}
+	}
+}
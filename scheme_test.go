@@ -0,0 +1,41 @@
+package straw_test
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+func TestScheme(t *testing.T) {
+	assert := assert.New(t)
+
+	for u, scheme := range map[string]string{
+		"file:///":     "file",
+		"mem://":       "mem",
+		"s3://bucket/": "s3",
+	} {
+		ss, err := straw.Open(u)
+		require.NoError(t, err)
+		assert.Equal(scheme, straw.Scheme(ss))
+	}
+}
+
+func TestSchemeWrapped(t *testing.T) {
+	assert := assert.New(t)
+
+	ss, _ := straw.Open("mem://")
+	ts := straw.NewTransformReader(ss, func(r io.Reader) io.Reader { return r })
+
+	assert.Equal("mem", straw.Scheme(ts))
+}
+
+func TestSchemeUnknown(t *testing.T) {
+	assert := assert.New(t)
+
+	ss, _ := straw.Open("mem://")
+
+	assert.Equal("", straw.Scheme(&TestRecordingStreamStore{wrapped: ss}))
+}
@@ -0,0 +1,91 @@
+package straw
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// NewSectionReader returns a StrawReader which reads the n bytes of r
+// starting at offset off, as io.NewSectionReader does. Offsets given to Seek
+// and ReadAt are relative to the start of the section, and reads end with
+// io.EOF at its end, or at the end of r if that comes first.
+//
+// Read seeks r to the start of the section and reads it sequentially, so the
+// object store backends fetch the section with a single range request, and
+// ReadAt is passed to the ReadAt of r. Close closes r.
+//
+// The reader returned is not safe for concurrent use, and r must not be
+// used directly while it is open.
+func NewSectionReader(r StrawReader, off, n int64) StrawReader {
+	return &sectionReader{r: r, base: off, n: n}
+}
+
+type sectionReader struct {
+	r    StrawReader
+	base int64
+	n    int64
+	// pos is the offset of the next Read within the section, and positioned
+	// reports whether r is at that offset.
+	pos        int64
+	positioned bool
+}
+
+func (sr *sectionReader) Read(p []byte) (int, error) {
+	if sr.pos >= sr.n {
+		return 0, io.EOF
+	}
+	if !sr.positioned {
+		if _, err := sr.r.Seek(sr.base+sr.pos, io.SeekStart); err != nil {
+			return 0, err
+		}
+		sr.positioned = true
+	}
+	if rest := sr.n - sr.pos; int64(len(p)) > rest {
+		p = p[:rest]
+	}
+	n, err := sr.r.Read(p)
+	sr.pos += int64(n)
+	return n, err
+}
+
+func (sr *sectionReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if off >= sr.n {
+		return 0, io.EOF
+	}
+	if rest := sr.n - off; int64(len(p)) > rest {
+		n, err := sr.r.ReadAt(p[:rest], sr.base+off)
+		if err == nil {
+			err = io.EOF
+		}
+		return n, err
+	}
+	return sr.r.ReadAt(p, sr.base+off)
+}
+
+func (sr *sectionReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += sr.pos
+	case io.SeekEnd:
+		offset += sr.n
+	default:
+		return 0, fmt.Errorf("invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, errors.New("invalid seek position")
+	}
+	if offset != sr.pos {
+		sr.positioned = false
+	}
+	sr.pos = offset
+	return offset, nil
+}
+
+func (sr *sectionReader) Close() error {
+	return sr.r.Close()
+}
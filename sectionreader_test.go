@@ -0,0 +1,84 @@
+package straw_test
+
+import (
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+func TestSectionReader(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, _ := straw.Open("mem://")
+	writeContent(t, ss, "/a", []byte("0123456789"))
+
+	r, err := ss.OpenReadCloser("/a")
+	require.NoError(err)
+	sr := straw.NewSectionReader(r, 2, 5)
+
+	data, err := ioutil.ReadAll(sr)
+	require.NoError(err)
+	assert.Equal("23456", string(data))
+
+	buf := make([]byte, 3)
+	n, err := sr.ReadAt(buf, 1)
+	assert.NoError(err)
+	assert.Equal("345", string(buf[:n]))
+	n, err = sr.ReadAt(buf, 3)
+	assert.Equal(io.EOF, err)
+	assert.Equal("56", string(buf[:n]))
+	_, err = sr.ReadAt(buf, 5)
+	assert.Equal(io.EOF, err)
+
+	pos, err := sr.Seek(-2, io.SeekEnd)
+	require.NoError(err)
+	assert.Equal(int64(3), pos)
+	data, err = ioutil.ReadAll(sr)
+	require.NoError(err)
+	assert.Equal("56", string(data))
+
+	_, err = sr.Seek(1, io.SeekStart)
+	require.NoError(err)
+	pos, err = sr.Seek(1, io.SeekCurrent)
+	require.NoError(err)
+	assert.Equal(int64(2), pos)
+	n, err = sr.Read(buf)
+	require.NoError(err)
+	assert.Equal("456", string(buf[:n]))
+
+	_, err = sr.Seek(-1, io.SeekStart)
+	assert.Error(err)
+	_, err = sr.Seek(10, io.SeekStart)
+	require.NoError(err)
+	_, err = sr.Read(buf)
+	assert.Equal(io.EOF, err)
+
+	require.NoError(sr.Close())
+}
+
+func TestSectionReaderBeyondEnd(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, _ := straw.Open("mem://")
+	writeContent(t, ss, "/a", []byte("0123456789"))
+
+	r, err := ss.OpenReadCloser("/a")
+	require.NoError(err)
+	sr := straw.NewSectionReader(r, 8, 5)
+	defer sr.Close()
+
+	data, err := ioutil.ReadAll(sr)
+	require.NoError(err)
+	assert.Equal("89", string(data))
+
+	buf := make([]byte, 5)
+	n, err := sr.ReadAt(buf, 0)
+	assert.Equal(io.EOF, err)
+	assert.Equal("89", string(buf[:n]))
+}
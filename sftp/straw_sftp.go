@@ -1,36 +1,228 @@
 package sftp
 
 import (
+	"context"
 	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"math"
+	"net"
 	"net/url"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/pkg/sftp"
 	"github.com/uw-labs/straw"
+	"github.com/uw-labs/straw/internal/bytesize"
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
 )
 
 var _ straw.StreamStore = &sftpStreamStore{}
+var _ straw.ContextStreamStore = &sftpStreamStore{}
+var _ straw.ContextCloser = &sftpStreamStore{}
 
 // host_key is a base64 encoded public key (e.g. ssh-rsa blah...)
 const hostKeyQueryParam = "host_key"
 
+// known_hosts is the path of an OpenSSH known_hosts file to verify the host
+// key against. One of host_key and known_hosts is required unless
+// insecure_skip_host_key_check is true, which accepts any host key.
+const (
+	knownHostsQueryParam               = "known_hosts"
+	insecureSkipHostKeyCheckQueryParam = "insecure_skip_host_key_check"
+)
+
+// identity_file is the path of a PEM encoded private key to authenticate
+// with, and private_key is such a key itself, base64 encoded. passphrase
+// decrypts either key if it is encrypted. A password in the url is tried
+// after any key.
+const (
+	identityFileQueryParam = "identity_file"
+	privateKeyQueryParam   = "private_key"
+	passphraseQueryParam   = "passphrase"
+)
+
+// agent, if true, authenticates with the keys held by the ssh agent listening
+// on $SSH_AUTH_SOCK, before trying any other method.
+const agentQueryParam = "agent"
+
+// keepalive is the interval at which keepalive requests are sent to the
+// server, such as 30s, so that a dead connection is noticed and re-dialled
+// before the next operation. No requests are sent by default.
+const keepaliveQueryParam = "keepalive"
+
+// jump is the address of a bastion, as user[:password]@host[:port], through
+// which the server is dialled, as with OpenSSH's ProxyJump. The bastion is
+// authenticated and verified with the same query parameters as the server,
+// except host_key, and any parameter prefixed with jump_, such as
+// jump_identity_file, applies to the bastion alone in place of its unprefixed
+// form.
+const (
+	jumpQueryParam  = "jump"
+	jumpParamPrefix = "jump_"
+)
+
+// max_concurrent_requests is the most requests in flight at once when
+// reading or writing a file, and max_packet is the size, such as "32KiB", of
+// the data in each request. Packets larger than 32KiB are not supported by
+// every server.
+const (
+	maxConcurrentRequestsQueryParam = "max_concurrent_requests"
+	maxPacketQueryParam             = "max_packet"
+)
+
+// ciphers, kex and macs are comma separated lists of algorithm names which
+// override the ssh defaults, for servers that require specific algorithms.
+const (
+	ciphersQueryParam = "ciphers"
+	kexQueryParam     = "kex"
+	macsQueryParam    = "macs"
+)
+
+var (
+	// supported algorithms, as implemented by golang.org/x/crypto/ssh
+	supportedCiphers = []string{
+		"aes128-ctr", "aes192-ctr", "aes256-ctr",
+		"aes128-gcm@openssh.com",
+		"chacha20-poly1305@openssh.com",
+		"arcfour256", "arcfour128", "arcfour",
+		"aes128-cbc",
+		"3des-cbc",
+	}
+	supportedKeyExchanges = []string{
+		"curve25519-sha256@libssh.org",
+		"ecdh-sha2-nistp256", "ecdh-sha2-nistp384", "ecdh-sha2-nistp521",
+		"diffie-hellman-group14-sha1", "diffie-hellman-group1-sha1",
+		"diffie-hellman-group-exchange-sha1", "diffie-hellman-group-exchange-sha256",
+	}
+	supportedMACs = []string{
+		"hmac-sha2-256-etm@openssh.com", "hmac-sha2-256",
+		"hmac-sha1", "hmac-sha1-96",
+	}
+)
+
 func init() {
 	straw.Register("sftp", func(u *url.URL) (straw.StreamStore, error) {
 		return newSFTPStreamStore(u.String())
 	})
 }
 
+// SFTPIdentityFile returns an Option which authenticates with the PEM encoded
+// private key in the file at path.
+func SFTPIdentityFile(path string) straw.Option {
+	return straw.QueryOption(identityFileQueryParam, path)
+}
+
+// SFTPPrivateKey returns an Option which authenticates with the PEM encoded
+// private key pemBytes.
+func SFTPPrivateKey(pemBytes []byte) straw.Option {
+	return straw.QueryOption(privateKeyQueryParam, base64.URLEncoding.EncodeToString(pemBytes))
+}
+
+// SFTPPassphrase returns an Option which decrypts the private key given by
+// SFTPIdentityFile or SFTPPrivateKey with passphrase.
+func SFTPPassphrase(passphrase string) straw.Option {
+	return straw.QueryOption(passphraseQueryParam, passphrase)
+}
+
+// SFTPKnownHosts returns an Option which verifies the host key of the server
+// against the OpenSSH known_hosts file at path, such as ~/.ssh/known_hosts.
+func SFTPKnownHosts(path string) straw.Option {
+	return straw.QueryOption(knownHostsQueryParam, path)
+}
+
+// SFTPInsecureSkipHostKeyCheck returns an Option which accepts any host key,
+// leaving the connection open to interception. It is intended for testing.
+func SFTPInsecureSkipHostKeyCheck() straw.Option {
+	return straw.QueryOption(insecureSkipHostKeyCheckQueryParam, "true")
+}
+
+// SFTPAgent returns an Option which authenticates with the keys held by the
+// ssh agent listening on $SSH_AUTH_SOCK.
+func SFTPAgent() straw.Option {
+	return straw.QueryOption(agentQueryParam, "true")
+}
+
+// SFTPKeepalive returns an Option which sends a keepalive request to the
+// server every interval, closing the connection if one is not answered, so
+// that it is re-dialled by the next operation.
+func SFTPKeepalive(interval time.Duration) straw.Option {
+	return straw.QueryOption(keepaliveQueryParam, interval.String())
+}
+
+// SFTPJump returns an Option which dials the server through the bastion at
+// jump, given as user[:password]@host[:port]. opts configure the bastion
+// alone, for example SFTPJump("me@bastion:22", SFTPIdentityFile(path)), and
+// otherwise it uses the same authentication and verification as the server.
+func SFTPJump(jump string, opts ...straw.Option) straw.Option {
+	return func(u *url.URL) {
+		straw.QueryOption(jumpQueryParam, jump)(u)
+		ju := &url.URL{}
+		for _, opt := range opts {
+			opt(ju)
+		}
+		for param, vals := range ju.Query() {
+			for _, val := range vals {
+				straw.QueryOption(jumpParamPrefix+param, val)(u)
+			}
+		}
+	}
+}
+
+// SFTPMaxConcurrentRequests returns an Option allowing up to n requests in
+// flight at once when reading or writing a file. The default is 64.
+func SFTPMaxConcurrentRequests(n int) straw.Option {
+	return straw.QueryOption(maxConcurrentRequestsQueryParam, strconv.Itoa(n))
+}
+
+// SFTPMaxPacket returns an Option setting the most data sent or received in
+// one request. The default is 32768 bytes, the most all servers must support.
+func SFTPMaxPacket(bytes int) straw.Option {
+	return straw.QueryOption(maxPacketQueryParam, strconv.Itoa(bytes))
+}
+
+// SFTPCiphers returns an Option restricting the ciphers offered to the server.
+func SFTPCiphers(ciphers ...string) straw.Option {
+	return straw.QueryOption(ciphersQueryParam, strings.Join(ciphers, ","))
+}
+
+// SFTPKeyExchanges returns an Option restricting the key exchange algorithms
+// offered to the server.
+func SFTPKeyExchanges(kex ...string) straw.Option {
+	return straw.QueryOption(kexQueryParam, strings.Join(kex, ","))
+}
+
+// SFTPMACs returns an Option restricting the MAC algorithms offered to the
+// server.
+func SFTPMACs(macs ...string) straw.Option {
+	return straw.QueryOption(macsQueryParam, strings.Join(macs, ","))
+}
+
 type sftpStreamStore struct {
+	addr      string
+	config    *ssh.ClientConfig
+	jumpAddr  string
+	jumpConf  *ssh.ClientConfig
+	opts      []sftp.ClientOption
+	useAgent  bool
+	keepalive time.Duration
+
+	// lk guards the connection, which is re-established by client if it has
+	// been lost.
+	lk         sync.Mutex
 	sshClient  *ssh.Client
 	sftpClient *sftp.Client
+	dead       bool
+	closed     bool
 }
 
 func newSFTPStreamStore(urlString string) (*sftpStreamStore, error) {
@@ -39,16 +231,277 @@ func newSFTPStreamStore(urlString string) (*sftpStreamStore, error) {
 		return nil, err
 	}
 
-	pass, passSet := u.User.Password()
-	if u.User.Username() == "" || !passSet {
-		return nil, errors.New("username and password are required in the url")
+	config, err := clientConfig(u)
+	if err != nil {
+		return nil, err
+	}
+
+	ss := &sftpStreamStore{addr: u.Host, config: config}
+	if ss.jumpAddr, ss.jumpConf, err = jumpConfig(u); err != nil {
+		return nil, err
+	}
+	ss.useAgent, _ = strconv.ParseBool(u.Query().Get(agentQueryParam))
+	if ka := u.Query().Get(keepaliveQueryParam); ka != "" {
+		if ss.keepalive, err = time.ParseDuration(ka); err != nil || ss.keepalive < 0 {
+			return nil, fmt.Errorf("invalid %q query parameter: %q", keepaliveQueryParam, ka)
+		}
+	}
+
+	if ss.opts, err = clientOptions(u); err != nil {
+		return nil, err
+	}
+
+	if err := ss.dial(); err != nil {
+		return nil, err
+	}
+	return ss, nil
+}
+
+// dial establishes a new connection to the server. It must be called with lk
+// held, or before the store is shared.
+func (s *sftpStreamStore) dial() error {
+	config, jumpConf := *s.config, s.jumpConf
+	if s.useAgent {
+		auth, conn, err := agentAuth()
+		if err != nil {
+			return err
+		}
+		// the agent is only needed until authentication is complete.
+		defer conn.Close()
+		config.Auth = append([]ssh.AuthMethod{auth}, config.Auth...)
+		if jumpConf != nil {
+			jc := *jumpConf
+			jc.Auth = append([]ssh.AuthMethod{auth}, jc.Auth...)
+			jumpConf = &jc
+		}
+	}
+
+	var client, bastion *ssh.Client
+	var err error
+	if jumpConf == nil {
+		if client, err = ssh.Dial("tcp", s.addr, &config); err != nil {
+			return err
+		}
+	} else {
+		if bastion, err = ssh.Dial("tcp", s.jumpAddr, jumpConf); err != nil {
+			return fmt.Errorf("failed to connect to jump host: %w", err)
+		}
+		if client, err = dialVia(bastion, s.addr, &config); err != nil {
+			bastion.Close()
+			return err
+		}
+	}
+
+	sclient, err := sftp.NewClient(client, s.opts...)
+	if err != nil {
+		client.Close()
+		return err
+	}
+
+	s.sshClient, s.sftpClient, s.dead = client, sclient, false
+
+	done := make(chan struct{})
+	go func() {
+		client.Wait()
+		if bastion != nil {
+			bastion.Close()
+		}
+		close(done)
+		s.lk.Lock()
+		if s.sshClient == client {
+			s.dead = true
+		}
+		s.lk.Unlock()
+	}()
+	if s.keepalive > 0 {
+		go keepalive(client, s.keepalive, done)
+	}
+	return nil
+}
+
+// dialVia establishes an ssh connection to addr over a direct-tcpip channel
+// opened through bastion.
+func dialVia(bastion *ssh.Client, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	conn, err := bastion.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// keepalive sends a keepalive request over client every interval until done
+// is closed, closing client if a request fails or is not answered within
+// interval, so that a dead connection is noticed.
+func keepalive(client *ssh.Client, interval time.Duration, done <-chan struct{}) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-t.C:
+		}
+		errc := make(chan error, 1)
+		go func() {
+			_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+			errc <- err
+		}()
+		select {
+		case <-done:
+			return
+		case err := <-errc:
+			if err == nil {
+				continue
+			}
+		case <-time.After(interval):
+		}
+		client.Close()
+		return
+	}
+}
+
+// client returns the sftp client of the current connection, re-dialling the
+// server first if the connection has been lost.
+func (s *sftpStreamStore) client() (*sftp.Client, error) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	if s.closed {
+		return nil, errors.New("sftp: store is closed")
+	}
+	if s.dead {
+		// the sftp client shuts itself down when its connection is lost.
+		s.sshClient.Close()
+		if err := s.dial(); err != nil {
+			return nil, err
+		}
+	}
+	return s.sftpClient, nil
+}
+
+func clientConfig(u *url.URL) (*ssh.ClientConfig, error) {
+	if u.User.Username() == "" {
+		return nil, errors.New("username is required in the url")
+	}
+	auth, err := authMethods(u)
+	if err != nil {
+		return nil, err
+	}
+
+	hkCallback, err := hostKeyCallback(u)
+	if err != nil {
+		return nil, err
 	}
 
-	hkCallback := ssh.InsecureIgnoreHostKey()
+	config := &ssh.ClientConfig{
+		User:            u.User.Username(),
+		Auth:            auth,
+		HostKeyCallback: hkCallback,
+	}
+
+	if config.Ciphers, err = algorithms(u, ciphersQueryParam, supportedCiphers); err != nil {
+		return nil, err
+	}
+	if config.KeyExchanges, err = algorithms(u, kexQueryParam, supportedKeyExchanges); err != nil {
+		return nil, err
+	}
+	if config.MACs, err = algorithms(u, macsQueryParam, supportedMACs); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
 
-	// Check for HostKey and use if found
-	hostKeyEncodedString := u.Query().Get(hostKeyQueryParam)
-	if len(hostKeyEncodedString) > 0 {
+// clientOptions returns the sftp client options given by the
+// max_concurrent_requests and max_packet query parameters.
+func clientOptions(u *url.URL) ([]sftp.ClientOption, error) {
+	q := u.Query()
+	var opts []sftp.ClientOption
+	if m := q.Get(maxConcurrentRequestsQueryParam); m != "" {
+		n, err := strconv.Atoi(m)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid %q query parameter: %q", maxConcurrentRequestsQueryParam, m)
+		}
+		opts = append(opts, sftp.MaxConcurrentRequestsPerFile(n))
+	}
+	if m := q.Get(maxPacketQueryParam); m != "" {
+		size, err := bytesize.Parse(m)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %q query parameter: %w", maxPacketQueryParam, err)
+		}
+		if size < 1 || size > math.MaxUint32 {
+			return nil, fmt.Errorf("invalid %q query parameter: %q", maxPacketQueryParam, m)
+		}
+		opts = append(opts, sftp.MaxPacketUnchecked(int(size)))
+	}
+	return opts, nil
+}
+
+// jumpConfig returns the address and client configuration of the bastion
+// given by the jump query parameter, or a nil configuration if there is none.
+func jumpConfig(u *url.URL) (string, *ssh.ClientConfig, error) {
+	q := u.Query()
+	jump := q.Get(jumpQueryParam)
+	if jump == "" {
+		return "", nil, nil
+	}
+	ju, err := url.Parse("ssh://" + jump)
+	if err != nil || ju.Hostname() == "" || ju.Path != "" || ju.RawQuery != "" {
+		return "", nil, fmt.Errorf("invalid %q query parameter: %q", jumpQueryParam, jump)
+	}
+	jq := url.Values{}
+	for param, vals := range q {
+		if param != jumpQueryParam && param != hostKeyQueryParam && !strings.HasPrefix(param, jumpParamPrefix) {
+			jq[param] = vals
+		}
+	}
+	// a bastion's key replaces any inherited key, and likewise for the ways
+	// to verify it, as only one of each may be given.
+	for _, group := range [][]string{
+		{identityFileQueryParam, privateKeyQueryParam, passphraseQueryParam},
+		{hostKeyQueryParam, knownHostsQueryParam, insecureSkipHostKeyCheckQueryParam},
+	} {
+		for _, param := range group {
+			if q.Get(jumpParamPrefix+param) != "" {
+				for _, inherited := range group {
+					jq.Del(inherited)
+				}
+				break
+			}
+		}
+	}
+	for param, vals := range q {
+		if strings.HasPrefix(param, jumpParamPrefix) {
+			jq[strings.TrimPrefix(param, jumpParamPrefix)] = vals
+		}
+	}
+	ju.RawQuery = jq.Encode()
+	config, err := clientConfig(ju)
+	if err != nil {
+		return "", nil, fmt.Errorf("jump host: %w", err)
+	}
+	addr := ju.Host
+	if ju.Port() == "" {
+		addr = net.JoinHostPort(ju.Hostname(), "22")
+	}
+	return addr, config, nil
+}
+
+// hostKeyCallback returns the callback verifying the host key of the server
+// as described by the host_key, known_hosts and insecure_skip_host_key_check
+// query parameters.
+func hostKeyCallback(u *url.URL) (ssh.HostKeyCallback, error) {
+	q := u.Query()
+	hostKeyEncodedString := q.Get(hostKeyQueryParam)
+	knownHostsFile := q.Get(knownHostsQueryParam)
+	switch {
+	case hostKeyEncodedString != "" && knownHostsFile != "":
+		return nil, fmt.Errorf("only one of %q and %q may be given", hostKeyQueryParam, knownHostsQueryParam)
+	case hostKeyEncodedString != "":
 		decoded, err := base64.URLEncoding.DecodeString(hostKeyEncodedString)
 		if err != nil {
 			return nil, fmt.Errorf("failed to decode %q query parameter: %w ", hostKeyQueryParam, err)
@@ -57,34 +510,113 @@ func newSFTPStreamStore(urlString string) (*sftpStreamStore, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed parsing key, err: %v", err)
 		}
-		hkCallback = ssh.FixedHostKey(hostKey)
+		return ssh.FixedHostKey(hostKey), nil
+	case knownHostsFile != "":
+		callback, err := knownhosts.New(knownHostsFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %q: %w", knownHostsQueryParam, err)
+		}
+		return callback, nil
 	}
-
-	config := &ssh.ClientConfig{
-		User: u.User.Username(),
-		Auth: []ssh.AuthMethod{
-			ssh.Password(pass),
-		},
-		HostKeyCallback: hkCallback,
+	if skip, _ := strconv.ParseBool(q.Get(insecureSkipHostKeyCheckQueryParam)); skip {
+		return ssh.InsecureIgnoreHostKey(), nil
 	}
+	return nil, fmt.Errorf("one of the %q or %q query parameters is required to verify the server, or set %q to skip verification", hostKeyQueryParam, knownHostsQueryParam, insecureSkipHostKeyCheckQueryParam)
+}
 
-	client, err := ssh.Dial("tcp", u.Host, config)
+// agentAuth connects to the ssh agent listening on $SSH_AUTH_SOCK, returning
+// an AuthMethod using its keys, and the connection, which must be kept open
+// until authentication is complete.
+func agentAuth() (ssh.AuthMethod, io.Closer, error) {
+	sock := os.Getenv("SSH_AUTH_SOCK")
+	if sock == "" {
+		return nil, nil, fmt.Errorf("%q query parameter set, but SSH_AUTH_SOCK is not", agentQueryParam)
+	}
+	conn, err := net.Dial("unix", sock)
 	if err != nil {
-		return nil, err
+		return nil, nil, fmt.Errorf("failed to connect to ssh agent: %w", err)
 	}
+	return ssh.PublicKeysCallback(agent.NewClient(conn).Signers), conn, nil
+}
 
-	sclient, err := sftp.NewClient(client)
-	if err != nil {
-		client.Close()
-		return nil, err
+// authMethods returns the ways to authenticate given in u, which are a
+// private key, from the identity_file or private_key query parameter, and a
+// password in the user info, in that order.
+func authMethods(u *url.URL) ([]ssh.AuthMethod, error) {
+	q := u.Query()
+	var pemBytes []byte
+	switch {
+	case q.Get(identityFileQueryParam) != "" && q.Get(privateKeyQueryParam) != "":
+		return nil, fmt.Errorf("only one of %q and %q may be given", identityFileQueryParam, privateKeyQueryParam)
+	case q.Get(identityFileQueryParam) != "":
+		var err error
+		if pemBytes, err = ioutil.ReadFile(q.Get(identityFileQueryParam)); err != nil {
+			return nil, fmt.Errorf("failed to read %q: %w", identityFileQueryParam, err)
+		}
+	case q.Get(privateKeyQueryParam) != "":
+		var err error
+		if pemBytes, err = base64.URLEncoding.DecodeString(q.Get(privateKeyQueryParam)); err != nil {
+			return nil, fmt.Errorf("failed to decode %q query parameter: %w", privateKeyQueryParam, err)
+		}
 	}
 
-	ss := &sftpStreamStore{client, sclient}
+	var methods []ssh.AuthMethod
+	if pemBytes != nil {
+		var signer ssh.Signer
+		var err error
+		if passphrase := q.Get(passphraseQueryParam); passphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(pemBytes, []byte(passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(pemBytes)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed parsing private key: %w", err)
+		}
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+	if pass, ok := u.User.Password(); ok {
+		methods = append(methods, ssh.Password(pass))
+	}
+	if len(methods) == 0 {
+		if useAgent, _ := strconv.ParseBool(q.Get(agentQueryParam)); !useAgent {
+			return nil, errors.New("a password, private key or agent is required to authenticate")
+		}
+	}
+	return methods, nil
+}
 
-	return ss, nil
+// algorithms parses the comma separated list of algorithms in the named query
+// parameter, checking each against the supported list. It returns nil if the
+// parameter is not set, so that the ssh defaults apply.
+func algorithms(u *url.URL, param string, supported []string) ([]string, error) {
+	val := u.Query().Get(param)
+	if val == "" {
+		return nil, nil
+	}
+	var algos []string
+	for _, algo := range strings.Split(val, ",") {
+		algo = strings.TrimSpace(algo)
+		if !contains(supported, algo) {
+			return nil, fmt.Errorf("unsupported algorithm %q in %q query parameter", algo, param)
+		}
+		algos = append(algos, algo)
+	}
+	return algos, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
 }
 
 func (s *sftpStreamStore) Close() error {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	s.closed = true
 	e1 := s.sftpClient.Close()
 	e2 := s.sshClient.Close()
 	if e1 != nil {
@@ -93,74 +625,469 @@ func (s *sftpStreamStore) Close() error {
 	return e2
 }
 
+// CloseContext closes the store, tearing down the underlying connection if
+// that does not complete before ctx is done, which aborts any in-flight
+// requests.
+func (s *sftpStreamStore) CloseContext(ctx context.Context) error {
+	s.lk.Lock()
+	sshClient := s.sshClient
+	s.lk.Unlock()
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Close()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		sshClient.Close()
+		return ctx.Err()
+	}
+}
+
+func (s *sftpStreamStore) Scheme() string {
+	return "sftp"
+}
+
+// Lstat asks the server not to follow symbolic links.
 func (s *sftpStreamStore) Lstat(filename string) (os.FileInfo, error) {
-	return s.sftpClient.Lstat(filename)
+	filename, err := straw.CleanPath(filename)
+	if err != nil {
+		return nil, err
+	}
+	c, err := s.client()
+	if err != nil {
+		return nil, err
+	}
+	fi, err := c.Lstat(filename)
+	return fi, pathError("lstat", filename, err)
 }
 
 func (s *sftpStreamStore) Stat(filename string) (os.FileInfo, error) {
-	return s.sftpClient.Stat(filename)
+	filename, err := straw.CleanPath(filename)
+	if err != nil {
+		return nil, err
+	}
+	c, err := s.client()
+	if err != nil {
+		return nil, err
+	}
+	fi, err := c.Stat(filename)
+	return fi, pathError("stat", filename, err)
 }
 
 func (s *sftpStreamStore) Mkdir(path string, mode os.FileMode) error {
-	err := s.sftpClient.Mkdir(path)
+	path, err := straw.CleanPath(path)
+	if err != nil {
+		return err
+	}
+	c, err := s.client()
+	if err != nil {
+		return err
+	}
+	err = c.Mkdir(path)
 	if err != nil && strings.Contains(err.Error(), ": file exists") {
 		d, _ := filepath.Split(path)
 		return fmt.Errorf("%s file exists", d)
 	}
-	return err
+	return pathError("mkdir", path, err)
 }
 
 func (s *sftpStreamStore) OpenReadCloser(name string) (straw.StrawReader, error) {
-	sr, err := s.sftpClient.Open(name)
+	return s.OpenReadCloserContext(context.Background(), name)
+}
+
+// OpenReadCloserContext opens the named file. Cancelling ctx fails subsequent
+// reads, and interrupts a ReadAt between chunks.
+func (s *sftpStreamStore) OpenReadCloserContext(ctx context.Context, name string) (straw.StrawReader, error) {
+	name, err := straw.CleanPath(name)
 	if err != nil {
 		return nil, err
 	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c, err := s.client()
+	if err != nil {
+		return nil, err
+	}
+	sr, err := c.Open(name)
+	if err != nil {
+		return nil, pathError("open", name, err)
+	}
 	fi, err := sr.Stat()
 	if err != nil {
 		sr.Close()
-		return nil, err
+		return nil, pathError("open", name, err)
 	}
 	if fi.IsDir() {
 		sr.Close()
-		return nil, fmt.Errorf("%s is a directory", name)
+		return nil, &os.PathError{Op: "open", Path: name, Err: straw.ErrIsDirectory}
 	}
-	return &sftpReader{f: sr}, nil
+	return &sftpReader{ctx: ctx, f: sr}, nil
 }
 
 func (s *sftpStreamStore) Remove(name string) error {
-	err := s.sftpClient.Remove(name)
-	if err != nil && strings.Contains(err.Error(), ": directory not empty") {
-		return fmt.Errorf("%s directory not empty", name)
+	name, err := straw.CleanPath(name)
+	if err != nil {
+		return err
 	}
-	return err
+	c, err := s.client()
+	if err != nil {
+		return err
+	}
+	return pathError("remove", name, c.Remove(name))
+}
+
+// OpenFile opens the named file with the given flags. SFTP has no way to give
+// the mode of a new file when opening it, so files created by OpenFile are
+// given perm with a separate request. Servers are not required to honour
+// os.O_APPEND, so files opened with it find the end of the file before each
+// Write.
+func (s *sftpStreamStore) OpenFile(name string, flag int, perm os.FileMode) (straw.StrawReadWriteCloser, error) {
+	name, err := straw.CleanPath(name)
+	if err != nil {
+		return nil, err
+	}
+	c, err := s.client()
+	if err != nil {
+		return nil, err
+	}
+	var created bool
+	if flag&os.O_CREATE != 0 {
+		_, err := c.Stat(name)
+		created = os.IsNotExist(err)
+		if !created && err == nil && flag&os.O_EXCL != 0 {
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrExist}
+		}
+	}
+	f, err := c.OpenFile(name, flag)
+	if err != nil {
+		if flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL {
+			// the file was created by another writer since the Stat above.
+			if _, serr := c.Stat(name); serr == nil {
+				return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrExist}
+			}
+		}
+		return nil, pathError("open", name, err)
+	}
+	if created {
+		if err := c.Chmod(name, perm); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return &sftpFile{sftpReader{ctx: context.Background(), f: f}, flag&os.O_APPEND != 0}, nil
 }
 
 func (s *sftpStreamStore) CreateWriteCloser(name string) (straw.StrawWriter, error) {
+	return s.CreateWriteCloserContext(context.Background(), name)
+}
+
+// CreateWriteCloserContext creates the named file. Cancelling ctx fails
+// subsequent writes, interrupting large writes between chunks, and the
+// partially written file is removed when the writer is closed.
+func (s *sftpStreamStore) CreateWriteCloserContext(ctx context.Context, name string) (straw.StrawWriter, error) {
+	name, err := straw.CleanPath(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	fi, err := s.Stat(name)
 	if err == nil && fi.IsDir() {
-		return nil, fmt.Errorf("%s is a directory", name)
+		return nil, &os.PathError{Op: "open", Path: name, Err: straw.ErrIsDirectory}
 	}
 
-	sw, err := s.sftpClient.Create(name)
-	if err != nil && strings.Contains(err.Error(), ": not a directory") {
-		d, _ := filepath.Split(name)
-		return nil, fmt.Errorf("%s not a directory", d)
+	c, err := s.client()
+	if err != nil {
+		return nil, err
+	}
+	sw, err := c.Create(name)
+	if err != nil {
+		return nil, pathError("open", name, err)
 	}
-	return sw, nil
+	return &sftpWriter{ctx: ctx, f: sw, client: c, name: name}, nil
 }
 
 func (s *sftpStreamStore) Readdir(name string) ([]os.FileInfo, error) {
-	fi, err := s.sftpClient.ReadDir(name)
+	name, err := straw.CleanPath(name)
+	if err != nil {
+		return nil, err
+	}
+	c, err := s.client()
 	if err != nil {
 		return nil, err
 	}
+	fi, err := c.ReadDir(name)
+	if err != nil {
+		return nil, pathError("readdir", name, err)
+	}
 	sort.Slice(fi, func(i, j int) bool { return fi[i].Name() < fi[j].Name() })
 	return fi, nil
 }
 
+// Copy streams the content of src to dst through the client, as SFTP has no
+// widely supported server side copy.
+func (s *sftpStreamStore) Copy(src, dst string) error {
+	return s.CopyContext(context.Background(), src, dst)
+}
+
+func (s *sftpStreamStore) CopyContext(ctx context.Context, src, dst string) error {
+	src, err := straw.CleanPath(src)
+	if err != nil {
+		return err
+	}
+	dst, err = straw.CleanPath(dst)
+	if err != nil {
+		return err
+	}
+
+	r, err := s.OpenReadCloserContext(ctx, src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if src == dst {
+		return nil
+	}
+
+	w, err := s.CreateWriteCloserContext(ctx, dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// contextChunkSize is the most data read or written in one go by readers and
+// writers bound to a context, which is checked for cancellation in between.
+const contextChunkSize = 1 << 20
+
+// LstatContext returns information about the named file, unless ctx is
+// already done.
+func (s *sftpStreamStore) LstatContext(ctx context.Context, filename string) (os.FileInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.Lstat(filename)
+}
+
+// StatContext returns information about the named file, unless ctx is
+// already done.
+func (s *sftpStreamStore) StatContext(ctx context.Context, filename string) (os.FileInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.Stat(filename)
+}
+
+// ReaddirContext lists the named directory, unless ctx is already done.
+func (s *sftpStreamStore) ReaddirContext(ctx context.Context, name string) ([]os.FileInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.Readdir(name)
+}
+
+// MkdirContext creates the named directory, unless ctx is already done.
+func (s *sftpStreamStore) MkdirContext(ctx context.Context, path string, mode os.FileMode) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Mkdir(path, mode)
+}
+
+// RemoveContext removes the named file or directory, unless ctx is already
+// done.
+func (s *sftpStreamStore) RemoveContext(ctx context.Context, name string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Remove(name)
+}
+
+func (s *sftpStreamStore) Chmod(name string, mode os.FileMode) error {
+	name, err := straw.CleanPath(name)
+	if err != nil {
+		return err
+	}
+	c, err := s.client()
+	if err != nil {
+		return err
+	}
+	return pathError("chmod", name, c.Chmod(name, mode))
+}
+
+func (s *sftpStreamStore) ChmodContext(ctx context.Context, name string, mode os.FileMode) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Chmod(name, mode)
+}
+
+func (s *sftpStreamStore) Truncate(name string, size int64) error {
+	name, err := straw.CleanPath(name)
+	if err != nil {
+		return err
+	}
+	c, err := s.client()
+	if err != nil {
+		return err
+	}
+	return pathError("truncate", name, c.Truncate(name, size))
+}
+
+func (s *sftpStreamStore) Symlink(oldname, newname string) error {
+	newname, err := straw.CleanPath(newname)
+	if err != nil {
+		return err
+	}
+	c, err := s.client()
+	if err != nil {
+		return err
+	}
+	return pathError("symlink", newname, c.Symlink(oldname, newname))
+}
+
+func (s *sftpStreamStore) Readlink(name string) (string, error) {
+	name, err := straw.CleanPath(name)
+	if err != nil {
+		return "", err
+	}
+	c, err := s.client()
+	if err != nil {
+		return "", err
+	}
+	target, err := c.ReadLink(name)
+	return target, pathError("readlink", name, err)
+}
+
+// pathError returns err, from the sftp client, as an *os.PathError for op on
+// name, as the os package would for a local file. The client returns
+// os.ErrNotExist without the path, and other failures as status errors with
+// the server's message, which are recognised for straw.ErrIsDirectory,
+// straw.ErrNotDirectory and straw.ErrDirectoryNotEmpty.
+func pathError(op, name string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*os.PathError); ok {
+		return err
+	}
+	switch msg := err.Error(); {
+	case os.IsNotExist(err):
+		err = os.ErrNotExist
+	case strings.Contains(msg, "is a directory"):
+		err = straw.ErrIsDirectory
+	case strings.Contains(msg, "not a directory"):
+		err = straw.ErrNotDirectory
+	case strings.Contains(msg, "directory not empty"):
+		err = straw.ErrDirectoryNotEmpty
+	}
+	return &os.PathError{Op: op, Path: name, Err: err}
+}
+
+// sftpWriter writes to a file until its context is cancelled, at which point
+// the file is removed on Close.
+type sftpWriter struct {
+	ctx    context.Context
+	f      *sftp.File
+	client *sftp.Client
+	name   string
+	closed bool
+}
+
+func (w *sftpWriter) Write(buf []byte) (int, error) {
+	if w.closed {
+		return 0, straw.ErrClosed
+	}
+	if w.ctx.Done() == nil {
+		// ctx can never be cancelled, so there is nothing to check.
+		return w.f.Write(buf)
+	}
+	var n int
+	for n < len(buf) {
+		if err := w.ctx.Err(); err != nil {
+			return n, err
+		}
+		end := n + contextChunkSize
+		if end > len(buf) {
+			end = len(buf)
+		}
+		i, err := w.f.Write(buf[n:end])
+		n += i
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// ReadFrom writes the content of r in chunks, each of which is sent as
+// concurrent requests, checking the context between them.
+func (w *sftpWriter) ReadFrom(r io.Reader) (int64, error) {
+	if w.closed {
+		return 0, straw.ErrClosed
+	}
+	if w.ctx.Done() == nil {
+		return w.f.ReadFrom(r)
+	}
+	buf := make([]byte, contextChunkSize)
+	var n int64
+	for {
+		if err := w.ctx.Err(); err != nil {
+			return n, err
+		}
+		i, err := io.ReadFull(r, buf)
+		if i > 0 {
+			j, werr := w.f.Write(buf[:i])
+			n += int64(j)
+			if werr != nil {
+				return n, werr
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+	}
+}
+
+// WriteAt writes buf at offset, leaving the offset used by Write unchanged.
+func (w *sftpWriter) WriteAt(buf []byte, offset int64) (int, error) {
+	if w.closed {
+		return 0, straw.ErrClosed
+	}
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return writeAt(w.f, buf, offset)
+}
+
+func (w *sftpWriter) Close() error {
+	if w.closed {
+		return straw.ErrClosed
+	}
+	w.closed = true
+	err := w.f.Close()
+	if ctxErr := w.ctx.Err(); ctxErr != nil {
+		w.client.Remove(w.name)
+		return ctxErr
+	}
+	return err
+}
+
 type sftpReader struct {
-	lk sync.Mutex
-	f  *sftp.File
+	lk  sync.Mutex
+	ctx context.Context
+	f   *sftp.File
 }
 
 func (r *sftpReader) Close() error {
@@ -172,14 +1099,85 @@ func (r *sftpReader) Close() error {
 func (r *sftpReader) Read(buf []byte) (int, error) {
 	r.lk.Lock()
 	defer r.lk.Unlock()
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	if len(buf) > contextChunkSize {
+		buf = buf[:contextChunkSize]
+	}
 	return r.f.Read(buf)
 }
 
+// WriteTo writes the rest of the file to w, keeping several reads in flight
+// at once, which is much faster than a series of small Reads over a high
+// latency link. io.Copy uses it automatically.
+func (r *sftpReader) WriteTo(w io.Writer) (int64, error) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	if r.ctx.Done() == nil {
+		start, err := r.f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, err
+		}
+		n, err := r.f.WriteTo(w)
+		// the sftp package version in use does not advance the offset.
+		if _, serr := r.f.Seek(start+n, io.SeekStart); serr != nil && err == nil {
+			err = serr
+		}
+		return n, err
+	}
+	// large Reads are also split into concurrent requests, and leave the
+	// context to be checked between them.
+	buf := make([]byte, contextChunkSize)
+	var n int64
+	for {
+		if err := r.ctx.Err(); err != nil {
+			return n, err
+		}
+		i, err := r.f.Read(buf)
+		if i > 0 {
+			j, werr := w.Write(buf[:i])
+			n += int64(j)
+			if werr != nil {
+				return n, werr
+			}
+		}
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+	}
+}
+
+// Seek sets the offset of the next Read, rejecting negative offsets, which
+// the sftp package accepts.
 func (r *sftpReader) Seek(offset int64, whence int) (int64, error) {
 	r.lk.Lock()
 	defer r.lk.Unlock()
 
-	return r.f.Seek(offset, whence)
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		cur, err := r.f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, err
+		}
+		offset += cur
+	case io.SeekEnd:
+		fi, err := r.f.Stat()
+		if err != nil {
+			return 0, err
+		}
+		offset += fi.Size()
+	default:
+		return 0, fmt.Errorf("%s : invalid whence %d", r.f.Name(), whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("%s : negative position", r.f.Name())
+	}
+	return r.f.Seek(offset, io.SeekStart)
 }
 
 func (r *sftpReader) ReadAt(buf []byte, offset int64) (int, error) {
@@ -198,10 +1196,7 @@ func (r *sftpReader) ReadAt(buf []byte, offset int64) (int, error) {
 	if off != offset {
 		panic("bug in offset handling")
 	}
-	j, err := io.ReadFull(r.f, buf)
-	if err == io.ErrUnexpectedEOF {
-		err = io.EOF
-	}
+	j, err := r.readFull(buf)
 
 	// put the original offset back
 	if _, err := r.f.Seek(oldOffset, io.SeekStart); err != nil {
@@ -210,3 +1205,72 @@ func (r *sftpReader) ReadAt(buf []byte, offset int64) (int, error) {
 
 	return j, err
 }
+
+// sftpFile is a file opened with OpenFile, which adds writing to the ReadAt
+// support of sftpReader.
+type sftpFile struct {
+	sftpReader
+	append bool
+}
+
+func (f *sftpFile) Write(buf []byte) (int, error) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	if f.append {
+		if _, err := f.f.Seek(0, io.SeekEnd); err != nil {
+			return 0, err
+		}
+	}
+	return f.f.Write(buf)
+}
+
+func (f *sftpFile) WriteAt(buf []byte, offset int64) (int, error) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	return writeAt(f.f, buf, offset)
+}
+
+// writeAt writes buf to f at offset, restoring the offset of f afterwards,
+// as the sftp package version in use does not implement io.WriterAt.
+func writeAt(f *sftp.File, buf []byte, offset int64) (int, error) {
+	if offset < 0 {
+		return 0, errors.New("negative offset")
+	}
+	oldOffset, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := f.Write(buf)
+	if _, serr := f.Seek(oldOffset, io.SeekStart); serr != nil && err == nil {
+		err = serr
+	}
+	return n, err
+}
+
+// readFull reads len(buf) bytes as io.ReadFull does, returning io.EOF if
+// there are too few, and checking whether the reader's context has been
+// cancelled between chunks.
+func (r *sftpReader) readFull(buf []byte) (int, error) {
+	var n int
+	for n < len(buf) {
+		if err := r.ctx.Err(); err != nil {
+			return n, err
+		}
+		end := n + contextChunkSize
+		if end > len(buf) {
+			end = len(buf)
+		}
+		i, err := io.ReadFull(r.f, buf[n:end])
+		n += i
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
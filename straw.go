@@ -3,9 +3,11 @@ package straw
 import (
 	"io"
 	"os"
-	"syscall"
 )
 
+// StrawReader is a file opened with OpenReadCloser. Seek supports every
+// whence, fails for negative offsets, and allows seeking past the end, after
+// which Read returns io.EOF.
 type StrawReader interface {
 	io.Reader
 	io.Closer
@@ -13,20 +15,75 @@ type StrawReader interface {
 	io.Seeker
 }
 
+// StrawWriter is a file created with CreateWriteCloser. Writers from stores
+// which can write at arbitrary offsets in place, which are the local
+// filesystem, mem and sftp, also implement io.WriterAt, so a type assertion
+// reports whether random access writes are available. Object stores cannot
+// modify part of an object, so their writers do not; use OpenFile, which
+// holds the file in memory until Close, instead.
+//
+// Once a writer has been closed, further calls to Close, Write and WriteAt
+// do nothing but return an error wrapping ErrClosed.
 type StrawWriter interface {
 	io.Writer
 	io.Closer
 }
 
+// StrawReadWriteCloser is a file opened with OpenFile, which may be read and
+// written according to the flags it was opened with. WriteAt zero fills any
+// gap between the end of the file and off.
+type StrawReadWriteCloser interface {
+	StrawReader
+	io.Writer
+	io.WriterAt
+}
+
 type StreamStore interface {
 	Close() error
 	OpenReadCloser(name string) (StrawReader, error)
+	// CreateWriteCloser creates the named file, replacing it if it exists.
+	// Writers for object stores, mem, and the local filesystem with atomic
+	// writes, replace the file only on Close, so the file is never seen half
+	// written, and of concurrent writers to the same name the last to close
+	// wins. Other local filesystem writers and sftp writers truncate the
+	// file and write to it in place, so concurrent writers interleave.
 	CreateWriteCloser(name string) (StrawWriter, error)
+	// OpenFile opens the named file with the given flags, which are those of
+	// os.OpenFile, creating it with perm if it does not exist and
+	// os.O_CREATE is given. os.O_CREATE|os.O_EXCL fails with an error
+	// satisfying os.IsExist if the file exists. Backends other than the
+	// local filesystem and sftp cannot modify files in place, so hold the
+	// content of files opened for writing in memory, reading any existing
+	// content when the file is opened unless os.O_TRUNC is given, and
+	// rewrite the whole file on Close. They ignore perm.
+	OpenFile(name string, flag int, perm os.FileMode) (StrawReadWriteCloser, error)
+	// Lstat returns information about the named file, describing a
+	// symbolic link itself rather than the file it refers to. Backends
+	// without symbolic links, which are all but the local filesystem, sftp,
+	// ftp and archives, return the same as Stat.
 	Lstat(path string) (os.FileInfo, error)
+	// Stat returns information about the named file, following symbolic
+	// links.
 	Stat(path string) (os.FileInfo, error)
+	// Readdir lists the named directory. Every backend returns the entries
+	// sorted by name in byte order, whatever order its storage lists them in.
 	Readdir(path string) ([]os.FileInfo, error)
 	Mkdir(path string, mode os.FileMode) error
 	Remove(path string) error
+	// Chmod changes the permission bits of the named file or directory to
+	// those of mode. Object store backends record the mode of files in
+	// object metadata, and return ErrNotSupported for directories.
+	Chmod(name string, mode os.FileMode) error
+	// Truncate changes the size of the named file, discarding content past
+	// size or extending it with zeros, as os.Truncate does. Object store
+	// backends read the whole object and rewrite it, so truncation takes
+	// time proportional to the size of the file and is not atomic.
+	Truncate(name string, size int64) error
+	// Copy copies the file src to dst, replacing dst if it exists. Object
+	// store backends perform the copy server side, preserving the content
+	// type and user metadata of src, and other backends stream the content.
+	// It is an error for src or dst to be a directory.
+	Copy(src, dst string) error
 }
 
 func MkdirAll(ss StreamStore, path string, perm os.FileMode) error {
@@ -36,7 +93,7 @@ func MkdirAll(ss StreamStore, path string, perm os.FileMode) error {
 		if dir.IsDir() {
 			return nil
 		}
-		return &os.PathError{"mkdir", path, syscall.ENOTDIR}
+		return &os.PathError{Op: "mkdir", Path: path, Err: ErrNotDirectory}
 	}
 
 	// Slow path: make sure parent exists and then call Mkdir for path.
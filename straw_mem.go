@@ -2,34 +2,96 @@ package straw
 
 import (
 	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/url"
 	"os"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/uw-labs/straw/internal/bytesize"
 )
 
 var _ StreamStore = &memStreamStore{}
+var _ MemSnapshotter = &memStreamStore{}
+var _ Hasher = &memStreamStore{}
+
+// MemSnapshotter is implemented by mem stores, allowing their whole tree to be
+// saved and later reloaded, for example to reset a test fixture between cases.
+type MemSnapshotter interface {
+	// Snapshot serializes the names, contents, modes and modification times
+	// of every file and directory in the store.
+	Snapshot() ([]byte, error)
+	// Restore replaces the contents of the store with those of a snapshot.
+	Restore(snapshot []byte) error
+}
+
+// max_bytes caps the total size, such as "100MiB", of the files in a mem
+// store. Writes which would exceed it fail with ErrNoSpace, unless evict is
+// "lru", in which case the least recently read or written files are removed
+// to make room.
+const (
+	memMaxBytesQueryParam = "max_bytes"
+	memEvictQueryParam    = "evict"
+)
 
 func init() {
 	Register("mem", func(u *url.URL) (StreamStore, error) {
-		return newMemStreamStore(), nil
+		fs := newMemStreamStore()
+		q := u.Query()
+		if m := q.Get(memMaxBytesQueryParam); m != "" {
+			var err error
+			if fs.maxBytes, err = bytesize.Parse(m); err != nil {
+				return nil, fmt.Errorf("invalid %q query parameter: %w", memMaxBytesQueryParam, err)
+			}
+		}
+		switch e := q.Get(memEvictQueryParam); e {
+		case "":
+		case "lru":
+			fs.evict = true
+		default:
+			return nil, fmt.Errorf("invalid %q query parameter: %q", memEvictQueryParam, e)
+		}
+		return fs, nil
 	})
 }
 
+// MemMaxBytes returns an Option capping the total size of the files in a mem
+// store at n bytes. Writes which would exceed it fail with an error wrapping
+// ErrNoSpace.
+func MemMaxBytes(n int64) Option {
+	return QueryOption(memMaxBytesQueryParam, strconv.FormatInt(n, 10))
+}
+
+// MemEvictLRU returns an Option which makes a mem store with MemMaxBytes
+// remove its least recently read or written files to make room for writes,
+// rather than failing them.
+func MemEvictLRU() Option {
+	return QueryOption(memEvictQueryParam, "lru")
+}
+
 func newMemStreamStore() *memStreamStore {
 	return &memStreamStore{Root: &memFile{
 		IsDir_: true,
+		Perm:   0755,
 	}}
 }
 
 type memStreamStore struct {
 	lk   sync.Mutex
 	Root *memFile
+
+	maxBytes int64 // no limit if zero
+	evict    bool
+	used     int64
+	clock    uint64 // ticks on every use of a file, for eviction
 }
 
 type memFile struct {
@@ -38,6 +100,9 @@ type memFile struct {
 	IsDir_  bool
 	Entries map[string]*memFile
 	Modtime time.Time
+	Perm    os.FileMode
+
+	lastUse uint64
 }
 
 func (mf *memFile) IsDir() bool {
@@ -50,9 +115,9 @@ func (mf *memFile) ModTime() time.Time {
 
 func (mf *memFile) Mode() os.FileMode {
 	if mf.IsDir_ {
-		return os.FileMode(0755) | os.ModeDir
+		return mf.Perm | os.ModeDir
 	}
-	return os.FileMode(0644)
+	return mf.Perm
 }
 
 func (mf *memFile) Name() string {
@@ -70,26 +135,56 @@ func (mf *memFile) Sys() interface{} {
 	return nil
 }
 
+// info returns a copy of mf, without its entries, which can be handed out
+// as an os.FileInfo without racing with later changes to mf. It must be
+// called with lk held.
+func (mf *memFile) info() os.FileInfo {
+	c := *mf
+	c.Entries = nil
+	return &c
+}
+
 func (fs *memStreamStore) Close() error {
 	return nil
 }
 
+func (fs *memStreamStore) Scheme() string {
+	return "mem"
+}
+
+// Lstat is Stat, as the memory store has no symbolic links.
 func (fs *memStreamStore) Lstat(name string) (os.FileInfo, error) {
 	return fs.Stat(name)
 }
 
 func (fs *memStreamStore) Stat(name string) (os.FileInfo, error) {
-	return fs.getExisting(name)
+	name, err := CleanPath(name)
+	if err != nil {
+		return nil, err
+	}
+	fs.lk.Lock()
+	defer fs.lk.Unlock()
+
+	f, err := fs.getExisting("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return f.info(), nil
 }
 
 func (fs *memStreamStore) OpenReadCloser(name string) (StrawReader, error) {
+	name, err := CleanPath(name)
+	if err != nil {
+		return nil, err
+	}
 	fs.lk.Lock()
 	defer fs.lk.Unlock()
 
-	file, err := fs.getExistingFile(name)
+	file, err := fs.getExistingFile("open", name)
 	if err != nil {
 		return nil, err
 	}
+	fs.touch(file)
 	return newMemFileReader(file)
 }
 
@@ -111,6 +206,10 @@ func (mfr *memFileReader) Close() error {
 }
 
 func (fs *memStreamStore) Mkdir(name string, mode os.FileMode) error {
+	name, err := CleanPath(name)
+	if err != nil {
+		return err
+	}
 	fs.lk.Lock()
 	defer fs.lk.Unlock()
 
@@ -119,20 +218,27 @@ func (fs *memStreamStore) Mkdir(name string, mode os.FileMode) error {
 	for _, elem := range list[0 : len(list)-1] {
 		dir = dir.Entries[elem]
 		if dir == nil {
-			return os.ErrNotExist
+			return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrNotExist}
 		}
 	}
+	if !dir.IsDir() {
+		return &os.PathError{Op: "mkdir", Path: name, Err: ErrNotDirectory}
+	}
 	newdir := list[len(list)-1]
 	if dir.Entries == nil {
 		dir.Entries = make(map[string]*memFile)
 	} else if dir.Entries[newdir] != nil {
 		return errors.New("file exists")
 	}
-	dir.Entries[newdir] = &memFile{IsDir_: true, Name_: newdir}
+	dir.Entries[newdir] = &memFile{IsDir_: true, Name_: newdir, Modtime: time.Now(), Perm: 0755}
 	return nil
 }
 
 func (fs *memStreamStore) Remove(name string) error {
+	name, err := CleanPath(name)
+	if err != nil {
+		return err
+	}
 	fs.lk.Lock()
 	defer fs.lk.Unlock()
 
@@ -141,117 +247,423 @@ func (fs *memStreamStore) Remove(name string) error {
 	for _, elem := range list[0 : len(list)-1] {
 		parent = parent.Entries[elem]
 		if parent == nil {
-			return os.ErrNotExist
+			return &os.PathError{Op: "remove", Path: name, Err: os.ErrNotExist}
 		}
 	}
 	filename := list[len(list)-1]
-	if parent.Entries == nil {
-		return os.ErrNotExist
-	}
-	if parent.Entries[filename] == nil {
-		return os.ErrNotExist
-	}
 	file := parent.Entries[filename]
 	if file == nil {
-		return os.ErrNotExist
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrNotExist}
 	}
-	if file.IsDir_ && file.Entries != nil && len(file.Entries) != 0 {
-		return errors.New("directory not empty")
+	if file.IsDir_ && len(file.Entries) != 0 {
+		return &os.PathError{Op: "remove", Path: name, Err: ErrDirectoryNotEmpty}
 	}
 	delete(parent.Entries, filename)
+	fs.used -= int64(len(file.Content))
 	return nil
 }
 
-func (fs *memStreamStore) getExistingFile(name string) (*memFile, error) {
-	file, err := fs.getExisting(name)
+// getParent returns the directory which would hold the named file, and the
+// name of the file within it, failing for op if the directory does not exist
+// or the file is a directory. It must be called with lk held.
+func (fs *memStreamStore) getParent(op, name string) (*memFile, string, error) {
+	list := fs.Split(name)
+	if len(list) == 0 {
+		return nil, "", &os.PathError{Op: op, Path: name, Err: ErrIsDirectory}
+	}
+	dir := fs.Root
+	for _, elem := range list[0 : len(list)-1] {
+		dir = dir.Entries[elem]
+		if dir == nil {
+			return nil, "", &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+		}
+	}
+	if !dir.IsDir() {
+		return nil, "", &os.PathError{Op: op, Path: name, Err: ErrNotDirectory}
+	}
+	fileName := list[len(list)-1]
+	if f := dir.Entries[fileName]; f != nil && f.IsDir() {
+		return nil, "", &os.PathError{Op: op, Path: name, Err: ErrIsDirectory}
+	}
+	return dir, fileName, nil
+}
+
+// getExistingFile returns the named file, failing for op if it does not
+// exist or is a directory.
+func (fs *memStreamStore) getExistingFile(op, name string) (*memFile, error) {
+	file, err := fs.getExisting(op, name)
 	if err != nil {
 		return nil, err
 	}
 	if file.IsDir_ {
-		return nil, fmt.Errorf("%s is a directory", name)
+		return nil, &os.PathError{Op: op, Path: name, Err: ErrIsDirectory}
 	}
 	return file, nil
 }
 
-func (fs *memStreamStore) getExisting(name string) (*memFile, error) {
+// getExisting returns the named file or directory, failing for op if it does
+// not exist.
+func (fs *memStreamStore) getExisting(op, name string) (*memFile, error) {
 	list := fs.Split(name)
 	f := fs.Root
-	for _, elem := range list {
+	for i, elem := range list {
+		if i > 0 && !f.IsDir_ {
+			return nil, &os.PathError{Op: op, Path: name, Err: ErrNotDirectory}
+		}
 		f = f.Entries[elem]
 		if f == nil {
-			break
+			return nil, &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
 		}
 	}
-	if f == nil {
-		return nil, os.ErrNotExist
-	}
 	return f, nil
 }
 
+func (fs *memStreamStore) Chmod(name string, mode os.FileMode) error {
+	name, err := CleanPath(name)
+	if err != nil {
+		return err
+	}
+	fs.lk.Lock()
+	defer fs.lk.Unlock()
+
+	f, err := fs.getExisting("chmod", name)
+	if err != nil {
+		return err
+	}
+	f.Perm = mode.Perm()
+	return nil
+}
+
+func (fs *memStreamStore) Truncate(name string, size int64) error {
+	name, err := CleanPath(name)
+	if err != nil {
+		return err
+	}
+	if size < 0 {
+		return &os.PathError{Op: "truncate", Path: name, Err: errNegativeSize}
+	}
+	fs.lk.Lock()
+	defer fs.lk.Unlock()
+
+	f, err := fs.getExistingFile("truncate", name)
+	if err != nil {
+		return err
+	}
+	if err := fs.reserve("truncate", name, f, size-int64(len(f.Content))); err != nil {
+		return err
+	}
+	f.Content = resize(f.Content, size)
+	f.Modtime = time.Now()
+	return nil
+}
+
+// ContentHash returns the hex encoded MD5 of the content of the named file.
+func (fs *memStreamStore) ContentHash(name string) (string, error) {
+	name, err := CleanPath(name)
+	if err != nil {
+		return "", err
+	}
+	fs.lk.Lock()
+	defer fs.lk.Unlock()
+
+	f, err := fs.getExistingFile("contenthash", name)
+	if err != nil {
+		return "", err
+	}
+	sum := md5.Sum(f.Content)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// CreateWriteCloser returns a writer which collects the content of the file
+// in memory of its own, replacing the file with it on Close, so readers never
+// see a half written file and, of concurrent writers, the last to close wins.
 func (fs *memStreamStore) CreateWriteCloser(name string) (StrawWriter, error) {
+	name, err := CleanPath(name)
+	if err != nil {
+		return nil, err
+	}
 	fs.lk.Lock()
 	defer fs.lk.Unlock()
 
-	list := fs.Split(name)
-	dir := fs.Root
-	for _, elem := range list[0 : len(list)-1] {
-		dir = dir.Entries[elem]
-		if dir == nil {
-			return nil, errors.New("not found")
-		}
+	dir, fileName, err := fs.getParent("open", name)
+	if err != nil {
+		return nil, err
 	}
-	if !dir.IsDir() {
-		return nil, errors.New("not a directory")
+	mf := &memFile{Name_: fileName, Perm: 0644, Modtime: time.Now()}
+	var credit int64
+	if old := dir.Entries[fileName]; old != nil {
+		credit = int64(len(old.Content))
 	}
+	return &memfileWriteCloser{fs: fs, name: name, mf: mf, credit: credit}, nil
+}
 
-	fileName := list[len(list)-1]
+func (fs *memStreamStore) OpenFile(name string, flag int, perm os.FileMode) (StrawReadWriteCloser, error) {
+	return OpenFileBuffered(fs, name, flag, func(name string) error {
+		_, err := fs.createFile(name, true)
+		return err
+	})
+}
 
+// createFile creates the named file, or truncates it if it exists, unless
+// exclusive is set, in which case it fails if the file exists.
+func (fs *memStreamStore) createFile(name string, exclusive bool) (*memFile, error) {
+	name, err := CleanPath(name)
+	if err != nil {
+		return nil, err
+	}
+	fs.lk.Lock()
+	defer fs.lk.Unlock()
+
+	dir, fileName, err := fs.getParent("open", name)
+	if err != nil {
+		return nil, err
+	}
 	f := dir.Entries[fileName]
+	if f != nil && exclusive {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrExist}
+	}
 	if f == nil {
-		f = &memFile{Name_: fileName}
+		f = &memFile{Name_: fileName, Perm: 0644}
 		if dir.Entries == nil {
 			dir.Entries = make(map[string]*memFile)
 		}
 		dir.Entries[fileName] = f
 	}
-	if f.IsDir() {
-		return nil, fmt.Errorf("%s is a directory", name)
-	}
+	fs.used -= int64(len(f.Content))
 	f.Content = f.Content[0:0]
-	return &memfileWriteCloser{f}, nil
+	f.Modtime = time.Now()
+	fs.touch(f)
+	return f, nil
+}
+
+func (fs *memStreamStore) Copy(src, dst string) error {
+	src, err := CleanPath(src)
+	if err != nil {
+		return err
+	}
+
+	fs.lk.Lock()
+	file, err := fs.getExistingFile("copy", src)
+	var content []byte
+	if err == nil {
+		content = append(content, file.Content...)
+	}
+	fs.lk.Unlock()
+	if err != nil {
+		return err
+	}
+
+	w, err := fs.CreateWriteCloser(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(content); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// touch marks f as the most recently used file. It must be called with lk
+// held.
+func (fs *memStreamStore) touch(f *memFile) {
+	fs.clock++
+	f.lastUse = fs.clock
+}
+
+// reserve accounts for f, named name, growing by growth bytes, which may be
+// negative, failing op with ErrNoSpace if that would exceed maxBytes, or
+// evicting other files to make room if evict is set. It must be called with
+// lk held.
+func (fs *memStreamStore) reserve(op, name string, f *memFile, growth int64) error {
+	for fs.maxBytes > 0 && growth > 0 && fs.used+growth > fs.maxBytes {
+		if !fs.evict || growth > fs.maxBytes || !fs.evictOldest(f) {
+			return &os.PathError{Op: op, Path: name, Err: ErrNoSpace}
+		}
+	}
+	fs.used += growth
+	fs.touch(f)
+	return nil
+}
+
+// evictOldest removes the least recently used file other than keep, and
+// reports whether there was one. It must be called with lk held.
+func (fs *memStreamStore) evictOldest(keep *memFile) bool {
+	var oldest, oldestDir *memFile
+	var walk func(dir *memFile)
+	walk = func(dir *memFile) {
+		for _, f := range dir.Entries {
+			switch {
+			case f.IsDir_:
+				walk(f)
+			case f != keep && (oldest == nil || f.lastUse < oldest.lastUse):
+				oldest, oldestDir = f, dir
+			}
+		}
+	}
+	walk(fs.Root)
+	if oldest == nil {
+		return false
+	}
+	delete(oldestDir.Entries, oldest.Name_)
+	fs.used -= int64(len(oldest.Content))
+	return true
 }
 
 type memfileWriteCloser struct {
-	mf *memFile
+	fs     *memStreamStore
+	name   string
+	mf     *memFile
+	pos    int64
+	closed bool
+
+	// credit is the size of the file being replaced, which the content may
+	// grow to without reserving space, as that file's space is reused on
+	// Close. reserved is the space reserved beyond it.
+	credit   int64
+	reserved int64
 }
 
 func (mfwc *memfileWriteCloser) Write(buf []byte) (int, error) {
-	mfwc.mf.Content = append(mfwc.mf.Content, buf...)
+	n, err := mfwc.WriteAt(buf, mfwc.pos)
+	mfwc.pos += int64(n)
+	return n, err
+}
+
+func (mfwc *memfileWriteCloser) WriteAt(buf []byte, off int64) (int, error) {
+	if mfwc.closed {
+		return 0, ErrClosed
+	}
+	if off < 0 {
+		return 0, &os.PathError{Op: "writeat", Path: mfwc.mf.Name_, Err: errors.New("negative offset")}
+	}
+	mfwc.fs.lk.Lock()
+	defer mfwc.fs.lk.Unlock()
+	if growth := off + int64(len(buf)) - mfwc.credit - mfwc.reserved; growth > 0 {
+		if err := mfwc.fs.reserve("write", mfwc.name, mfwc.mf, growth); err != nil {
+			return 0, err
+		}
+		mfwc.reserved += growth
+	}
+	mfwc.mf.Content = writeAt(mfwc.mf.Content, buf, off)
+	mfwc.mf.Modtime = time.Now()
 	return len(buf), nil
 }
 
+// Close replaces the file with what has been written, keeping the mode of
+// any file it replaces.
 func (mfwc *memfileWriteCloser) Close() error {
+	if mfwc.closed {
+		return ErrClosed
+	}
+	mfwc.closed = true
+	fs := mfwc.fs
+	fs.lk.Lock()
+	defer fs.lk.Unlock()
+
+	dir, fileName, err := fs.getParent("close", mfwc.name)
+	if err != nil {
+		fs.used -= mfwc.reserved
+		return err
+	}
+	old := dir.Entries[fileName]
+	if old != nil {
+		delete(dir.Entries, fileName)
+		fs.used -= int64(len(old.Content))
+		mfwc.mf.Perm = old.Perm
+	}
+	// the file replaced may have changed since the writer was created, so
+	// what was not reserved up front is reserved now.
+	if err := fs.reserve("write", mfwc.name, mfwc.mf, int64(len(mfwc.mf.Content))-mfwc.reserved); err != nil {
+		fs.used -= mfwc.reserved
+		if old != nil {
+			dir.Entries[fileName] = old
+			fs.used += int64(len(old.Content))
+		}
+		return err
+	}
+	if dir.Entries == nil {
+		dir.Entries = make(map[string]*memFile)
+	}
+	dir.Entries[fileName] = mfwc.mf
+	fs.touch(mfwc.mf)
 	return nil
 }
 
 func (fs *memStreamStore) Readdir(name string) ([]os.FileInfo, error) {
-	file, err := fs.getExisting(name)
+	name, err := CleanPath(name)
+	if err != nil {
+		return nil, err
+	}
+	fs.lk.Lock()
+	defer fs.lk.Unlock()
+
+	file, err := fs.getExisting("readdir", name)
 	if err != nil {
 		return nil, err
 	}
 	if !file.IsDir() {
-		return nil, fmt.Errorf("%v is not a dir", name)
+		return nil, &os.PathError{Op: "readdir", Path: name, Err: ErrNotDirectory}
 	}
 	var res []os.FileInfo
 	for _, entry := range file.Entries {
-		res = append(res, entry)
+		res = append(res, entry.info())
 	}
 	sort.Slice(res, func(i, j int) bool { return res[i].Name() < res[j].Name() })
 	return res, nil
 }
 
+// Snapshot returns the tree of the store encoded as JSON.
+func (fs *memStreamStore) Snapshot() ([]byte, error) {
+	fs.lk.Lock()
+	defer fs.lk.Unlock()
+	return json.Marshal(fs.Root)
+}
+
+// Restore replaces the tree of the store with one returned by Snapshot,
+// failing with ErrNoSpace if it is larger than the store allows.
+func (fs *memStreamStore) Restore(snapshot []byte) error {
+	root := &memFile{}
+	if err := json.Unmarshal(snapshot, root); err != nil {
+		return fmt.Errorf("invalid mem snapshot: %w", err)
+	}
+	if !root.IsDir_ {
+		return errors.New("invalid mem snapshot: root is not a directory")
+	}
+	root.Name_ = ""
+	var used int64
+	var check func(dir *memFile) error
+	check = func(dir *memFile) error {
+		for name, f := range dir.Entries {
+			if f == nil || name == "" || name == "." || name == ".." || strings.Contains(name, "/") {
+				return fmt.Errorf("invalid mem snapshot: bad entry %q", name)
+			}
+			f.Name_ = name
+			if !f.IsDir_ {
+				used += int64(len(f.Content))
+				continue
+			}
+			if err := check(f); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := check(root); err != nil {
+		return err
+	}
+
+	fs.lk.Lock()
+	defer fs.lk.Unlock()
+	if fs.maxBytes > 0 && used > fs.maxBytes {
+		return fmt.Errorf("restore: %w", ErrNoSpace)
+	}
+	fs.Root, fs.used = root, used
+	return nil
+}
+
 func (fs *memStreamStore) Split(name string) []string {
-	if name == "" {
+	if name == "" || name == "." {
 		return []string{}
 	}
 	spl := strings.Split(name, string(os.PathSeparator))
@@ -0,0 +1,135 @@
+package straw_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+func TestMemMaxBytes(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, err := straw.Open("mem://", straw.MemMaxBytes(10))
+	require.NoError(err)
+
+	require.NoError(putFile(ss, "/a", []byte("123456")))
+
+	w, err := ss.CreateWriteCloser("/b")
+	require.NoError(err)
+	_, err = w.Write([]byte("1234"))
+	assert.NoError(err)
+	_, err = w.Write([]byte("5"))
+	assert.True(errors.Is(err, straw.ErrNoSpace))
+	assert.EqualError(err, "write /b: no space left in store")
+	require.NoError(w.Close())
+
+	assert.True(errors.Is(ss.Truncate("/a", 7), straw.ErrNoSpace))
+	require.NoError(ss.Truncate("/a", 2))
+
+	// space freed by truncating, removing and overwriting files is reused.
+	require.NoError(putFile(ss, "/b", []byte("12345678")))
+	require.NoError(ss.Remove("/b"))
+	require.NoError(putFile(ss, "/c", []byte("12345678")))
+	assert.True(errors.Is(putFile(ss, "/d", []byte("1")), straw.ErrNoSpace))
+}
+
+func TestMemEvictLRU(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, err := straw.Open("mem://?max_bytes=10&evict=lru")
+	require.NoError(err)
+
+	require.NoError(putFile(ss, "/a", []byte("1234")))
+	require.NoError(ss.Mkdir("/dir", 0755))
+	require.NoError(putFile(ss, "/dir/b", []byte("1234")))
+	r, err := ss.OpenReadCloser("/a")
+	require.NoError(err)
+	require.NoError(r.Close())
+
+	// /dir/b is the least recently used, so is evicted to make room.
+	require.NoError(putFile(ss, "/c", []byte("1234")))
+	_, err = ss.Stat("/dir/b")
+	assert.True(os.IsNotExist(err))
+	_, err = ss.Stat("/a")
+	assert.NoError(err)
+
+	// a file too large for the store is never written.
+	assert.True(errors.Is(putFile(ss, "/d", make([]byte, 11)), straw.ErrNoSpace))
+	_, err = ss.Stat("/c")
+	assert.NoError(err)
+}
+
+func TestMemInvalidOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := straw.Open("mem://?max_bytes=lots")
+	assert.EqualError(err, `invalid "max_bytes" query parameter: invalid size "lots"`)
+	_, err = straw.Open("mem://?evict=fifo")
+	assert.EqualError(err, `invalid "evict" query parameter: "fifo"`)
+}
+
+func putFile(ss straw.StreamStore, name string, data []byte) error {
+	w, err := ss.CreateWriteCloser(name)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func TestMemSnapshot(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, err := straw.Open("mem://")
+	require.NoError(err)
+	snap, ok := ss.(straw.MemSnapshotter)
+	require.True(ok)
+
+	require.NoError(ss.Mkdir("/dir", 0755))
+	require.NoError(putFile(ss, "/dir/a", []byte("fixture")))
+	require.NoError(ss.Chmod("/dir/a", 0600))
+	fi, err := ss.Stat("/dir/a")
+	require.NoError(err)
+	mode, modTime := fi.Mode(), fi.ModTime()
+
+	data, err := snap.Snapshot()
+	require.NoError(err)
+
+	require.NoError(putFile(ss, "/dir/a", []byte("changed")))
+	require.NoError(putFile(ss, "/b", []byte("new")))
+
+	require.NoError(snap.Restore(data))
+	_, err = ss.Stat("/b")
+	assert.True(os.IsNotExist(err))
+	fi, err = ss.Stat("/dir/a")
+	require.NoError(err)
+	assert.Equal(mode, fi.Mode())
+	assert.True(modTime.Equal(fi.ModTime()))
+	content, err := straw.ReadFile(ss, "/dir/a")
+	require.NoError(err)
+	assert.Equal("fixture", string(content))
+
+	// snapshots are portable between stores, within their limits.
+	other, err := straw.Open("mem://", straw.MemMaxBytes(4))
+	require.NoError(err)
+	assert.True(errors.Is(other.(straw.MemSnapshotter).Restore(data), straw.ErrNoSpace))
+	other, err = straw.Open("mem://")
+	require.NoError(err)
+	require.NoError(other.(straw.MemSnapshotter).Restore(data))
+	content, err = straw.ReadFile(other, "/dir/a")
+	require.NoError(err)
+	assert.Equal("fixture", string(content))
+
+	assert.Error(snap.Restore([]byte("not json")))
+	assert.EqualError(snap.Restore([]byte(`{"IsDir_":true,"Entries":{"..":{}}}`)), `invalid mem snapshot: bad entry ".."`)
+}
@@ -0,0 +1,265 @@
+package straw
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+var (
+	_ StreamStore        = &opStreamStore{}
+	_ ContextStreamStore = &opStreamStore{}
+	_ OptionsWriter      = &opStreamStore{}
+	_ AppendStore        = &opStreamStore{}
+	_ AllRemover         = &opStreamStore{}
+	_ ExistsChecker      = &opStreamStore{}
+	_ DirChecker         = &opStreamStore{}
+	_ Taggable           = &opStreamStore{}
+	_ PresignStore       = &opStreamStore{}
+)
+
+// opFunc runs the operation f, named op and acting on name, adding some
+// behaviour such as retries or timeouts around it. The result of f is
+// returned as an interface{} so that a single opFunc can handle every method.
+// ctx is the context f is bound to, which is done when the caller has given
+// up on the operation.
+type opFunc func(ctx context.Context, op, name string, f func() (interface{}, error)) (interface{}, error)
+
+// opStreamStore wraps a StreamStore, passing each of its methods other than
+// Close through do. It implements the optional interfaces which act on paths
+// of the store, such as OptionsWriter and ContextStreamStore, so that they
+// are not hidden from callers, passing each to the package function of the
+// same name, which falls back to the plain methods if ss does not implement
+// it. The methods of Taggable and PresignStore return ErrNotSupported if ss
+// does not implement them.
+type opStreamStore struct {
+	ss StreamStore
+	do opFunc
+}
+
+func (fs *opStreamStore) Close() error {
+	return fs.ss.Close()
+}
+
+func (fs *opStreamStore) Unwrap() StreamStore {
+	return fs.ss
+}
+
+func (fs *opStreamStore) OpenReadCloser(name string) (StrawReader, error) {
+	return fs.OpenReadCloserContext(context.Background(), name)
+}
+
+func (fs *opStreamStore) OpenReadCloserContext(ctx context.Context, name string) (StrawReader, error) {
+	v, err := fs.do(ctx, "OpenReadCloser", name, func() (interface{}, error) {
+		r, err := OpenReadCloserContext(ctx, fs.ss, name)
+		return r, err
+	})
+	r, _ := v.(StrawReader)
+	return r, err
+}
+
+func (fs *opStreamStore) CreateWriteCloser(name string) (StrawWriter, error) {
+	return fs.CreateWriteCloserContext(context.Background(), name)
+}
+
+func (fs *opStreamStore) CreateWriteCloserContext(ctx context.Context, name string) (StrawWriter, error) {
+	v, err := fs.do(ctx, "CreateWriteCloser", name, func() (interface{}, error) {
+		w, err := CreateWriteCloserContext(ctx, fs.ss, name)
+		return w, err
+	})
+	w, _ := v.(StrawWriter)
+	return w, err
+}
+
+func (fs *opStreamStore) CreateWriteCloserWithOptions(name string, opts WriteOptions) (StrawWriter, error) {
+	v, err := fs.do(context.Background(), "CreateWriteCloser", name, func() (interface{}, error) {
+		w, err := createWriteCloser(fs.ss, name, opts)
+		return w, err
+	})
+	w, _ := v.(StrawWriter)
+	return w, err
+}
+
+func (fs *opStreamStore) AppendWriteCloser(name string) (StrawWriter, error) {
+	v, err := fs.do(context.Background(), "AppendWriteCloser", name, func() (interface{}, error) {
+		w, err := AppendWriteCloser(fs.ss, name)
+		return w, err
+	})
+	w, _ := v.(StrawWriter)
+	return w, err
+}
+
+func (fs *opStreamStore) OpenFile(name string, flag int, perm os.FileMode) (StrawReadWriteCloser, error) {
+	v, err := fs.do(context.Background(), "OpenFile", name, func() (interface{}, error) {
+		f, err := fs.ss.OpenFile(name, flag, perm)
+		return f, err
+	})
+	f, _ := v.(StrawReadWriteCloser)
+	return f, err
+}
+
+func (fs *opStreamStore) Lstat(path string) (os.FileInfo, error) {
+	return fs.LstatContext(context.Background(), path)
+}
+
+func (fs *opStreamStore) LstatContext(ctx context.Context, path string) (os.FileInfo, error) {
+	v, err := fs.do(ctx, "Lstat", path, func() (interface{}, error) {
+		fi, err := LstatContext(ctx, fs.ss, path)
+		return fi, err
+	})
+	fi, _ := v.(os.FileInfo)
+	return fi, err
+}
+
+func (fs *opStreamStore) Stat(path string) (os.FileInfo, error) {
+	return fs.StatContext(context.Background(), path)
+}
+
+func (fs *opStreamStore) StatContext(ctx context.Context, path string) (os.FileInfo, error) {
+	v, err := fs.do(ctx, "Stat", path, func() (interface{}, error) {
+		fi, err := StatContext(ctx, fs.ss, path)
+		return fi, err
+	})
+	fi, _ := v.(os.FileInfo)
+	return fi, err
+}
+
+func (fs *opStreamStore) Exists(name string) (bool, error) {
+	v, err := fs.do(context.Background(), "Exists", name, func() (interface{}, error) {
+		return Exists(fs.ss, name)
+	})
+	exists, _ := v.(bool)
+	return exists, err
+}
+
+func (fs *opStreamStore) IsDir(name string) (bool, error) {
+	v, err := fs.do(context.Background(), "IsDir", name, func() (interface{}, error) {
+		return IsDir(fs.ss, name)
+	})
+	isDir, _ := v.(bool)
+	return isDir, err
+}
+
+func (fs *opStreamStore) Readdir(path string) ([]os.FileInfo, error) {
+	return fs.ReaddirContext(context.Background(), path)
+}
+
+func (fs *opStreamStore) ReaddirContext(ctx context.Context, path string) ([]os.FileInfo, error) {
+	v, err := fs.do(ctx, "Readdir", path, func() (interface{}, error) {
+		fis, err := ReaddirContext(ctx, fs.ss, path)
+		return fis, err
+	})
+	fis, _ := v.([]os.FileInfo)
+	return fis, err
+}
+
+func (fs *opStreamStore) Mkdir(path string, mode os.FileMode) error {
+	return fs.MkdirContext(context.Background(), path, mode)
+}
+
+func (fs *opStreamStore) MkdirContext(ctx context.Context, path string, mode os.FileMode) error {
+	_, err := fs.do(ctx, "Mkdir", path, func() (interface{}, error) {
+		return nil, MkdirContext(ctx, fs.ss, path, mode)
+	})
+	return err
+}
+
+func (fs *opStreamStore) Remove(path string) error {
+	return fs.RemoveContext(context.Background(), path)
+}
+
+func (fs *opStreamStore) RemoveContext(ctx context.Context, path string) error {
+	_, err := fs.do(ctx, "Remove", path, func() (interface{}, error) {
+		return nil, RemoveContext(ctx, fs.ss, path)
+	})
+	return err
+}
+
+// RemoveAll removes the tree at path with the RemoveAll method of ss if it
+// has one, and otherwise a file at a time through fs.
+func (fs *opStreamStore) RemoveAll(path string) error {
+	ar, ok := fs.ss.(AllRemover)
+	if !ok {
+		return removeAll(fs, path, newMutateOptions(nil))
+	}
+	_, err := fs.do(context.Background(), "RemoveAll", path, func() (interface{}, error) {
+		return nil, ar.RemoveAll(path)
+	})
+	return err
+}
+
+func (fs *opStreamStore) Chmod(name string, mode os.FileMode) error {
+	return fs.ChmodContext(context.Background(), name, mode)
+}
+
+func (fs *opStreamStore) ChmodContext(ctx context.Context, name string, mode os.FileMode) error {
+	_, err := fs.do(ctx, "Chmod", name, func() (interface{}, error) {
+		return nil, ChmodContext(ctx, fs.ss, name, mode)
+	})
+	return err
+}
+
+func (fs *opStreamStore) Truncate(name string, size int64) error {
+	_, err := fs.do(context.Background(), "Truncate", name, func() (interface{}, error) {
+		return nil, fs.ss.Truncate(name, size)
+	})
+	return err
+}
+
+func (fs *opStreamStore) Symlink(oldname, newname string) error {
+	_, err := fs.do(context.Background(), "Symlink", newname, func() (interface{}, error) {
+		return nil, Symlink(fs.ss, oldname, newname)
+	})
+	return err
+}
+
+func (fs *opStreamStore) Readlink(name string) (string, error) {
+	v, err := fs.do(context.Background(), "Readlink", name, func() (interface{}, error) {
+		return Readlink(fs.ss, name)
+	})
+	dest, _ := v.(string)
+	return dest, err
+}
+
+func (fs *opStreamStore) Copy(src, dst string) error {
+	return fs.CopyContext(context.Background(), src, dst)
+}
+
+func (fs *opStreamStore) CopyContext(ctx context.Context, src, dst string) error {
+	_, err := fs.do(ctx, "Copy", src, func() (interface{}, error) {
+		return nil, CopyContext(ctx, fs.ss, src, dst)
+	})
+	return err
+}
+
+func (fs *opStreamStore) SetTags(name string, tags map[string]string) error {
+	_, err := fs.do(context.Background(), "SetTags", name, func() (interface{}, error) {
+		return nil, setTags(fs.ss, name, tags)
+	})
+	return err
+}
+
+func (fs *opStreamStore) GetTags(name string) (map[string]string, error) {
+	v, err := fs.do(context.Background(), "GetTags", name, func() (interface{}, error) {
+		tags, err := getTags(fs.ss, name)
+		return tags, err
+	})
+	tags, _ := v.(map[string]string)
+	return tags, err
+}
+
+func (fs *opStreamStore) PresignGetURL(name string, expiry time.Duration) (string, error) {
+	v, err := fs.do(context.Background(), "PresignGetURL", name, func() (interface{}, error) {
+		return presignGetURL(fs.ss, name, expiry)
+	})
+	u, _ := v.(string)
+	return u, err
+}
+
+func (fs *opStreamStore) PresignPutURL(name string, expiry time.Duration) (string, error) {
+	v, err := fs.do(context.Background(), "PresignPutURL", name, func() (interface{}, error) {
+		return presignPutURL(fs.ss, name, expiry)
+	})
+	u, _ := v.(string)
+	return u, err
+}
@@ -1,34 +1,120 @@
 package straw
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"net/url"
 	"os"
+	"path/filepath"
+	"runtime"
 	"sort"
+	"strconv"
+	"sync"
+	"time"
 )
 
 var _ StreamStore = &osStreamStore{}
+var _ SymlinkStore = &osStreamStore{}
+var _ Hasher = &osStreamStore{}
+
+// atomic, if true, makes CreateWriteCloser and Copy write to a temporary
+// file in the destination directory which is renamed into place on Close, so
+// that readers see either the old content or the whole of the new.
+const osAtomicQueryParam = "atomic"
+
+// sync, if true, makes the writers returned by CreateWriteCloser, and Copy,
+// flush the file and its directory entry to disk before Close returns.
+const osSyncQueryParam = "sync"
+
+func newOSStreamStore(u *url.URL) (*osStreamStore, error) {
+	fs := &osStreamStore{}
+	q := u.Query()
+	for param, v := range map[string]*bool{osAtomicQueryParam: &fs.atomic, osSyncQueryParam: &fs.sync} {
+		if s := q.Get(param); s != "" {
+			var err error
+			if *v, err = strconv.ParseBool(s); err != nil {
+				return nil, fmt.Errorf("invalid %q query parameter: %w", param, err)
+			}
+		}
+	}
+	return fs, nil
+}
+
+// OSAtomicWrites returns an Option which makes a local filesystem store write
+// files atomically, by writing to a temporary file in the same directory and
+// renaming it into place on Close. The temporary file is removed if writing
+// or renaming fails, or if the writer is garbage collected without being
+// closed, but is left behind if the process dies; its name starts with "."
+// and ends with ".tmp" so that it can be cleaned up.
+func OSAtomicWrites() Option {
+	return QueryOption(osAtomicQueryParam, "true")
+}
+
+// OSSync returns an Option which makes a local filesystem store call
+// File.Sync on the files written with CreateWriteCloser before closing
+// them, and then sync their directory, so that both the content and the
+// directory entry are durable once Close returns.
+func OSSync() Option {
+	return QueryOption(osSyncQueryParam, "true")
+}
 
 type osStreamStore struct {
+	atomic bool
+	sync   bool
+
+	hashLk sync.Mutex
+	hashes map[string]osHash // by name, computed by ContentHash
+}
+
+// osHash is the MD5 of a file, which is reused while the file has the size
+// and modification time it had when the MD5 was computed.
+type osHash struct {
+	size    int64
+	modTime time.Time
+	sum     string
 }
 
 func (_ *osStreamStore) Close() error {
 	return nil
 }
 
+func (_ *osStreamStore) Scheme() string {
+	return "file"
+}
+
+// Lstat uses os.Lstat, so describes symbolic links rather than following
+// them.
 func (_ *osStreamStore) Lstat(filename string) (os.FileInfo, error) {
+	filename, err := CleanPath(filename)
+	if err != nil {
+		return nil, err
+	}
 	return os.Lstat(filename)
 }
 
 func (_ *osStreamStore) Stat(filename string) (os.FileInfo, error) {
+	filename, err := CleanPath(filename)
+	if err != nil {
+		return nil, err
+	}
 	return os.Stat(filename)
 }
 
 func (_ *osStreamStore) Mkdir(path string, mode os.FileMode) error {
+	path, err := CleanPath(path)
+	if err != nil {
+		return err
+	}
 	return os.Mkdir(path, mode)
 }
 
 func (_ *osStreamStore) OpenReadCloser(name string) (StrawReader, error) {
+	name, err := CleanPath(name)
+	if err != nil {
+		return nil, err
+	}
 	f, err := os.Open(name)
 	if err != nil {
 		return nil, err
@@ -40,7 +126,7 @@ func (_ *osStreamStore) OpenReadCloser(name string) (StrawReader, error) {
 	}
 	if fi.IsDir() {
 		f.Close()
-		return nil, fmt.Errorf("%s is a directory", name)
+		return nil, &os.PathError{Op: "open", Path: name, Err: ErrIsDirectory}
 	}
 	return file{f}, nil
 }
@@ -49,20 +135,218 @@ type file struct {
 	*os.File
 }
 
+// WriteTo copies the rest of the file to w, passing w the *os.File itself so
+// that destinations such as files and sockets can use copy_file_range or
+// sendfile, which they cannot through the wrapper.
+func (f file) WriteTo(w io.Writer) (int64, error) {
+	return io.Copy(w, f.File)
+}
+
+// Flush syncs the file to disk.
+func (f file) Flush() error {
+	return f.Sync()
+}
+
 func (f file) SeekStart(offset int64) error {
 	_, err := f.Seek(offset, io.SeekStart)
 	return err
 }
 
 func (_ *osStreamStore) Remove(name string) error {
+	name, err := CleanPath(name)
+	if err != nil {
+		return err
+	}
 	return os.Remove(name)
 }
 
-func (_ *osStreamStore) CreateWriteCloser(name string) (StrawWriter, error) {
-	return os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+func (fs *osStreamStore) CreateWriteCloser(name string) (StrawWriter, error) {
+	name, err := CleanPath(name)
+	if err != nil {
+		return nil, err
+	}
+	return fs.create(name)
+}
+
+// create creates the file name, which must already be clean, for writing.
+func (fs *osStreamStore) create(name string) (StrawWriter, error) {
+	if !fs.atomic {
+		f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+		if err != nil {
+			return nil, err
+		}
+		if !fs.sync {
+			return file{f}, nil
+		}
+		return syncFile{f}, nil
+	}
+	if fi, err := os.Stat(name); err == nil && fi.IsDir() {
+		return nil, &os.PathError{Op: "open", Path: name, Err: ErrIsDirectory}
+	}
+	dir, base := filepath.Split(name)
+	var f *os.File
+	for {
+		var suffix [4]byte
+		if _, err := rand.Read(suffix[:]); err != nil {
+			return nil, err
+		}
+		tmp := filepath.Join(dir, "."+base+"."+hex.EncodeToString(suffix[:])+".tmp")
+		var err error
+		f, err = os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+		if err == nil {
+			break
+		}
+		if !os.IsExist(err) {
+			// the temporary file is an implementation detail, so the error
+			// is reported for name, as a plain open would.
+			if pe, ok := err.(*os.PathError); ok {
+				pe.Path = name
+			}
+			return nil, err
+		}
+	}
+	// a truncated file would keep its mode, so the replacement does too.
+	if fi, err := os.Stat(name); err == nil {
+		if err := f.Chmod(fi.Mode().Perm()); err != nil {
+			f.Close()
+			os.Remove(f.Name())
+			return nil, err
+		}
+	}
+	w := &atomicFile{f: f, name: name, sync: fs.sync}
+	runtime.SetFinalizer(w, (*atomicFile).abandon)
+	return w, nil
+}
+
+// atomicFile writes to a temporary file, which is renamed to name on Close.
+type atomicFile struct {
+	f      *os.File
+	name   string
+	sync   bool
+	err    error // the first write error, which abandons the file on Close
+	closed bool
+}
+
+func (w *atomicFile) Write(p []byte) (int, error) {
+	n, err := w.f.Write(p)
+	if err != nil && w.err == nil {
+		w.err = err
+	}
+	return n, err
+}
+
+func (w *atomicFile) WriteAt(p []byte, off int64) (int, error) {
+	n, err := w.f.WriteAt(p, off)
+	if err != nil && w.err == nil {
+		w.err = err
+	}
+	return n, err
+}
+
+// ReadFrom lets io.Copy use copy_file_range or sendfile into the temporary
+// file, as it can for an *os.File.
+func (w *atomicFile) ReadFrom(r io.Reader) (int64, error) {
+	n, err := w.f.ReadFrom(r)
+	if err != nil && w.err == nil {
+		w.err = err
+	}
+	return n, err
+}
+
+// Flush syncs the temporary file to disk. It is not renamed into place until
+// Close.
+func (w *atomicFile) Flush() error {
+	if w.closed {
+		return &os.PathError{Op: "flush", Path: w.name, Err: ErrClosed}
+	}
+	return w.f.Sync()
+}
+
+// Close renames the temporary file into place, unless a write failed, in
+// which case it is removed and the write error returned.
+func (w *atomicFile) Close() error {
+	if w.closed {
+		return &os.PathError{Op: "close", Path: w.name, Err: ErrClosed}
+	}
+	w.closed = true
+	runtime.SetFinalizer(w, nil)
+	err := w.err
+	if err == nil && w.sync {
+		err = w.f.Sync()
+	}
+	if cerr := w.f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(w.f.Name(), w.name)
+	}
+	if err != nil {
+		os.Remove(w.f.Name())
+		return err
+	}
+	if w.sync {
+		return fsyncDir(filepath.Dir(w.name))
+	}
+	return nil
+}
+
+// syncFile is a file which is synced, along with its directory, on Close.
+type syncFile struct {
+	*os.File
+}
+
+// Flush syncs the file to disk.
+func (f syncFile) Flush() error {
+	return f.Sync()
+}
+
+func (f syncFile) Close() error {
+	err := f.Sync()
+	if cerr := f.File.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return fsyncDir(filepath.Dir(f.Name()))
+}
+
+// fsyncDir flushes the entries of the directory dir to disk.
+func fsyncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if cerr := d.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// abandon removes the temporary file of a writer which was never closed.
+func (w *atomicFile) abandon() {
+	w.f.Close()
+	os.Remove(w.f.Name())
+}
+
+func (_ *osStreamStore) OpenFile(name string, flag int, perm os.FileMode) (StrawReadWriteCloser, error) {
+	name, err := CleanPath(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return file{f}, nil
 }
 
 func (_ *osStreamStore) Readdir(name string) ([]os.FileInfo, error) {
+	name, err := CleanPath(name)
+	if err != nil {
+		return nil, err
+	}
 	f, err := os.Open(name)
 	if err != nil {
 		return nil, err
@@ -79,3 +363,109 @@ func (_ *osStreamStore) Readdir(name string) ([]os.FileInfo, error) {
 	sort.Slice(fi, func(i, j int) bool { return fi[i].Name() < fi[j].Name() })
 	return fi, nil
 }
+
+func (_ *osStreamStore) Chmod(name string, mode os.FileMode) error {
+	name, err := CleanPath(name)
+	if err != nil {
+		return err
+	}
+	return os.Chmod(name, mode)
+}
+
+func (_ *osStreamStore) Truncate(name string, size int64) error {
+	name, err := CleanPath(name)
+	if err != nil {
+		return err
+	}
+	return os.Truncate(name, size)
+}
+
+func (_ *osStreamStore) Symlink(oldname, newname string) error {
+	newname, err := CleanPath(newname)
+	if err != nil {
+		return err
+	}
+	return os.Symlink(oldname, newname)
+}
+
+func (_ *osStreamStore) Readlink(name string) (string, error) {
+	name, err := CleanPath(name)
+	if err != nil {
+		return "", err
+	}
+	return os.Readlink(name)
+}
+
+// ContentHash returns the hex encoded MD5 of the content of the named file.
+// The MD5 is remembered, and is only computed again once the size or
+// modification time of the file changes.
+func (fs *osStreamStore) ContentHash(name string) (string, error) {
+	name, err := CleanPath(name)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	if fi.IsDir() {
+		return "", &os.PathError{Op: "contenthash", Path: name, Err: ErrIsDirectory}
+	}
+
+	fs.hashLk.Lock()
+	h, ok := fs.hashes[name]
+	fs.hashLk.Unlock()
+	if ok && h.size == fi.Size() && h.modTime.Equal(fi.ModTime()) {
+		return h.sum, nil
+	}
+
+	sum, err := md5Hex(f)
+	if err != nil {
+		return "", err
+	}
+	fs.hashLk.Lock()
+	if fs.hashes == nil {
+		fs.hashes = make(map[string]osHash)
+	}
+	fs.hashes[name] = osHash{size: fi.Size(), modTime: fi.ModTime(), sum: sum}
+	fs.hashLk.Unlock()
+	return sum, nil
+}
+
+func (fs *osStreamStore) Copy(src, dst string) error {
+	src, err := CleanPath(src)
+	if err != nil {
+		return err
+	}
+	dst, err = CleanPath(dst)
+	if err != nil {
+		return err
+	}
+
+	r, err := fs.OpenReadCloser(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if src == dst {
+		return nil
+	}
+
+	if fi, err := os.Stat(dst); err == nil && fi.IsDir() {
+		return &os.PathError{Op: "copy", Path: dst, Err: ErrIsDirectory}
+	}
+	w, err := fs.create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
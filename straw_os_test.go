@@ -0,0 +1,177 @@
+package straw_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+// dirNames returns the names of the entries of the local directory dir.
+func dirNames(t *testing.T, dir string) []string {
+	fis, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, fi := range fis {
+		names = append(names, fi.Name())
+	}
+	return names
+}
+
+func TestOSAtomicWrites(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "straw_os_test_")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "f")
+	require.NoError(ioutil.WriteFile(name, []byte("old"), 0600))
+
+	ss, err := straw.Open("file:///", straw.OSAtomicWrites())
+	require.NoError(err)
+
+	w, err := ss.CreateWriteCloser(name)
+	require.NoError(err)
+	_, err = w.Write([]byte("new content"))
+	require.NoError(err)
+
+	// readers see the old content until the writer is closed.
+	data, err := ioutil.ReadFile(name)
+	require.NoError(err)
+	assert.Equal("old", string(data))
+	assert.Len(dirNames(t, dir), 2)
+
+	require.NoError(w.Close())
+	data, err = ioutil.ReadFile(name)
+	require.NoError(err)
+	assert.Equal("new content", string(data))
+	assert.Equal([]string{"f"}, dirNames(t, dir))
+
+	fi, err := os.Stat(name)
+	require.NoError(err)
+	assert.Equal(os.FileMode(0600), fi.Mode().Perm())
+
+	require.NoError(ss.Copy(name, filepath.Join(dir, "g")))
+	data, err = ioutil.ReadFile(filepath.Join(dir, "g"))
+	require.NoError(err)
+	assert.Equal("new content", string(data))
+	assert.Equal([]string{"f", "g"}, dirNames(t, dir))
+}
+
+func TestOSAtomicWritesNewFileMode(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "straw_os_test_")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	plain, err := straw.Open("file:///")
+	require.NoError(err)
+	atomic, err := straw.Open("file:///?atomic=true")
+	require.NoError(err)
+	writeContent(t, plain, filepath.Join(dir, "plain"), []byte("a"))
+	writeContent(t, atomic, filepath.Join(dir, "atomic"), []byte("a"))
+
+	want, err := os.Stat(filepath.Join(dir, "plain"))
+	require.NoError(err)
+	got, err := os.Stat(filepath.Join(dir, "atomic"))
+	require.NoError(err)
+	require.Equal(want.Mode(), got.Mode())
+}
+
+func TestOSAtomicWritesCleanUp(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "straw_os_test_")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	ss, err := straw.Open("file:///", straw.OSAtomicWrites())
+	require.NoError(err)
+
+	// a directory created over the destination makes the rename fail.
+	w, err := ss.CreateWriteCloser(filepath.Join(dir, "d"))
+	require.NoError(err)
+	_, err = w.Write([]byte("content"))
+	require.NoError(err)
+	require.NoError(os.Mkdir(filepath.Join(dir, "d"), 0755))
+	require.NoError(ioutil.WriteFile(filepath.Join(dir, "d", "x"), nil, 0644))
+	assert.Error(w.Close())
+	assert.Equal([]string{"d"}, dirNames(t, dir))
+
+	_, err = ss.CreateWriteCloser(filepath.Join(dir, "d"))
+	assert.Error(err)
+	assert.Equal([]string{"d"}, dirNames(t, dir))
+}
+
+func TestOSInvalidOptions(t *testing.T) {
+	for _, u := range []string{"file:///?atomic=maybe", "file:///?sync=maybe"} {
+		_, err := straw.Open(u)
+		assert.Error(t, err, u)
+	}
+}
+
+func TestOSSync(t *testing.T) {
+	for _, u := range []string{"file:///?sync=true", "file:///?sync=true&atomic=true"} {
+		t.Run(u, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			dir, err := ioutil.TempDir("", "straw_os_test_")
+			require.NoError(err)
+			defer os.RemoveAll(dir)
+
+			ss, err := straw.Open(u)
+			require.NoError(err)
+			writeContent(t, ss, filepath.Join(dir, "f"), []byte("durable"))
+			require.NoError(ss.Copy(filepath.Join(dir, "f"), filepath.Join(dir, "g")))
+
+			for _, name := range []string{"f", "g"} {
+				data, err := ioutil.ReadFile(filepath.Join(dir, name))
+				require.NoError(err)
+				assert.Equal("durable", string(data))
+			}
+			assert.Equal([]string{"f", "g"}, dirNames(t, dir))
+		})
+	}
+}
+
+func TestOSLstat(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "straw_os_test_")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "f")
+	link := filepath.Join(dir, "link")
+	require.NoError(ioutil.WriteFile(name, []byte("hello"), 0644))
+	require.NoError(os.Symlink("f", link))
+
+	ss, err := straw.Open("file:///")
+	require.NoError(err)
+
+	fi, err := ss.Lstat(link)
+	require.NoError(err)
+	assert.Equal(os.ModeSymlink, fi.Mode()&os.ModeType)
+	assert.Equal("link", fi.Name())
+
+	fi, err = ss.Stat(link)
+	require.NoError(err)
+	assert.True(fi.Mode().IsRegular())
+	assert.Equal(int64(5), fi.Size())
+
+	// a dangling link can be described, but not followed.
+	require.NoError(os.Remove(name))
+	fi, err = ss.Lstat(link)
+	require.NoError(err)
+	assert.Equal(os.ModeSymlink, fi.Mode()&os.ModeType)
+	_, err = ss.Stat(link)
+	assert.True(os.IsNotExist(err))
+}
@@ -0,0 +1,110 @@
+package straw_test
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/uw-labs/straw"
+)
+
+var _ straw.StreamStore = &TestRecordingStreamStore{}
+
+// TestRecordingStreamStore records the name and path of each call made to
+// the wrapped store.
+type TestRecordingStreamStore struct {
+	lk      sync.Mutex
+	calls   []string
+	wrapped straw.StreamStore
+}
+
+func (fs *TestRecordingStreamStore) Lstat(name string) (os.FileInfo, error) {
+	fs.record("Lstat", name)
+	return fs.wrapped.Lstat(name)
+}
+
+func (fs *TestRecordingStreamStore) Stat(name string) (os.FileInfo, error) {
+	fs.record("Stat", name)
+	return fs.wrapped.Stat(name)
+}
+
+func (fs *TestRecordingStreamStore) OpenReadCloser(name string) (straw.StrawReader, error) {
+	fs.record("OpenReadCloser", name)
+	return fs.wrapped.OpenReadCloser(name)
+}
+
+func (fs *TestRecordingStreamStore) Mkdir(name string, mode os.FileMode) error {
+	fs.record("Mkdir", name)
+	return fs.wrapped.Mkdir(name, mode)
+}
+
+func (fs *TestRecordingStreamStore) Remove(name string) error {
+	fs.record("Remove", name)
+	return fs.wrapped.Remove(name)
+}
+
+func (fs *TestRecordingStreamStore) CreateWriteCloser(name string) (straw.StrawWriter, error) {
+	fs.record("CreateWriteCloser", name)
+	return fs.wrapped.CreateWriteCloser(name)
+}
+
+func (fs *TestRecordingStreamStore) OpenFile(name string, flag int, perm os.FileMode) (straw.StrawReadWriteCloser, error) {
+	fs.record("OpenFile", name, flag, perm)
+	return fs.wrapped.OpenFile(name, flag, perm)
+}
+
+func (fs *TestRecordingStreamStore) Readdir(name string) ([]os.FileInfo, error) {
+	fs.record("Readdir", name)
+	return fs.wrapped.Readdir(name)
+}
+
+func (fs *TestRecordingStreamStore) Chmod(name string, mode os.FileMode) error {
+	fs.record("Chmod", name, mode)
+	return fs.wrapped.Chmod(name, mode)
+}
+
+func (fs *TestRecordingStreamStore) Truncate(name string, size int64) error {
+	fs.record("Truncate", name, size)
+	return fs.wrapped.Truncate(name, size)
+}
+
+func (fs *TestRecordingStreamStore) Copy(src, dst string) error {
+	fs.record("Copy", src, dst)
+	return fs.wrapped.Copy(src, dst)
+}
+
+func (fs *TestRecordingStreamStore) Close() error {
+	fs.record("Close")
+	return fs.wrapped.Close()
+}
+
+func (fs *TestRecordingStreamStore) record(funcName string, args ...interface{}) {
+	fs.lk.Lock()
+	defer fs.lk.Unlock()
+	call := funcName
+	for _, arg := range args {
+		call += fmt.Sprintf(" %v", arg)
+	}
+	fs.calls = append(fs.calls, call)
+}
+
+// Calls returns the recorded calls whose name is one of funcNames, or all
+// calls if no names are given.
+func (fs *TestRecordingStreamStore) Calls(funcNames ...string) []string {
+	fs.lk.Lock()
+	defer fs.lk.Unlock()
+	var calls []string
+	for _, call := range fs.calls {
+		if len(funcNames) == 0 {
+			calls = append(calls, call)
+			continue
+		}
+		for _, funcName := range funcNames {
+			if strings.SplitN(call, " ", 2)[0] == funcName {
+				calls = append(calls, call)
+			}
+		}
+	}
+	return calls
+}
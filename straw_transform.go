@@ -0,0 +1,126 @@
+package straw
+
+import (
+	"io"
+	"os"
+)
+
+var _ StreamStore = &transformStreamStore{}
+
+// NewTransformReader returns a StreamStore which passes the content of every
+// file opened with OpenReadCloser through transform. All other operations are
+// passed directly to ss.
+// The returned readers only support Seek and ReadAt if the io.Reader returned
+// by transform implements io.Seeker and io.ReaderAt respectively, otherwise
+// those methods return ErrNotSupported. If the transformed reader implements
+// io.Closer, it is closed before the underlying reader.
+func NewTransformReader(ss StreamStore, transform func(io.Reader) io.Reader) StreamStore {
+	return &transformStreamStore{ss, transform}
+}
+
+type transformStreamStore struct {
+	ss        StreamStore
+	transform func(io.Reader) io.Reader
+}
+
+func (fs *transformStreamStore) Close() error {
+	return fs.ss.Close()
+}
+
+func (fs *transformStreamStore) Unwrap() StreamStore {
+	return fs.ss
+}
+
+func (fs *transformStreamStore) OpenReadCloser(name string) (StrawReader, error) {
+	r, err := fs.ss.OpenReadCloser(name)
+	if err != nil {
+		return nil, err
+	}
+	return &transformReader{fs.transform(r), r}, nil
+}
+
+func (fs *transformStreamStore) CreateWriteCloser(name string) (StrawWriter, error) {
+	return fs.ss.CreateWriteCloser(name)
+}
+
+// OpenFile applies the transform to files opened read only. Files opened for
+// writing are passed directly to ss, so are not transformed when read.
+func (fs *transformStreamStore) OpenFile(name string, flag int, perm os.FileMode) (StrawReadWriteCloser, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return OpenFileBuffered(fs, name, flag, nil)
+	}
+	return fs.ss.OpenFile(name, flag, perm)
+}
+
+func (fs *transformStreamStore) Lstat(path string) (os.FileInfo, error) {
+	return fs.ss.Lstat(path)
+}
+
+func (fs *transformStreamStore) Stat(path string) (os.FileInfo, error) {
+	return fs.ss.Stat(path)
+}
+
+func (fs *transformStreamStore) Readdir(path string) ([]os.FileInfo, error) {
+	return fs.ss.Readdir(path)
+}
+
+func (fs *transformStreamStore) Mkdir(path string, mode os.FileMode) error {
+	return fs.ss.Mkdir(path, mode)
+}
+
+func (fs *transformStreamStore) Remove(path string) error {
+	return fs.ss.Remove(path)
+}
+
+func (fs *transformStreamStore) Chmod(name string, mode os.FileMode) error {
+	return fs.ss.Chmod(name, mode)
+}
+
+// Truncate changes the size of the untransformed content of name.
+func (fs *transformStreamStore) Truncate(name string, size int64) error {
+	return fs.ss.Truncate(name, size)
+}
+
+func (fs *transformStreamStore) Symlink(oldname, newname string) error {
+	return Symlink(fs.ss, oldname, newname)
+}
+
+func (fs *transformStreamStore) Readlink(name string) (string, error) {
+	return Readlink(fs.ss, name)
+}
+
+// Copy copies the untransformed content of src.
+func (fs *transformStreamStore) Copy(src, dst string) error {
+	return fs.ss.Copy(src, dst)
+}
+
+type transformReader struct {
+	io.Reader
+	src StrawReader
+}
+
+func (r *transformReader) ReadAt(buf []byte, off int64) (int, error) {
+	ra, ok := r.Reader.(io.ReaderAt)
+	if !ok {
+		return 0, ErrNotSupported
+	}
+	return ra.ReadAt(buf, off)
+}
+
+func (r *transformReader) Seek(offset int64, whence int) (int64, error) {
+	s, ok := r.Reader.(io.Seeker)
+	if !ok {
+		return 0, ErrNotSupported
+	}
+	return s.Seek(offset, whence)
+}
+
+func (r *transformReader) Close() error {
+	if c, ok := r.Reader.(io.Closer); ok {
+		if err := c.Close(); err != nil {
+			r.src.Close()
+			return err
+		}
+	}
+	return r.src.Close()
+}
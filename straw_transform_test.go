@@ -0,0 +1,84 @@
+package straw_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+type rot13Reader struct {
+	r io.Reader
+}
+
+func (r rot13Reader) Read(buf []byte) (int, error) {
+	n, err := r.r.Read(buf)
+	for i := 0; i < n; i++ {
+		switch c := buf[i]; {
+		case c >= 'a' && c <= 'z':
+			buf[i] = 'a' + (c-'a'+13)%26
+		case c >= 'A' && c <= 'Z':
+			buf[i] = 'A' + (c-'A'+13)%26
+		}
+	}
+	return n, err
+}
+
+func TestTransformReader(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, _ := straw.Open("mem://")
+	writeContent(t, ss, "/a", []byte("Hello, World!"))
+
+	ts := straw.NewTransformReader(ss, func(r io.Reader) io.Reader { return rot13Reader{r} })
+
+	r, err := ts.OpenReadCloser("/a")
+	require.NoError(err)
+	data, err := ioutil.ReadAll(r)
+	assert.NoError(err)
+	assert.Equal("Uryyb, Jbeyq!", string(data))
+
+	_, err = r.Seek(0, io.SeekStart)
+	assert.Equal(straw.ErrNotSupported, err)
+	_, err = r.ReadAt(make([]byte, 1), 0)
+	assert.Equal(straw.ErrNotSupported, err)
+	assert.NoError(r.Close())
+
+	// everything other than reading is unaffected.
+	fi, err := ts.Stat("/a")
+	require.NoError(err)
+	assert.Equal(int64(13), fi.Size())
+}
+
+func TestTransformReaderSeekable(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, _ := straw.Open("mem://")
+	writeContent(t, ss, "/a", []byte("Hello, World!"))
+
+	ts := straw.NewTransformReader(ss, func(r io.Reader) io.Reader {
+		data, _ := ioutil.ReadAll(r)
+		return bytes.NewReader(bytes.ToUpper(data))
+	})
+
+	r, err := ts.OpenReadCloser("/a")
+	require.NoError(err)
+
+	buf := make([]byte, 5)
+	_, err = r.ReadAt(buf, 7)
+	assert.NoError(err)
+	assert.Equal("WORLD", string(buf))
+
+	_, err = r.Seek(7, io.SeekStart)
+	assert.NoError(err)
+	data, err := ioutil.ReadAll(r)
+	assert.NoError(err)
+	assert.Equal("WORLD!", string(data))
+	assert.NoError(r.Close())
+}
@@ -0,0 +1,1131 @@
+// Package strawtest provides a conformance suite for StreamStore
+// implementations, being the tests which the backends of straw themselves
+// pass, so that the authors of other backends can check that theirs behave
+// the same way.
+package strawtest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+// RunConformance runs the conformance suite against the stores returned by
+// newStore, as a subtest of t for each test in the suite. newStore is called
+// afresh for each subtest, and may return the same store each time. Each test
+// works within a directory of its own under root, which must exist and be
+// empty, and which the tests leave behind.
+//
+// The suite covers the semantics documented on straw.StreamStore and its
+// readers and writers, such as the errors returned for missing paths and
+// directories, overwriting files, and seeking. Optional features, such as
+// symbolic links, are skipped for stores which return straw.ErrNotSupported.
+func RunConformance(t *testing.T, newStore func() straw.StreamStore, root string) {
+	tester := &fsTester{ff: newStore, testRoot: root}
+
+	typ := reflect.TypeOf(tester)
+	val := reflect.ValueOf(tester)
+	nm := typ.NumMethod()
+	for i := 0; i < nm; i++ {
+		mName := typ.Method(i).Name
+		if strings.HasPrefix(mName, "Test") {
+			tester.fs = tester.ff()
+			t.Run(mName, val.Method(i).Interface().(func(*testing.T)))
+		}
+	}
+}
+
+type fsTester struct {
+	fs       straw.StreamStore
+	ff       func() straw.StreamStore
+	testRoot string
+}
+
+func (fst *fsTester) TestOpenReadCloserNotExisting(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := fst.fs.OpenReadCloser("/does/not/exist")
+	assert.True(os.IsNotExist(err))
+	assert.Nil(f)
+}
+
+func (fst *fsTester) TestOpenReadCloserOnDirectory(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	name := filepath.Join(fst.testRoot, "TestOpenReadCloserOnDirectory")
+
+	err := fst.fs.Mkdir(name, 0755)
+	require.NoError(err)
+
+	f, err := fst.fs.OpenReadCloser(name)
+	assertPathError(t, err, name, straw.ErrIsDirectory)
+	assert.Nil(f)
+}
+
+func (fst *fsTester) TestCreateNewWriteOnly(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	name := filepath.Join(fst.testRoot, "TestCreateNewWriteOnly")
+
+	f, err := fst.fs.CreateWriteCloser(name)
+	require.NoError(err)
+	assert.NotNil(f)
+	require.NoError(writeAll(f, []byte{0, 1, 2, 3, 4}))
+	require.NoError(f.Close())
+
+	fi, err := fst.fs.Stat(name)
+	require.NoError(err)
+	assert.Equal(fi.Size(), int64(5))
+	assert.Equal(fi.IsDir(), false)
+
+	files, err := fst.fs.Readdir(fst.testRoot)
+	require.NoError(err)
+	assert.Equal(1, len(files))
+
+	assert.False(files[0].IsDir())
+	assert.Equal("TestCreateNewWriteOnly", files[0].Name())
+	assert.Equal(int64(5), files[0].Size())
+	assert.Equal(os.FileMode(0644), files[0].Mode())
+}
+
+func (fst *fsTester) TestCreateWriteOnlyOnExistingDir(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	name := filepath.Join(fst.testRoot, "TestCreateWriteOnlyOnExistingDir")
+
+	err := fst.fs.Mkdir(name, 0755)
+	require.NoError(err)
+
+	f, err := fst.fs.CreateWriteCloser(name)
+	require.NotNil(err)
+	assert.True(errors.Is(err, straw.ErrIsDirectory), "error does not match : %s", err)
+	assert.Nil(f)
+
+	fi, err := fst.fs.Stat(name)
+	require.NoError(err)
+	assert.Equal(fi.Size(), int64(4096))
+	assert.Equal(fi.IsDir(), true)
+}
+
+func (fst *fsTester) TestCreateWriteOnlyInExistingFile(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	filename := filepath.Join(fst.testRoot, "TestCreateWriteOnlyInExistingFile")
+	f, err := fst.fs.CreateWriteCloser(filename)
+	require.NoError(err)
+	require.NoError(writeAll(f, []byte{0, 1, 2, 3, 4}))
+	require.NoError(f.Close())
+
+	name := filepath.Join(filename, "another_filename")
+
+	f, err = fst.fs.CreateWriteCloser(name)
+	require.NotNil(err)
+	assert.True(errors.Is(err, straw.ErrNotDirectory), "error does not match : %s", err)
+	assert.Nil(f)
+}
+
+func (fst *fsTester) TestMkdirAtRoot(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	name := filepath.Join(fst.testRoot, "TestMkdirAtRoot")
+
+	err := fst.fs.Mkdir(name, 0755)
+	require.NoError(err)
+
+	fi, err := fst.fs.Stat(name)
+	require.NoError(err)
+	assert.Equal(fi.Size(), int64(4096))
+	assert.Equal(fi.IsDir(), true)
+}
+
+func (fst *fsTester) TestMkdirTrailingSlash(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	name := filepath.Join(fst.testRoot, "TestMkdirTrailingSlash")
+	name = name + "/"
+
+	err := fst.fs.Mkdir(name, 0755)
+	require.NoError(err)
+
+	fi, err := fst.fs.Stat(name)
+	require.NoError(err)
+	assert.Equal(fi.Size(), int64(4096))
+	assert.Equal(fi.IsDir(), true)
+}
+
+func (fst *fsTester) TestMkdirOnExistingDir(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	name := filepath.Join(fst.testRoot, "TestMkdirOnExistingDir")
+
+	require.NoError(fst.fs.Mkdir(name, 0755))
+
+	err := fst.fs.Mkdir(name, 0755)
+	require.NotNil(err)
+	assert.Condition(func() bool { return strings.HasSuffix(err.Error(), "file exists") }, "error does not match: %s", err.Error())
+}
+
+func (fst *fsTester) TestMkdirOnExistingFile(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	name := filepath.Join(fst.testRoot, "TestMkdirOnExistingFile")
+	require.NoError(fst.fs.Mkdir(name, 0755))
+
+	filename := filepath.Join(name, "testfile")
+	f, err := fst.fs.CreateWriteCloser(filename)
+	require.NoError(err)
+	require.NoError(writeAll(f, []byte{0, 1, 2, 3, 4}))
+	require.NoError(f.Close())
+
+	err = fst.fs.Mkdir(filename, 0755)
+	assert.Condition(func() bool { return strings.HasSuffix(err.Error(), "file exists") })
+}
+
+func (fst *fsTester) TestMkdirInNonExistingDir(t *testing.T) {
+	assert := assert.New(t)
+
+	name := filepath.Join(fst.testRoot, "TestMkdirInNonExistingDir")
+	name = filepath.Join(name, "innerdir")
+	err := fst.fs.Mkdir(name, 0755)
+
+	assert.True(os.IsNotExist(err))
+}
+
+func (fst *fsTester) TestRemoveNonExistingAtRoot(t *testing.T) {
+	assert := assert.New(t)
+
+	err := fst.fs.Remove("not_existing_file")
+	assert.True(os.IsNotExist(err))
+}
+
+func (fst *fsTester) TestRemoveNonExistingInSubdir(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	top := filepath.Join(fst.testRoot, "TestRemoveNonExistingInSubdir")
+	require.NoError(fst.fs.Mkdir(top, 0755))
+
+	err := fst.fs.Remove(filepath.Join(top, "not_existing_file"))
+	assert.True(os.IsNotExist(err))
+}
+
+func (fst *fsTester) TestRemoveParentDirDoesNotExist(t *testing.T) {
+	assert := assert.New(t)
+
+	parent := filepath.Join(fst.testRoot, "TestRemoveParentDirDoesNotExist")
+	child := filepath.Join(parent, "some_filename")
+
+	err := fst.fs.Remove(child)
+	assert.True(os.IsNotExist(err))
+}
+
+func (fst *fsTester) TestRemoveEmptyDir(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	name := filepath.Join(fst.testRoot, "TestRemoveEmptyDir")
+
+	err := fst.fs.Mkdir(name, 0755)
+	require.NoError(err)
+
+	fi, err := fst.fs.Stat(name)
+	assert.NoError(err)
+	assert.NotNil(fi)
+
+	assert.NoError(fst.fs.Remove(name))
+
+	fi, err = fst.fs.Stat(name)
+	assert.Nil(fi)
+	require.NotNil(err)
+	assert.True(os.IsNotExist(err))
+}
+
+func (fst *fsTester) TestRemoveNonEmptyDir(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	name := filepath.Join(fst.testRoot, "TestRemoveNonEmptyDir")
+
+	err := fst.fs.Mkdir(name, 0755)
+	require.NoError(err)
+
+	w1, err := fst.fs.CreateWriteCloser(filepath.Join(name, "a_file"))
+	require.NoError(err)
+	assert.NoError(writeAll(w1, []byte{0, 1, 2, 3, 4}))
+	assert.NoError(w1.Close())
+
+	err = fst.fs.Remove(name)
+	require.NotNil(err)
+	assert.True(errors.Is(err, straw.ErrDirectoryNotEmpty), "error does not match : %s", err)
+
+	fi, err := fst.fs.Stat(name)
+	require.NoError(err)
+	assert.Equal(fi.Name(), "TestRemoveNonEmptyDir")
+}
+
+func (fst *fsTester) TestRemoveFileInDir(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dirname := filepath.Join(fst.testRoot, "TestRemoveFileInDir")
+	filename := filepath.Join(dirname, "a_file")
+
+	require.NoError(fst.fs.Mkdir(dirname, 0755))
+	require.NoError(fst.writeFile(fst.fs, filename, []byte{1}))
+
+	fi, err := fst.fs.Stat(filename)
+	assert.NoError(err)
+	assert.NotNil(fi)
+
+	assert.NoError(fst.fs.Remove(filename))
+
+	fi, err = fst.fs.Stat(filename)
+	assert.Nil(fi)
+	require.NotNil(err)
+	assert.True(os.IsNotExist(err))
+}
+
+func (fst *fsTester) TestOverwrite(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	name := filepath.Join(fst.testRoot, "TestOverwrite")
+
+	w1, err := fst.fs.CreateWriteCloser(name)
+	require.NoError(err)
+	assert.NotNil(w1)
+	assert.NoError(writeAll(w1, []byte{0, 1, 2, 3, 4}))
+
+	assert.NoError(w1.Close())
+
+	r1, err := fst.fs.OpenReadCloser(name)
+	require.NoError(err)
+	assert.NotNil(r1)
+	all, err := ioutil.ReadAll(r1)
+	assert.NoError(err)
+	assert.Equal([]byte{0, 1, 2, 3, 4}, all)
+
+	w2, err := fst.fs.CreateWriteCloser(name)
+	assert.NoError(err)
+	assert.NotNil(w2)
+	assert.NoError(writeAll(w2, []byte{5, 6, 7}))
+	assert.NoError(w2.Close())
+
+	r2, err := fst.fs.OpenReadCloser(name)
+	assert.NoError(err)
+	assert.NotNil(r2)
+	all, err = ioutil.ReadAll(r2)
+	assert.NoError(err)
+	assert.Equal([]byte{5, 6, 7}, all)
+}
+
+func (fst *fsTester) TestOpenFileAppend(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := filepath.Join(fst.testRoot, "TestOpenFileAppend")
+	name := filepath.Join(dir, "file")
+	require.NoError(fst.fs.Mkdir(dir, 0755))
+
+	f, err := fst.fs.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	require.NoError(err)
+	assert.NotNil(f)
+	assert.NoError(writeAll(f, []byte{0, 1, 2, 3, 4}))
+	assert.NoError(f.Close())
+
+	f, err = fst.fs.OpenFile(name, os.O_RDONLY, 0)
+	require.NoError(err)
+	assert.NotNil(f)
+	all, err := ioutil.ReadAll(f)
+	assert.NoError(err)
+	assert.Equal([]byte{0, 1, 2, 3, 4}, all)
+	_, err = f.Write([]byte{9})
+	assert.Error(err)
+	assert.NoError(f.Close())
+
+	f, err = fst.fs.OpenFile(name, os.O_RDWR|os.O_APPEND, 0666)
+	require.NoError(err)
+	assert.NotNil(f)
+	assert.NoError(writeAll(f, []byte{5, 6, 7}))
+	assert.NoError(f.Close())
+
+	f, err = fst.fs.OpenFile(name, os.O_RDONLY, 0)
+	require.NoError(err)
+	assert.NotNil(f)
+	all, err = ioutil.ReadAll(f)
+	assert.NoError(err)
+	assert.Equal([]byte{0, 1, 2, 3, 4, 5, 6, 7}, all)
+	assert.NoError(f.Close())
+
+	f, err = fst.fs.OpenFile(name, os.O_WRONLY|os.O_TRUNC, 0)
+	require.NoError(err)
+	assert.NoError(writeAll(f, []byte{8}))
+	assert.NoError(f.Close())
+
+	all, err = straw.ReadFile(fst.fs, name)
+	assert.NoError(err)
+	assert.Equal([]byte{8}, all)
+}
+
+func (fst *fsTester) TestOpenFileExclusive(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := filepath.Join(fst.testRoot, "TestOpenFileExclusive")
+	name := filepath.Join(dir, "file")
+	require.NoError(fst.fs.Mkdir(dir, 0755))
+
+	_, err := fst.fs.OpenFile(name, os.O_RDONLY, 0)
+	assert.True(os.IsNotExist(err))
+
+	f, err := fst.fs.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	require.NoError(err)
+
+	// the file exists as soon as it is created.
+	_, err = fst.fs.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	assert.True(os.IsExist(err))
+
+	assert.NoError(writeAll(f, []byte{1, 2}))
+	assert.NoError(f.Close())
+
+	_, err = fst.fs.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	assert.True(os.IsExist(err))
+
+	all, err := straw.ReadFile(fst.fs, name)
+	assert.NoError(err)
+	assert.Equal([]byte{1, 2}, all)
+}
+
+func (fst *fsTester) TestOpenFileWriteAt(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := filepath.Join(fst.testRoot, "TestOpenFileWriteAt")
+	name := filepath.Join(dir, "file")
+	require.NoError(fst.fs.Mkdir(dir, 0755))
+
+	f, err := fst.fs.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	require.NoError(err)
+	assert.NotNil(f)
+
+	i, err := f.WriteAt([]byte{1, 2}, 14)
+	assert.NoError(err)
+	assert.Equal(2, i)
+	assert.NoError(f.Close())
+
+	fi, err := fst.fs.Stat(name)
+	assert.NoError(err)
+	assert.Equal(fi.Size(), int64(16))
+
+	f, err = fst.fs.OpenFile(name, os.O_RDONLY, 0)
+	require.NoError(err)
+	assert.NotNil(f)
+	all, err := ioutil.ReadAll(f)
+	assert.NoError(err)
+	assert.Equal([]byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 2}, all)
+	assert.NoError(f.Close())
+}
+
+func (fst *fsTester) TestWriterAt(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := filepath.Join(fst.testRoot, "TestWriterAt")
+	name := filepath.Join(dir, "file")
+	require.NoError(fst.fs.Mkdir(dir, 0755))
+
+	w, err := fst.fs.CreateWriteCloser(name)
+	require.NoError(err)
+	wa, ok := w.(io.WriterAt)
+	if !ok {
+		w.Close()
+		t.Skip("store does not support WriteAt")
+	}
+
+	assert.NoError(writeAll(w, []byte{1, 2}))
+	i, err := wa.WriteAt([]byte{5, 6}, 4)
+	assert.NoError(err)
+	assert.Equal(2, i)
+	_, err = wa.WriteAt([]byte{9}, 0)
+	assert.NoError(err)
+	// WriteAt does not move the offset used by Write.
+	assert.NoError(writeAll(w, []byte{3}))
+	assert.NoError(w.Close())
+
+	all, err := straw.ReadFile(fst.fs, name)
+	assert.NoError(err)
+	assert.Equal([]byte{9, 2, 3, 0, 5, 6}, all)
+}
+
+func (fst *fsTester) TestWriterCloseTwice(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := filepath.Join(fst.testRoot, "TestWriterCloseTwice")
+	name := filepath.Join(dir, "file")
+	require.NoError(fst.fs.Mkdir(dir, 0755))
+
+	w, err := fst.fs.CreateWriteCloser(name)
+	require.NoError(err)
+	assert.NoError(writeAll(w, []byte("data")))
+	require.NoError(w.Close())
+
+	err = w.Close()
+	assert.True(errors.Is(err, straw.ErrClosed), "second Close: %v", err)
+	_, err = w.Write([]byte("more"))
+	assert.True(errors.Is(err, straw.ErrClosed), "Write after Close: %v", err)
+	if wa, ok := w.(io.WriterAt); ok {
+		_, err = wa.WriteAt([]byte("more"), 0)
+		assert.True(errors.Is(err, straw.ErrClosed), "WriteAt after Close: %v", err)
+	}
+
+	all, err := straw.ReadFile(fst.fs, name)
+	assert.NoError(err)
+	assert.Equal("data", string(all))
+}
+
+func (fst *fsTester) TestWriterReadFrom(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := filepath.Join(fst.testRoot, "TestWriterReadFrom")
+	name := filepath.Join(dir, "file")
+	require.NoError(fst.fs.Mkdir(dir, 0755))
+
+	w, err := fst.fs.CreateWriteCloser(name)
+	require.NoError(err)
+	rf, ok := w.(io.ReaderFrom)
+	if !ok {
+		w.Close()
+		t.Skip("writer does not implement io.ReaderFrom")
+	}
+
+	data := make([]byte, 100000)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	assert.NoError(writeAll(w, []byte{1, 2}))
+	// hide the WriterTo of bytes.Reader, which io.Copy would prefer.
+	n, err := rf.ReadFrom(struct{ io.Reader }{bytes.NewReader(data)})
+	assert.NoError(err)
+	assert.Equal(int64(len(data)), n)
+	assert.NoError(writeAll(w, []byte{3}))
+	assert.NoError(w.Close())
+
+	all, err := straw.ReadFile(fst.fs, name)
+	assert.NoError(err)
+	assert.Equal(append(append([]byte{1, 2}, data...), 3), all)
+}
+
+func (fst *fsTester) TestFileChmod(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := filepath.Join(fst.testRoot, "TestFileChmod")
+	file := filepath.Join(dir, "file")
+
+	require.NoError(fst.fs.Mkdir(dir, 0755))
+	require.NoError(fst.writeFile(fst.fs, file, []byte{1}))
+
+	require.NoError(fst.fs.Chmod(file, 0600))
+	fi, err := fst.fs.Stat(file)
+	require.NoError(err)
+	assert.Equal(os.FileMode(0600), fi.Mode().Perm())
+
+	require.NoError(fst.fs.Chmod(file, 0640))
+	fi, err = fst.fs.Stat(file)
+	require.NoError(err)
+	assert.Equal(os.FileMode(0640), fi.Mode().Perm())
+
+	err = fst.fs.Chmod(filepath.Join(dir, "missing"), 0600)
+	assert.True(os.IsNotExist(err))
+}
+
+func (fst *fsTester) TestSymlink(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := filepath.Join(fst.testRoot, "TestSymlink")
+	file := filepath.Join(dir, "file")
+	link := filepath.Join(dir, "link")
+
+	require.NoError(fst.fs.Mkdir(dir, 0755))
+	require.NoError(fst.writeFile(fst.fs, file, []byte{1, 2, 3}))
+
+	err := straw.Symlink(fst.fs, "file", link)
+	if err == straw.ErrNotSupported {
+		_, err = straw.Readlink(fst.fs, link)
+		assert.Equal(straw.ErrNotSupported, err)
+		t.Skip("store does not support symlinks")
+	}
+	require.NoError(err)
+
+	dest, err := straw.Readlink(fst.fs, link)
+	require.NoError(err)
+	assert.Equal("file", dest)
+
+	fi, err := fst.fs.Lstat(link)
+	require.NoError(err)
+	assert.Equal(os.ModeSymlink, fi.Mode()&os.ModeSymlink)
+
+	fi, err = fst.fs.Stat(link)
+	require.NoError(err)
+	assert.True(fi.Mode().IsRegular())
+	assert.Equal(int64(3), fi.Size())
+
+	data, err := straw.ReadFile(fst.fs, link)
+	require.NoError(err)
+	assert.Equal([]byte{1, 2, 3}, data)
+}
+
+func (fst *fsTester) TestTruncate(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := filepath.Join(fst.testRoot, "TestTruncate")
+	file := filepath.Join(dir, "file")
+
+	require.NoError(fst.fs.Mkdir(dir, 0755))
+	require.NoError(fst.writeFile(fst.fs, file, []byte{1, 2, 3, 4, 5}))
+
+	require.NoError(fst.fs.Truncate(file, 2))
+	data, err := straw.ReadFile(fst.fs, file)
+	require.NoError(err)
+	assert.Equal([]byte{1, 2}, data)
+
+	require.NoError(fst.fs.Truncate(file, 4))
+	fi, err := fst.fs.Stat(file)
+	require.NoError(err)
+	assert.Equal(int64(4), fi.Size())
+	data, err = straw.ReadFile(fst.fs, file)
+	require.NoError(err)
+	assert.Equal([]byte{1, 2, 0, 0}, data)
+
+	require.NoError(fst.fs.Truncate(file, 0))
+	data, err = straw.ReadFile(fst.fs, file)
+	require.NoError(err)
+	assert.Empty(data)
+
+	assert.Error(fst.fs.Truncate(file, -1))
+	assert.True(os.IsNotExist(fst.fs.Truncate(filepath.Join(dir, "missing"), 0)))
+}
+
+func (fst *fsTester) TestFileCopy(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := filepath.Join(fst.testRoot, "TestFileCopy")
+	src := filepath.Join(dir, "src")
+	dst := filepath.Join(dir, "dst")
+
+	require.NoError(fst.fs.Mkdir(dir, 0755))
+	require.NoError(fst.writeFile(fst.fs, src, []byte{0, 1, 2, 3, 4}))
+	require.NoError(fst.writeFile(fst.fs, dst, []byte{5, 6, 7, 8, 9, 10}))
+
+	require.NoError(fst.fs.Copy(src, dst))
+
+	r, err := fst.fs.OpenReadCloser(dst)
+	require.NoError(err)
+	all, err := ioutil.ReadAll(r)
+	assert.NoError(err)
+	assert.NoError(r.Close())
+	assert.Equal([]byte{0, 1, 2, 3, 4}, all)
+
+	r, err = fst.fs.OpenReadCloser(src)
+	require.NoError(err)
+	all, err = ioutil.ReadAll(r)
+	assert.NoError(err)
+	assert.NoError(r.Close())
+	assert.Equal([]byte{0, 1, 2, 3, 4}, all)
+}
+
+func (fst *fsTester) TestFileCopyOnDirectory(t *testing.T) {
+	require := require.New(t)
+
+	parent := filepath.Join(fst.testRoot, "TestFileCopyOnDirectory")
+	dir := filepath.Join(parent, "dir")
+	file := filepath.Join(parent, "file")
+
+	require.NoError(fst.fs.Mkdir(parent, 0755))
+	require.NoError(fst.fs.Mkdir(dir, 0755))
+	require.NoError(fst.writeFile(fst.fs, file, []byte{1}))
+
+	assertPathError(t, fst.fs.Copy(dir, file), dir, straw.ErrIsDirectory)
+	assertPathError(t, fst.fs.Copy(file, dir), dir, straw.ErrIsDirectory)
+	assertPathError(t, fst.fs.Copy(filepath.Join(dir, "missing"), file), filepath.Join(dir, "missing"), os.ErrNotExist)
+}
+
+func (fst *fsTester) TestPathErrors(t *testing.T) {
+	require := require.New(t)
+
+	dir := filepath.Join(fst.testRoot, "TestPathErrors")
+	file := filepath.Join(dir, "file")
+	sub := filepath.Join(dir, "sub")
+	missing := filepath.Join(dir, "missing")
+
+	require.NoError(fst.fs.Mkdir(dir, 0755))
+	require.NoError(fst.fs.Mkdir(sub, 0755))
+	require.NoError(fst.writeFile(fst.fs, file, []byte{1}))
+	require.NoError(fst.writeFile(fst.fs, filepath.Join(sub, "file"), []byte{1}))
+
+	_, err := fst.fs.Stat(missing)
+	assertPathError(t, err, missing, os.ErrNotExist)
+	_, err = fst.fs.Lstat(missing)
+	assertPathError(t, err, missing, os.ErrNotExist)
+	_, err = fst.fs.OpenReadCloser(missing)
+	assertPathError(t, err, missing, os.ErrNotExist)
+	_, err = fst.fs.Readdir(missing)
+	assertPathError(t, err, missing, os.ErrNotExist)
+	assertPathError(t, fst.fs.Remove(missing), missing, os.ErrNotExist)
+	assertPathError(t, fst.fs.Chmod(missing, 0644), missing, os.ErrNotExist)
+
+	_, err = fst.fs.OpenReadCloser(dir)
+	assertPathError(t, err, dir, straw.ErrIsDirectory)
+	_, err = fst.fs.CreateWriteCloser(dir)
+	assertPathError(t, err, dir, straw.ErrIsDirectory)
+
+	_, err = fst.fs.CreateWriteCloser(filepath.Join(file, "x"))
+	assertPathError(t, err, filepath.Join(file, "x"), straw.ErrNotDirectory)
+
+	assertPathError(t, fst.fs.Remove(sub), sub, straw.ErrDirectoryNotEmpty)
+}
+
+func (fst *fsTester) TestReaddir(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := filepath.Join(fst.testRoot, "TestReaddir")
+	dir1 := filepath.Join(dir, "dir1")
+	file1 := filepath.Join(dir, "file1")
+	file2 := filepath.Join(dir1, "file2")
+
+	require.NoError(fst.fs.Mkdir(dir, 0755))
+	require.NoError(fst.fs.Mkdir(dir1, 0755))
+	require.NoError(fst.writeFile(fst.fs, file1, []byte{1}))
+	require.NoError(fst.writeFile(fst.fs, file2, []byte{2}))
+
+	rd1, err := fst.fs.Readdir(dir)
+	assert.NoError(err)
+	assert.Equal(2, len(rd1))
+
+	assert.Equal("dir1", rd1[0].Name())
+	assert.Equal("file1", rd1[1].Name())
+
+	rd2, err := fst.fs.Readdir(dir1)
+	assert.NoError(err)
+	assert.Equal(1, len(rd2))
+
+	assert.Equal("file2", rd2[0].Name())
+}
+
+func (fst *fsTester) TestReaddirMoreThanMaxKeysFiles(t *testing.T) {
+	// max keys defaults to 1000
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := filepath.Join(fst.testRoot, "TestReaddirManyFiles")
+	require.NoError(fst.fs.Mkdir(dir, 0755))
+	for i := 0; i < 1010; i++ {
+		if i%100 == 0 {
+			t.Logf("created %d files", i)
+		}
+		file := filepath.Join(dir, fmt.Sprintf("file%d", i))
+		require.NoError(fst.writeFile(fst.fs, file, []byte{1}))
+	}
+	rd1, err := fst.fs.Readdir(dir)
+	assert.NoError(err)
+	require.Equal(1010, len(rd1))
+	assert.True(sort.SliceIsSorted(rd1, func(i, j int) bool { return rd1[i].Name() < rd1[j].Name() }))
+}
+
+func (fst *fsTester) TestReaddirSorted(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := filepath.Join(fst.testRoot, "TestReaddirSorted")
+	require.NoError(fst.fs.Mkdir(dir, 0755))
+	// created out of order, and with a directory "a" which object stores
+	// list as the prefix "a/", after "a.txt".
+	for _, name := range []string{"b", "a.txt", "B", "a0"} {
+		require.NoError(fst.writeFile(fst.fs, filepath.Join(dir, name), []byte{1}))
+	}
+	require.NoError(fst.fs.Mkdir(filepath.Join(dir, "a"), 0755))
+	require.NoError(fst.writeFile(fst.fs, filepath.Join(dir, "a", "file"), []byte{1}))
+
+	fis, err := fst.fs.Readdir(dir)
+	require.NoError(err)
+	var names []string
+	for _, fi := range fis {
+		names = append(names, fi.Name())
+	}
+	assert.Equal([]string{"B", "a", "a.txt", "a0", "b"}, names)
+}
+
+func (fst *fsTester) TestStat(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := filepath.Join(fst.testRoot, "TestStat")
+	dir1 := filepath.Join(dir, "dir")
+	file := filepath.Join(dir1, "file")
+
+	require.NoError(fst.fs.Mkdir(dir, 0755))
+	require.NoError(fst.fs.Mkdir(dir1, 0755))
+	require.NoError(fst.writeFile(fst.fs, file, []byte{2}))
+
+	fi, err := fst.fs.Stat(dir1)
+	assert.NoError(err)
+	assert.Equal(true, fi.IsDir())
+	assert.Equal("dir", fi.Name())
+	assert.Equal(os.FileMode(0755)|os.ModeDir, fi.Mode())
+	assert.Equal(int64(4096), fi.Size())
+
+	fi, err = fst.fs.Stat(file)
+	assert.NoError(err)
+	assert.Equal(false, fi.IsDir())
+	assert.Equal("file", fi.Name())
+	assert.Equal(os.FileMode(0644), fi.Mode())
+	assert.Equal(int64(1), fi.Size())
+
+	root := "/"
+	fi, err = fst.fs.Stat(root)
+	assert.NoError(err)
+	assert.Equal(true, fi.IsDir())
+}
+
+func (fst *fsTester) TestReadAtBasic(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := filepath.Join(fst.testRoot, "TestReadAtBasic")
+	file := filepath.Join(dir, "file")
+
+	data := make([]byte, 64)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	require.NoError(fst.fs.Mkdir(dir, 0755))
+	require.NoError(fst.writeFile(fst.fs, file, data))
+
+	r, err := fst.fs.OpenReadCloser(file)
+	require.NoError(err)
+	assert.NotNil(r)
+
+	buf := make([]byte, 1024)
+
+	// read first 4 bytes
+	i, err := r.ReadAt(buf[0:4], 0)
+	assert.NoError(err)
+	assert.Equal(4, i)
+	assert.Equal(data[0:i], buf[0:i])
+
+	// read second 4 bytes
+	i, err = r.ReadAt(buf[0:4], 4)
+	assert.NoError(err)
+	assert.Equal(4, i)
+	assert.Equal(data[4:i+4], buf[0:i])
+
+	// read all bytes, but not past file end
+	i, err = r.ReadAt(buf[0:len(data)], 0)
+	assert.NoError(err)
+	assert.Equal(len(data), i)
+	assert.Equal(data, buf[0:i])
+
+	// read all bytes, and past file
+	i, err = r.ReadAt(buf, 0)
+	assert.Equal(io.EOF, err)
+	assert.Equal(len(data), i)
+	assert.Equal(data, buf[0:i])
+
+	// read first 4 bytes again, now that we've seen EOF.
+	i, err = r.ReadAt(buf[0:4], 0)
+	assert.NoError(err)
+	assert.Equal(4, i)
+	assert.Equal(data[0:i], buf[0:i])
+}
+
+func (fst *fsTester) TestReadAtWithRead(t *testing.T) {
+	// This test shows that ReadAt does not move the file position Read sees.
+
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := filepath.Join(fst.testRoot, "TestReadAtWithRead")
+	file := filepath.Join(dir, "file")
+
+	data := make([]byte, 64)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	require.NoError(fst.fs.Mkdir(dir, 0755))
+	require.NoError(fst.writeFile(fst.fs, file, data))
+
+	r, err := fst.fs.OpenReadCloser(file)
+	require.NoError(err)
+	assert.NotNil(r)
+
+	buf := make([]byte, 1024)
+
+	// read 8 bytes, using Read
+	i, err := r.Read(buf[0:8])
+	assert.NoError(err)
+	assert.Equal(8, i)
+	assert.Equal(data[0:i], buf[0:i])
+
+	// read 4 bytes from position 40 using ReadAt
+	i, err = r.ReadAt(buf[0:4], 20)
+	assert.NoError(err)
+	assert.Equal(4, i)
+	assert.Equal(data[20:i+20], buf[0:i])
+
+	// read 8 bytes, using Read
+	i, err = r.Read(buf[0:8])
+	assert.NoError(err)
+	assert.Equal(8, i)
+	assert.Equal(data[8:i+8], buf[0:i])
+
+	// read to EOF, using Read
+	i, err = r.Read(buf)
+	// might get EOF now, or might be on next call.
+	if err == io.EOF {
+		assert.Equal(io.EOF, err)
+		assert.Equal(48, i)
+		assert.Equal(data[16:], buf[0:i])
+	} else {
+		i, err = r.Read(buf)
+		assert.Equal(io.EOF, err)
+		assert.Equal(0, i)
+	}
+
+	// readat should still work now.
+	i, err = r.ReadAt(buf[0:4], 20)
+	assert.NoError(err)
+	assert.Equal(4, i)
+	assert.Equal(data[20:i+20], buf[0:i])
+
+}
+
+func (fst *fsTester) TestSeek(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := filepath.Join(fst.testRoot, "TestSeek")
+	file := filepath.Join(dir, "file")
+
+	data := make([]byte, 64)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	require.NoError(fst.fs.Mkdir(dir, 0755))
+	require.NoError(fst.writeFile(fst.fs, file, data))
+
+	r, err := fst.fs.OpenReadCloser(file)
+	require.NoError(err)
+	assert.NotNil(r)
+
+	buf := make([]byte, 1024)
+
+	// read first 4 bytes
+	i, err := r.ReadAt(buf[0:4], 0)
+	assert.NoError(err)
+	assert.Equal(4, i)
+	assert.Equal(data[0:i], buf[0:i])
+
+	// seek to 48, read 4 bytes
+	pos, err := r.Seek(48, io.SeekStart)
+	assert.NoError(err)
+	assert.Equal(int64(48), pos)
+	i, err = r.Read(buf[0:4])
+	assert.NoError(err)
+	assert.Equal(4, i)
+	assert.Equal(data[48:i+48], buf[0:i])
+
+	// seek to almost end, try to read past end
+	pos, err = r.Seek(60, io.SeekStart)
+	assert.NoError(err)
+	assert.Equal(int64(60), pos)
+	i, err = r.Read(buf[0:8])
+	if err != nil && err != io.EOF {
+		assert.NoError(err)
+	}
+	assert.Equal(4, i)
+	assert.Equal(data[60:i+60], buf[0:i])
+
+	// next read should be EOF
+	i, err = r.Read(buf[0:8])
+	assert.Equal(io.EOF, err)
+	assert.Equal(0, i)
+
+	// read first 4 bytes again, now that we've seen EOF.
+	pos, err = r.Seek(0, io.SeekStart)
+	assert.NoError(err)
+	assert.Equal(int64(0), pos)
+	i, err = r.Read(buf[0:4])
+	assert.NoError(err)
+	assert.Equal(4, i)
+	assert.Equal(data[0:i], buf[0:i])
+
+	// seek past end
+	pos, err = r.Seek(128, io.SeekStart)
+	assert.NoError(err)
+	assert.Equal(int64(128), pos)
+	i, err = r.Read(buf[0:4])
+	assert.Equal(io.EOF, err)
+	assert.Equal(0, i)
+}
+
+func (fst *fsTester) TestSeekWhence(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := filepath.Join(fst.testRoot, "TestSeekWhence")
+	file := filepath.Join(dir, "file")
+
+	data := make([]byte, 64)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	require.NoError(fst.fs.Mkdir(dir, 0755))
+	require.NoError(fst.writeFile(fst.fs, file, data))
+
+	r, err := fst.fs.OpenReadCloser(file)
+	require.NoError(err)
+	defer r.Close()
+
+	buf := make([]byte, 4)
+
+	// read a trailer relative to the end
+	pos, err := r.Seek(-4, io.SeekEnd)
+	assert.NoError(err)
+	assert.Equal(int64(60), pos)
+	_, err = io.ReadFull(r, buf)
+	assert.NoError(err)
+	assert.Equal(data[60:64], buf)
+
+	// seek relative to the current position, after reading
+	pos, err = r.Seek(-40, io.SeekCurrent)
+	assert.NoError(err)
+	assert.Equal(int64(24), pos)
+	_, err = io.ReadFull(r, buf)
+	assert.NoError(err)
+	assert.Equal(data[24:28], buf)
+
+	pos, err = r.Seek(0, io.SeekCurrent)
+	assert.NoError(err)
+	assert.Equal(int64(28), pos)
+
+	// a negative resulting offset is an error, and leaves the offset alone
+	_, err = r.Seek(-1, io.SeekStart)
+	assert.Error(err)
+	_, err = r.Seek(-65, io.SeekEnd)
+	assert.Error(err)
+	_, err = r.Seek(-29, io.SeekCurrent)
+	assert.Error(err)
+	_, err = io.ReadFull(r, buf)
+	assert.NoError(err)
+	assert.Equal(data[28:32], buf)
+
+	// past the end reads io.EOF
+	pos, err = r.Seek(10, io.SeekEnd)
+	assert.NoError(err)
+	assert.Equal(int64(74), pos)
+	n, err := r.Read(buf)
+	assert.Equal(io.EOF, err)
+	assert.Equal(0, n)
+
+	_, err = r.Seek(0, 42)
+	assert.Error(err)
+}
+
+func (fst *fsTester) TestWriteTo(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := filepath.Join(fst.testRoot, "TestWriteTo")
+	file := filepath.Join(dir, "file")
+
+	data := make([]byte, 100000)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	require.NoError(fst.fs.Mkdir(dir, 0755))
+	require.NoError(fst.writeFile(fst.fs, file, data))
+
+	r, err := fst.fs.OpenReadCloser(file)
+	require.NoError(err)
+	defer r.Close()
+
+	wt, ok := r.(io.WriterTo)
+	require.True(ok, "reader does not implement io.WriterTo")
+
+	_, err = r.Seek(1000, io.SeekStart)
+	require.NoError(err)
+	var buf bytes.Buffer
+	n, err := wt.WriteTo(&buf)
+	require.NoError(err)
+	assert.Equal(int64(len(data)-1000), n)
+	assert.Equal(data[1000:], buf.Bytes())
+
+	// the reader is left at the end.
+	i, err := r.Read(make([]byte, 1))
+	assert.Equal(io.EOF, err)
+	assert.Equal(0, i)
+}
+
+func (fst *fsTester) writeFile(fs straw.StreamStore, name string, data []byte) error {
+	w, err := fs.CreateWriteCloser(name)
+	if err != nil {
+		return err
+	}
+	if err := writeAll(w, data); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// assertPathError asserts that err is, or wraps, an *os.PathError for name,
+// and that it wraps target.
+func assertPathError(t *testing.T, err error, name string, target error) {
+	var pe *os.PathError
+	if assert.True(t, errors.As(err, &pe), "not a PathError: %v", err) {
+		assert.Equal(t, name, pe.Path)
+	}
+	assert.True(t, errors.Is(err, target), "%v does not wrap %v", err, target)
+}
+
+func writeAll(w io.Writer, data []byte) error {
+	i, err := w.Write(data)
+	if err != nil {
+		return err
+	}
+	if i != len(data) {
+		return io.ErrShortWrite
+	}
+	return nil
+}
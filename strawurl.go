@@ -3,20 +3,42 @@ package straw
 import (
 	"fmt"
 	"net/url"
+	"strings"
 )
 
-func Open(u string) (StreamStore, error) {
+// Option configures a StreamStore opened with Open. Options are applied to the
+// parsed URL before it is passed to the backend, so each option has an
+// equivalent query parameter.
+type Option func(u *url.URL)
+
+// QueryOption returns an Option that sets the query parameter key to value,
+// replacing any value already present in the URL.
+func QueryOption(key, value string) Option {
+	return func(u *url.URL) {
+		q := u.Query()
+		q.Set(key, value)
+		u.RawQuery = q.Encode()
+	}
+}
+
+func Open(u string, opts ...Option) (StreamStore, error) {
 	parsed, err := url.Parse(u)
 	if err != nil {
 		return nil, err
 	}
 
+	for _, opt := range opts {
+		opt(parsed)
+	}
+
 	backendsLk.RLock()
 	defer backendsLk.RUnlock()
 
 	f := backends[parsed.Scheme]
 	if f == nil {
-		return nil, fmt.Errorf("unknown scheme : %s", parsed.Scheme)
+		// backends outside this package are easily forgotten, since they
+		// are registered by blank imports.
+		return nil, fmt.Errorf("unknown scheme %q; known schemes: %s", parsed.Scheme, strings.Join(schemes(), ", "))
 	}
 	return f(parsed)
 }
@@ -24,6 +46,6 @@ func Open(u string) (StreamStore, error) {
 func init() {
 	// the only "built in" backend is "file"
 	Register("file", func(u *url.URL) (StreamStore, error) {
-		return &osStreamStore{}, nil
+		return newOSStreamStore(u)
 	})
 }
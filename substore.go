@@ -0,0 +1,225 @@
+package straw
+
+import (
+	"errors"
+	"os"
+	"path"
+	"strings"
+)
+
+var _ StreamStore = &subStreamStore{}
+
+// NewSubStore returns a StreamStore confined to the directory prefix of ss.
+// Every path is interpreted relative to prefix, so "/a" refers to
+// prefix+"/a" in ss, and paths which would traverse above prefix are
+// rejected with an error wrapping ErrInvalidPath. The root of the returned
+// store is named "/", and the prefix is removed from the paths of any
+// *os.PathError returned by ss.
+// Closing the returned store closes ss.
+func NewSubStore(ss StreamStore, prefix string) StreamStore {
+	return &subStreamStore{ss, path.Clean("/" + prefix)}
+}
+
+type subStreamStore struct {
+	ss     StreamStore
+	prefix string
+}
+
+// full returns the path in the underlying store of name.
+func (fs *subStreamStore) full(name string) (string, error) {
+	name, err := CleanPath(name)
+	if err != nil {
+		return "", err
+	}
+	return path.Join(fs.prefix, name), nil
+}
+
+// err removes the prefix from the path of err if it is an *os.PathError, so
+// that the location of the sub-root is not revealed.
+func (fs *subStreamStore) err(err error) error {
+	var pe *os.PathError
+	if fs.prefix == "/" || !errors.As(err, &pe) {
+		return err
+	}
+	switch {
+	case pe.Path == fs.prefix:
+		return &os.PathError{Op: pe.Op, Path: "/", Err: pe.Err}
+	case strings.HasPrefix(pe.Path, fs.prefix+"/"):
+		return &os.PathError{Op: pe.Op, Path: strings.TrimPrefix(pe.Path, fs.prefix), Err: pe.Err}
+	default:
+		return err
+	}
+}
+
+// stat wraps fi, renaming it to "/" if it describes the sub-root.
+func (fs *subStreamStore) stat(full string, fi os.FileInfo) os.FileInfo {
+	if full != fs.prefix {
+		return fi
+	}
+	return &renamedFileInfo{fi, "/"}
+}
+
+func (fs *subStreamStore) Close() error {
+	return fs.ss.Close()
+}
+
+// Scheme reports the scheme of the store beneath. The store is deliberately
+// not a Wrapper, since unwrapping it would give access outside prefix.
+func (fs *subStreamStore) Scheme() string {
+	return Scheme(fs.ss)
+}
+
+func (fs *subStreamStore) OpenReadCloser(name string) (StrawReader, error) {
+	full, err := fs.full(name)
+	if err != nil {
+		return nil, err
+	}
+	r, err := fs.ss.OpenReadCloser(full)
+	return r, fs.err(err)
+}
+
+func (fs *subStreamStore) CreateWriteCloser(name string) (StrawWriter, error) {
+	full, err := fs.full(name)
+	if err != nil {
+		return nil, err
+	}
+	w, err := fs.ss.CreateWriteCloser(full)
+	return w, fs.err(err)
+}
+
+func (fs *subStreamStore) OpenFile(name string, flag int, perm os.FileMode) (StrawReadWriteCloser, error) {
+	full, err := fs.full(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := fs.ss.OpenFile(full, flag, perm)
+	return f, fs.err(err)
+}
+
+func (fs *subStreamStore) Lstat(name string) (os.FileInfo, error) {
+	full, err := fs.full(name)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := fs.ss.Lstat(full)
+	if err != nil {
+		return nil, fs.err(err)
+	}
+	return fs.stat(full, fi), nil
+}
+
+func (fs *subStreamStore) Stat(name string) (os.FileInfo, error) {
+	full, err := fs.full(name)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := fs.ss.Stat(full)
+	if err != nil {
+		return nil, fs.err(err)
+	}
+	return fs.stat(full, fi), nil
+}
+
+func (fs *subStreamStore) Readdir(name string) ([]os.FileInfo, error) {
+	full, err := fs.full(name)
+	if err != nil {
+		return nil, err
+	}
+	fis, err := fs.ss.Readdir(full)
+	return fis, fs.err(err)
+}
+
+func (fs *subStreamStore) Mkdir(name string, mode os.FileMode) error {
+	full, err := fs.full(name)
+	if err != nil {
+		return err
+	}
+	return fs.err(fs.ss.Mkdir(full, mode))
+}
+
+func (fs *subStreamStore) Remove(name string) error {
+	full, err := fs.full(name)
+	if err != nil {
+		return err
+	}
+	return fs.err(fs.ss.Remove(full))
+}
+
+func (fs *subStreamStore) Chmod(name string, mode os.FileMode) error {
+	full, err := fs.full(name)
+	if err != nil {
+		return err
+	}
+	return fs.err(fs.ss.Chmod(full, mode))
+}
+
+func (fs *subStreamStore) Truncate(name string, size int64) error {
+	full, err := fs.full(name)
+	if err != nil {
+		return err
+	}
+	return fs.err(fs.ss.Truncate(full, size))
+}
+
+// Symlink creates newname as a link to oldname. An absolute oldname is
+// interpreted relative to the prefix, and a relative oldname which would
+// point above the prefix is rejected with an error wrapping ErrInvalidPath.
+func (fs *subStreamStore) Symlink(oldname, newname string) error {
+	full, err := fs.full(newname)
+	if err != nil {
+		return err
+	}
+	if path.IsAbs(oldname) {
+		if oldname, err = fs.full(oldname); err != nil {
+			return err
+		}
+	} else if _, err := CleanPath(strings.TrimPrefix(path.Dir(path.Clean("/"+newname)), "/") + "/" + oldname); err != nil {
+		return err
+	}
+	return fs.err(Symlink(fs.ss, oldname, full))
+}
+
+// Readlink returns the destination of the named link, with the prefix removed
+// from absolute destinations within it.
+func (fs *subStreamStore) Readlink(name string) (string, error) {
+	full, err := fs.full(name)
+	if err != nil {
+		return "", err
+	}
+	dest, err := Readlink(fs.ss, full)
+	if err != nil {
+		return "", fs.err(err)
+	}
+	switch {
+	case fs.prefix == "/":
+		return dest, nil
+	case dest == fs.prefix:
+		return "/", nil
+	case strings.HasPrefix(dest, fs.prefix+"/"):
+		return strings.TrimPrefix(dest, fs.prefix), nil
+	default:
+		return dest, nil
+	}
+}
+
+func (fs *subStreamStore) Copy(src, dst string) error {
+	fullSrc, err := fs.full(src)
+	if err != nil {
+		return err
+	}
+	fullDst, err := fs.full(dst)
+	if err != nil {
+		return err
+	}
+	return fs.err(fs.ss.Copy(fullSrc, fullDst))
+}
+
+// renamedFileInfo is an os.FileInfo with its name replaced.
+type renamedFileInfo struct {
+	os.FileInfo
+	name string
+}
+
+func (fi *renamedFileInfo) Name() string {
+	return fi.name
+}
@@ -0,0 +1,121 @@
+package straw_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+func TestSubStore(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	mem, _ := straw.Open("mem://")
+	require.NoError(straw.MkdirAll(mem, "/tenants/acme", 0755))
+	writeFile(mem, "/tenants/other")
+	ss := straw.NewSubStore(mem, "/tenants/acme/")
+
+	require.NoError(ss.Mkdir("/dir", 0755))
+	writeContent(t, ss, "/dir/a", []byte("a"))
+	require.NoError(ss.Copy("/dir/a", "b"))
+
+	data, err := straw.ReadFile(mem, "/tenants/acme/dir/a")
+	require.NoError(err)
+	assert.Equal("a", string(data))
+	_, err = mem.Stat("/tenants/acme/b")
+	assert.NoError(err)
+
+	fis, err := ss.Readdir("/")
+	require.NoError(err)
+	require.Equal(2, len(fis))
+	assert.Equal("b", fis[0].Name())
+	assert.Equal("dir", fis[1].Name())
+
+	fi, err := ss.Stat("/")
+	require.NoError(err)
+	assert.Equal("/", fi.Name())
+	assert.True(fi.IsDir())
+
+	require.NoError(ss.Remove("/b"))
+	_, err = mem.Stat("/tenants/acme/b")
+	assert.True(os.IsNotExist(err))
+}
+
+func TestSubStoreRejectsEscape(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	mem, _ := straw.Open("mem://")
+	require.NoError(straw.MkdirAll(mem, "/tenants/acme", 0755))
+	writeFile(mem, "/tenants/other")
+	ss := straw.NewSubStore(mem, "/tenants/acme")
+
+	for _, name := range []string{"/../other", "../other", "/dir/../../other"} {
+		_, err := ss.OpenReadCloser(name)
+		assert.True(errors.Is(err, straw.ErrInvalidPath), name)
+		_, err = ss.Stat(name)
+		assert.True(errors.Is(err, straw.ErrInvalidPath), name)
+	}
+	assert.True(errors.Is(ss.Copy("/../other", "/stolen"), straw.ErrInvalidPath))
+}
+
+func TestSubStoreErrorPaths(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	osfs, _ := straw.Open("file:///")
+	dir := tempDir()
+	ss := straw.NewSubStore(osfs, dir)
+
+	_, err := ss.OpenReadCloser("/missing")
+	require.Error(err)
+	assert.True(os.IsNotExist(err))
+	assert.NotContains(err.Error(), dir)
+	assert.Contains(err.Error(), "/missing")
+}
+
+func TestSubStoreSymlink(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	osfs, _ := straw.Open("file:///")
+	root := tempDir()
+	defer os.RemoveAll(root)
+	require.NoError(osfs.Mkdir(root+"/sub", 0755))
+	ss := straw.NewSubStore(osfs, root+"/sub")
+
+	require.NoError(ss.Mkdir("/dir", 0755))
+	writeContent(t, ss, "/dir/a", []byte("a"))
+
+	require.NoError(straw.Symlink(ss, "/dir/a", "/abs"))
+	dest, err := os.Readlink(root + "/sub/abs")
+	require.NoError(err)
+	assert.Equal(root+"/sub/dir/a", dest)
+	dest, err = straw.Readlink(ss, "/abs")
+	require.NoError(err)
+	assert.Equal("/dir/a", dest)
+
+	require.NoError(straw.Symlink(ss, "../dir/a", "/dir/rel"))
+	data, err := straw.ReadFile(ss, "/dir/rel")
+	require.NoError(err)
+	assert.Equal("a", string(data))
+
+	err = straw.Symlink(ss, "../../x", "/dir/escape")
+	assert.True(errors.Is(err, straw.ErrInvalidPath))
+}
+
+func TestSubStoreCannotBeUnwrapped(t *testing.T) {
+	assert := assert.New(t)
+
+	mem, _ := straw.Open("mem://")
+	require.NoError(t, straw.MkdirAll(mem, "/tenants/acme", 0755))
+	ss := straw.NewSubStore(mem, "/tenants/acme")
+
+	_, ok := ss.(straw.Wrapper)
+	assert.False(ok)
+	assert.Equal("mem", straw.Scheme(ss))
+}
@@ -0,0 +1,109 @@
+package straw
+
+import (
+	"bytes"
+	"hash"
+	"os"
+)
+
+// SumWriter is a writer which computes the checksum of the content written to
+// it, as returned by CreateSumWriter.
+type SumWriter interface {
+	StrawWriter
+	// Sum returns the checksum of the content, once Close has succeeded,
+	// or nil before then.
+	Sum() []byte
+}
+
+// CreateSumWriter creates the named file as CreateWriteCloser does, with
+// opts, returning a writer which computes the checksum of the content as it
+// is written, using algo, one of those supported by Checksum.
+//
+// If expected is not nil, the content is held in memory and only written to
+// the file on Close, once its checksum is known to match, so that a
+// mismatch, for which Close fails with ErrChecksumMismatch, leaves any
+// existing file unchanged. Errors creating the file are then also returned
+// by Close. If algo is "md5", expected is also passed to the store with
+// WithContentMD5, so that object stores can check the content they receive.
+func CreateSumWriter(ss StreamStore, name string, algo string, expected []byte, opts ...WriteOption) (SumWriter, error) {
+	h, err := newHash(algo)
+	if err != nil {
+		return nil, err
+	}
+	if expected != nil {
+		if algo == "md5" {
+			opts = append(opts[:len(opts):len(opts)], WithContentMD5(expected))
+		}
+		return &sumWriter{ss: ss, name: name, opts: opts, buf: &bytes.Buffer{}, h: h, expected: expected}, nil
+	}
+	w, err := CreateWriteCloser(ss, name, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &sumWriter{ss: ss, name: name, w: w, h: h}, nil
+}
+
+// sumWriter writes to w as it is written to, or, if an expected checksum is
+// given, to buf until Close.
+type sumWriter struct {
+	ss       StreamStore
+	name     string
+	opts     []WriteOption
+	w        StrawWriter
+	buf      *bytes.Buffer
+	h        hash.Hash
+	expected []byte
+	sum      []byte
+	closed   bool
+}
+
+func (w *sumWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, ErrClosed
+	}
+	if w.buf != nil {
+		w.buf.Write(p)
+		w.h.Write(p)
+		return len(p), nil
+	}
+	n, err := w.w.Write(p)
+	w.h.Write(p[:n])
+	return n, err
+}
+
+func (w *sumWriter) Close() error {
+	if w.closed {
+		return ErrClosed
+	}
+	w.closed = true
+	sum := w.h.Sum(nil)
+	if w.buf == nil {
+		if err := w.w.Close(); err != nil {
+			return err
+		}
+		w.sum = sum
+		return nil
+	}
+
+	if !bytes.Equal(sum, w.expected) {
+		return &os.PathError{Op: "close", Path: w.name, Err: ErrChecksumMismatch}
+	}
+	f, err := CreateWriteCloser(w.ss, w.name, w.opts...)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(w.buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	w.buf = nil
+	w.sum = sum
+	return nil
+}
+
+func (w *sumWriter) Sum() []byte {
+	return w.sum
+}
@@ -0,0 +1,141 @@
+package straw_test
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+func TestCreateSumWriter(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, _ := straw.Open("mem://")
+
+	w, err := straw.CreateSumWriter(ss, "/a", "sha256", nil)
+	require.NoError(err)
+	_, err = w.Write([]byte("hel"))
+	require.NoError(err)
+	_, err = io.Copy(w, strings.NewReader("lo"))
+	require.NoError(err)
+	assert.Nil(w.Sum())
+	require.NoError(w.Close())
+	want := sha256.Sum256([]byte("hello"))
+	assert.Equal(want[:], w.Sum())
+	assert.Equal(straw.ErrClosed, w.Close())
+
+	data, err := straw.ReadFile(ss, "/a")
+	require.NoError(err)
+	assert.Equal("hello", string(data))
+
+	_, err = straw.CreateSumWriter(ss, "/b", "md4", nil)
+	assert.Error(err)
+}
+
+func TestCreateSumWriterExpected(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, _ := straw.Open("mem://")
+	sum := md5.Sum([]byte("hello"))
+
+	w, err := straw.CreateSumWriter(ss, "/a", "md5", sum[:])
+	require.NoError(err)
+	_, err = w.Write([]byte("hello"))
+	require.NoError(err)
+	require.NoError(w.Close())
+	assert.Equal(sum[:], w.Sum())
+
+	// content which does not match is removed.
+	w, err = straw.CreateSumWriter(ss, "/b", "md5", sum[:])
+	require.NoError(err)
+	_, err = w.Write([]byte("jello"))
+	require.NoError(err)
+	err = w.Close()
+	assertPathError(t, err, "/b", straw.ErrChecksumMismatch)
+	assert.Nil(w.Sum())
+	_, err = ss.Stat("/b")
+	assert.True(os.IsNotExist(err))
+}
+
+func TestCreateSumWriterKeepsFileOnMismatch(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, _ := straw.Open("mem://")
+	writeContent(t, ss, "/a", []byte("old"))
+	sum := sha256.Sum256([]byte("hello"))
+
+	w, err := straw.CreateSumWriter(ss, "/a", "sha256", sum[:])
+	require.NoError(err)
+	_, err = io.Copy(w, strings.NewReader("jello"))
+	require.NoError(err)
+
+	// nothing is written until the checksum is known.
+	data, err := straw.ReadFile(ss, "/a")
+	require.NoError(err)
+	assert.Equal("old", string(data))
+
+	assertPathError(t, w.Close(), "/a", straw.ErrChecksumMismatch)
+	data, err = straw.ReadFile(ss, "/a")
+	require.NoError(err)
+	assert.Equal("old", string(data))
+
+	w, err = straw.CreateSumWriter(ss, "/a", "sha256", sum[:])
+	require.NoError(err)
+	_, err = io.Copy(w, strings.NewReader("hello"))
+	require.NoError(err)
+	require.NoError(w.Close())
+	data, err = straw.ReadFile(ss, "/a")
+	require.NoError(err)
+	assert.Equal("hello", string(data))
+}
+
+// shortWriteStreamStore returns writers which write at most two bytes of
+// each Write.
+type shortWriteStreamStore struct {
+	straw.StreamStore
+}
+
+func (ss shortWriteStreamStore) CreateWriteCloser(name string) (straw.StrawWriter, error) {
+	w, err := ss.StreamStore.CreateWriteCloser(name)
+	return shortWriter{w}, err
+}
+
+type shortWriter struct {
+	straw.StrawWriter
+}
+
+func (w shortWriter) Write(p []byte) (int, error) {
+	if len(p) <= 2 {
+		return w.StrawWriter.Write(p)
+	}
+	n, _ := w.StrawWriter.Write(p[:2])
+	return n, io.ErrShortWrite
+}
+
+func TestCreateSumWriterShortWrite(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	mem, _ := straw.Open("mem://")
+	w, err := straw.CreateSumWriter(shortWriteStreamStore{mem}, "/a", "sha256", nil)
+	require.NoError(err)
+	_, err = io.Copy(w, strings.NewReader("hello"))
+	assert.Equal(io.ErrShortWrite, err)
+	require.NoError(w.Close())
+
+	// only what was written is summed.
+	want := sha256.Sum256([]byte("he"))
+	assert.Equal(want[:], w.Sum())
+	data, err := straw.ReadFile(mem, "/a")
+	require.NoError(err)
+	assert.Equal("he", string(data))
+}
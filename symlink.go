@@ -0,0 +1,33 @@
+package straw
+
+// SymlinkStore is implemented by stores which can create and read symbolic
+// links. The local filesystem and sftp backends implement it. Object stores
+// and mem have no way to represent a link, so do not.
+type SymlinkStore interface {
+	// Symlink creates newname as a symbolic link to oldname, which is stored
+	// as given and may be relative to the directory containing newname.
+	Symlink(oldname, newname string) error
+	// Readlink returns the destination of the named symbolic link.
+	Readlink(name string) (string, error)
+}
+
+// Symlink creates newname as a symbolic link to oldname in ss, returning
+// ErrNotSupported if ss does not implement SymlinkStore. Lstat of the link
+// reports os.ModeSymlink, and Stat follows it.
+func Symlink(ss StreamStore, oldname, newname string) error {
+	sls, ok := ss.(SymlinkStore)
+	if !ok {
+		return ErrNotSupported
+	}
+	return sls.Symlink(oldname, newname)
+}
+
+// Readlink returns the destination of the named symbolic link in ss,
+// returning ErrNotSupported if ss does not implement SymlinkStore.
+func Readlink(ss StreamStore, name string) (string, error) {
+	sls, ok := ss.(SymlinkStore)
+	if !ok {
+		return "", ErrNotSupported
+	}
+	return sls.Readlink(name)
+}
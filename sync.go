@@ -0,0 +1,110 @@
+package straw
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Sync mirrors the tree rooted at srcRoot in src to dstRoot in dst, which may
+// be a different store, creating directories and copying files as needed.
+// Files are not copied if the destination already has a file of the same
+// size which is not older than the source, as copying cannot preserve
+// modification times. With the DeleteExtraneous option, files and
+// directories in the destination which are not in the source are removed.
+// Sync stops at the first error it encounters.
+func Sync(dst StreamStore, dstRoot string, src StreamStore, srcRoot string, opts ...MutateOption) error {
+	o := newMutateOptions(opts)
+
+	fi, err := src.Stat(srcRoot)
+	if err != nil {
+		return err
+	}
+	dfi, err := dst.Stat(dstRoot)
+	switch {
+	case os.IsNotExist(err):
+		dfi = nil
+	case err != nil:
+		return err
+	}
+	return syncEntry(dst, dstRoot, dfi, src, srcRoot, fi, o)
+}
+
+// syncEntry syncs the file or directory srcPath, described by fi, to dstPath,
+// which is described by dfi, or nil if it does not exist.
+func syncEntry(dst StreamStore, dstPath string, dfi os.FileInfo, src StreamStore, srcPath string, fi os.FileInfo, o *mutateOptions) error {
+	if dfi != nil && dfi.IsDir() != fi.IsDir() {
+		if !o.deleteExtraneous {
+			return fmt.Errorf("%s : cannot replace with %s, as one is a directory", dstPath, srcPath)
+		}
+		err := o.do("RemoveAll", dstPath, func() error {
+			return RemoveAll(dst, dstPath)
+		})
+		if err != nil {
+			return err
+		}
+		dfi = nil
+	}
+
+	if !fi.IsDir() {
+		if dfi != nil && dfi.Size() == fi.Size() && !dfi.ModTime().Before(fi.ModTime()) {
+			return nil
+		}
+		return o.do("Copy", dstPath, func() error {
+			_, err := CopyFile(dst, dstPath, src, srcPath)
+			return err
+		})
+	}
+
+	if dfi == nil {
+		err := o.do("Mkdir", dstPath, func() error {
+			return dst.Mkdir(dstPath, 0755)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return syncDir(dst, dstPath, src, srcPath, o)
+}
+
+func syncDir(dst StreamStore, dstDir string, src StreamStore, srcDir string, o *mutateOptions) error {
+	fis, err := src.Readdir(srcDir)
+	if err != nil {
+		return err
+	}
+	// In dry-run mode the destination directory may not have been created.
+	dfis, err := dst.Readdir(dstDir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	existing := make(map[string]os.FileInfo, len(dfis))
+	for _, dfi := range dfis {
+		existing[dfi.Name()] = dfi
+	}
+
+	for _, fi := range fis {
+		dstPath := filepath.Join(dstDir, fi.Name())
+		srcPath := filepath.Join(srcDir, fi.Name())
+		if err := syncEntry(dst, dstPath, existing[fi.Name()], src, srcPath, fi, o); err != nil {
+			return err
+		}
+		delete(existing, fi.Name())
+	}
+
+	if !o.deleteExtraneous {
+		return nil
+	}
+	for _, dfi := range dfis {
+		if _, ok := existing[dfi.Name()]; !ok {
+			continue
+		}
+		dstPath := filepath.Join(dstDir, dfi.Name())
+		err := o.do("RemoveAll", dstPath, func() error {
+			return RemoveAll(dst, dstPath)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package straw_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+func newSyncSource(t *testing.T) straw.StreamStore {
+	require := require.New(t)
+
+	src, _ := straw.Open("mem://")
+	require.NoError(straw.MkdirAll(src, "/src/a/b", 0755))
+	writeContent(t, src, "/src/1", []byte("one"))
+	writeContent(t, src, "/src/a/2", []byte("two"))
+	writeContent(t, src, "/src/a/b/3", []byte("three"))
+	return src
+}
+
+func TestSync(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	src := newSyncSource(t)
+	dst, _ := straw.Open("mem://")
+
+	require.NoError(straw.Sync(dst, "/dst", src, "/src"))
+
+	for name, want := range map[string]string{"/dst/1": "one", "/dst/a/2": "two", "/dst/a/b/3": "three"} {
+		data, err := straw.ReadFile(dst, name)
+		require.NoError(err)
+		assert.Equal(want, string(data))
+	}
+}
+
+func TestSyncSkipsUnchanged(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	src := newSyncSource(t)
+	dst, _ := straw.Open("mem://")
+	require.NoError(straw.Sync(dst, "/dst", src, "/src"))
+
+	writeContent(t, src, "/src/a/2", []byte("changed"))
+
+	rec := &TestRecordingStreamStore{wrapped: dst}
+	require.NoError(straw.Sync(rec, "/dst", src, "/src"))
+	assert.Equal([]string{"CreateWriteCloser /dst/a/2"}, rec.Calls("CreateWriteCloser", "Mkdir"))
+
+	data, err := straw.ReadFile(dst, "/dst/a/2")
+	require.NoError(err)
+	assert.Equal("changed", string(data))
+}
+
+func TestSyncDeleteExtraneous(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	src := newSyncSource(t)
+	dst, _ := straw.Open("mem://")
+	require.NoError(straw.MkdirAll(dst, "/dst/c", 0755))
+	writeContent(t, dst, "/dst/c/4", []byte("four"))
+	writeContent(t, dst, "/dst/5", []byte("five"))
+
+	require.NoError(straw.Sync(dst, "/dst", src, "/src"))
+	_, err := dst.Stat("/dst/5")
+	assert.NoError(err)
+
+	require.NoError(straw.Sync(dst, "/dst", src, "/src", straw.DeleteExtraneous()))
+	for _, name := range []string{"/dst/5", "/dst/c"} {
+		_, err := dst.Stat(name)
+		assert.True(os.IsNotExist(err), name)
+	}
+	_, err = dst.Stat("/dst/a/b/3")
+	assert.NoError(err)
+}
+
+func TestSyncReplaceDirectory(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	src := newSyncSource(t)
+	dst, _ := straw.Open("mem://")
+	require.NoError(straw.MkdirAll(dst, "/dst/1", 0755))
+
+	assert.Error(straw.Sync(dst, "/dst", src, "/src"))
+
+	require.NoError(straw.Sync(dst, "/dst", src, "/src", straw.DeleteExtraneous()))
+	data, err := straw.ReadFile(dst, "/dst/1")
+	require.NoError(err)
+	assert.Equal("one", string(data))
+}
+
+func TestSyncDryRun(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	src := newSyncSource(t)
+	dst, _ := straw.Open("mem://")
+	require.NoError(straw.MkdirAll(dst, "/dst", 0755))
+	writeContent(t, dst, "/dst/1", []byte("one"))
+	writeContent(t, dst, "/dst/5", []byte("five"))
+
+	var planned []string
+	err := straw.Sync(dst, "/dst", src, "/src", straw.DeleteExtraneous(), straw.DryRun(func(op, path string) {
+		planned = append(planned, op+" "+path)
+	}))
+	require.NoError(err)
+	assert.Equal([]string{
+		"Mkdir /dst/a",
+		"Copy /dst/a/2",
+		"Mkdir /dst/a/b",
+		"Copy /dst/a/b/3",
+		"RemoveAll /dst/5",
+	}, planned)
+
+	_, err = dst.Stat("/dst/a")
+	assert.True(os.IsNotExist(err))
+}
@@ -0,0 +1,58 @@
+package straw
+
+// Taggable is implemented by stores that can attach key/value tags to
+// objects. SetTags replaces any tags already present on the object.
+//
+// The s3 backend uses the object tagging API, so the tags can drive
+// lifecycle rules and access policies, and S3 limits them to 10 per object.
+// GCS has no object tags, so the gcs backend stores them as custom metadata
+// entries prefixed with "tag-", which GCS does not interpret. In both cases
+// the tags are carried over by Copy, and are kept by Chmod.
+type Taggable interface {
+	SetTags(name string, tags map[string]string) error
+	GetTags(name string) (map[string]string, error)
+}
+
+// ReadAndTag opens name for reading and then applies tags to it, returning
+// the open reader. The tags are only applied once the object has been
+// successfully opened, so a missing object is never tagged, and if tagging
+// fails the reader is closed and the error returned.
+// Opening and tagging are not atomic: if the object is replaced between the
+// two steps, the reader sees the old content while the tags are applied to
+// the new object.
+// ErrNotSupported is returned if ss does not implement Taggable.
+func ReadAndTag(ss StreamStore, name string, tags map[string]string) (StrawReader, error) {
+	t, ok := ss.(Taggable)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	r, err := ss.OpenReadCloser(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.SetTags(name, tags); err != nil {
+		r.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// setTags calls the SetTags method of ss, returning ErrNotSupported if ss
+// does not implement Taggable.
+func setTags(ss StreamStore, name string, tags map[string]string) error {
+	t, ok := ss.(Taggable)
+	if !ok {
+		return ErrNotSupported
+	}
+	return t.SetTags(name, tags)
+}
+
+// getTags calls the GetTags method of ss, returning ErrNotSupported if ss
+// does not implement Taggable.
+func getTags(ss StreamStore, name string) (map[string]string, error) {
+	t, ok := ss.(Taggable)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	return t.GetTags(name)
+}
@@ -0,0 +1,75 @@
+package straw_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+type tagStreamStore struct {
+	straw.StreamStore
+	tags map[string]map[string]string
+}
+
+func (ts *tagStreamStore) SetTags(name string, tags map[string]string) error {
+	if _, err := ts.Stat(name); err != nil {
+		return err
+	}
+	ts.tags[name] = tags
+	return nil
+}
+
+func (ts *tagStreamStore) GetTags(name string) (map[string]string, error) {
+	if _, err := ts.Stat(name); err != nil {
+		return nil, err
+	}
+	return ts.tags[name], nil
+}
+
+func TestReadAndTag(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss, _ := straw.Open("mem://")
+	ts := &tagStreamStore{ss, make(map[string]map[string]string)}
+
+	writeFile(ts, "/a")
+
+	r, err := straw.ReadAndTag(ts, "/a", map[string]string{"processed": "true"})
+	require.NoError(err)
+	data, err := ioutil.ReadAll(r)
+	assert.NoError(err)
+	assert.Equal([]byte{0}, data)
+	assert.NoError(r.Close())
+
+	tags, err := ts.GetTags("/a")
+	assert.NoError(err)
+	assert.Equal(map[string]string{"processed": "true"}, tags)
+}
+
+func TestReadAndTagNotExist(t *testing.T) {
+	assert := assert.New(t)
+
+	ss, _ := straw.Open("mem://")
+	ts := &tagStreamStore{ss, make(map[string]map[string]string)}
+
+	r, err := straw.ReadAndTag(ts, "/a", map[string]string{"processed": "true"})
+	assert.True(os.IsNotExist(err))
+	assert.Nil(r)
+	assert.Empty(ts.tags)
+}
+
+func TestReadAndTagNotSupported(t *testing.T) {
+	assert := assert.New(t)
+
+	ss, _ := straw.Open("mem://")
+	writeFile(ss, "/a")
+
+	r, err := straw.ReadAndTag(ss, "/a", map[string]string{"processed": "true"})
+	assert.Equal(straw.ErrNotSupported, err)
+	assert.Nil(r)
+}
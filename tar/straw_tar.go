@@ -0,0 +1,149 @@
+// Package tar provides a read-only StreamStore over the members of a tar
+// archive, registered under the "tar" scheme for archives on the local
+// filesystem, such as "tar:///data/archive.tar".
+//
+// The archive is indexed when the store is opened, after which members are
+// read directly from their position in the archive, so ReadAt and Seek are
+// as cheap as they are on the archive itself. Directories are synthesized
+// from the paths of members for archives without explicit directory
+// entries. Symbolic links within the archive are followed by Stat and
+// OpenReadCloser, and hard links read the content of their target. Sparse
+// members cannot be read. Operations which would modify the archive return
+// straw.ErrNotSupported.
+package tar
+
+import (
+	archivetar "archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/uw-labs/straw"
+	"github.com/uw-labs/straw/internal/archive"
+)
+
+var errSparse = errors.New("sparse tar members are not supported")
+
+func init() {
+	straw.Register("tar", func(u *url.URL) (straw.StreamStore, error) {
+		if u.Host != "" {
+			return nil, fmt.Errorf("tar URLs must not provide a host, got %q", u.Host)
+		}
+		f, err := os.Open(u.Path)
+		if err != nil {
+			return nil, err
+		}
+		fi, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		ss, err := newStore(f, fi.Size(), f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return ss, nil
+	})
+}
+
+// New returns a read-only StreamStore over the tar archive of size bytes
+// read from r, which must remain readable until the store is closed.
+// Closing the store does not close r.
+func New(r io.ReaderAt, size int64) (straw.StreamStore, error) {
+	return newStore(r, size, nil)
+}
+
+// member locates the content of a member within the archive.
+type member struct {
+	offset int64
+	size   int64
+	sparse bool
+}
+
+func newStore(r io.ReaderAt, size int64, closer io.Closer) (*archive.Store, error) {
+	s := archive.New("tar", closer, func(data interface{}) (straw.StrawReader, error) {
+		m := data.(member)
+		if m.sparse {
+			return nil, errSparse
+		}
+		return &memberReader{io.NewSectionReader(r, m.offset, m.size)}, nil
+	})
+
+	sr := io.NewSectionReader(r, 0, size)
+	tr := archivetar.NewReader(sr)
+	files := make(map[string]archive.Member)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		// The tar reader consumes exactly the headers of each member, so
+		// its content starts at the current offset.
+		offset, err := sr.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+
+		m := archive.Member{Name: hdr.Name, Info: hdr.FileInfo()}
+		switch hdr.Typeflag {
+		case archivetar.TypeSymlink:
+			m.Link = hdr.Linkname
+		case archivetar.TypeLink:
+			target, ok := files[cleanName(hdr.Linkname)]
+			if !ok {
+				return nil, fmt.Errorf("tar member %q links to missing member %q", hdr.Name, hdr.Linkname)
+			}
+			m.Info, m.Data = target.Info, target.Data
+		case archivetar.TypeReg, archivetar.TypeRegA, archivetar.TypeGNUSparse:
+			m.Data = member{offset: offset, size: hdr.Size, sparse: isSparse(hdr)}
+		case archivetar.TypeDir:
+		default:
+			// Devices, fifos and the like have no content to read.
+			continue
+		}
+		if err := s.Add(m); err != nil {
+			return nil, err
+		}
+		if m.Data != nil {
+			files[cleanName(hdr.Name)] = m
+		}
+	}
+	return s, nil
+}
+
+// isSparse reports whether hdr describes a sparse file, whose content is not
+// stored contiguously in the archive.
+func isSparse(hdr *archivetar.Header) bool {
+	if hdr.Typeflag == archivetar.TypeGNUSparse {
+		return true
+	}
+	for k := range hdr.PAXRecords {
+		if strings.HasPrefix(k, "GNU.sparse.") {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanName returns the name of a member as it is indexed, for matching
+// hard links to their targets.
+func cleanName(name string) string {
+	return path.Clean("/" + name)
+}
+
+// memberReader reads the content of a member.
+type memberReader struct {
+	*io.SectionReader
+}
+
+func (r *memberReader) Close() error {
+	return nil
+}
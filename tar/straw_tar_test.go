@@ -0,0 +1,233 @@
+package tar
+
+import (
+	archivetar "archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+var testModTime = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// buildArchive returns a tar archive of hdrs, giving regular files the
+// content for their name in contents.
+func buildArchive(t *testing.T, hdrs []*archivetar.Header, contents map[string]string) []byte {
+	var buf bytes.Buffer
+	tw := archivetar.NewWriter(&buf)
+	for _, hdr := range hdrs {
+		if hdr.ModTime.IsZero() {
+			hdr.ModTime = testModTime
+		}
+		if hdr.Mode == 0 {
+			hdr.Mode = 0644
+		}
+		content := contents[hdr.Name]
+		if hdr.Typeflag == archivetar.TypeReg {
+			hdr.Size = int64(len(content))
+		}
+		require.NoError(t, tw.WriteHeader(hdr))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	return buf.Bytes()
+}
+
+func testArchive(t *testing.T) []byte {
+	return buildArchive(t, []*archivetar.Header{
+		{Name: "./top.txt", Typeflag: archivetar.TypeReg},
+		{Name: "a/b/c.txt", Typeflag: archivetar.TypeReg},
+		{Name: "a/b/d.txt", Typeflag: archivetar.TypeReg},
+		{Name: "e/", Typeflag: archivetar.TypeDir, Mode: 0700},
+		{Name: "e/link", Typeflag: archivetar.TypeSymlink, Linkname: "../a/b/c.txt"},
+		{Name: "e/hard", Typeflag: archivetar.TypeLink, Linkname: "top.txt"},
+		{Name: "dirlink", Typeflag: archivetar.TypeSymlink, Linkname: "/a/b"},
+	}, map[string]string{
+		"./top.txt": "top level",
+		"a/b/c.txt": "the quick brown fox",
+		"a/b/d.txt": "jumps over the lazy dog",
+	})
+}
+
+func TestStatAndReaddir(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	data := testArchive(t)
+	ss, err := New(bytes.NewReader(data), int64(len(data)))
+	require.NoError(err)
+	defer ss.Close()
+	assert.Equal("tar", straw.Scheme(ss))
+
+	fis, err := ss.Readdir("/")
+	require.NoError(err)
+	var names []string
+	for _, fi := range fis {
+		names = append(names, fi.Name())
+	}
+	assert.Equal([]string{"a", "dirlink", "e", "top.txt"}, names)
+
+	fi, err := ss.Stat("/a")
+	require.NoError(err)
+	assert.True(fi.IsDir())
+	assert.True(testModTime.Equal(fi.ModTime()))
+
+	fi, err = ss.Stat("/e")
+	require.NoError(err)
+	assert.True(fi.IsDir())
+	assert.Equal(os.ModeDir|0700, fi.Mode())
+
+	fi, err = ss.Stat("/a/b/d.txt")
+	require.NoError(err)
+	assert.Equal("d.txt", fi.Name())
+	assert.Equal(int64(23), fi.Size())
+	assert.False(fi.IsDir())
+
+	fis, err = ss.Readdir("a/b")
+	require.NoError(err)
+	require.Len(fis, 2)
+	assert.Equal("c.txt", fis[0].Name())
+	assert.Equal("d.txt", fis[1].Name())
+
+	_, err = ss.Stat("/a/missing")
+	assert.True(os.IsNotExist(err))
+	_, err = ss.Readdir("/top.txt")
+	assert.Error(err)
+}
+
+func TestLinks(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	data := testArchive(t)
+	ss, err := New(bytes.NewReader(data), int64(len(data)))
+	require.NoError(err)
+	defer ss.Close()
+
+	fi, err := ss.Lstat("/e/link")
+	require.NoError(err)
+	assert.True(fi.Mode()&os.ModeSymlink != 0)
+	dest, err := straw.Readlink(ss, "/e/link")
+	require.NoError(err)
+	assert.Equal("../a/b/c.txt", dest)
+
+	fi, err = ss.Stat("/e/link")
+	require.NoError(err)
+	assert.Equal(int64(19), fi.Size())
+	content, err := straw.ReadFile(ss, "/e/link")
+	require.NoError(err)
+	assert.Equal("the quick brown fox", string(content))
+
+	content, err = straw.ReadFile(ss, "/dirlink/d.txt")
+	require.NoError(err)
+	assert.Equal("jumps over the lazy dog", string(content))
+
+	content, err = straw.ReadFile(ss, "/e/hard")
+	require.NoError(err)
+	assert.Equal("top level", string(content))
+}
+
+func TestReadAtAndSeek(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	data := testArchive(t)
+	ss, err := New(bytes.NewReader(data), int64(len(data)))
+	require.NoError(err)
+	defer ss.Close()
+
+	r, err := ss.OpenReadCloser("/a/b/c.txt")
+	require.NoError(err)
+	defer r.Close()
+
+	buf := make([]byte, 5)
+	n, err := r.ReadAt(buf, 4)
+	require.NoError(err)
+	assert.Equal("quick", string(buf[:n]))
+
+	pos, err := r.Seek(-3, io.SeekEnd)
+	require.NoError(err)
+	assert.Equal(int64(16), pos)
+	rest, err := ioutil.ReadAll(r)
+	require.NoError(err)
+	assert.Equal("fox", string(rest))
+
+	_, err = r.Seek(10, io.SeekStart)
+	require.NoError(err)
+	_, err = r.Seek(6, io.SeekCurrent)
+	require.NoError(err)
+	rest, err = ioutil.ReadAll(r)
+	require.NoError(err)
+	assert.Equal("fox", string(rest))
+
+	n, err = r.ReadAt(buf, 17)
+	assert.Equal(io.EOF, err)
+	assert.Equal("ox", string(buf[:n]))
+}
+
+func TestUnsupported(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	data := testArchive(t)
+	ss, err := New(bytes.NewReader(data), int64(len(data)))
+	require.NoError(err)
+	defer ss.Close()
+
+	_, err = ss.CreateWriteCloser("/new")
+	assert.Equal(straw.ErrNotSupported, err)
+	_, err = ss.OpenFile("/top.txt", os.O_RDWR, 0)
+	assert.Equal(straw.ErrNotSupported, err)
+	assert.Equal(straw.ErrNotSupported, ss.Mkdir("/dir", 0755))
+	assert.Equal(straw.ErrNotSupported, ss.Remove("/top.txt"))
+	assert.Equal(straw.ErrNotSupported, ss.Chmod("/top.txt", 0600))
+	assert.Equal(straw.ErrNotSupported, ss.Truncate("/top.txt", 0))
+	assert.Equal(straw.ErrNotSupported, ss.Copy("/top.txt", "/copy"))
+
+	f, err := ss.OpenFile("/top.txt", os.O_RDONLY, 0)
+	require.NoError(err)
+	content, err := ioutil.ReadAll(f)
+	require.NoError(err)
+	assert.Equal("top level", string(content))
+	require.NoError(f.Close())
+}
+
+func TestInvalidMember(t *testing.T) {
+	data := buildArchive(t, []*archivetar.Header{
+		{Name: "../escape.txt", Typeflag: archivetar.TypeReg},
+	}, nil)
+	_, err := New(bytes.NewReader(data), int64(len(data)))
+	assert.Error(t, err)
+}
+
+func TestOpenURL(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "straw-tar")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "test.tar")
+	require.NoError(ioutil.WriteFile(name, testArchive(t), 0644))
+
+	ss, err := straw.Open("tar://" + name)
+	require.NoError(err)
+	assert.Equal("tar", straw.Scheme(ss))
+	content, err := straw.ReadFile(ss, "/a/b/c.txt")
+	require.NoError(err)
+	assert.Equal("the quick brown fox", string(content))
+	require.NoError(ss.Close())
+
+	_, err = straw.Open("tar://" + filepath.Join(dir, "missing.tar"))
+	assert.True(os.IsNotExist(err))
+	_, err = straw.Open("tar://host/archive.tar")
+	assert.Error(err)
+}
@@ -0,0 +1,506 @@
+package straw
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	_ StreamStore        = &timeoutStreamStore{}
+	_ ContextStreamStore = &timeoutStreamStore{}
+	_ OptionsWriter      = &timeoutStreamStore{}
+	_ AppendStore        = &timeoutStreamStore{}
+	_ AllRemover         = &timeoutStreamStore{}
+	_ ExistsChecker      = &timeoutStreamStore{}
+	_ DirChecker         = &timeoutStreamStore{}
+	_ Taggable           = &timeoutStreamStore{}
+	_ PresignStore       = &timeoutStreamStore{}
+)
+
+// NewTimeoutStore returns a StreamStore which wraps ss, failing any call
+// which takes longer than d with an error wrapping ErrTimeout, so that a
+// hung connection fails rather than blocking forever. Every method of the
+// store is bounded, and so is each call on the files it opens, such as Read,
+// Write and Close, rather than the whole of a transfer. d should allow for
+// the largest buffers callers read and write in one go.
+//
+// If ss implements ContextStreamStore, operations are given a context with
+// a deadline, which aborts them when it passes. Other operations which time
+// out are abandoned. A file which a call times out on is closed, which
+// interrupts blocked network reads and writes for most backends, and every
+// later call on it fails with the same error.
+//
+// The optional interfaces which act on paths of ss, such as OptionsWriter
+// and ContextStreamStore, are implemented by passing each call to the
+// package function of the same name, bounded in the same way. The context
+// given to the methods of ContextStreamStore is the parent of the one with
+// the deadline.
+func NewTimeoutStore(ss StreamStore, d time.Duration) StreamStore {
+	return &timeoutStreamStore{ss, d}
+}
+
+type timeoutStreamStore struct {
+	ss StreamStore
+	d  time.Duration
+}
+
+func (fs *timeoutStreamStore) Unwrap() StreamStore {
+	return fs.ss
+}
+
+func (fs *timeoutStreamStore) Close() error {
+	return fs.ss.Close()
+}
+
+// do runs f with a context derived from parent which is done after fs.d,
+// failing with an error wrapping ErrTimeout if f hasn't returned by then.
+func (fs *timeoutStreamStore) do(parent context.Context, op, name string, f func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	ctx, cancel := context.WithTimeout(parent, fs.d)
+	defer cancel()
+	return fs.run(parent, ctx, op, name, f)
+}
+
+// open runs f, which opens a file bound to the context it is given, as do
+// does. The context is cancelled if the open times out, and otherwise lives
+// as long as the returned file, or parent.
+func (fs *timeoutStreamStore) open(parent context.Context, op, name string, f func(ctx context.Context) (interface{}, error)) (*timeoutFile, interface{}, error) {
+	ctx, cancel := context.WithCancel(parent)
+	t := time.AfterFunc(fs.d, cancel)
+	v, err := fs.run(parent, ctx, op, name, f)
+	t.Stop()
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	c := v.(io.Closer)
+	return &timeoutFile{d: fs.d, name: name, c: c, cancel: cancel}, v, nil
+}
+
+// run runs f with ctx, which is derived from parent and done when the call
+// times out.
+func (fs *timeoutStreamStore) run(parent, ctx context.Context, op, name string, f func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	return timingOut(fs.d)(ctx, op, name, func() (interface{}, error) {
+		v, err := f(ctx)
+		if ctx.Err() == nil {
+			return v, err
+		}
+		// the deadline passed before timingOut noticed, or the caller gave
+		// up. A file opened just in time is bound to the context, so is no
+		// use.
+		if c, ok := v.(io.Closer); ok {
+			c.Close()
+		} else if err == nil {
+			return v, nil
+		}
+		if err := parent.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s %s: %w", op, name, ErrTimeout)
+	})
+}
+
+func (fs *timeoutStreamStore) OpenReadCloser(name string) (StrawReader, error) {
+	return fs.OpenReadCloserContext(context.Background(), name)
+}
+
+func (fs *timeoutStreamStore) OpenReadCloserContext(parent context.Context, name string) (StrawReader, error) {
+	t, v, err := fs.open(parent, "OpenReadCloser", name, func(ctx context.Context) (interface{}, error) {
+		r, err := OpenReadCloserContext(ctx, fs.ss, name)
+		if err != nil {
+			return nil, err
+		}
+		return r, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &timeoutReader{t, v.(StrawReader)}, nil
+}
+
+func (fs *timeoutStreamStore) CreateWriteCloser(name string) (StrawWriter, error) {
+	return fs.CreateWriteCloserContext(context.Background(), name)
+}
+
+func (fs *timeoutStreamStore) CreateWriteCloserContext(parent context.Context, name string) (StrawWriter, error) {
+	return fs.openWriter(parent, "CreateWriteCloser", name, func(ctx context.Context) (StrawWriter, error) {
+		return CreateWriteCloserContext(ctx, fs.ss, name)
+	})
+}
+
+func (fs *timeoutStreamStore) CreateWriteCloserWithOptions(name string, opts WriteOptions) (StrawWriter, error) {
+	return fs.openWriter(context.Background(), "CreateWriteCloser", name, func(ctx context.Context) (StrawWriter, error) {
+		return createWriteCloser(fs.ss, name, opts)
+	})
+}
+
+func (fs *timeoutStreamStore) AppendWriteCloser(name string) (StrawWriter, error) {
+	return fs.openWriter(context.Background(), "AppendWriteCloser", name, func(ctx context.Context) (StrawWriter, error) {
+		return AppendWriteCloser(fs.ss, name)
+	})
+}
+
+// openWriter runs f, which opens a writer, as open does, and bounds each
+// call on the writer it returns.
+func (fs *timeoutStreamStore) openWriter(parent context.Context, op, name string, f func(ctx context.Context) (StrawWriter, error)) (StrawWriter, error) {
+	t, v, err := fs.open(parent, op, name, func(ctx context.Context) (interface{}, error) {
+		w, err := f(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return w, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	w := &timeoutWriter{t, v.(StrawWriter)}
+	if wa, ok := v.(io.WriterAt); ok {
+		return &timeoutWriterAt{w, wa}, nil
+	}
+	return w, nil
+}
+
+func (fs *timeoutStreamStore) OpenFile(name string, flag int, perm os.FileMode) (StrawReadWriteCloser, error) {
+	t, v, err := fs.open(context.Background(), "OpenFile", name, func(ctx context.Context) (interface{}, error) {
+		f, err := fs.ss.OpenFile(name, flag, perm)
+		if err != nil {
+			return nil, err
+		}
+		return f, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	f := v.(StrawReadWriteCloser)
+	return &timeoutReadWriter{timeoutReader{t, f}, f}, nil
+}
+
+func (fs *timeoutStreamStore) Lstat(path string) (os.FileInfo, error) {
+	return fs.LstatContext(context.Background(), path)
+}
+
+func (fs *timeoutStreamStore) LstatContext(parent context.Context, path string) (os.FileInfo, error) {
+	v, err := fs.do(parent, "Lstat", path, func(ctx context.Context) (interface{}, error) {
+		fi, err := LstatContext(ctx, fs.ss, path)
+		return fi, err
+	})
+	fi, _ := v.(os.FileInfo)
+	return fi, err
+}
+
+func (fs *timeoutStreamStore) Stat(path string) (os.FileInfo, error) {
+	return fs.StatContext(context.Background(), path)
+}
+
+func (fs *timeoutStreamStore) StatContext(parent context.Context, path string) (os.FileInfo, error) {
+	v, err := fs.do(parent, "Stat", path, func(ctx context.Context) (interface{}, error) {
+		fi, err := StatContext(ctx, fs.ss, path)
+		return fi, err
+	})
+	fi, _ := v.(os.FileInfo)
+	return fi, err
+}
+
+func (fs *timeoutStreamStore) Readdir(path string) ([]os.FileInfo, error) {
+	return fs.ReaddirContext(context.Background(), path)
+}
+
+func (fs *timeoutStreamStore) ReaddirContext(parent context.Context, path string) ([]os.FileInfo, error) {
+	v, err := fs.do(parent, "Readdir", path, func(ctx context.Context) (interface{}, error) {
+		fis, err := ReaddirContext(ctx, fs.ss, path)
+		return fis, err
+	})
+	fis, _ := v.([]os.FileInfo)
+	return fis, err
+}
+
+func (fs *timeoutStreamStore) Mkdir(path string, mode os.FileMode) error {
+	return fs.MkdirContext(context.Background(), path, mode)
+}
+
+func (fs *timeoutStreamStore) MkdirContext(parent context.Context, path string, mode os.FileMode) error {
+	_, err := fs.do(parent, "Mkdir", path, func(ctx context.Context) (interface{}, error) {
+		return nil, MkdirContext(ctx, fs.ss, path, mode)
+	})
+	return err
+}
+
+func (fs *timeoutStreamStore) Remove(path string) error {
+	return fs.RemoveContext(context.Background(), path)
+}
+
+func (fs *timeoutStreamStore) RemoveContext(parent context.Context, path string) error {
+	_, err := fs.do(parent, "Remove", path, func(ctx context.Context) (interface{}, error) {
+		return nil, RemoveContext(ctx, fs.ss, path)
+	})
+	return err
+}
+
+func (fs *timeoutStreamStore) Chmod(name string, mode os.FileMode) error {
+	return fs.ChmodContext(context.Background(), name, mode)
+}
+
+func (fs *timeoutStreamStore) ChmodContext(parent context.Context, name string, mode os.FileMode) error {
+	_, err := fs.do(parent, "Chmod", name, func(ctx context.Context) (interface{}, error) {
+		return nil, ChmodContext(ctx, fs.ss, name, mode)
+	})
+	return err
+}
+
+func (fs *timeoutStreamStore) Truncate(name string, size int64) error {
+	_, err := fs.do(context.Background(), "Truncate", name, func(ctx context.Context) (interface{}, error) {
+		return nil, fs.ss.Truncate(name, size)
+	})
+	return err
+}
+
+func (fs *timeoutStreamStore) Symlink(oldname, newname string) error {
+	_, err := fs.do(context.Background(), "Symlink", newname, func(ctx context.Context) (interface{}, error) {
+		return nil, Symlink(fs.ss, oldname, newname)
+	})
+	return err
+}
+
+func (fs *timeoutStreamStore) Readlink(name string) (string, error) {
+	v, err := fs.do(context.Background(), "Readlink", name, func(ctx context.Context) (interface{}, error) {
+		return Readlink(fs.ss, name)
+	})
+	dest, _ := v.(string)
+	return dest, err
+}
+
+func (fs *timeoutStreamStore) Copy(src, dst string) error {
+	return fs.CopyContext(context.Background(), src, dst)
+}
+
+func (fs *timeoutStreamStore) CopyContext(parent context.Context, src, dst string) error {
+	_, err := fs.do(parent, "Copy", src, func(ctx context.Context) (interface{}, error) {
+		return nil, CopyContext(ctx, fs.ss, src, dst)
+	})
+	return err
+}
+
+func (fs *timeoutStreamStore) Exists(name string) (bool, error) {
+	v, err := fs.do(context.Background(), "Exists", name, func(ctx context.Context) (interface{}, error) {
+		return Exists(fs.ss, name)
+	})
+	exists, _ := v.(bool)
+	return exists, err
+}
+
+func (fs *timeoutStreamStore) IsDir(name string) (bool, error) {
+	v, err := fs.do(context.Background(), "IsDir", name, func(ctx context.Context) (interface{}, error) {
+		return IsDir(fs.ss, name)
+	})
+	isDir, _ := v.(bool)
+	return isDir, err
+}
+
+// RemoveAll removes the tree at path with the RemoveAll method of ss if it
+// has one, bounded as a whole, and otherwise a file at a time through fs.
+func (fs *timeoutStreamStore) RemoveAll(path string) error {
+	ar, ok := fs.ss.(AllRemover)
+	if !ok {
+		return removeAll(fs, path, newMutateOptions(nil))
+	}
+	_, err := fs.do(context.Background(), "RemoveAll", path, func(ctx context.Context) (interface{}, error) {
+		return nil, ar.RemoveAll(path)
+	})
+	return err
+}
+
+func (fs *timeoutStreamStore) SetTags(name string, tags map[string]string) error {
+	_, err := fs.do(context.Background(), "SetTags", name, func(ctx context.Context) (interface{}, error) {
+		return nil, setTags(fs.ss, name, tags)
+	})
+	return err
+}
+
+func (fs *timeoutStreamStore) GetTags(name string) (map[string]string, error) {
+	v, err := fs.do(context.Background(), "GetTags", name, func(ctx context.Context) (interface{}, error) {
+		tags, err := getTags(fs.ss, name)
+		return tags, err
+	})
+	tags, _ := v.(map[string]string)
+	return tags, err
+}
+
+func (fs *timeoutStreamStore) PresignGetURL(name string, expiry time.Duration) (string, error) {
+	v, err := fs.do(context.Background(), "PresignGetURL", name, func(ctx context.Context) (interface{}, error) {
+		return presignGetURL(fs.ss, name, expiry)
+	})
+	u, _ := v.(string)
+	return u, err
+}
+
+func (fs *timeoutStreamStore) PresignPutURL(name string, expiry time.Duration) (string, error) {
+	v, err := fs.do(context.Background(), "PresignPutURL", name, func(ctx context.Context) (interface{}, error) {
+		return presignPutURL(fs.ss, name, expiry)
+	})
+	u, _ := v.(string)
+	return u, err
+}
+
+// timeoutFile bounds each call on a file opened by a timeoutStreamStore.
+// Calls are made in another goroutine, with buffers of their own so that an
+// abandoned call can't touch those of the caller after it has returned.
+type timeoutFile struct {
+	d      time.Duration
+	name   string
+	c      io.Closer
+	cancel context.CancelFunc
+
+	lk sync.Mutex
+	// err is set once a call has timed out.
+	err error
+}
+
+func (t *timeoutFile) timedOut() error {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+	return t.err
+}
+
+// call runs f, failing with an error wrapping ErrTimeout if it takes longer
+// than t.d. The file is then closed, unless the call was Close itself.
+func (t *timeoutFile) call(op string, f func() (int64, error)) (int64, error) {
+	if err := t.timedOut(); err != nil {
+		return 0, err
+	}
+	type result struct {
+		n   int64
+		err error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		n, err := f()
+		ch <- result{n, err}
+	}()
+
+	timer := time.NewTimer(t.d)
+	defer timer.Stop()
+	select {
+	case res := <-ch:
+		return res.n, res.err
+	case <-timer.C:
+		t.lk.Lock()
+		if t.err == nil {
+			t.err = fmt.Errorf("%s %s: %w", op, t.name, ErrTimeout)
+		}
+		err := t.err
+		t.lk.Unlock()
+		t.cancel()
+		if op != "close" {
+			go t.c.Close()
+		}
+		return 0, err
+	}
+}
+
+func (t *timeoutFile) read(p []byte, read func([]byte) (int, error)) (int, error) {
+	buf := make([]byte, len(p))
+	n, err := t.call("read", func() (int64, error) {
+		n, err := read(buf)
+		return int64(n), err
+	})
+	copy(p, buf[:n])
+	return int(n), err
+}
+
+func (t *timeoutFile) readAt(p []byte, off int64, readAt func([]byte, int64) (int, error)) (int, error) {
+	buf := make([]byte, len(p))
+	n, err := t.call("read", func() (int64, error) {
+		n, err := readAt(buf, off)
+		return int64(n), err
+	})
+	copy(p, buf[:n])
+	return int(n), err
+}
+
+func (t *timeoutFile) write(p []byte, write func([]byte) (int, error)) (int, error) {
+	buf := append([]byte(nil), p...)
+	n, err := t.call("write", func() (int64, error) {
+		n, err := write(buf)
+		return int64(n), err
+	})
+	return int(n), err
+}
+
+func (t *timeoutFile) writeAt(p []byte, off int64, writeAt func([]byte, int64) (int, error)) (int, error) {
+	buf := append([]byte(nil), p...)
+	n, err := t.call("write", func() (int64, error) {
+		n, err := writeAt(buf, off)
+		return int64(n), err
+	})
+	return int(n), err
+}
+
+func (t *timeoutFile) seek(offset int64, whence int, seek func(int64, int) (int64, error)) (int64, error) {
+	return t.call("seek", func() (int64, error) {
+		return seek(offset, whence)
+	})
+}
+
+func (t *timeoutFile) Close() error {
+	if err := t.timedOut(); err != nil {
+		return err
+	}
+	_, err := t.call("close", func() (int64, error) {
+		return 0, t.c.Close()
+	})
+	t.cancel()
+	return err
+}
+
+type timeoutReader struct {
+	*timeoutFile
+	r StrawReader
+}
+
+func (r *timeoutReader) Read(p []byte) (int, error) {
+	return r.read(p, r.r.Read)
+}
+
+func (r *timeoutReader) ReadAt(p []byte, off int64) (int, error) {
+	return r.readAt(p, off, r.r.ReadAt)
+}
+
+func (r *timeoutReader) Seek(offset int64, whence int) (int64, error) {
+	return r.seek(offset, whence, r.r.Seek)
+}
+
+type timeoutWriter struct {
+	*timeoutFile
+	w StrawWriter
+}
+
+func (w *timeoutWriter) Write(p []byte) (int, error) {
+	return w.write(p, w.w.Write)
+}
+
+// timeoutWriterAt is a timeoutWriter for writers which implement
+// io.WriterAt.
+type timeoutWriterAt struct {
+	*timeoutWriter
+	wa io.WriterAt
+}
+
+func (w *timeoutWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	return w.writeAt(p, off, w.wa.WriteAt)
+}
+
+type timeoutReadWriter struct {
+	timeoutReader
+	f StrawReadWriteCloser
+}
+
+func (f *timeoutReadWriter) Write(p []byte) (int, error) {
+	return f.write(p, f.f.Write)
+}
+
+func (f *timeoutReadWriter) WriteAt(p []byte, off int64) (int, error) {
+	return f.writeAt(p, off, f.f.WriteAt)
+}
@@ -0,0 +1,181 @@
+package straw_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+// hangingStreamStore hangs in Stat until its context is done, or forever
+// when it has none, and returns readers which hang in Read until closed.
+type hangingStreamStore struct {
+	straw.StreamStore
+	statCtx chan context.Context
+}
+
+func (ss *hangingStreamStore) Stat(name string) (os.FileInfo, error) {
+	select {}
+}
+
+func (ss *hangingStreamStore) StatContext(ctx context.Context, name string) (os.FileInfo, error) {
+	ss.statCtx <- ctx
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (ss *hangingStreamStore) OpenReadCloser(name string) (straw.StrawReader, error) {
+	r, err := ss.StreamStore.OpenReadCloser(name)
+	if err != nil {
+		return nil, err
+	}
+	return &hangingReader{r, make(chan struct{})}, nil
+}
+
+type hangingReader struct {
+	straw.StrawReader
+	closed chan struct{}
+}
+
+func (r *hangingReader) Read(p []byte) (int, error) {
+	<-r.closed
+	return 0, os.ErrClosed
+}
+
+func (r *hangingReader) Close() error {
+	close(r.closed)
+	return r.StrawReader.Close()
+}
+
+// contextHangingStreamStore is a hangingStreamStore implementing
+// ContextStreamStore, whose Stat hangs until its context is done.
+type contextHangingStreamStore struct {
+	*hangingStreamStore
+}
+
+func (ss contextHangingStreamStore) Stat(name string) (os.FileInfo, error) {
+	return ss.StatContext(context.Background(), name)
+}
+
+func (ss contextHangingStreamStore) OpenReadCloserContext(ctx context.Context, name string) (straw.StrawReader, error) {
+	return ss.OpenReadCloser(name)
+}
+
+func (ss contextHangingStreamStore) CreateWriteCloserContext(ctx context.Context, name string) (straw.StrawWriter, error) {
+	return ss.CreateWriteCloser(name)
+}
+
+func (ss contextHangingStreamStore) LstatContext(ctx context.Context, name string) (os.FileInfo, error) {
+	return ss.Lstat(name)
+}
+
+func (ss contextHangingStreamStore) ReaddirContext(ctx context.Context, name string) ([]os.FileInfo, error) {
+	return ss.Readdir(name)
+}
+
+func (ss contextHangingStreamStore) MkdirContext(ctx context.Context, name string, mode os.FileMode) error {
+	return ss.Mkdir(name, mode)
+}
+
+func (ss contextHangingStreamStore) RemoveContext(ctx context.Context, name string) error {
+	return ss.Remove(name)
+}
+
+func (ss contextHangingStreamStore) CopyContext(ctx context.Context, src, dst string) error {
+	return ss.Copy(src, dst)
+}
+
+func (ss contextHangingStreamStore) ChmodContext(ctx context.Context, name string, mode os.FileMode) error {
+	return ss.Chmod(name, mode)
+}
+
+func TestTimeoutStore(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	mem, _ := straw.Open("mem://")
+	writeContent(t, mem, "/a", []byte("hello"))
+	ss := straw.NewTimeoutStore(mem, time.Second)
+
+	data, err := straw.ReadFile(ss, "/a")
+	require.NoError(err)
+	assert.Equal("hello", string(data))
+
+	require.NoError(straw.WriteFile(ss, "/b", []byte("world"), 0644))
+	data, err = straw.ReadFile(mem, "/b")
+	require.NoError(err)
+	assert.Equal("world", string(data))
+
+	f, err := ss.OpenFile("/b", os.O_RDWR, 0)
+	require.NoError(err)
+	_, err = f.WriteAt([]byte("W"), 0)
+	require.NoError(err)
+	require.NoError(f.Close())
+	data, err = straw.ReadFile(mem, "/b")
+	require.NoError(err)
+	assert.Equal("World", string(data))
+
+	_, err = ss.Stat("/c")
+	assert.True(os.IsNotExist(err))
+}
+
+func TestTimeoutStoreAbandonsOperations(t *testing.T) {
+	assert := assert.New(t)
+
+	mem, _ := straw.Open("mem://")
+	ss := straw.NewTimeoutStore(&hangingStreamStore{StreamStore: mem}, 10*time.Millisecond)
+
+	_, err := ss.Stat("/a")
+	assert.True(errors.Is(err, straw.ErrTimeout))
+}
+
+func TestTimeoutStoreCancelsContext(t *testing.T) {
+	assert := assert.New(t)
+
+	mem, _ := straw.Open("mem://")
+	hanging := &hangingStreamStore{StreamStore: mem, statCtx: make(chan context.Context, 1)}
+	ss := straw.NewTimeoutStore(contextHangingStreamStore{hanging}, 10*time.Millisecond)
+
+	_, err := ss.Stat("/a")
+	assert.True(errors.Is(err, straw.ErrTimeout))
+
+	// the store was given a context, which has been cancelled.
+	ctx := <-hanging.statCtx
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("context not done")
+	}
+
+	// a caller which gives up is told so, rather than of a timeout.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = straw.StatContext(ctx, ss, "/a")
+	assert.True(errors.Is(err, context.Canceled))
+	assert.False(errors.Is(err, straw.ErrTimeout))
+}
+
+func TestTimeoutStoreInterruptsReads(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	mem, _ := straw.Open("mem://")
+	writeContent(t, mem, "/a", []byte("hello"))
+	ss := straw.NewTimeoutStore(&hangingStreamStore{StreamStore: mem}, 10*time.Millisecond)
+
+	r, err := ss.OpenReadCloser("/a")
+	require.NoError(err)
+	_, err = ioutil.ReadAll(r)
+	assert.True(errors.Is(err, straw.ErrTimeout))
+
+	// the reader has been closed, so fails from now on.
+	_, err = r.Read(make([]byte, 1))
+	assert.True(errors.Is(err, straw.ErrTimeout))
+	assert.True(errors.Is(r.Close(), straw.ErrTimeout))
+}
@@ -0,0 +1,116 @@
+package straw
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// MismatchReason describes why a path differs between two trees.
+type MismatchReason string
+
+const (
+	// MissingFromA means the path only exists in the second tree.
+	MissingFromA MismatchReason = "missing from a"
+	// MissingFromB means the path only exists in the first tree.
+	MissingFromB MismatchReason = "missing from b"
+	// TypeDiffers means the path is a directory in one tree and a file in
+	// the other.
+	TypeDiffers MismatchReason = "type differs"
+	// SizeDiffers means the files have different sizes, so checksums were
+	// not compared.
+	SizeDiffers MismatchReason = "size differs"
+	// ChecksumDiffers means the files have different checksums.
+	ChecksumDiffers MismatchReason = "checksum differs"
+)
+
+// Mismatch is a difference found by VerifyTree.
+type Mismatch struct {
+	// Path is relative to the roots of the trees.
+	Path   string
+	Reason MismatchReason
+}
+
+// VerifyTree walks the trees rooted at aRoot in a and bRoot in b, comparing
+// every file by checksum using algo (see Checksum), and returns any
+// differences found in lexical order of path. An empty result means the trees
+// are identical.
+func VerifyTree(a, b StreamStore, aRoot, bRoot string, algo string) ([]Mismatch, error) {
+	if _, err := newHash(algo); err != nil {
+		return nil, err
+	}
+
+	aInfos, err := treeInfos(a, aRoot)
+	if err != nil {
+		return nil, err
+	}
+	bInfos, err := treeInfos(b, bRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for p := range aInfos {
+		paths = append(paths, p)
+	}
+	for p := range bInfos {
+		if _, ok := aInfos[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	var mismatches []Mismatch
+	for _, p := range paths {
+		aInfo, inA := aInfos[p]
+		bInfo, inB := bInfos[p]
+		switch {
+		case !inA:
+			mismatches = append(mismatches, Mismatch{p, MissingFromA})
+		case !inB:
+			mismatches = append(mismatches, Mismatch{p, MissingFromB})
+		case aInfo.IsDir() != bInfo.IsDir():
+			mismatches = append(mismatches, Mismatch{p, TypeDiffers})
+		case aInfo.IsDir():
+		case aInfo.Size() != bInfo.Size():
+			mismatches = append(mismatches, Mismatch{p, SizeDiffers})
+		default:
+			aSum, err := Checksum(a, filepath.Join(aRoot, p), algo)
+			if err != nil {
+				return nil, err
+			}
+			bSum, err := Checksum(b, filepath.Join(bRoot, p), algo)
+			if err != nil {
+				return nil, err
+			}
+			if !bytes.Equal(aSum, bSum) {
+				mismatches = append(mismatches, Mismatch{p, ChecksumDiffers})
+			}
+		}
+	}
+	return mismatches, nil
+}
+
+// treeInfos returns the FileInfo of everything below root, keyed by path
+// relative to root.
+func treeInfos(ss StreamStore, root string) (map[string]os.FileInfo, error) {
+	infos := make(map[string]os.FileInfo)
+	err := Walk(ss, root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if rel != "." {
+			infos[rel] = info
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return infos, nil
+}
@@ -0,0 +1,72 @@
+package straw_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+func newVerifyTree(t *testing.T, root string) straw.StreamStore {
+	require := require.New(t)
+
+	ss, _ := straw.Open("mem://")
+	require.NoError(straw.MkdirAll(ss, root+"/a/b", 0755))
+	writeContent(t, ss, root+"/a/1", []byte{1, 2, 3})
+	writeContent(t, ss, root+"/a/b/2", []byte{4, 5, 6})
+	writeContent(t, ss, root+"/3", []byte{7})
+	return ss
+}
+
+func writeContent(t *testing.T, ss straw.StreamStore, name string, data []byte) {
+	require := require.New(t)
+
+	w, err := ss.CreateWriteCloser(name)
+	require.NoError(err)
+	_, err = w.Write(data)
+	require.NoError(err)
+	require.NoError(w.Close())
+}
+
+func TestVerifyTreeIdentical(t *testing.T) {
+	assert := assert.New(t)
+
+	a := newVerifyTree(t, "/src")
+	b := newVerifyTree(t, "/dst")
+
+	mismatches, err := straw.VerifyTree(a, b, "/src", "/dst", "sha256")
+	assert.NoError(err)
+	assert.Empty(mismatches)
+}
+
+func TestVerifyTreeMismatches(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	a := newVerifyTree(t, "/src")
+	b := newVerifyTree(t, "/dst")
+
+	writeContent(t, b, "/dst/a/b/2", []byte{4, 5, 0})
+	writeContent(t, b, "/dst/3", []byte{7, 8})
+	require.NoError(b.Remove("/dst/a/1"))
+	writeContent(t, b, "/dst/4", []byte{9})
+
+	mismatches, err := straw.VerifyTree(a, b, "/src", "/dst", "md5")
+	assert.NoError(err)
+	assert.Equal([]straw.Mismatch{
+		{"3", straw.SizeDiffers},
+		{"4", straw.MissingFromA},
+		{"a/1", straw.MissingFromB},
+		{"a/b/2", straw.ChecksumDiffers},
+	}, mismatches)
+}
+
+func TestVerifyTreeUnknownAlgorithm(t *testing.T) {
+	assert := assert.New(t)
+
+	a := newVerifyTree(t, "/src")
+
+	_, err := straw.VerifyTree(a, a, "/src", "/src", "rot13")
+	assert.EqualError(err, `unsupported checksum algorithm "rot13"`)
+}
@@ -0,0 +1,29 @@
+package straw
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// WaitForExists polls ss.Stat every interval until name exists, smoothing
+// over eventual consistency and races between systems. If name still does not
+// exist once timeout has elapsed, an error wrapping ErrTimeout is returned.
+// Any error from Stat other than one satisfying os.IsNotExist is returned
+// immediately.
+func WaitForExists(ss StreamStore, name string, timeout, interval time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		_, err := ss.Stat(name)
+		if err == nil {
+			return nil
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+		if time.Now().Add(interval).After(deadline) {
+			return fmt.Errorf("waiting for %s to exist: %w", name, ErrTimeout)
+		}
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,56 @@
+package straw_test
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/uw-labs/straw"
+)
+
+// appearingStreamStore reports name as missing for the first misses calls to
+// Stat.
+type appearingStreamStore struct {
+	straw.StreamStore
+	lk     sync.Mutex
+	misses int
+	stats  int
+}
+
+func (ss *appearingStreamStore) Stat(name string) (os.FileInfo, error) {
+	ss.lk.Lock()
+	defer ss.lk.Unlock()
+	ss.stats++
+	if ss.stats <= ss.misses {
+		return nil, os.ErrNotExist
+	}
+	return ss.StreamStore.Stat(name)
+}
+
+func TestWaitForExists(t *testing.T) {
+	assert := assert.New(t)
+
+	mem, _ := straw.Open("mem://")
+	writeFile(mem, "/a")
+	ss := &appearingStreamStore{StreamStore: mem, misses: 2}
+
+	assert.NoError(straw.WaitForExists(ss, "/a", time.Second, time.Millisecond))
+	assert.Equal(3, ss.stats)
+}
+
+func TestWaitForExistsTimeout(t *testing.T) {
+	ss, _ := straw.Open("mem://")
+
+	err := straw.WaitForExists(ss, "/a", 5*time.Millisecond, time.Millisecond)
+	assert.True(t, errors.Is(err, straw.ErrTimeout))
+}
+
+func TestWaitForExistsError(t *testing.T) {
+	ss, _ := straw.Open("mem://")
+
+	err := straw.WaitForExists(ss, "../a", time.Second, time.Millisecond)
+	assert.True(t, errors.Is(err, straw.ErrInvalidPath))
+}
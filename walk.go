@@ -1,15 +1,15 @@
 package straw
 
 import (
-	"errors"
 	"os"
 	"path/filepath"
 )
 
 // SkipDir is used as a return value from WalkFuncs to indicate that
 // the directory named in the call is to be skipped. It is not returned
-// as an error by any function.
-var SkipDir = errors.New("skip this directory")
+// as an error by any function. It is the same value as filepath.SkipDir, so
+// either may be used.
+var SkipDir = filepath.SkipDir
 
 // WalkFunc is the type of the function called for each file or directory
 // visited by Walk. The path argument contains the argument to Walk as a
@@ -59,7 +59,8 @@ func walk(store StreamStore, path string, info os.FileInfo, walkFn WalkFunc) err
 // and directories are filtered by walkFn. The files are walked in lexical
 // order, which makes the output deterministic but means that for very
 // large directories Walk can be inefficient.
-// Walk does not follow symbolic links.
+// Walk does not follow symbolic links, other than root itself; see WalkLstat
+// and WalkFollow.
 // This is the straw equivalent of filepath.Walk in the standard library.
 func Walk(store StreamStore, root string, walkFn WalkFunc) error {
 	info, err := store.Stat(root)
@@ -73,3 +74,121 @@ func Walk(store StreamStore, root string, walkFn WalkFunc) error {
 	}
 	return err
 }
+
+// WalkLstat walks the file tree rooted at root as Walk does, but uses Lstat
+// rather than Stat for root, so that if root is a symbolic link, it is
+// reported to walkFn as a link rather than walked. No symbolic links are
+// followed, so the walk cannot loop.
+func WalkLstat(store StreamStore, root string, walkFn WalkFunc) error {
+	info, err := store.Lstat(root)
+	if err != nil {
+		err = walkFn(root, nil, err)
+	} else {
+		err = walk(store, root, info, walkFn)
+	}
+	if err == SkipDir {
+		return nil
+	}
+	return err
+}
+
+// WalkFollow walks the file tree rooted at root as Walk does, but follows
+// symbolic links, passing walkFn the os.FileInfo of each link's target and
+// walking the target if it is a directory. The path passed to walkFn is that
+// of the link, not its target. Links whose target does not exist are passed
+// to walkFn with the error from Stat.
+// A link to a directory which contains it would make the walk endless, so
+// instead of being walked, such a link is passed to walkFn with an error
+// wrapping ErrSymlinkLoop. Directories are recognised by os.SameFile on the
+// local filesystem, and elsewhere by their paths with the links that lead to
+// them resolved.
+func WalkFollow(store StreamStore, root string, walkFn WalkFunc) error {
+	info, err := store.Stat(root)
+	if err != nil {
+		err = walkFn(root, nil, err)
+	} else {
+		real := root
+		if li, err := store.Lstat(root); err == nil && li.Mode()&os.ModeSymlink != 0 {
+			if link, err := Readlink(store, root); err == nil {
+				real = resolveLink(filepath.Dir(root), link)
+			}
+		}
+		fw := &followWalker{store: store, walkFn: walkFn}
+		err = fw.walk(root, real, info)
+	}
+	if err == SkipDir {
+		return nil
+	}
+	return err
+}
+
+// followWalker walks a tree for WalkFollow.
+type followWalker struct {
+	store  StreamStore
+	walkFn WalkFunc
+	// ancestors holds the directories being walked, from the root down.
+	ancestors []walkedDir
+}
+
+type walkedDir struct {
+	// real is the path of the directory with the links leading to it
+	// resolved.
+	real string
+	info os.FileInfo
+}
+
+func (fw *followWalker) walk(path, real string, info os.FileInfo) error {
+	if !info.IsDir() {
+		return fw.walkFn(path, info, nil)
+	}
+	for _, dir := range fw.ancestors {
+		if dir.real == real || os.SameFile(dir.info, info) {
+			return fw.walkFn(path, nil, &os.PathError{Op: "walk", Path: path, Err: ErrSymlinkLoop})
+		}
+	}
+
+	fileInfos, err := fw.store.Readdir(path)
+	err1 := fw.walkFn(path, info, err)
+
+	if err != nil || err1 != nil {
+		return err1
+	}
+
+	fw.ancestors = append(fw.ancestors, walkedDir{real: real, info: info})
+	defer func() { fw.ancestors = fw.ancestors[:len(fw.ancestors)-1] }()
+
+	for _, fileInfo := range fileInfos {
+		filename := filepath.Join(path, fileInfo.Name())
+		childReal := filepath.Join(real, fileInfo.Name())
+		if fileInfo.Mode()&os.ModeSymlink != 0 {
+			target, err := fw.store.Stat(filename)
+			if err != nil {
+				if err := fw.walkFn(filename, nil, err); err != nil {
+					return err
+				}
+				continue
+			}
+			if link, err := Readlink(fw.store, filename); err == nil {
+				childReal = resolveLink(real, link)
+			}
+			fileInfo = target
+		}
+		err = fw.walk(filename, childReal, fileInfo)
+		if err != nil {
+			if !fileInfo.IsDir() || err != SkipDir {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// resolveLink returns the path a symbolic link in dir with destination link
+// refers to.
+func resolveLink(dir, link string) string {
+	if filepath.IsAbs(link) {
+		return filepath.Clean(link)
+	}
+	return filepath.Join(dir, link)
+}
@@ -2,10 +2,13 @@ package straw_test
 
 import (
 	"errors"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/uw-labs/straw"
 )
 
@@ -122,3 +125,163 @@ func writeFile(ss straw.StreamStore, name string) {
 	wc.Write([]byte{0})
 	wc.Close()
 }
+
+func TestWalkFilepathSkipDir(t *testing.T) {
+	assert := assert.New(t)
+
+	ss, _ := straw.Open("mem://")
+
+	ss.Mkdir("a", 0755)
+	writeFile(ss, "a/1")
+	writeFile(ss, "b")
+
+	var found []string
+	err := straw.Walk(ss, "/", func(name string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if name == "/a" {
+			return filepath.SkipDir
+		}
+		found = append(found, name)
+		return nil
+	})
+	assert.NoError(err)
+	assert.Equal([]string{"/", "/b"}, found)
+}
+
+// failingReaddirStreamStore fails Readdir for the directory named fail.
+type failingReaddirStreamStore struct {
+	straw.StreamStore
+	fail string
+	err  error
+}
+
+func (ss *failingReaddirStreamStore) Readdir(name string) ([]os.FileInfo, error) {
+	if name == ss.fail {
+		return nil, ss.err
+	}
+	return ss.StreamStore.Readdir(name)
+}
+
+func TestWalkReaddirError(t *testing.T) {
+	assert := assert.New(t)
+
+	mem, _ := straw.Open("mem://")
+	mem.Mkdir("a", 0755)
+	writeFile(mem, "a/1")
+	writeFile(mem, "b")
+
+	readdirErr := errors.New("readdir failed")
+	ss := &failingReaddirStreamStore{StreamStore: mem, fail: "/a", err: readdirErr}
+
+	var found []string
+	var errs []error
+	err := straw.Walk(ss, "/", func(name string, fi os.FileInfo, err error) error {
+		found = append(found, name)
+		errs = append(errs, err)
+		// carry on regardless.
+		return nil
+	})
+	assert.NoError(err)
+	assert.Equal([]string{"/", "/a", "/b"}, found)
+	assert.Equal([]error{nil, readdirErr, nil}, errs)
+}
+
+// symlinkTree creates a tree in a new directory containing a link to its
+// parent, making a loop, a link to a sibling directory and a dangling link.
+func symlinkTree(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "straw_walk_test_")
+	require.NoError(t, err)
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "a"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a", "1"), nil, 0644))
+	require.NoError(t, os.Symlink("..", filepath.Join(dir, "a", "up")))
+	require.NoError(t, os.Symlink("a", filepath.Join(dir, "b")))
+	require.NoError(t, os.Symlink("missing", filepath.Join(dir, "c")))
+	return dir
+}
+
+func TestWalkLstat(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := symlinkTree(t)
+	defer os.RemoveAll(dir)
+	link := dir + ".link"
+	require.NoError(os.Symlink(dir, link))
+	defer os.Remove(link)
+
+	ss, _ := straw.Open("file:///")
+
+	var found []string
+	var links []bool
+	err := straw.WalkLstat(ss, dir, func(name string, fi os.FileInfo, err error) error {
+		require.NoError(err)
+		rel, _ := filepath.Rel(dir, name)
+		found = append(found, rel)
+		links = append(links, fi.Mode()&os.ModeSymlink != 0)
+		return nil
+	})
+	assert.NoError(err)
+	assert.Equal([]string{".", "a", "a/1", "a/up", "b", "c"}, found)
+	assert.Equal([]bool{false, false, false, true, true, true}, links)
+
+	found = nil
+	err = straw.WalkLstat(ss, link, func(name string, fi os.FileInfo, err error) error {
+		found = append(found, name)
+		return err
+	})
+	assert.NoError(err)
+	assert.Equal([]string{link}, found)
+}
+
+func TestWalkFollow(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := symlinkTree(t)
+	defer os.RemoveAll(dir)
+
+	ss, _ := straw.Open("file:///")
+
+	var found []string
+	var loops, missing []string
+	err := straw.WalkFollow(ss, dir, func(name string, fi os.FileInfo, err error) error {
+		rel, _ := filepath.Rel(dir, name)
+		switch {
+		case errors.Is(err, straw.ErrSymlinkLoop):
+			loops = append(loops, rel)
+		case os.IsNotExist(err):
+			missing = append(missing, rel)
+		case err != nil:
+			return err
+		default:
+			found = append(found, rel)
+		}
+		return nil
+	})
+	assert.NoError(err)
+	assert.Equal([]string{".", "a", "a/1", "b", "b/1"}, found)
+	assert.Equal([]string{"a/up", "b/up"}, loops)
+	assert.Equal([]string{"c"}, missing)
+}
+
+func TestWalkFollowLoopError(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := symlinkTree(t)
+	defer os.RemoveAll(dir)
+
+	ss, _ := straw.Open("file:///")
+
+	var found []string
+	err := straw.WalkFollow(ss, dir, func(name string, fi os.FileInfo, err error) error {
+		rel, _ := filepath.Rel(dir, name)
+		found = append(found, rel)
+		if rel == "a" {
+			return straw.SkipDir
+		}
+		return err
+	})
+	assert.True(errors.Is(err, straw.ErrSymlinkLoop))
+	assert.Equal([]string{".", "a", "b", "b/1", "b/up"}, found)
+}
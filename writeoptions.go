@@ -0,0 +1,146 @@
+package straw
+
+import (
+	"mime"
+	"os"
+	"path"
+	"time"
+)
+
+// WriteOptions holds the settings applied by WriteOption values when creating
+// a file with CreateWriteCloser.
+type WriteOptions struct {
+	// Expires is when the written file should be considered stale, or the
+	// zero time if unset.
+	Expires time.Time
+	// ContentType is the MIME type of the written file. If empty, object
+	// stores infer it from the extension of the file name.
+	ContentType string
+	// Metadata holds user metadata to store with the file, such as the
+	// x-amz-meta- headers of s3 objects.
+	Metadata map[string]string
+	// ContentMD5 is the expected MD5 of the content, or nil if unset. Stores
+	// which can check it reject content which does not match.
+	ContentMD5 []byte
+	// IfNotExists, if true, makes the write fail unless no file exists at
+	// the name when it completes.
+	IfNotExists bool
+	// IfMatch, if not empty, makes the write fail unless the file at the
+	// name has this ETag, as reported by ObjectInfo, when it completes.
+	IfMatch string
+}
+
+// HasPreconditions reports whether o makes the write conditional on the
+// existing file, so that stores unable to check them must fail rather than
+// ignore them.
+func (o WriteOptions) HasPreconditions() bool {
+	return o.IfNotExists || o.IfMatch != ""
+}
+
+// WriteOption configures how CreateWriteCloser writes a file.
+type WriteOption func(*WriteOptions)
+
+// WithExpires records t as the time at which the written file should be
+// considered stale. On s3 this sets the Expires header of the object, and on
+// gcs it is held in the "expires" metadata entry. In both cases it is reported
+// by the ObjectInfo returned from the Sys method of the file's os.FileInfo.
+// It is a hint for caches and tooling only, and does not cause the file to be
+// deleted.
+func WithExpires(t time.Time) WriteOption {
+	return func(o *WriteOptions) {
+		o.Expires = t
+	}
+}
+
+// WithContentType sets the MIME type that the written file is served with by
+// object stores, overriding the type inferred from its extension.
+func WithContentType(contentType string) WriteOption {
+	return func(o *WriteOptions) {
+		o.ContentType = contentType
+	}
+}
+
+// WithMetadata stores metadata with the written file. On s3 each entry is
+// sent as an x-amz-meta- header, and on gcs it is held in the object's
+// metadata.
+func WithMetadata(metadata map[string]string) WriteOption {
+	return func(o *WriteOptions) {
+		o.Metadata = metadata
+	}
+}
+
+// WithContentMD5 passes the expected MD5 of the content to the store, so that
+// it can reject the content if it was corrupted on the way. On s3 it is sent
+// as the Content-MD5 header, which is checked for uploads made in a single
+// part, and on gcs it is checked once the whole object has been written. In
+// both cases Close then fails, leaving any existing object unchanged. Other
+// stores ignore it; see CreateSumWriter to check the content on any store.
+func WithContentMD5(sum []byte) WriteOption {
+	return func(o *WriteOptions) {
+		o.ContentMD5 = sum
+	}
+}
+
+// IfNotExists makes the write create the file only if it does not already
+// exist, as for a lock. On s3 this sends an If-None-Match: * header with the
+// PutObject or CompleteMultipartUpload request, and on gcs the object is
+// written with a DoesNotExist precondition, so the check is atomic. If the
+// file exists, Close fails with an error wrapping ErrPreconditionFailed,
+// leaving it unchanged.
+func IfNotExists() WriteOption {
+	return func(o *WriteOptions) {
+		o.IfNotExists = true
+	}
+}
+
+// IfMatch makes the write replace the file only if it has not changed since
+// it had the given ETag, as reported by ObjectInfo, with or without quotes.
+// On s3 this sends an If-Match header, and on gcs the ETag is checked when
+// the writer is created and the object then written with a precondition on
+// its generation, so that CreateWriteCloser may also fail. If the file has
+// changed or does not exist, the write fails with an error wrapping
+// ErrPreconditionFailed, leaving it unchanged.
+func IfMatch(etag string) WriteOption {
+	return func(o *WriteOptions) {
+		o.IfMatch = etag
+	}
+}
+
+// ContentTypeFor returns the content type of a file written with opts, which
+// is opts.ContentType if set, and otherwise the type registered for the
+// extension of name, if any.
+func ContentTypeFor(name string, opts WriteOptions) string {
+	if opts.ContentType != "" {
+		return opts.ContentType
+	}
+	return mime.TypeByExtension(path.Ext(name))
+}
+
+// OptionsWriter is implemented by stores that can honour WriteOptions when
+// creating files.
+type OptionsWriter interface {
+	CreateWriteCloserWithOptions(name string, opts WriteOptions) (StrawWriter, error)
+}
+
+// CreateWriteCloser creates the named file with the given options applied.
+// If ss does not implement OptionsWriter, the options are ignored and the
+// file is created with ss.CreateWriteCloser, unless they include IfNotExists
+// or IfMatch, which fail with ErrNotSupported rather than write regardless.
+func CreateWriteCloser(ss StreamStore, name string, opts ...WriteOption) (StrawWriter, error) {
+	var o WriteOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return createWriteCloser(ss, name, o)
+}
+
+func createWriteCloser(ss StreamStore, name string, o WriteOptions) (StrawWriter, error) {
+	ow, ok := ss.(OptionsWriter)
+	if !ok {
+		if o.HasPreconditions() {
+			return nil, &os.PathError{Op: "open", Path: name, Err: ErrNotSupported}
+		}
+		return ss.CreateWriteCloser(name)
+	}
+	return ow.CreateWriteCloserWithOptions(name, o)
+}
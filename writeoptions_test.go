@@ -0,0 +1,75 @@
+package straw_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+// optionsStreamStore records the options it was last asked to create a file
+// with, and the trees it is asked to remove.
+type optionsStreamStore struct {
+	straw.StreamStore
+	opts    straw.WriteOptions
+	removed []string
+}
+
+func (ss *optionsStreamStore) CreateWriteCloserWithOptions(name string, opts straw.WriteOptions) (straw.StrawWriter, error) {
+	ss.opts = opts
+	return ss.StreamStore.CreateWriteCloser(name)
+}
+
+func (ss *optionsStreamStore) RemoveAll(path string) error {
+	ss.removed = append(ss.removed, path)
+	return straw.RemoveAll(ss.StreamStore, path)
+}
+
+func TestCreateWriteCloserWithOptions(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	mem, _ := straw.Open("mem://")
+	ss := &optionsStreamStore{StreamStore: mem}
+
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	w, err := straw.CreateWriteCloser(ss, "/a", straw.WithExpires(expires))
+	require.NoError(err)
+	require.NoError(w.Close())
+	assert.Equal(expires, ss.opts.Expires)
+
+	// options are ignored by stores unable to honour them.
+	w, err = straw.CreateWriteCloser(mem, "/b", straw.WithExpires(expires))
+	require.NoError(err)
+	_, err = w.Write([]byte("b"))
+	require.NoError(err)
+	require.NoError(w.Close())
+
+	// but preconditions cannot be ignored.
+	_, err = straw.CreateWriteCloser(mem, "/c", straw.IfNotExists())
+	assert.True(errors.Is(err, straw.ErrNotSupported))
+	_, err = straw.CreateWriteCloser(mem, "/c", straw.IfMatch("abc"))
+	assert.True(errors.Is(err, straw.ErrNotSupported))
+
+	r, err := mem.OpenReadCloser("/b")
+	require.NoError(err)
+	data, err := ioutil.ReadAll(r)
+	require.NoError(err)
+	assert.Equal("b", string(data))
+}
+
+func TestContentTypeFor(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("text/html; charset=utf-8", straw.ContentTypeFor("/a/index.html", straw.WriteOptions{}))
+	assert.Equal("image/png", straw.ContentTypeFor("/a/b.png", straw.WriteOptions{}))
+	assert.Equal("", straw.ContentTypeFor("/a/b", straw.WriteOptions{}))
+
+	var o straw.WriteOptions
+	straw.WithContentType("application/x-custom")(&o)
+	assert.Equal("application/x-custom", straw.ContentTypeFor("/a/index.html", o))
+}
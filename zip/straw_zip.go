@@ -0,0 +1,223 @@
+// Package zip provides a read-only StreamStore over the entries of a zip
+// archive, registered under the "zip" scheme for archives on the local
+// filesystem, such as "zip:///data/archive.zip".
+//
+// Stat reports the uncompressed size of each entry, and directories are
+// synthesized from the paths of entries for archives without explicit
+// directory entries. Entries stored without compression are read directly
+// from the archive. Compressed entries can only be read from their start, so
+// ReadAt, and Read after a Seek other than forwards from the current
+// position, decompress the entry from its start up to the requested offset,
+// which makes random access to them as costly as reading everything before
+// it. Symbolic links within the archive are followed by Stat and
+// OpenReadCloser. Operations which would modify the archive return
+// straw.ErrNotSupported.
+package zip
+
+import (
+	archivezip "archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+
+	"github.com/uw-labs/straw"
+	"github.com/uw-labs/straw/internal/archive"
+)
+
+func init() {
+	straw.Register("zip", func(u *url.URL) (straw.StreamStore, error) {
+		if u.Host != "" {
+			return nil, fmt.Errorf("zip URLs must not provide a host, got %q", u.Host)
+		}
+		f, err := os.Open(u.Path)
+		if err != nil {
+			return nil, err
+		}
+		fi, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		ss, err := newStore(f, fi.Size(), f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return ss, nil
+	})
+}
+
+// New returns a read-only StreamStore over the zip archive of size bytes
+// read from r, which must remain readable until the store is closed.
+// Closing the store does not close r.
+func New(r io.ReaderAt, size int64) (straw.StreamStore, error) {
+	return newStore(r, size, nil)
+}
+
+func newStore(r io.ReaderAt, size int64, closer io.Closer) (*archive.Store, error) {
+	zr, err := archivezip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	s := archive.New("zip", closer, func(data interface{}) (straw.StrawReader, error) {
+		f := data.(*archivezip.File)
+		if f.Method == archivezip.Store {
+			off, err := f.DataOffset()
+			if err != nil {
+				return nil, err
+			}
+			return &storedReader{io.NewSectionReader(r, off, int64(f.UncompressedSize64))}, nil
+		}
+		return &entryReader{f: f, size: int64(f.UncompressedSize64)}, nil
+	})
+	for _, f := range zr.File {
+		m := archive.Member{Name: f.Name, Info: f.FileInfo()}
+		switch mode := m.Info.Mode(); {
+		case mode.IsDir():
+		case mode&os.ModeSymlink != 0:
+			link, err := readLink(f)
+			if err != nil {
+				return nil, err
+			}
+			m.Link = link
+		case mode.IsRegular():
+			m.Data = f
+		default:
+			continue
+		}
+		if err := s.Add(m); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// readLink returns the destination of a symbolic link, which zip stores as
+// the content of the entry.
+func readLink(f *archivezip.File) (string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	link, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return "", err
+	}
+	return string(link), nil
+}
+
+// storedReader reads an entry stored without compression.
+type storedReader struct {
+	*io.SectionReader
+}
+
+func (r *storedReader) Close() error {
+	return nil
+}
+
+var errNegativeOffset = errors.New("negative offset")
+
+// entryReader reads a compressed entry, decompressing it from its start
+// whenever it needs to move backwards.
+type entryReader struct {
+	f    *archivezip.File
+	size int64
+	// rc is the stream of the decompressed entry, positioned at rcPos, or
+	// nil if it has not been opened.
+	rc    io.ReadCloser
+	rcPos int64
+	// pos is the offset of the next Read.
+	pos int64
+}
+
+// stream returns the decompressed entry positioned at off, reusing the open
+// stream if it hasn't gone past off.
+func (r *entryReader) stream(off int64) (io.Reader, error) {
+	if r.rc == nil || r.rcPos > off {
+		if r.rc != nil {
+			r.rc.Close()
+			r.rc = nil
+		}
+		rc, err := r.f.Open()
+		if err != nil {
+			return nil, err
+		}
+		r.rc, r.rcPos = rc, 0
+	}
+	if r.rcPos < off {
+		n, err := io.CopyN(ioutil.Discard, r.rc, off-r.rcPos)
+		r.rcPos += n
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r.rc, nil
+}
+
+func (r *entryReader) Read(p []byte) (int, error) {
+	if r.pos >= r.size {
+		return 0, io.EOF
+	}
+	rc, err := r.stream(r.pos)
+	if err != nil {
+		return 0, err
+	}
+	n, err := rc.Read(p)
+	r.rcPos += int64(n)
+	r.pos += int64(n)
+	return n, err
+}
+
+// ReadAt decompresses the entry from its start up to off on every call, so
+// it does not disturb the position of Read.
+func (r *entryReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, &os.PathError{Op: "readat", Path: r.f.Name, Err: errNegativeOffset}
+	}
+	if off >= r.size {
+		return 0, io.EOF
+	}
+	rc, err := r.f.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+	if _, err := io.CopyN(ioutil.Discard, rc, off); err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(rc, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (r *entryReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.pos
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return r.pos, &os.PathError{Op: "seek", Path: r.f.Name, Err: fmt.Errorf("invalid whence %d", whence)}
+	}
+	if offset < 0 {
+		return r.pos, &os.PathError{Op: "seek", Path: r.f.Name, Err: errNegativeOffset}
+	}
+	r.pos = offset
+	return offset, nil
+}
+
+func (r *entryReader) Close() error {
+	if r.rc == nil {
+		return nil
+	}
+	err := r.rc.Close()
+	r.rc = nil
+	return err
+}
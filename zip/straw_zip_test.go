@@ -0,0 +1,180 @@
+package zip
+
+import (
+	archivezip "archive/zip"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uw-labs/straw"
+)
+
+const testContent = "the quick brown fox jumps over the lazy dog"
+
+// testArchive returns a zip archive holding the same content both
+// compressed and stored, in directories which have no entries of their own,
+// with an explicit directory and a symbolic link.
+func testArchive(t *testing.T) []byte {
+	require := require.New(t)
+
+	var buf bytes.Buffer
+	zw := archivezip.NewWriter(&buf)
+	for _, hdr := range []*archivezip.FileHeader{
+		{Name: "a/b/deflated.txt", Method: archivezip.Deflate},
+		{Name: "a/stored.txt", Method: archivezip.Store},
+	} {
+		w, err := zw.CreateHeader(hdr)
+		require.NoError(err)
+		_, err = w.Write([]byte(testContent))
+		require.NoError(err)
+	}
+	_, err := zw.Create("empty/")
+	require.NoError(err)
+	link := &archivezip.FileHeader{Name: "link"}
+	link.SetMode(os.ModeSymlink | 0777)
+	w, err := zw.CreateHeader(link)
+	require.NoError(err)
+	_, err = w.Write([]byte("a/b/deflated.txt"))
+	require.NoError(err)
+	require.NoError(zw.Close())
+	return buf.Bytes()
+}
+
+func openTestArchive(t *testing.T) straw.StreamStore {
+	data := testArchive(t)
+	ss, err := New(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	return ss
+}
+
+func TestStatAndReaddir(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ss := openTestArchive(t)
+	defer ss.Close()
+	assert.Equal("zip", straw.Scheme(ss))
+
+	fis, err := ss.Readdir("/")
+	require.NoError(err)
+	var names []string
+	for _, fi := range fis {
+		names = append(names, fi.Name())
+	}
+	assert.Equal([]string{"a", "empty", "link"}, names)
+
+	fis, err = ss.Readdir("/a")
+	require.NoError(err)
+	require.Len(fis, 2)
+	assert.Equal("b", fis[0].Name())
+	assert.True(fis[0].IsDir())
+	assert.Equal("stored.txt", fis[1].Name())
+
+	fis, err = ss.Readdir("/empty")
+	require.NoError(err)
+	assert.Empty(fis)
+
+	fi, err := ss.Stat("/a/b/deflated.txt")
+	require.NoError(err)
+	assert.Equal("deflated.txt", fi.Name())
+	assert.Equal(int64(len(testContent)), fi.Size())
+
+	fi, err = ss.Lstat("/link")
+	require.NoError(err)
+	assert.True(fi.Mode()&os.ModeSymlink != 0)
+	fi, err = ss.Stat("/link")
+	require.NoError(err)
+	assert.Equal(int64(len(testContent)), fi.Size())
+
+	_, err = ss.Stat("/a/missing")
+	assert.True(os.IsNotExist(err))
+}
+
+func TestRead(t *testing.T) {
+	ss := openTestArchive(t)
+	defer ss.Close()
+
+	for _, name := range []string{"/a/b/deflated.txt", "/a/stored.txt", "/link"} {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			r, err := ss.OpenReadCloser(name)
+			require.NoError(err)
+			defer r.Close()
+
+			buf := make([]byte, 5)
+			n, err := r.ReadAt(buf, 4)
+			require.NoError(err)
+			assert.Equal("quick", string(buf[:n]))
+
+			content, err := ioutil.ReadAll(r)
+			require.NoError(err)
+			assert.Equal(testContent, string(content))
+
+			pos, err := r.Seek(-8, io.SeekEnd)
+			require.NoError(err)
+			assert.Equal(int64(len(testContent)-8), pos)
+			content, err = ioutil.ReadAll(r)
+			require.NoError(err)
+			assert.Equal("lazy dog", string(content))
+
+			_, err = r.Seek(16, io.SeekStart)
+			require.NoError(err)
+			_, err = r.Seek(4, io.SeekCurrent)
+			require.NoError(err)
+			n, err = io.ReadFull(r, buf)
+			require.NoError(err)
+			assert.Equal("jumps", string(buf[:n]))
+
+			n, err = r.ReadAt(buf, int64(len(testContent)-3))
+			assert.Equal(io.EOF, err)
+			assert.Equal("dog", string(buf[:n]))
+
+			_, err = r.Seek(-1, io.SeekStart)
+			assert.Error(err)
+		})
+	}
+}
+
+func TestUnsupported(t *testing.T) {
+	assert := assert.New(t)
+
+	ss := openTestArchive(t)
+	defer ss.Close()
+
+	_, err := ss.CreateWriteCloser("/new")
+	assert.Equal(straw.ErrNotSupported, err)
+	_, err = ss.OpenFile("/a/stored.txt", os.O_WRONLY, 0)
+	assert.Equal(straw.ErrNotSupported, err)
+	assert.Equal(straw.ErrNotSupported, ss.Mkdir("/dir", 0755))
+	assert.Equal(straw.ErrNotSupported, ss.Remove("/a/stored.txt"))
+}
+
+func TestOpenURL(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "straw-zip")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "test.zip")
+	require.NoError(ioutil.WriteFile(name, testArchive(t), 0644))
+
+	ss, err := straw.Open("zip://" + name)
+	require.NoError(err)
+	assert.Equal("zip", straw.Scheme(ss))
+	content, err := straw.ReadFile(ss, "/a/b/deflated.txt")
+	require.NoError(err)
+	assert.Equal(testContent, string(content))
+	require.NoError(ss.Close())
+
+	require.NoError(ioutil.WriteFile(name, []byte("not a zip"), 0644))
+	_, err = straw.Open("zip://" + name)
+	assert.Error(err)
+}